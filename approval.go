@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// defaultApprovalSecretEnv is the env var holding the secret approval
+// requests are signed with, unless the config names another.
+const defaultApprovalSecretEnv = "CIRCLECI_APPROVAL_SECRET"
+
+// approvalMaxSkew is how far the timestamp of a signed approval response may
+// be from now, so that old responses cannot be replayed.
+const approvalMaxSkew = 5 * time.Minute
+
+// approvalTimeout is how long the approval webhook has to reply.
+const approvalTimeout = time.Minute
+
+// approvalClient sends approval requests.
+var approvalClient = &http.Client{Timeout: approvalTimeout}
+
+// Headers of signed approval requests and responses. The signature is the
+// hex HMAC-SHA256 keyed by the shared secret of "<timestamp>.<body>" for
+// requests, and of "<timestamp>.<nonce>.<body>" for responses, where nonce
+// is the request's, so that a response only approves the request it
+// answers.
+const (
+	approvalTimestampHeader = "X-Approval-Timestamp"
+	approvalSignatureHeader = "X-Approval-Signature"
+)
+
+// ApprovalConfig points at a change-management webhook that must approve
+// destructive operations before they are made.
+type ApprovalConfig struct {
+	URL       string `yaml:"url"`       // Webhook asked for approval, no approval is needed if empty
+	SecretEnv string `yaml:"secretEnv"` // Env var holding the signing secret
+	Ticket    string `yaml:"ticket"`    // Change ticket sent with the request, e.g. CHG-1234
+}
+
+// merge returns the global approval config overridden by the fields the
+// project's config sets.
+func (c ApprovalConfig) merge(project ApprovalConfig) ApprovalConfig {
+	if project.URL != "" {
+		c.URL = project.URL
+	}
+	if project.SecretEnv != "" {
+		c.SecretEnv = project.SecretEnv
+	}
+	if project.Ticket != "" {
+		c.Ticket = project.Ticket
+	}
+	return c
+}
+
+// approvalRequest is the body sent to the approval webhook.
+type approvalRequest struct {
+	Project   string   `json:"project"`
+	Operation string   `json:"operation"` // e.g. canonical, unfollow or purge
+	Changes   []string `json:"changes,omitempty"`
+	Ticket    string   `json:"ticket,omitempty"`
+	Nonce     string   `json:"nonce"` // Random, signed into the response
+}
+
+// approvalResponse is the body the approval webhook replies with.
+type approvalResponse struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason"`
+}
+
+// signApproval returns the signature of the body sent at timestamp.
+func signApproval(secret, timestamp string, body []byte) string {
+	return hex.EncodeToString(hmacSHA256([]byte(secret), timestamp+"."+string(body)))
+}
+
+// approvalResponsePayload returns what the signature of the response to the
+// request with the nonce covers besides its timestamp.
+func approvalResponsePayload(nonce string, body []byte) []byte {
+	return append([]byte(nonce+"."), body...)
+}
+
+// approver returns a function asking the approval webhook of config to let
+// the operation go ahead with its changes, or nil if there is no webhook.
+func approver(ctx context.Context, config ApprovalConfig, operation string) confirmFunc {
+	if config.URL == "" {
+		return nil
+	}
+	return func(subject string, changes []string) error {
+		return requestApproval(ctx, config, os.Getenv, subject, operation, changes)
+	}
+}
+
+// requestApproval asks the approval webhook whether the operation may be
+// made on the project, returning an error unless it is approved. Nothing is
+// asked if no webhook is configured.
+func requestApproval(ctx context.Context, config ApprovalConfig, getenv func(string) string, project, operation string, changes []string) error {
+	if config.URL == "" {
+		return nil
+	}
+	secretEnv := config.SecretEnv
+	if secretEnv == "" {
+		secretEnv = defaultApprovalSecretEnv
+	}
+	secret := getenv(secretEnv)
+	if secret == "" {
+		return fmt.Errorf("approval requests need %s to be set", secretEnv)
+	}
+
+	nonce, err := newRunID()
+	if err != nil {
+		return fmt.Errorf("could not generate an approval nonce: %v", err)
+	}
+	body, err := json.Marshal(approvalRequest{project, operation, changes, config.Ticket, nonce})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid approval URL: %v", err)
+	}
+	req = req.WithContext(ctx)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(approvalTimestampHeader, timestamp)
+	req.Header.Set(approvalSignatureHeader, signApproval(secret, timestamp, body))
+
+	logInfof("Requesting approval to %s project %s from %s", operation, project, config.URL)
+	resp, err := approvalClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not request approval: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read approval response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not request approval: status %s", resp.Status)
+	}
+	err = verifyApproval(secret, nonce, resp.Header, respBody, time.Now())
+	if err != nil {
+		return fmt.Errorf("invalid approval response: %v", err)
+	}
+	var approval approvalResponse
+	err = json.Unmarshal(respBody, &approval)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal approval response: %v", err)
+	}
+	if !approval.Approved {
+		return fmt.Errorf("%s of project %s was not approved: %s", operation, project, approval.Reason)
+	}
+	logInfof("Approved to %s project %s", operation, project)
+	return nil
+}
+
+// canonicalChanges returns the removals making the project canonical would
+// make, for approvers to review.
+func canonicalChanges(ctx context.Context, project circleci.Project, config Config, opts provisionOptions) ([]string, error) {
+	state, err := fetchPlanState(ctx, project, config, opts)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	for _, action := range computePlan(config, state, opts) {
+		if action.Op == opRemove || action.Op == opQuarantine {
+			changes = append(changes, fmt.Sprintf("%s %s %s", action.Op, action.Resource, action.Name))
+		}
+	}
+	return changes, nil
+}
+
+// verifyApproval checks the response to the request with the nonce was
+// signed with the secret recently.
+func verifyApproval(secret, nonce string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get(approvalTimestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing or invalid %s", approvalTimestampHeader)
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > approvalMaxSkew || skew < -approvalMaxSkew {
+		return fmt.Errorf("signed %v from now, expected within %v", skew, approvalMaxSkew)
+	}
+	expected := signApproval(secret, timestamp, approvalResponsePayload(nonce, body))
+	if !hmac.Equal([]byte(header.Get(approvalSignatureHeader)), []byte(expected)) {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
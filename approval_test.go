@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+// fakeApprover is an approval webhook replying with body, signed with secret
+// for the request's nonce, or for nonce if set. It checks the requests it
+// receives are signed with "secret".
+func fakeApprover(t *testing.T, secret, nonce, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		timestamp := r.Header.Get(approvalTimestampHeader)
+		if r.Header.Get(approvalSignatureHeader) != signApproval("secret", timestamp, reqBody) {
+			t.Errorf("Unexpected signature of request %s", reqBody)
+		}
+		var req approvalRequest
+		if err := json.Unmarshal(reqBody, &req); err != nil || req.Operation != "canonical" || req.Ticket != "CHG-1" ||
+			req.Nonce == "" {
+			t.Errorf("Unexpected request %s", reqBody)
+		}
+		if nonce == "" {
+			nonce = req.Nonce
+		}
+
+		now := strconv.FormatInt(time.Now().Unix(), 10)
+		w.Header().Set(approvalTimestampHeader, now)
+		w.Header().Set(approvalSignatureHeader, signApproval(secret, now, approvalResponsePayload(nonce, []byte(body))))
+		io.WriteString(w, body)
+	}))
+}
+
+func TestRequestApproval(t *testing.T) {
+	type test struct {
+		name   string
+		secret string // Secret the response is signed with
+		nonce  string // Nonce the response is signed for, the request's if empty
+		body   string
+		fails  bool
+	}
+
+	testCases := []test{
+		{"approved", "secret", "", `{"approved": true}`, false},
+		{"denied", "secret", "", `{"approved": false, "reason": "no change ticket"}`, true},
+		{"forged", "other", "", `{"approved": true}`, true},
+		{"replayed", "secret", "another-request", `{"approved": true}`, true},
+	}
+
+	getenv := func(name string) string {
+		if name == defaultApprovalSecretEnv {
+			return "secret"
+		}
+		return ""
+	}
+	for _, tc := range testCases {
+		svr := fakeApprover(t, tc.secret, tc.nonce, tc.body)
+		config := ApprovalConfig{URL: svr.URL, Ticket: "CHG-1"}
+		err := requestApproval(context.Background(), config, getenv, "gh/test/test", "canonical", []string{"remove envvar A"})
+		if tc.fails && err == nil {
+			t.Errorf("Expected an error for %s", tc.name)
+		} else if !tc.fails && err != nil {
+			t.Errorf("Expected no error for %s, found: %v", tc.name, err)
+		}
+		svr.Close()
+	}
+}
+
+func TestRequestApprovalNeedsSecret(t *testing.T) {
+	config := ApprovalConfig{URL: "http://approvals.invalid", SecretEnv: "APPROVAL_SECRET"}
+	err := requestApproval(context.Background(), config, func(string) string { return "" }, "gh/test/test", "unfollow", nil)
+	if err == nil {
+		t.Error("Expected an error without a secret")
+	}
+	err = requestApproval(context.Background(), ApprovalConfig{}, func(string) string { return "" }, "gh/test/test", "unfollow", nil)
+	if err != nil {
+		t.Errorf("Expected nothing to be asked without a webhook, found: %v", err)
+	}
+}
+
+func TestVerifyApprovalRejectsOldResponses(t *testing.T) {
+	body := []byte(`{"approved": true}`)
+	then := time.Now().Add(-time.Hour)
+	timestamp := strconv.FormatInt(then.Unix(), 10)
+	header := http.Header{}
+	header.Set(approvalTimestampHeader, timestamp)
+	header.Set(approvalSignatureHeader, signApproval("secret", timestamp, approvalResponsePayload("nonce", body)))
+
+	if err := verifyApproval("secret", "nonce", header, body, then); err != nil {
+		t.Errorf("Expected a fresh response to verify, found: %v", err)
+	}
+	if err := verifyApproval("secret", "other", header, body, then); err == nil {
+		t.Error("Expected a response to another request to be rejected")
+	}
+	if err := verifyApproval("secret", "nonce", header, body, time.Now()); err == nil {
+		t.Error("Expected an old response to be rejected")
+	}
+}
+
+func TestApprovalConfigMerge(t *testing.T) {
+	global := ApprovalConfig{URL: "https://global", SecretEnv: "GLOBAL_SECRET", Ticket: "CHG-1"}
+	merged := global.merge(ApprovalConfig{URL: "https://project", Ticket: "CHG-2"})
+	expected := ApprovalConfig{URL: "https://project", SecretEnv: "GLOBAL_SECRET", Ticket: "CHG-2"}
+	if merged != expected {
+		t.Errorf("Expected %+v, found %+v", expected, merged)
+	}
+}
+
+func TestProvisionRequestsApprovalOfCheckoutKeyRemovals(t *testing.T) {
+	os.Setenv(defaultApprovalSecretEnv, "secret")
+	defer os.Unsetenv(defaultApprovalSecretEnv)
+	var changes []string
+	approvals := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req approvalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Could not decode approval request: %v", err)
+		}
+		changes = req.Changes
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer approvals.Close()
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"POST /project/git/test/test/follow": {Status: http.StatusCreated, Body: `{"following": true}`},
+		"GET /project/git/test/test/envvar":  {Status: http.StatusOK, Body: `[]`},
+		"GET " + circlecitest.SettingsPath:   {Status: http.StatusOK, Body: `{"ssh_keys": []}`},
+		"GET /project/git/test/test/checkout-key": {Status: http.StatusOK,
+			Body: `[{"type": "deploy-key", "fingerprint": "aa"}, {"type": "github-user-key", "fingerprint": "bb"}]`},
+		"DELETE /project/git/test/test/checkout-key/bb": {Status: http.StatusOK, Body: `{"message": "ok"}`},
+	})
+	defer svr.Close()
+
+	config := Config{CheckoutKeys: []string{"deploy-key"}}
+	err := provision(context.Background(), svr.project(), config,
+		provisionOptions{canonical: true, approval: ApprovalConfig{URL: approvals.URL}})
+	if err == nil {
+		t.Error("Expected an error without approval")
+	}
+	if expected := []string{"remove checkout-key user-key (bb)"}; !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected approval of %v to be requested, found %v", expected, changes)
+	}
+	for _, req := range svr.Requests() {
+		if strings.HasPrefix(req, "DELETE ") {
+			t.Errorf("Expected nothing to be removed without approval, found %s", req)
+		}
+	}
+}
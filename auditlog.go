@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// AuditEntry records a run that may change CircleCI. Each entry's hash covers
+// the previous entry's, so that editing or removing an entry breaks the chain.
+// Hashes are keyed with the audit key, which is kept outside the log so that
+// whoever can write the log cannot rebuild the chain.
+type AuditEntry struct {
+	Time        time.Time `json:"time"`
+	RunID       string    `json:"runId"`
+	Caller      string    `json:"caller"`
+	Command     []string  `json:"command"`     // Secret flag values redacted
+	RequestHash string    `json:"requestHash"` // HMAC-SHA256 of the command and config with the audit key
+	PrevHash    string    `json:"prevHash"`
+	Hash        string    `json:"hash"`
+}
+
+// secretFlags are the flags whose values are never written to the audit log.
+var secretFlags = map[string]bool{
+	"token":         true,
+	"org-token":     true,
+	"github-token":  true,
+	"token-command": true,
+}
+
+// redactedValue replaces the values of secret flags in the audit log.
+const redactedValue = "REDACTED"
+
+// redactArgs returns args with the values of secret flags redacted, whether
+// given as -flag=value or -flag value.
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for i := 0; i < len(redacted); i++ {
+		arg := redacted[i]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if eq := strings.Index(name, "="); eq >= 0 {
+			if secretFlags[name[:eq]] {
+				redacted[i] = arg[:len(arg)-len(name)+eq+1] + redactedValue
+			}
+			continue
+		}
+		if secretFlags[name] && i+1 < len(redacted) {
+			i++
+			redacted[i] = redactedValue
+		}
+	}
+	return redacted
+}
+
+// auditKey returns the key of the audit log's hashes.
+func auditKey() ([]byte, error) {
+	key := os.Getenv("CIRCLECI_AUDIT_KEY")
+	if key == "" {
+		return nil, fmt.Errorf("CIRCLECI_AUDIT_KEY must be set to the secret keying the audit log's hashes")
+	}
+	return []byte(key), nil
+}
+
+// newRunID returns a random ID for a run.
+func newRunID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// auditCaller identifies who is running the tool: CIRCLECI_AUDIT_CALLER if
+// set, otherwise user@host.
+func auditCaller() string {
+	if caller := os.Getenv("CIRCLECI_AUDIT_CALLER"); caller != "" {
+		return caller
+	}
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, _ := os.Hostname()
+	return name + "@" + host
+}
+
+// requestHash hashes the command and config a run was given, keyed with key.
+// The command should already be redacted.
+func requestHash(command []string, config []byte, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	for _, arg := range command {
+		fmt.Fprintf(mac, "%d:%s", len(arg), arg)
+	}
+	mac.Write(config)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// entryHash returns the hash chaining the entry to the previous one, keyed
+// with key.
+func entryHash(entry AuditEntry, key []byte) string {
+	entry.Hash = ""
+	data, _ := json.Marshal(entry)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(entry.PrevHash))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// readAuditLog reads the entries of an audit log, returning none if it does
+// not exist.
+func readAuditLog(path string) ([]AuditEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// lastAuditHash returns the hash of the last entry of the audit log, or an
+// empty one if it has none. Only the end of the log is read.
+func lastAuditHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := info.Size()
+	for chunk := int64(4096); ; chunk *= 2 {
+		if chunk > size {
+			chunk = size
+		}
+		buf := make([]byte, chunk)
+		if _, err := f.ReadAt(buf, size-chunk); err != nil {
+			return "", err
+		}
+		buf = bytes.TrimRight(buf, "\n")
+		start := bytes.LastIndexByte(buf, '\n')
+		if start < 0 && chunk < size {
+			continue
+		}
+		if len(buf) == 0 {
+			return "", nil
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal(buf[start+1:], &entry); err != nil {
+			return "", fmt.Errorf("last entry: %v", err)
+		}
+		return entry.Hash, nil
+	}
+}
+
+// appendAuditEntry chains the entry onto the audit log and appends it.
+func appendAuditEntry(path string, entry AuditEntry, key []byte) error {
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	entry.PrevHash, err = lastAuditHash(path)
+	if err != nil {
+		return fmt.Errorf("could not read audit log %s: %v", path, err)
+	}
+	entry.Hash = entryHash(entry, key)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open audit log %s: %v", path, err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// verifyAuditLog checks that no entry of the audit log has been changed,
+// removed or reordered.
+func verifyAuditLog(entries []AuditEntry, key []byte) error {
+	prev := ""
+	for i, entry := range entries {
+		if entry.PrevHash != prev {
+			return fmt.Errorf("entry %d (run %s) does not follow the entry before it", i+1, entry.RunID)
+		}
+		if !hmac.Equal([]byte(entryHash(entry, key)), []byte(entry.Hash)) {
+			return fmt.Errorf("entry %d (run %s) has been modified", i+1, entry.RunID)
+		}
+		prev = entry.Hash
+	}
+	return nil
+}
+
+// audit records the run in the -audit-log, if one is set.
+func (f *commonFlags) audit(runID string) error {
+	if *f.auditLog == "" {
+		return nil
+	}
+	var config []byte
+	if *f.configFile != "" {
+		files, err := f.configFiles()
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("could not read config file %s: %v", file, err)
+			}
+			config = append(config, data...)
+		}
+	}
+	key, err := auditKey()
+	if err != nil {
+		return usageError(err)
+	}
+	command := redactArgs(os.Args[1:])
+	entry := AuditEntry{
+		Time:        time.Now().UTC(),
+		RunID:       runID,
+		Caller:      auditCaller(),
+		Command:     command,
+		RequestHash: requestHash(command, config, key),
+	}
+	if err := appendAuditEntry(*f.auditLog, entry, key); err != nil {
+		return fmt.Errorf("could not record run in audit log: %v", err)
+	}
+	return nil
+}
+
+func runAuditLog(args []string) error {
+	_, args, err := subcommand("audit-log", args, "verify")
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("audit-log verify", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s audit-log verify FILE\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("audit-log verify takes one file")
+	}
+
+	key, err := auditKey()
+	if err != nil {
+		return usageError(err)
+	}
+	entries, err := readAuditLog(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("could not read audit log %s: %v", fs.Arg(0), err)
+	}
+	if err := verifyAuditLog(entries, key); err != nil {
+		return fmt.Errorf("audit log %s has been tampered with: %v", fs.Arg(0), err)
+	}
+	fmt.Printf("%d entries verified\n", len(entries))
+	return nil
+}
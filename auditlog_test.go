@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	key := []byte("key")
+
+	for _, runID := range []string{"run1", "run2", "run3"} {
+		entry := AuditEntry{RunID: runID, Caller: "ci@host", Command: []string{"provision"},
+			RequestHash: requestHash([]string{"provision"}, []byte("owner: test"), key)}
+		if err := appendAuditEntry(path, entry, key); err != nil {
+			t.Fatalf("Expected no error, found: %v", err)
+		}
+	}
+	entries, err := readAuditLog(path)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if len(entries) != 3 || entries[1].PrevHash != entries[0].Hash {
+		t.Fatalf("Expected 3 chained entries, found %+v", entries)
+	}
+	if err := verifyAuditLog(entries, key); err != nil {
+		t.Errorf("Expected the log to verify, found: %v", err)
+	}
+	if err := verifyAuditLog(entries, []byte("other")); err == nil {
+		t.Error("Expected the log not to verify with another key")
+	}
+
+	tampered := append([]AuditEntry(nil), entries...)
+	tampered[1].Caller = "someone@else"
+	if err := verifyAuditLog(tampered, key); err == nil {
+		t.Error("Expected a modified entry to fail verification")
+	}
+	if err := verifyAuditLog(append(entries[:1:1], entries[2]), key); err == nil {
+		t.Error("Expected a removed entry to fail verification")
+	}
+}
+
+func TestRequestHash(t *testing.T) {
+	signed := requestHash([]string{"provision", "-canonical"}, []byte("config"), []byte("key"))
+	if signed == requestHash([]string{"provision", "-canonical"}, []byte("config"), []byte("other")) {
+		t.Error("Expected the key to change the hash")
+	}
+	if signed != requestHash([]string{"provision", "-canonical"}, []byte("config"), []byte("key")) {
+		t.Error("Expected the same request to hash the same")
+	}
+	if signed == requestHash([]string{"provision-canonical"}, []byte("config"), []byte("key")) {
+		t.Error("Expected argument boundaries to change the hash")
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	args := []string{"provision", "-token", "secret", "--org-token=org-secret", "-config", "project.yml",
+		"-github-token=gh", "-dry-run", "--", "-token", "kept"}
+	expected := []string{"provision", "-token", "REDACTED", "--org-token=REDACTED", "-config", "project.yml",
+		"-github-token=REDACTED", "-dry-run", "--", "-token", "kept"}
+	if redacted := redactArgs(args); !reflect.DeepEqual(redacted, expected) {
+		t.Errorf("Expected %q, found %q", expected, redacted)
+	}
+	if args[2] != "secret" {
+		t.Error("Expected the arguments not to be modified")
+	}
+}
+
+func TestLastAuditHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	if hash, err := lastAuditHash(path); err != nil || hash != "" {
+		t.Errorf("Expected no hash for a missing log, found %q, %v", hash, err)
+	}
+
+	// Entries longer than the first chunk read make it read further back.
+	long := AuditEntry{Caller: strings.Repeat("x", 10000), Hash: "long"}
+	data, _ := json.Marshal(long)
+	short, _ := json.Marshal(AuditEntry{Hash: "short"})
+	for _, tc := range []struct {
+		content  []byte
+		expected string
+	}{
+		{append(append(short, '\n'), append(data, '\n')...), "long"},
+		{append(append(data, '\n'), append(short, '\n')...), "short"},
+		{data, "long"},
+	} {
+		if err := ioutil.WriteFile(path, tc.content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if hash, err := lastAuditHash(path); err != nil || hash != tc.expected {
+			t.Errorf("Expected hash %s, found %q, %v", tc.expected, hash, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// authPlugins build the authenticator selected with -auth from the
+// environment, for CircleCI Server installs behind an SSO proxy.
+var authPlugins = map[string]func(getenv func(string) string) (circleci.Authenticator, error){
+	"token":  func(getenv func(string) string) (circleci.Authenticator, error) { return nil, nil },
+	"cookie": newCookieAuth,
+	"oauth2": newOAuth2Auth,
+}
+
+// newAuthenticator builds the named authenticator. Plain token auth needs no
+// authenticator, so it is nil.
+func newAuthenticator(name string, getenv func(string) string) (circleci.Authenticator, error) {
+	plugin, ok := authPlugins[name]
+	if !ok {
+		names := make([]string, 0, len(authPlugins))
+		for name := range authPlugins {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown -auth %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return plugin(getenv)
+}
+
+// newCookieAuth sends the session cookie in CIRCLECI_AUTH_COOKIE, given as
+// name=value, with every request.
+func newCookieAuth(getenv func(string) string) (circleci.Authenticator, error) {
+	parts := strings.SplitN(getenv("CIRCLECI_AUTH_COOKIE"), "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, fmt.Errorf("-auth cookie needs CIRCLECI_AUTH_COOKIE set to name=value")
+	}
+	return circleci.SessionCookie{Name: parts[0], Value: parts[1]}, nil
+}
+
+// newOAuth2Auth gets bearer tokens through the OAuth2 client credentials
+// flow, configured by the CIRCLECI_OAUTH2_* env vars.
+func newOAuth2Auth(getenv func(string) string) (circleci.Authenticator, error) {
+	auth := &circleci.OAuth2ClientCredentials{
+		TokenURL:     getenv("CIRCLECI_OAUTH2_TOKEN_URL"),
+		ClientID:     getenv("CIRCLECI_OAUTH2_CLIENT_ID"),
+		ClientSecret: getenv("CIRCLECI_OAUTH2_CLIENT_SECRET"),
+		Scopes:       strings.Fields(getenv("CIRCLECI_OAUTH2_SCOPES")),
+	}
+	if auth.TokenURL == "" || auth.ClientID == "" || auth.ClientSecret == "" {
+		return nil, fmt.Errorf("-auth oauth2 needs CIRCLECI_OAUTH2_TOKEN_URL, CIRCLECI_OAUTH2_CLIENT_ID " +
+			"and CIRCLECI_OAUTH2_CLIENT_SECRET set")
+	}
+	return auth, nil
+}
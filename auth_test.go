@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+func TestNewAuthenticator(t *testing.T) {
+	env := map[string]string{
+		"CIRCLECI_AUTH_COOKIE":          "_sso_session=abc=",
+		"CIRCLECI_OAUTH2_TOKEN_URL":     "https://sso.example.com/token",
+		"CIRCLECI_OAUTH2_CLIENT_ID":     "client",
+		"CIRCLECI_OAUTH2_CLIENT_SECRET": "secret",
+	}
+	getenv := func(name string) string { return env[name] }
+
+	auth, err := newAuthenticator("token", getenv)
+	if err != nil || auth != nil {
+		t.Errorf("Expected no authenticator for token auth, found %v (%v)", auth, err)
+	}
+	auth, err = newAuthenticator("cookie", getenv)
+	if err != nil || auth != (circleci.SessionCookie{Name: "_sso_session", Value: "abc="}) {
+		t.Errorf("Expected the _sso_session cookie, found %v (%v)", auth, err)
+	}
+	auth, err = newAuthenticator("oauth2", getenv)
+	if oauth2, ok := auth.(*circleci.OAuth2ClientCredentials); err != nil || !ok || oauth2.ClientID != "client" {
+		t.Errorf("Expected OAuth2 client credentials, found %v (%v)", auth, err)
+	}
+
+	_, err = newAuthenticator("oauth2", func(string) string { return "" })
+	if err == nil {
+		t.Error("Expected an error for oauth2 without credentials")
+	}
+	_, err = newAuthenticator("kerberos", getenv)
+	if err == nil {
+		t.Error("Expected an error for an unknown -auth")
+	}
+}
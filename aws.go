@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsCredentials are the credentials requests to AWS are signed with.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// signAWSRequest signs req, whose body is body, with AWS Signature Version 4.
+func signAWSRequest(req *http.Request, body []byte, service, region string, creds awsCredentials, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	uri := req.URL.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uri,
+		strings.Replace(req.URL.Query().Encode(), "+", "%20", -1),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsClient calls the JSON APIs of an AWS service.
+type awsClient struct {
+	service  string
+	region   string
+	endpoint string
+	creds    awsCredentials
+	client   *http.Client
+}
+
+func newAWSClient(service, region string, creds awsCredentials) *awsClient {
+	return &awsClient{
+		service:  service,
+		region:   region,
+		endpoint: fmt.Sprintf("https://%s.%s.amazonaws.com/", service, region),
+		creds:    creds,
+		client:   &http.Client{},
+	}
+}
+
+// call invokes target (e.g. secretsmanager.GetSecretValue) with input and
+// unmarshals the response into output.
+func (c *awsClient) call(target string, input, output interface{}) error {
+	if c.region == "" {
+		return secretErrorf(secretUnreachable, "AWS_REGION should be set to read secrets from AWS")
+	}
+	if c.creds.AccessKeyID == "" || c.creds.SecretAccessKey == "" {
+		return secretErrorf(secretUnreachable, "AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY should be set to read secrets from AWS")
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	signAWSRequest(req, body, c.service, c.region, c.creds, time.Now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return secretErrorf(secretUnreachable, "%v", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(respBody, &apiErr)
+		return secretErrorf(awsFailure(resp.StatusCode, apiErr.Type), "%s failed with status %d: %s %s",
+			target, resp.StatusCode, apiErr.Type, apiErr.Message)
+	}
+	return json.Unmarshal(respBody, output)
+}
+
+// awsFailure returns why an AWS call failed with status and error type, which
+// may be prefixed with a namespace (e.g. com.amazonaws...#ParameterNotFound).
+func awsFailure(status int, errType string) string {
+	if i := strings.LastIndex(errType, "#"); i >= 0 {
+		errType = errType[i+1:]
+	}
+	switch {
+	case status == http.StatusForbidden, errType == "AccessDeniedException", errType == "UnrecognizedClientException",
+		errType == "InvalidSignatureException", errType == "ExpiredTokenException":
+		return secretDenied
+	case errType == "ResourceNotFoundException", errType == "ParameterNotFound":
+		return secretMissing
+	}
+	return secretFailed
+}
+
+// secretKey returns key of the JSON object value, or the whole value if key
+// is empty.
+func secretKey(value, key string) (string, error) {
+	if key == "" {
+		return value, nil
+	}
+	var fields map[string]interface{}
+	err := json.Unmarshal([]byte(value), &fields)
+	if err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, so key %s cannot be selected", key)
+	}
+	field, ok := fields[key].(string)
+	if !ok {
+		return "", secretErrorf(secretMissing, "secret has no string key %s", key)
+	}
+	return field, nil
+}
+
+// AWSSecretsManager is a SecretStore reading from AWS Secrets Manager. A key
+// selects a field of a secret holding a JSON object.
+type AWSSecretsManager struct {
+	client *awsClient
+}
+
+// NewAWSSecretsManager creates a Secrets Manager client for region.
+func NewAWSSecretsManager(region string, creds awsCredentials) *AWSSecretsManager {
+	return &AWSSecretsManager{newAWSClient("secretsmanager", region, creds)}
+}
+
+// Secret gets the key of the secret with the given ID or ARN.
+func (s *AWSSecretsManager) Secret(secretID, key string) (string, error) {
+	var output struct {
+		SecretString string `json:"SecretString"`
+	}
+	err := s.client.call("secretsmanager.GetSecretValue", map[string]string{"SecretId": secretID}, &output)
+	if err != nil {
+		return "", err
+	}
+	return secretKey(output.SecretString, key)
+}
+
+// AWSParameterStore is a SecretStore reading from SSM Parameter Store.
+// SecureString parameters are decrypted.
+type AWSParameterStore struct {
+	client *awsClient
+}
+
+// NewAWSParameterStore creates a Parameter Store client for region.
+func NewAWSParameterStore(region string, creds awsCredentials) *AWSParameterStore {
+	return &AWSParameterStore{newAWSClient("ssm", region, creds)}
+}
+
+// Secret gets the key of the named parameter.
+func (s *AWSParameterStore) Secret(name, key string) (string, error) {
+	input := struct {
+		Name           string `json:"Name"`
+		WithDecryption bool   `json:"WithDecryption"`
+	}{name, true}
+	var output struct {
+		Parameter struct {
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+	}
+	err := s.client.call("AmazonSSM.GetParameter", input, &output)
+	if err != nil {
+		return "", err
+	}
+	return secretKey(output.Parameter.Value, key)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAWSRequest(t *testing.T) {
+	// Example from the AWS Signature Version 4 documentation.
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signAWSRequest(req, nil, "iam", "us-east-1", creds, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if actual := req.Header.Get("Authorization"); actual != expected {
+		t.Errorf("Expected Authorization %q, found %q", expected, actual)
+	}
+}
+
+func TestAWSSecretStores(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var input map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&input)
+		switch r.Header.Get("X-Amz-Target") {
+		case "secretsmanager.GetSecretValue":
+			if input["SecretId"] != "ci/npm" {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"__type": "ResourceNotFoundException", "message": "not found"}`)
+				return
+			}
+			io.WriteString(w, `{"SecretString": "{\"token\": \"npm-token\"}"}`)
+		case "AmazonSSM.GetParameter":
+			if input["WithDecryption"] != true {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			io.WriteString(w, `{"Parameter": {"Value": "ssm-value"}}`)
+		}
+	}))
+	defer svr.Close()
+
+	creds := awsCredentials{AccessKeyID: "id", SecretAccessKey: "secret"}
+	sm := NewAWSSecretsManager("us-east-1", creds)
+	sm.client.endpoint = svr.URL
+	ssm := NewAWSParameterStore("us-east-1", creds)
+	ssm.client.endpoint = svr.URL
+
+	testCases := []struct {
+		store    SecretStore
+		path     string
+		key      string
+		expected string
+	}{
+		{sm, "ci/npm", "token", "npm-token"},
+		{sm, "ci/npm", "", `{"token": "npm-token"}`},
+		{ssm, "/ci/npm/token", "", "ssm-value"},
+	}
+	for _, tc := range testCases {
+		actual, err := tc.store.Secret(tc.path, tc.key)
+		if err != nil {
+			t.Errorf("Expected no error reading %s#%s, found: %v", tc.path, tc.key, err)
+		} else if actual != tc.expected {
+			t.Errorf("Expected %s#%s to be %q, found %q", tc.path, tc.key, tc.expected, actual)
+		}
+	}
+
+	_, err := sm.Secret("ci/missing", "")
+	if err == nil || !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		t.Errorf("Expected the API error to be reported, found: %v", err)
+	}
+}
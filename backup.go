@@ -0,0 +1,306 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// backupVersion is the version of the backup format written by backup.
+const backupVersion = 1
+
+// backupManifest describes a backup.
+type backupManifest struct {
+	Version   int       `json:"version"`
+	Project   string    `json:"project"` // Project backed up, as vcs/owner/name
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// backupState is the restorable state of a project. Secret values cannot be
+// read back from CircleCI so only their names are kept.
+type backupState struct {
+	Following    bool                   `json:"following"`
+	EnvVarNames  []string               `json:"envVarNames"`
+	SSHKeys      []circleci.SSHKey      `json:"sshKeys"`
+	CheckoutKeys []circleci.CheckoutKey `json:"checkoutKeys"`
+	FeatureFlags map[string]interface{} `json:"featureFlags"`
+}
+
+// fetchBackupState reads the restorable state of the project.
+func fetchBackupState(ctx context.Context, project circleci.Project) (backupState, error) {
+	state, err := fetchState(ctx, project)
+	if err != nil {
+		return backupState{}, err
+	}
+	backup := backupState{Following: state.Following, EnvVarNames: sortedKeys(state.EnvVars), SSHKeys: state.SSHKeys}
+	backup.CheckoutKeys, err = project.CheckoutKeys(ctx)
+	if err != nil {
+		return backup, err
+	}
+	backup.FeatureFlags, err = project.FeatureFlags(ctx)
+	if err != nil {
+		return backup, fmt.Errorf("could not get feature flags of project %s: %v", project.FullName(), err)
+	}
+	return backup, nil
+}
+
+// writeBackup writes a gzipped tarball of the project's restorable state to w.
+func writeBackup(ctx context.Context, w io.Writer, project circleci.Project, slug string) error {
+	backup, err := fetchBackupState(ctx, project)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"manifest.json", backupManifest{backupVersion, slug, time.Now().UTC()}},
+		{"project.json", backup},
+	}
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.content, "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not marshal %s: %v", file.name, err)
+		}
+		err = tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// readBackup reads the manifest and state from a backup tarball.
+func readBackup(r io.Reader) (backupManifest, backupState, error) {
+	var manifest backupManifest
+	var state backupState
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return manifest, state, fmt.Errorf("could not decompress backup: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	found := make(map[string]bool)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return manifest, state, fmt.Errorf("could not read backup: %v", err)
+		}
+
+		var out interface{}
+		switch header.Name {
+		case "manifest.json":
+			out = &manifest
+		case "project.json":
+			out = &state
+		default:
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return manifest, state, fmt.Errorf("could not read %s from backup: %v", header.Name, err)
+		}
+		err = json.Unmarshal(data, out)
+		if err != nil {
+			return manifest, state, fmt.Errorf("could not unmarshal %s from backup: %v", header.Name, err)
+		}
+		found[header.Name] = true
+	}
+
+	if !found["manifest.json"] || !found["project.json"] {
+		return manifest, state, fmt.Errorf("backup is missing manifest.json or project.json")
+	}
+	if manifest.Version != backupVersion {
+		return manifest, state, fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	return manifest, state, nil
+}
+
+// restoreBackup replays a backup onto the project, prompting for the secret
+// values that could not be backed up.
+func restoreBackup(ctx context.Context, r io.Reader, project circleci.Project, prompt prompter) error {
+	manifest, state, err := readBackup(r)
+	if err != nil {
+		return err
+	}
+	logInfof("Restoring backup of %s taken at %s onto %s", manifest.Project, manifest.CreatedAt, project.FullName())
+	return applyBackupState(ctx, project, state, secretSources{}, prompt)
+}
+
+// secretSources are the env var values and SSH key paths already known when
+// applying a backup state, so that they need not be prompted for.
+type secretSources struct {
+	envVars map[string]string // Values keyed by name
+	sshKeys map[string]string // Private key paths keyed by hostname
+}
+
+// applyBackupState applies a restorable state onto the project, taking the
+// secret values it lacks from sources or prompting for them.
+func applyBackupState(ctx context.Context, project circleci.Project, state backupState, sources secretSources,
+	prompt prompter) error {
+	var err error
+	if state.Following {
+		err = project.Follow(ctx)
+		if err != nil {
+			return fmt.Errorf("could not follow %s: %v", project.FullName(), err)
+		}
+	}
+
+	if len(state.FeatureFlags) > 0 {
+		err = project.SetFeatureFlags(ctx, state.FeatureFlags)
+		if err != nil {
+			return fmt.Errorf("could not restore feature flags: %v", err)
+		}
+	}
+
+	for _, name := range state.EnvVarNames {
+		value, ok := sources.envVars[name]
+		if !ok {
+			value, err = prompt.PromptSecret(fmt.Sprintf("Value for environment variable %s (blank to skip)", name))
+			if err != nil {
+				return err
+			}
+		}
+		if value == "" {
+			logInfof("Skipping environment variable %s", name)
+			continue
+		}
+		err = project.Setenv(ctx, name, value)
+		if err != nil {
+			return fmt.Errorf("could not restore environment variable %s: %v", name, err)
+		}
+	}
+
+	sourced := make(map[string]bool)
+	for _, key := range state.SSHKeys {
+		keyPath, ok := sources.sshKeys[key.Hostname]
+		if ok && sourced[key.Hostname] {
+			// A hostname's keys are all replaced by the one sourced key.
+			continue
+		}
+		sourced[key.Hostname] = ok
+		if !ok {
+			keyPath, err = prompt.Prompt(fmt.Sprintf("Path to private key for %s, fingerprint %s (blank to skip)",
+				key.Hostname, key.Fingerprint))
+			if err != nil {
+				return err
+			}
+		}
+		if keyPath == "" {
+			logInfof("Skipping SSH key for %s", key.Hostname)
+			continue
+		}
+		privateKey, err := ioutil.ReadFile(keyPath)
+		if err != nil {
+			return fmt.Errorf("could not read SSH key at path %s: %v", keyPath, err)
+		}
+		err = project.AddSSHKey(ctx, key.Hostname, string(privateKey))
+		if err != nil {
+			return fmt.Errorf("could not restore SSH key for %s: %v", key.Hostname, err)
+		}
+	}
+
+	for _, key := range state.CheckoutKeys {
+		logWarnf("Checkout key %s (%s) cannot be restored and must be recreated", key.Fingerprint, key.Type)
+	}
+	return nil
+}
+
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	out := fs.String("out", "", "File to write the backup to (default owner-project-backup.tar.gz)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	vcsType, owner, projectName, err := s.projectSlug()
+	if err != nil {
+		return err
+	}
+	project := s.v1Project(vcsType, owner, projectName)
+	if *out == "" {
+		*out = fmt.Sprintf("%s-%s-backup.tar.gz", owner, projectName)
+	}
+
+	fh, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %v", *out, err)
+	}
+	defer fh.Close()
+
+	err = writeBackup(s.ctx, fh, project, path.Join(vcsType, owner, projectName))
+	if err != nil {
+		return fmt.Errorf("could not back up project %s: %v", project.FullName(), err)
+	}
+	logInfof("Project %s has been backed up to %s", project.FullName(), *out)
+	return nil
+}
+
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s restore [flags] BACKUP\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("restore takes exactly one backup file")
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	vcsType, owner, projectName, err := s.projectSlug()
+	if err != nil {
+		return err
+	}
+	project := s.v1Project(vcsType, owner, projectName)
+
+	fh, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("could not open backup %s: %v", fs.Arg(0), err)
+	}
+	defer fh.Close()
+
+	err = restoreBackup(s.ctx, fh, project, newTerminalPrompter())
+	if err != nil {
+		return fmt.Errorf("could not restore project %s: %v", project.FullName(), err)
+	}
+	logInfof("Project %s has been restored from %s", project.FullName(), fs.Arg(0))
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// What happens to in-flight operations when a run is cancelled.
+const (
+	cancelFinish = "finish" // Let them finish, aborting them on a second signal
+	cancelAbort  = "abort"  // Abort their requests at once
+)
+
+// errCancelled is returned for operations that were not started because the
+// run was cancelled.
+var errCancelled = errors.New("run was cancelled")
+
+// validateCancelPolicy checks the -on-cancel policy.
+func validateCancelPolicy(policy string) error {
+	if policy != cancelFinish && policy != cancelAbort {
+		return fmt.Errorf("invalid -on-cancel %q, expected %s or %s", policy, cancelFinish, cancelAbort)
+	}
+	return nil
+}
+
+// cancelOnInterrupt cancels the run recorded in report on SIGINT or SIGTERM,
+// so that no new operations are started. In-flight requests are aborted on
+// the next signal, or straight away with -on-cancel abort.
+func (s *session) cancelOnInterrupt(report *Report) {
+	signal.Notify(s.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range s.signals {
+			if report.Cancelled() {
+				logWarnf("Received %v again, aborting in-flight operations", sig)
+				s.cancel()
+				continue
+			}
+			report.Cancel()
+			if *s.flags.onCancel == cancelAbort {
+				logWarnf("Received %v, cancelling the run and aborting in-flight operations", sig)
+				s.cancel()
+			} else {
+				logWarnf("Received %v, cancelling the run once in-flight operations finish (send it again to abort them)", sig)
+			}
+		}
+	}()
+}
+
+// collapseCancelled replaces the errors of operations that were not started
+// because the run was cancelled with a single errCancelled.
+func collapseCancelled(errs []error) []error {
+	var collapsed []error
+	cancelled := false
+	for _, err := range errs {
+		if err == errCancelled {
+			cancelled = true
+			continue
+		}
+		collapsed = append(collapsed, err)
+	}
+	if cancelled {
+		collapsed = append(collapsed, errCancelled)
+	}
+	return collapsed
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestProvisionCancelled(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{})
+	defer svr.Close()
+
+	report := NewReport(nil)
+	report.Cancel()
+	err := provision(context.Background(), svr.project(), Config{EnvVars: EnvVars{"A": "1"}},
+		provisionOptions{report: report})
+	if err != errCancelled {
+		t.Errorf("Expected the run to be cancelled, found: %v", err)
+	}
+	if len(svr.Requests()) > 0 {
+		t.Errorf("Expected no requests once cancelled, found %v", svr.Requests())
+	}
+
+	err = setEnvVars(context.Background(), svr.project(), EnvVars{"A": "1", "B": "2"}, nil, nil, 2, report)
+	if err != errCancelled {
+		t.Errorf("Expected one cancellation error, found: %v", err)
+	}
+}
+
+func TestReportCancelled(t *testing.T) {
+	var events bytes.Buffer
+	report := NewReport(NewEventLog(&events))
+	report.Record("git/test/a", circleci.ResourceEnvVar, "A", outcomeCreated, nil)
+	report.Cancel()
+	report.Cancel()
+	report.Finished("git/test/a", fmt.Errorf("could not set B: %v", errCancelled))
+
+	var out bytes.Buffer
+	report.Print(&out)
+	if !strings.HasSuffix(out.String(), "cancelled: resources not listed above were not provisioned\n") {
+		t.Errorf("Expected the report to be marked cancelled, found:\n%s", out.String())
+	}
+	if strings.Count(events.String(), `"type":"run_cancelled"`) != 1 {
+		t.Errorf("Expected one run_cancelled event, found:\n%s", events.String())
+	}
+	if !strings.Contains(events.String(), `"type":"project_finished","project":"git/test/a","outcome":"cancelled"`) {
+		t.Errorf("Expected the project to finish cancelled, found:\n%s", events.String())
+	}
+}
+
+func TestCollapseCancelled(t *testing.T) {
+	failure := fmt.Errorf("bad request")
+	errs := collapseCancelled([]error{nil, errCancelled, failure, errCancelled})
+	if len(errs) != 3 || errs[0] != nil || errs[1] != failure || errs[2] != errCancelled {
+		t.Errorf("Expected the failure and one cancellation, found %v", errs)
+	}
+	if err := joinErrors(collapseCancelled([]error{nil, nil})); err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// checkoutKeyManager is what managing checkout keys needs of a project.
+type checkoutKeyManager interface {
+	FullName() string
+	CheckoutKeys(ctx context.Context) ([]circleci.CheckoutKey, error)
+	CreateCheckoutKey(ctx context.Context, keyType string) (circleci.CheckoutKey, error)
+	DeleteCheckoutKey(ctx context.Context, fingerprint string) error
+}
+
+// ensureCheckoutKeys creates a checkout key of each of the types the project
+// is missing. If canonical is set, keys of other types are removed.
+func ensureCheckoutKeys(ctx context.Context, project checkoutKeyManager, keyTypes []string, canonical bool) error {
+	for _, keyType := range keyTypes {
+		if keyType != circleci.CheckoutKeyDeploy && keyType != circleci.CheckoutKeyUser {
+			return fmt.Errorf("unknown checkout key type %q, expected %s or %s",
+				keyType, circleci.CheckoutKeyDeploy, circleci.CheckoutKeyUser)
+		}
+	}
+
+	keys, err := project.CheckoutKeys(ctx)
+	if err != nil {
+		return err
+	}
+	present := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		present[key.Kind()] = true
+	}
+	if canonical {
+		for _, key := range staleCheckoutKeys(keys, keyTypes) {
+			logInfof("Removing %s %s from project %s", key.Kind(), key.Fingerprint, project.FullName())
+			err = project.DeleteCheckoutKey(ctx, key.Fingerprint)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, keyType := range keyTypes {
+		if present[keyType] {
+			continue
+		}
+		logInfof("Creating %s for project %s", keyType, project.FullName())
+		key, err := project.CreateCheckoutKey(ctx, keyType)
+		if err != nil {
+			return err
+		}
+		present[keyType] = true
+		logInfof("Created %s %s for project %s", keyType, key.Fingerprint, project.FullName())
+	}
+	return nil
+}
+
+// staleCheckoutKeys returns the keys whose types are not in keyTypes, which
+// canonical mode removes.
+func staleCheckoutKeys(keys []circleci.CheckoutKey, keyTypes []string) []circleci.CheckoutKey {
+	var stale []circleci.CheckoutKey
+	for _, key := range keys {
+		if !containsName(keyTypes, key.Kind()) {
+			stale = append(stale, key)
+		}
+	}
+	return stale
+}
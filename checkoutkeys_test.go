@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+type fakeCheckoutKeyManager struct {
+	keys    []circleci.CheckoutKey
+	created []string
+	deleted []string
+}
+
+func (p *fakeCheckoutKeyManager) FullName() string { return "owner/project" }
+
+func (p *fakeCheckoutKeyManager) CheckoutKeys(ctx context.Context) ([]circleci.CheckoutKey, error) {
+	return p.keys, nil
+}
+
+func (p *fakeCheckoutKeyManager) CreateCheckoutKey(ctx context.Context, keyType string) (circleci.CheckoutKey, error) {
+	p.created = append(p.created, keyType)
+	return circleci.CheckoutKey{Type: keyType, Fingerprint: "new"}, nil
+}
+
+func (p *fakeCheckoutKeyManager) DeleteCheckoutKey(ctx context.Context, fingerprint string) error {
+	p.deleted = append(p.deleted, fingerprint)
+	return nil
+}
+
+func TestEnsureCheckoutKeys(t *testing.T) {
+	deployKey := circleci.CheckoutKey{Type: "deploy-key", Fingerprint: "aa"}
+	userKey := circleci.CheckoutKey{Type: "github-user-key", Fingerprint: "bb"}
+	testCases := []struct {
+		name      string
+		keys      []circleci.CheckoutKey
+		keyTypes  []string
+		canonical bool
+		created   []string
+		deleted   []string
+	}{
+		{"missing", []circleci.CheckoutKey{deployKey}, []string{"deploy-key", "user-key"}, false, []string{"user-key"}, nil},
+		{"present", []circleci.CheckoutKey{deployKey, userKey}, []string{"user-key"}, false, nil, nil},
+		{"canonical", []circleci.CheckoutKey{deployKey, userKey}, []string{"user-key"}, true, nil, []string{"aa"}},
+		{"canonical replaces", []circleci.CheckoutKey{userKey}, []string{"deploy-key"}, true, []string{"deploy-key"}, []string{"bb"}},
+	}
+
+	for _, tc := range testCases {
+		project := &fakeCheckoutKeyManager{keys: tc.keys}
+		err := ensureCheckoutKeys(context.Background(), project, tc.keyTypes, tc.canonical)
+		if err != nil {
+			t.Errorf("%s: expected no error, found: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(project.created, tc.created) || !reflect.DeepEqual(project.deleted, tc.deleted) {
+			t.Errorf("%s: expected %v created and %v deleted, found %v and %v",
+				tc.name, tc.created, tc.deleted, project.created, project.deleted)
+		}
+	}
+
+	project := &fakeCheckoutKeyManager{}
+	err := ensureCheckoutKeys(context.Background(), project, []string{"deploy-key", "machine-key"}, false)
+	if err == nil || project.created != nil {
+		t.Errorf("Expected an error and no keys created for an unknown type, found %v and %v", err, project.created)
+	}
+}
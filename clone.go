@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// cloneSettings copies the restorable settings of one project onto another,
+// taking secret values from sources or prompting for them.
+func cloneSettings(ctx context.Context, from circleci.Project, to circleci.Project, sources secretSources, prompt prompter) error {
+	state, err := fetchBackupState(ctx, from)
+	if err != nil {
+		return fmt.Errorf("could not read settings of project %s: %v", from.FullName(), err)
+	}
+	logInfof("Cloning settings of %s onto %s", from.FullName(), to.FullName())
+	return applyBackupState(ctx, to, state, sources, prompt)
+}
+
+func runCloneSettings(args []string) error {
+	fs := flag.NewFlagSet("clone-settings", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	from := fs.String("from", "", "Project to copy settings from, as vcs/owner/name")
+	to := fs.String("to", "", "Project to copy settings to, as vcs/owner/name")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		fs.Usage()
+		return fmt.Errorf("-from and -to are required")
+	}
+	fromVcs, fromOwner, fromName, err := parseProjectSlug(*from)
+	if err != nil {
+		return err
+	}
+	toVcs, toOwner, toName, err := parseProjectSlug(*to)
+	if err != nil {
+		return err
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	// Secret values in -config are used instead of prompting for them.
+	var sources secretSources
+	if *common.configFile != "" {
+		config, err := s.config()
+		if err != nil {
+			return err
+		}
+		sources = secretSources{envVars: config.EnvVars, sshKeys: config.SSHKeys}
+	}
+
+	source := s.v1Project(fromVcs, fromOwner, fromName)
+	target := s.v1Project(toVcs, toOwner, toName)
+	err = cloneSettings(s.ctx, source, target, sources, newTerminalPrompter())
+	if err != nil {
+		return fmt.Errorf("could not clone settings onto project %s: %v", target.FullName(), err)
+	}
+	logInfof("Project %s has been set up with the settings of %s", target.FullName(), source.FullName())
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+// fakePrompter answers every prompt from canned answers keyed by a word of
+// the question.
+type fakePrompter map[string]string
+
+func (p fakePrompter) answer(question string) (string, error) {
+	for word, answer := range p {
+		if strings.Contains(question, word) {
+			return answer, nil
+		}
+	}
+	return "", nil
+}
+
+func (p fakePrompter) Prompt(question string) (string, error) { return p.answer(question) }
+
+func (p fakePrompter) PromptSecret(question string) (string, error) { return p.answer(question) }
+
+func TestCloneSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "github.key")
+	err = ioutil.WriteFile(keyFile, []byte("KEY"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := newFakeCircleCI(map[string]circlecitest.Response{
+		"GET " + circlecitest.SettingsPath:        {Status: http.StatusOK, Body: circlecitest.Settings},
+		"GET /project/git/test/test/envvar":       {Status: http.StatusOK, Body: circlecitest.EnvVars},
+		"GET /project/git/test/test/checkout-key": {Status: http.StatusOK, Body: `[]`},
+	})
+	defer source.Close()
+	target := newFakeCircleCI(map[string]circlecitest.Response{
+		"POST /project/git/test/test/follow":  {Status: http.StatusCreated, Body: `{}`},
+		"PUT " + circlecitest.SettingsPath:    {Status: http.StatusOK, Body: `{}`},
+		"POST /project/git/test/test/envvar":  {Status: http.StatusCreated, Body: `{}`},
+		"POST /project/git/test/test/ssh-key": {Status: http.StatusCreated, Body: ``},
+	})
+	defer target.Close()
+
+	sources := secretSources{envVars: map[string]string{"A": "sourced"}, sshKeys: map[string]string{"github.com": keyFile}}
+	prompt := fakePrompter{"variable B": "prompted"}
+	err = cloneSettings(context.Background(), source.project(), target.project(), sources, prompt)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := []string{
+		"POST /project/git/test/test/follow",
+		`PUT /project/git/test/test/settings {"feature_flags":{"oss":true}}`,
+		`POST /project/git/test/test/envvar {"name":"A","value":"sourced"}`,
+		`POST /project/git/test/test/envvar {"name":"B","value":"prompted"}`,
+		`POST /project/git/test/test/ssh-key {"hostname":"github.com","private_key":"KEY"}`,
+	}
+	if !reflect.DeepEqual(target.Requests(), expected) {
+		t.Errorf("Expected requests %v, found %v", expected, target.Requests())
+	}
+}
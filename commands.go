@@ -0,0 +1,663 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// command is a subcommand of the CLI.
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+// commands are the available subcommands, keyed by name.
+var commands = map[string]command{
+	"provision":      {"Follow a project and bring it in line with its config", runProvision},
+	"apply":          {"Provision every config of a workspace in order", runApply},
+	"unfollow":       {"Stop following a project", runUnfollow},
+	"trigger":        {"Trigger a pipeline of a project", runTrigger},
+	"sync":           {"Provision every repo in an org based on its GitHub topics", runSync},
+	"discover":       {"Provision every GitHub repo of an org matching a topic or name with one config", runDiscover},
+	"shadow":         {"Log discrepancies between API v1.1 and v2 reads of a project", runShadow},
+	"audit":          {"Report expired env vars and workflows breaking their thresholds", runAudit},
+	"backup":         {"Export a project's restorable state into a tarball", runBackup},
+	"restore":        {"Replay a backup tarball onto a project", runRestore},
+	"trigger-all":    {"Trigger a pipeline of every configured project", runTriggerAll},
+	"diff":           {"Show how a project has drifted from its config", runDiff},
+	"edit":           {"Edit a config, then review its plan and apply it", runEdit},
+	"plan":           {"Print the changes provisioning configs would make, optionally offline from snapshots", runPlan},
+	"contexts":       {"Show how org contexts have drifted from configs (contexts diff)", runContexts},
+	"graph":          {"Draw the projects of configs and the contexts, keys and env files they share", runGraph},
+	"dedupe-keys":    {"Remove duplicate SSH keys left by past runs", runDedupeKeys},
+	"purge":          {"Remove env vars quarantined by -canonical -quarantine", runPurge},
+	"export":         {"Write a config skeleton describing an existing project", runExport},
+	"clone-settings": {"Copy the settings of one project onto another", runCloneSettings},
+	"env":            {"Manage a single environment variable (env set)", runEnv},
+	"sshkey":         {"Manage a single SSH key (sshkey add)", runSSHKey},
+	"state":          {"Print the live state of a project (state show)", runState},
+	"resolve-check":  {"Resolve every secret reference of configs without provisioning anything", runResolveCheck},
+	"audit-log":      {"Check that an -audit-log has not been tampered with (audit-log verify)", runAuditLog},
+	"self-update":    {"Replace this binary with a verified release", runSelfUpdate},
+}
+
+// usage prints the available subcommands to w.
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s COMMAND [flags]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-14s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s COMMAND -h' for the flags of a command, or '%[1]s run NAME [flags]' to run a\n"+
+		"run profile of the user config.\n", os.Args[0])
+}
+
+// addYesFlags adds -yes and its alias -force, which skip confirming
+// destructive changes.
+func addYesFlags(fs *flag.FlagSet) *bool {
+	yes := fs.Bool("yes", false, "Do not ask for confirmation before removing anything")
+	fs.BoolVar(yes, "force", false, "Same as -yes")
+	return yes
+}
+
+// commonFlags are the flags shared by subcommands operating on a project.
+type commonFlags struct {
+	fs           *flag.FlagSet
+	profile      *string
+	token        *string
+	tokenFile    *string
+	tokenCommand *string
+	orgToken     *string
+	platform     *string
+	apiURL       *string
+	caBundle     *string
+	insecureTLS  *bool
+	tlsMin       *string
+	tlsCiphers   *string
+	serverVer    *string
+	apiVersion   *string
+	auth         *string
+	configFile   *string
+	configFormat *string
+	configGlob   *string
+	recursive    *bool
+	project      *string
+	noTemplate   *bool
+	templateSeed *int64
+	values       *string
+	strict       *bool
+	metricsFile  *string
+	pushgateway  *string
+	noColor      *bool
+	events       *string
+	eventsFile   *string
+	auditLog     *string
+	onCancel     *string
+	output       *string
+
+	approvalURL    *string
+	approvalTicket *string
+
+	followAttempts *int
+	followDelay    *time.Duration
+	timeout        *time.Duration
+	requestTimeout *time.Duration
+	maxRetries     *int
+	dialTimeout    *time.Duration
+	dialRetries    *int
+	dnsCacheTTL    *time.Duration
+	dnsRetries     *int
+	secretTTL      *time.Duration
+	secretEntries  *int
+	logFormat      *string
+	verbose        *bool
+
+	offline bool // The API is not used, so no token is needed
+}
+
+func addCommonFlags(fs *flag.FlagSet) *commonFlags {
+	platform := os.Getenv("CIRCLECI_PLATFORM")
+	if platform == "" {
+		platform = string(circleci.PlatformCloud)
+	}
+	logFormat := os.Getenv("CIRCLECI_LOG_FORMAT")
+	if logFormat == "" {
+		logFormat = logFormatText
+	}
+	apiVersion := os.Getenv("CIRCLECI_API_VERSION")
+	if apiVersion == "" {
+		apiVersion = string(circleci.APIv2)
+	}
+	auth := os.Getenv("CIRCLECI_AUTH")
+	if auth == "" {
+		auth = "token"
+	}
+	output := os.Getenv("CIRCLECI_OUTPUT")
+	if output == "" {
+		output = outputTable
+	}
+	tlsMinVersion := os.Getenv("CIRCLECI_TLS_MIN_VERSION")
+	if tlsMinVersion == "" {
+		tlsMinVersion = "1.2"
+	}
+	return &commonFlags{
+		fs: fs,
+		profile: fs.String("profile", os.Getenv("CIRCLECI_PROFILE"),
+			"Profile of the credentials file to take the token and installation from (default \""+defaultProfile+"\" if it exists)"),
+		token: fs.String("token", os.Getenv("CIRCLECI_TOKEN"), "Circle CI token"),
+		tokenFile: fs.String("token-file", os.Getenv("CIRCLECI_TOKEN_FILE"),
+			"File holding the Circle CI token, used instead of -token"),
+		tokenCommand: fs.String("token-command", os.Getenv("CIRCLECI_TOKEN_COMMAND"),
+			"Shell command printing the Circle CI token, e.g. 'op read op://ci/circleci/token', used instead of -token"),
+		orgToken: fs.String("org-token", os.Getenv("CIRCLECI_ORG_TOKEN"),
+			"Circle CI organization token, used for org-level resources such as contexts"),
+		platform: fs.String("platform", platform,
+			"CircleCI platform being provisioned (cloud, server-2 or server-3)"),
+		apiURL: fs.String("api-url", os.Getenv("CIRCLECI_API_URL"),
+			"Address of the CircleCI installation, e.g. a CircleCI Server at https://circleci.example.com (default "+
+				circleci.DefaultAPIURL+")"),
+		caBundle: fs.String("ca-bundle", os.Getenv("CIRCLECI_CA_BUNDLE"),
+			"PEM file of CA certificates to trust besides the system ones, e.g. of a CircleCI Server's private CA"),
+		insecureTLS: fs.Bool("insecure-skip-tls-verify", envBool("CIRCLECI_INSECURE_SKIP_TLS_VERIFY"),
+			"Do not verify the TLS certificate of the CircleCI installation. Insecure, only for testing"),
+		tlsMin: fs.String("tls-min-version", tlsMinVersion,
+			"Oldest TLS version to connect to the CircleCI installation with (1.0, 1.1, 1.2 or 1.3)"),
+		serverVer: fs.String("server-version", os.Getenv("CIRCLECI_SERVER_VERSION"),
+			"Version of the CircleCI Server being provisioned, e.g. 4.1, instead of detecting it"),
+		tlsCiphers: fs.String("tls-ciphers", os.Getenv("CIRCLECI_TLS_CIPHERS"),
+			"Comma separated IANA names of the TLS 1.2 cipher suites to allow (default Go's)"),
+		apiVersion: fs.String("api-version", apiVersion,
+			"CircleCI API version to use (v2 or v1.1). v2 falls back to v1.1 for resources it does not cover"),
+		auth: fs.String("auth", auth,
+			"How to authenticate besides the token (token, cookie or oauth2), for CircleCI Server behind an SSO proxy"),
+		configFile: fs.String("config", os.Getenv("CIRCLECI_CONFIG"), "Circle CI provisioning config"),
+		configFormat: fs.String("config-format", os.Getenv("CIRCLECI_CONFIG_FORMAT"),
+			"Format of config files (yaml, json or toml), detected from their extension if empty"),
+		configGlob: fs.String("config-glob", os.Getenv("CIRCLECI_CONFIG_GLOB"),
+			"Names of the files to read when -config is a directory (defaults to *.yaml and *.yml)"),
+		recursive: fs.Bool("recursive", false, "Also read config files in subdirectories when -config is a directory"),
+		project: fs.String("project", "",
+			"Project to operate on as vcs/owner/name, instead of the one in -config"),
+		noTemplate: fs.Bool("no-template", os.Getenv("CIRCLECI_NO_TEMPLATE") != "",
+			"Read config files verbatim instead of rendering them as Go templates"),
+		templateSeed: fs.Int64("template-seed", 0,
+			"Seed for randomAlphaNum in config templates (defaults to a new seed every run)"),
+		values: fs.String("values", os.Getenv("CIRCLECI_VALUES"),
+			"YAML, JSON or TOML file of values to render config templates against"),
+		strict: fs.Bool("strict", envBool("CIRCLECI_STRICT"),
+			"Fail on {{ }} or ${ } placeholders left in configs once rendered, instead of warning about them"),
+		metricsFile: fs.String("metrics-file", os.Getenv("CIRCLECI_METRICS_FILE"),
+			"Write a JSON summary of the run to this file"),
+		pushgateway: fs.String("pushgateway", os.Getenv("CIRCLECI_PUSHGATEWAY"),
+			"Push run metrics to this Prometheus Pushgateway URL"),
+		noColor: fs.Bool("no-color", false, "Do not color output, even on a terminal (also set by NO_COLOR)"),
+		events: fs.String("events", os.Getenv("CIRCLECI_EVENTS"),
+			"Stream an event per provisioning action as it happens, in this format (ndjson)"),
+		eventsFile: fs.String("events-file", os.Getenv("CIRCLECI_EVENTS_FILE"),
+			"Append -events to this file instead of stdout"),
+		auditLog: fs.String("audit-log", os.Getenv("CIRCLECI_AUDIT_LOG"),
+			"Append a tamper-evident record of each run to this file"),
+		onCancel: fs.String("on-cancel", cancelFinish,
+			"What to do with in-flight operations when interrupted: finish them, or abort them"),
+		output: fs.String("output", output,
+			"Format of the run's result: a table, or a json or yaml document of each resource provisioned"),
+		approvalURL: fs.String("approval-url", os.Getenv("CIRCLECI_APPROVAL_URL"),
+			"Webhook that must approve destructive operations, signed with CIRCLECI_APPROVAL_SECRET"),
+		approvalTicket: fs.String("approval-ticket", os.Getenv("CIRCLECI_APPROVAL_TICKET"),
+			"Change ticket sent with approval requests"),
+		followAttempts: fs.Int("follow-attempts", circleci.DefaultFollowAttempts,
+			"Times to try following a project CircleCI does not know about yet"),
+		followDelay: fs.Duration("follow-retry-delay", circleci.DefaultFollowDelay, "Wait between follow attempts"),
+		timeout:     fs.Duration("timeout", 0, "Give up on the whole run after this long (no limit if 0)"),
+		requestTimeout: fs.Duration("request-timeout", time.Minute,
+			"Give up on a single API request after this long (no limit if 0)"),
+		maxRetries: fs.Int("max-retries", circleci.DefaultMaxRetries,
+			"Times to retry an API request that is rate limited or fails with a server error"),
+		dialTimeout: fs.Duration("dial-timeout", circleci.DefaultNetworkOptions.DialTimeout,
+			"Give up on a single connection attempt after this long"),
+		dialRetries: fs.Int("dial-retries", circleci.DefaultNetworkOptions.DialRetries,
+			"Times to retry connecting to the API when every address fails"),
+		dnsCacheTTL: fs.Duration("dns-cache-ttl", circleci.DefaultNetworkOptions.DNSCacheTTL,
+			"Reuse resolved API addresses for this long, falling back to them if lookups fail (no cache if 0)"),
+		dnsRetries: fs.Int("dns-retries", circleci.DefaultNetworkOptions.DNSRetries,
+			"Times to retry a DNS lookup failing with a temporary error"),
+		secretTTL: fs.Duration("secret-cache-ttl", defaultSecretCacheTTL,
+			"Reuse secrets resolved from a store for this long across the run's projects (no cache if 0)"),
+		secretEntries: fs.Int("secret-cache-size", defaultSecretCacheSize,
+			"Most secrets to keep cached at once"),
+		logFormat: fs.String("log-format", logFormat, "Format of log messages (text or json)"),
+		verbose: fs.Bool("verbose", envBool("CIRCLECI_VERBOSE"),
+			"Log debug messages, including traces of API requests and responses"),
+	}
+}
+
+// session holds everything a subcommand needs to talk to CircleCI, as
+// given by the common flags.
+type session struct {
+	flags      *commonFlags
+	creds      circleci.Credentials
+	platform   circleci.Platform
+	apiVersion circleci.APIVersion
+	configOpts configOptions
+	metrics    *Metrics
+	client     *circleci.HTTPClient // API v1.1
+	v2Client   *circleci.HTTPClient // API v2
+	apiURL     string               // Installation client and v2Client talk to
+	version    string               // CircleCI Server version of the installation, empty if unknown
+	installs   *installations       // Clients of the installations configs point at with apiURL
+	stdout     *output
+	approval   ApprovalConfig // Global approval webhook, overridden by project configs
+	events     *EventLog      // Where -events are streamed, if set
+	signals    chan os.Signal // Interrupts that cancel the run
+
+	ctx    context.Context // Cancelled once -timeout has passed
+	cancel context.CancelFunc
+}
+
+// session validates the common flags and sets up the clients they describe.
+// Errors are usage errors unless they say otherwise.
+func (f *commonFlags) session() (*session, error) {
+	s, err := f.newSession()
+	if err != nil {
+		return nil, usageError(err)
+	}
+	return s, nil
+}
+
+func (f *commonFlags) newSession() (*session, error) {
+	err := configureLogging(*f.logFormat, *f.verbose)
+	if err != nil {
+		return nil, err
+	}
+	circleci.SetLogger(logs)
+	if f.fs != nil {
+		err = applyProfile(f.fs, *f.profile, os.Getenv)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !f.offline {
+		*f.token, err = resolveToken(*f.token, *f.tokenFile, *f.tokenCommand)
+		if err != nil {
+			return nil, withExitCode(exitAPI, err)
+		}
+	}
+	if *f.token == "" && !f.offline {
+		return nil, fmt.Errorf("-token, -token-file or -token-command is required or CIRCLECI_TOKEN should be set")
+	}
+	platform, err := circleci.ParsePlatform(*f.platform)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -platform: %v", err)
+	}
+	apiVersion := circleci.APIVersion(*f.apiVersion)
+	if apiVersion != circleci.APIv1 && apiVersion != circleci.APIv2 {
+		return nil, fmt.Errorf("invalid -api-version %q, expected v2 or v1.1", *f.apiVersion)
+	}
+	auth, err := newAuthenticator(*f.auth, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCancelPolicy(*f.onCancel); err != nil {
+		return nil, err
+	}
+	if err := validateOutputFormat(*f.output); err != nil {
+		return nil, err
+	}
+
+	seed := *f.templateSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if !*f.noTemplate {
+		logInfof("Rendering config templates with seed %d", seed)
+	}
+	var values map[string]interface{}
+	if *f.values != "" {
+		values, err = readValues(*f.values)
+		if err != nil {
+			return nil, fmt.Errorf("could not read values file %s: %v", *f.values, err)
+		}
+	}
+	runID, err := newRunID()
+	if err != nil {
+		return nil, fmt.Errorf("could not generate a run ID: %v", err)
+	}
+	if err := f.audit(runID); err != nil {
+		return nil, err
+	}
+	logDebugf("Starting run %s", runID)
+
+	awsRegion := os.Getenv("AWS_REGION")
+	if awsRegion == "" {
+		awsRegion = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	awsCreds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	secrets := map[string]SecretStore{
+		"vault":  NewVaultClient(os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN"), os.Getenv("VAULT_NAMESPACE")),
+		"aws-sm": NewAWSSecretsManager(awsRegion, awsCreds),
+		"ssm":    NewAWSParameterStore(awsRegion, awsCreds),
+	}
+	for scheme, store := range secrets {
+		secrets[scheme] = newSecretCache(store, *f.secretTTL, *f.secretEntries)
+	}
+	events, err := openEventLog(*f.events, *f.eventsFile)
+	if err != nil {
+		return nil, err
+	}
+	events.SetRunID(runID)
+	// Human readable output moves to stderr when events are streamed to
+	// stdout, so that stdout can be parsed line by line.
+	stdout := os.Stdout
+	if events != nil && *f.eventsFile == "" {
+		stdout = os.Stderr
+	}
+
+	configOpts := configOptions{noTemplate: *f.noTemplate, format: *f.configFormat, rand: newLockedRand(seed),
+		secrets: secrets, values: values, strict: *f.strict}
+	metrics := NewMetrics()
+	network := circleci.DefaultNetworkOptions
+	network.DialTimeout, network.DialRetries = *f.dialTimeout, *f.dialRetries
+	network.DNSCacheTTL, network.DNSRetries = *f.dnsCacheTTL, *f.dnsRetries
+	network.TLS, err = circleci.TLSConfig(*f.caBundle, *f.insecureTLS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -ca-bundle: %v", err)
+	}
+	var ciphers []string
+	if *f.tlsCiphers != "" {
+		ciphers = strings.Split(*f.tlsCiphers, ",")
+	}
+	if err := circleci.RestrictTLS(network.TLS, *f.tlsMin, ciphers); err != nil {
+		return nil, fmt.Errorf("invalid TLS settings: %v", err)
+	}
+	if *f.insecureTLS {
+		logWarnf("Not verifying TLS certificates, anyone on the network can read the secrets being provisioned")
+	}
+	creds := circleci.Credentials{Token: *f.token, OrgToken: *f.orgToken}
+	installs := &installations{
+		transport: circleci.NewTransport(network),
+		metrics:   metrics,
+		configure: func(c *circleci.HTTPClient) {
+			c.HTTP.Timeout = *f.requestTimeout
+			c.MaxRetries = *f.maxRetries
+			c.Trace = *f.verbose
+			c.Token = creds.TokenFor(circleci.ResourceProject)
+			c.Auth = auth
+		},
+		byURL:    make(map[string][2]*circleci.HTTPClient),
+		versions: make(map[string]string),
+	}
+	apiURL := *f.apiURL
+	if apiURL == "" {
+		apiURL = circleci.DefaultAPIURL
+	}
+	client, v2Client, err := installs.clients(apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -api-url: %v", err)
+	}
+	version := *f.serverVer
+	if version != "" && platform != circleci.PlatformCloud {
+		warnUnsupported(version)
+	} else if version == "" && platform == circleci.PlatformServer3 && !f.offline {
+		version = installs.serverVersion(apiURL, v2Client)
+	}
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if *f.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), *f.timeout)
+	}
+	return &session{
+		flags:      f,
+		creds:      creds,
+		platform:   platform,
+		apiVersion: apiVersion,
+		configOpts: configOpts,
+		metrics:    metrics,
+		client:     client,
+		v2Client:   v2Client,
+		apiURL:     apiURL,
+		version:    version,
+		installs:   installs,
+		stdout:     newOutput(stdout, *f.noColor),
+		approval:   ApprovalConfig{URL: *f.approvalURL, Ticket: *f.approvalTicket},
+		events:     events,
+		signals:    make(chan os.Signal, 1),
+		ctx:        ctx,
+		cancel:     cancel,
+	}, nil
+}
+
+// installations creates the API clients of each CircleCI installation a
+// session talks to, sharing one transport.
+type installations struct {
+	transport http.RoundTripper
+	metrics   *Metrics
+	configure func(*circleci.HTTPClient) // Applies the common flags to a new client
+
+	mu       sync.Mutex
+	byURL    map[string][2]*circleci.HTTPClient // API v1.1 and v2 clients, keyed by API URL
+	versions map[string]string                  // CircleCI Server versions, keyed by API URL
+}
+
+// clients returns the API v1.1 and v2 clients of the installation at apiURL.
+func (i *installations) clients(apiURL string) (*circleci.HTTPClient, *circleci.HTTPClient, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if clients, ok := i.byURL[apiURL]; ok {
+		return clients[0], clients[1], nil
+	}
+	v1URL, v2URL, err := circleci.BaseURLs(apiURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := circleci.NewHTTPClient(v1URL, i.metrics)
+	v2Client := circleci.NewHTTPClient(v2URL, i.metrics)
+	for _, c := range []*circleci.HTTPClient{client, v2Client} {
+		c.HTTP.Transport = i.transport
+		i.configure(c)
+	}
+	i.byURL[apiURL] = [2]*circleci.HTTPClient{client, v2Client}
+	return client, v2Client, nil
+}
+
+// forConfig returns the session talking to the installation the config's
+// apiURL points at, or s itself if it does not set one.
+func (s *session) forConfig(config Config) (*session, error) {
+	if config.APIURL == "" || config.APIURL == s.apiURL || s.installs == nil {
+		return s, nil
+	}
+	client, v2Client, err := s.installs.clients(config.APIURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid apiURL of project %s/%s: %v", config.Owner, config.ProjectName, err)
+	}
+	scoped := *s
+	scoped.client, scoped.v2Client, scoped.apiURL = client, v2Client, config.APIURL
+	if s.platform == circleci.PlatformServer3 && *s.flags.serverVer == "" && !s.flags.offline {
+		scoped.version = s.installs.serverVersion(config.APIURL, v2Client)
+	}
+	return &scoped, nil
+}
+
+// readConfig reads and renders configFile.
+func (s *session) readConfig(configFile string) (Config, error) {
+	config, err := readConfig(configFile, s.configOpts)
+	if err != nil {
+		return config, usageError(fmt.Errorf("could not read config file %s: %v", configFile, err))
+	}
+	return config, nil
+}
+
+// readConfigs reads and renders configFile, which may be an org config
+// describing several projects.
+func (s *session) readConfigs(configFile string) ([]Config, error) {
+	configs, err := readConfigs(configFile, s.configOpts)
+	if err != nil {
+		return nil, usageError(fmt.Errorf("could not read config file %s: %v", configFile, err))
+	}
+	return configs, nil
+}
+
+// config reads the config given by -config.
+func (s *session) config() (Config, error) {
+	if *s.flags.configFile == "" {
+		return Config{}, usageError(fmt.Errorf("-config is required or CIRCLECI_CONFIG should be set"))
+	}
+	if info, err := os.Stat(*s.flags.configFile); err == nil && info.IsDir() {
+		return Config{}, usageError(fmt.Errorf("-config %s is a directory, which only provision accepts", *s.flags.configFile))
+	}
+	return s.readConfig(*s.flags.configFile)
+}
+
+// configArgs returns the config files given as arguments, or by -config if
+// there are none. Either may be directories of them.
+func (f *commonFlags) configArgs(args []string) ([]string, error) {
+	if len(args) == 0 {
+		if *f.configFile == "" {
+			return nil, usageError(fmt.Errorf("config files or -config are required"))
+		}
+		args = []string{*f.configFile}
+	}
+	var files []string
+	for _, arg := range args {
+		expanded, err := configFiles(arg, *f.configGlob, *f.recursive)
+		if err != nil {
+			return nil, usageError(fmt.Errorf("invalid config %s: %v", arg, err))
+		}
+		files = append(files, expanded...)
+	}
+	return files, nil
+}
+
+// configFiles returns the config files given by -config, which may be a
+// directory of them.
+func (f *commonFlags) configFiles() ([]string, error) {
+	if *f.configFile == "" {
+		return nil, usageError(fmt.Errorf("-config is required or CIRCLECI_CONFIG should be set"))
+	}
+	files, err := configFiles(*f.configFile, *f.configGlob, *f.recursive)
+	if err != nil {
+		return nil, usageError(fmt.Errorf("invalid -config: %v", err))
+	}
+	return files, nil
+}
+
+// projectSlug returns the project given by -project, or by -config if
+// -project is not set.
+func (s *session) projectSlug() (vcsType, owner, projectName string, err error) {
+	if *s.flags.project != "" {
+		vcsType, owner, projectName, err = parseProjectSlug(*s.flags.project)
+		return vcsType, owner, projectName, usageError(err)
+	}
+	config, err := s.config()
+	return config.VcsType, config.Owner, config.ProjectName, err
+}
+
+// project returns the project using the API version given by -api-version,
+// or API v2 for standalone projects, recording its changes in the session's
+// metrics.
+func (s *session) project(vcsType, owner, projectName string) circleci.Project {
+	if s.apiVersion == circleci.APIv1 && !circleci.IsStandalone(vcsType) {
+		return instrumentedProject{s.v1Project(vcsType, owner, projectName), s.metrics}
+	}
+	return instrumentedProject{s.v2Project(vcsType, owner, projectName), s.metrics}
+}
+
+// v1Project returns the API v1.1 representation of the project.
+func (s *session) v1Project(vcsType, owner, projectName string) *circleci.ProjectV1 {
+	project := circleci.NewProjectV1WithClient(vcsType, owner, projectName, s.client)
+	project.Platform, project.Version = s.platform, s.version
+	project.FollowAttempts = *s.flags.followAttempts
+	project.FollowDelay = *s.flags.followDelay
+	return project
+}
+
+// v2Project returns the API v2 representation of the project.
+func (s *session) v2Project(vcsType, owner, projectName string) *circleci.ProjectV2 {
+	project := circleci.NewProjectV2WithClient(vcsType, owner, projectName, s.v2Client, s.client)
+	project.Platform, project.Version = s.platform, s.version
+	project.Legacy = s.v1Project(vcsType, owner, projectName)
+	return project
+}
+
+// contexts returns the contexts of the organisation, managed with the
+// organisation token if one is set.
+func (s *session) contexts(vcsType, owner string) *circleci.Contexts {
+	client := s.v2Client.WithToken(s.creds.TokenFor(circleci.ResourceContext))
+	contexts := circleci.NewContextsWithClient(vcsType, owner, client)
+	contexts.Platform = s.platform
+	return contexts
+}
+
+// printReport writes the run's report to stdout in the -output format.
+func (s *session) printReport(report *Report) {
+	err := report.Write(s.stdout, *s.flags.output)
+	if err != nil {
+		logErrorf("Could not write the run's result: %v", err)
+	}
+}
+
+// close ends the session, writing its metrics to -metrics-file and pushing
+// them to -pushgateway if either is set.
+func (s *session) close() {
+	signal.Stop(s.signals)
+	close(s.signals)
+	s.cancel()
+	reportMetrics(s.metrics, *s.flags.metricsFile, *s.flags.pushgateway)
+	if err := s.events.Close(); err != nil {
+		logWarnf("Could not close event log: %v", err)
+	}
+}
+
+// reportMetrics writes the run's metrics to file and pushes them to
+// the Pushgateway, if either is configured.
+func reportMetrics(metrics *Metrics, file, pushgateway string) {
+	if file != "" {
+		err := metrics.WriteFile(file)
+		if err != nil {
+			logWarnf("Could not write metrics to %s: %v", file, err)
+		}
+	}
+	if pushgateway != "" {
+		err := metrics.Push(pushgateway)
+		if err != nil {
+			logWarnf("Could not push metrics: %v", err)
+		}
+	}
+}
+
+// parseProjectSlug splits a vcs/owner/name project slug.
+func parseProjectSlug(slug string) (vcsType, owner, projectName string, err error) {
+	parts := strings.Split(slug, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("invalid project %q, expected vcs/owner/name", slug)
+	}
+	if err := checkVCS(parts[0], parts[1], parts[2]); err != nil {
+		return "", "", "", fmt.Errorf("invalid project %q: %v", slug, err)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// envBool returns the boolean value of the environment variable name, or
+// false if it is unset or not a boolean.
+func envBool(name string) bool {
+	value, err := strconv.ParseBool(os.Getenv(name))
+	return err == nil && value
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+func TestSessionForConfig(t *testing.T) {
+	installs := &installations{
+		configure: func(c *circleci.HTTPClient) { c.MaxRetries = 7 },
+		byURL:     make(map[string][2]*circleci.HTTPClient),
+	}
+	client, v2Client, err := installs.clients(circleci.DefaultAPIURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &session{client: client, v2Client: v2Client, apiURL: circleci.DefaultAPIURL, installs: installs}
+
+	if scoped, err := s.forConfig(Config{}); err != nil || scoped != s {
+		t.Errorf("Expected a config without apiURL to use the session, found %v (%v)", scoped, err)
+	}
+	server := Config{APIURL: "https://circleci.example.com"}
+	scoped, err := s.forConfig(server)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if scoped.v2Client.BaseURL() != "https://circleci.example.com/api/v2" || scoped.client.MaxRetries != 7 {
+		t.Errorf("Expected configured clients of the server, found %+v", scoped.v2Client)
+	}
+	if again, _ := s.forConfig(server); again.client != scoped.client {
+		t.Error("Expected the server's clients to be reused")
+	}
+	if s.v2Client.BaseURL() != circleci.DefaultV2BaseURL {
+		t.Errorf("Expected the session to be left alone, found %s", s.v2Client.BaseURL())
+	}
+	if _, err := s.forConfig(Config{APIURL: "circleci.example.com"}); err == nil {
+		t.Error("Expected an error for an invalid apiURL")
+	}
+}
+
+func TestSessionServerVersion(t *testing.T) {
+	requests := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Circleci-Server-Version", "4.0.1")
+	}))
+	defer svr.Close()
+	installs := &installations{
+		configure: func(c *circleci.HTTPClient) { c.HTTP = svr.Client() },
+		byURL:     make(map[string][2]*circleci.HTTPClient),
+	}
+	serverVer, followAttempts, followDelay := "", 1, time.Duration(0)
+	s := &session{platform: circleci.PlatformServer3, installs: installs,
+		flags: &commonFlags{serverVer: &serverVer, followAttempts: &followAttempts, followDelay: &followDelay}}
+
+	for i := 0; i < 2; i++ {
+		scoped, err := s.forConfig(Config{APIURL: svr.URL})
+		if err != nil {
+			t.Fatalf("Expected no error, found: %v", err)
+		}
+		if scoped.version != "4.0.1" || scoped.v2Project("gh", "owner", "project").Version != "4.0.1" {
+			t.Errorf("Expected Server 4.0.1 to be detected, found %q", scoped.version)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected the version to be detected once, found %d requests", requests)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configFiles returns the config files at path: path itself if it is a file,
+// otherwise the files in the directory whose names match glob (*.yaml and
+// *.yml if empty), descending into subdirectories if recursive. Files are
+// returned in lexical order.
+func configFiles(path, glob string, recursive bool) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	if _, err := filepath.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid -config-glob %q: %v", glob, err)
+	}
+
+	var files []string
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if file != path && (!recursive || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matchesConfigGlob(info.Name(), glob) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no config files in %s", path)
+	}
+	return files, nil
+}
+
+// matchesConfigGlob reports whether the file name matches glob, or is YAML if
+// glob is empty.
+func matchesConfigGlob(name, glob string) bool {
+	if glob == "" {
+		ext := strings.ToLower(filepath.Ext(name))
+		return ext == ".yaml" || ext == ".yml"
+	}
+	ok, _ := filepath.Match(glob, name)
+	return ok
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"b.yml", "a.yaml", "sa.json", "team/c.yaml", "team/d.toml", ".git/e.yaml"} {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type test struct {
+		path      string
+		glob      string
+		recursive bool
+		expected  []string
+	}
+
+	testCases := []test{
+		{"a.yaml", "", false, []string{"a.yaml"}},
+		{"", "", false, []string{"a.yaml", "b.yml"}},
+		{"", "", true, []string{"a.yaml", "b.yml", "team/c.yaml"}},
+		{"", "*.toml", true, []string{"team/d.toml"}},
+	}
+
+	for _, tc := range testCases {
+		actual, err := configFiles(filepath.Join(dir, tc.path), tc.glob, tc.recursive)
+		if err != nil {
+			t.Errorf("Expected no error for %q, found: %v", tc.path, err)
+			continue
+		}
+		var expected []string
+		for _, file := range tc.expected {
+			expected = append(expected, filepath.Join(dir, file))
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Expected %v for %q (%q, %v), found %v", expected, tc.path, tc.glob, tc.recursive, actual)
+		}
+	}
+
+	if _, err := configFiles(dir, "*.ini", false); err == nil {
+		t.Error("Expected an error for a directory without configs")
+	}
+	if _, err := configFiles(dir, "[", false); err == nil {
+		t.Error("Expected an error for an invalid glob")
+	}
+}
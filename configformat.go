@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Config file formats.
+const (
+	formatYAML = "yaml"
+	formatJSON = "json"
+	formatTOML = "toml"
+)
+
+// configFormat returns the format of the config file: format if it is set,
+// otherwise the one its extension names, defaulting to YAML.
+func configFormat(file, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case formatYAML, "yml":
+		return formatYAML, nil
+	case formatJSON:
+		return formatJSON, nil
+	case formatTOML:
+		return formatTOML, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unknown config format %q, expected yaml, json or toml", format)
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return formatJSON, nil
+	case ".toml":
+		return formatTOML, nil
+	}
+	return formatYAML, nil
+}
+
+// convertToYAML converts a config in format to YAML, which the rest of the
+// config is read as.
+func convertToYAML(data []byte, format string) ([]byte, error) {
+	var doc interface{}
+	var err error
+	switch format {
+	case formatYAML:
+		return data, nil
+	case formatJSON:
+		err = json.Unmarshal(data, &doc)
+	case formatTOML:
+		_, err = toml.Decode(string(data), &doc)
+		doc = tomlDates(doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", strings.ToUpper(format), err)
+	}
+	return yaml.Marshal(doc)
+}
+
+// tomlDates replaces the dates and times of a decoded TOML document with
+// strings, as they would be read from YAML: local dates as 2006-01-02, and
+// anything else as RFC 3339.
+func tomlDates(v interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Time:
+		if v.Location() == time.Local && v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format(time.RFC3339Nano)
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = tomlDates(value)
+		}
+	case []map[string]interface{}:
+		for _, table := range v {
+			tomlDates(table)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = tomlDates(value)
+		}
+	}
+	return v
+}
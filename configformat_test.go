@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigFormat(t *testing.T) {
+	type test struct {
+		file     string
+		format   string
+		expected string
+	}
+
+	testCases := []test{
+		{"project.yml", "", formatYAML},
+		{"project.yaml", "", formatYAML},
+		{"project.JSON", "", formatJSON},
+		{"project.toml", "", formatTOML},
+		{"project.conf", "toml", formatTOML},
+		{"project.json", "yaml", formatYAML},
+	}
+
+	for _, tc := range testCases {
+		actual, err := configFormat(tc.file, tc.format)
+		if err != nil || actual != tc.expected {
+			t.Errorf("Expected %s for %s (%q), found %s (%v)", tc.expected, tc.file, tc.format, actual, err)
+		}
+	}
+	if _, err := configFormat("project.yml", "ini"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestReadConfigFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"project.yml": "version: 1\nvcsType: gh\nowner: nick96\nprojectName: test\n" +
+			"envVars:\n  A: \"1\"\n  B:\n    value: two\n    expiresAt: 2030-01-01\n",
+		"project.json": `{"version": 1, "vcsType": "gh", "owner": "nick96", "projectName": "test",
+			"envVars": {"A": "1", "B": {"value": "two", "expiresAt": "2030-01-01"}}}`,
+		"project.toml": "version = 1\nvcsType = \"gh\"\nowner = \"nick96\"\nprojectName = \"test\"\n" +
+			"[envVars]\nA = \"1\"\nB = { value = \"two\", expiresAt = 2030-01-01 }\n",
+	}
+	var configs []Config
+	for name, data := range files {
+		file := filepath.Join(dir, name)
+		err = ioutil.WriteFile(file, []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, err := readConfig(file, configOptions{noTemplate: true})
+		if err != nil {
+			t.Fatalf("Expected no error reading %s, found: %v", name, err)
+		}
+		configs = append(configs, config)
+	}
+	for _, config := range configs[1:] {
+		if !reflect.DeepEqual(config, configs[0]) {
+			t.Errorf("Expected every format to read the same config, found %+v and %+v", configs[0], config)
+		}
+	}
+	if configs[0].EnvVars["B"] != "two" || len(configs[0].Expiry) != 1 {
+		t.Errorf("Unexpected config %+v", configs[0])
+	}
+}
+
+func TestConvertTOMLToYAML(t *testing.T) {
+	data := []byte(`[[contexts]]
+name = "shared"
+expiresAt = 2030-01-01
+rotated = [2030-01-01T10:00:00Z]
+`)
+	expected := "contexts:\n- expiresAt: \"2030-01-01\"\n  name: shared\n  rotated:\n  - \"2030-01-01T10:00:00Z\"\n"
+	actual, err := convertToYAML(data, formatTOML)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if string(actual) != expected {
+		t.Errorf("Expected %q, found %q", expected, actual)
+	}
+	if _, err := convertToYAML([]byte("a = 1\na = 2\n"), formatTOML); err == nil {
+		t.Error("Expected an error for a duplicate key")
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// resourceRestriction is the drift resource of a context's project
+// restrictions.
+const resourceRestriction = "restriction"
+
+// errContextDrift is returned by contexts diff when an org's contexts have
+// drifted from their configs.
+var errContextDrift = errors.New("contexts have drifted from their config")
+
+// contextDeclaration is what the configs say about a context.
+type contextDeclaration struct {
+	declared bool              // Listed under contexts, rather than only attached to
+	envVars  map[string]bool   // Names of the env vars set in it
+	projects map[string]string // Projects attached to it, keyed by project ID
+	groups   map[string]bool   // Security groups allowed to use it, if any are declared
+}
+
+// liveContext is a context as it is in the org.
+type liveContext struct {
+	context      circleci.Context
+	envVarNames  []string
+	restrictions []circleci.ContextRestriction
+}
+
+// contextDeclarations collects the contexts the configs declare or attach
+// projects to. projectID returns the ID of a config's project.
+func contextDeclarations(configs []Config, projectID func(Config) (string, error)) (map[string]*contextDeclaration, error) {
+	declarations := make(map[string]*contextDeclaration)
+	declaration := func(name string) *contextDeclaration {
+		if declarations[name] == nil {
+			declarations[name] = &contextDeclaration{envVars: make(map[string]bool), projects: make(map[string]string)}
+		}
+		return declarations[name]
+	}
+	for _, config := range configs {
+		for _, context := range config.Contexts {
+			d := declaration(context.Name)
+			d.declared = true
+			for name := range context.EnvVars {
+				d.envVars[name] = true
+			}
+			for _, group := range context.SecurityGroups {
+				if d.groups == nil {
+					d.groups = make(map[string]bool)
+				}
+				d.groups[group] = true
+			}
+		}
+		if len(config.AttachContexts) == 0 {
+			continue
+		}
+		id, err := projectID(config)
+		if err != nil {
+			return nil, fmt.Errorf("could not get the ID of project %s/%s: %v", config.Owner, config.ProjectName, err)
+		}
+		for _, name := range config.AttachContexts {
+			declaration(name).projects[id] = config.Owner + "/" + config.ProjectName
+		}
+	}
+	return declarations, nil
+}
+
+// fetchContexts reads every context of the org with its env var names and
+// restrictions.
+func fetchContexts(ctx context.Context, contexts *circleci.Contexts) ([]liveContext, error) {
+	list, err := contexts.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live := make([]liveContext, 0, len(list))
+	for _, context := range list {
+		names, err := contexts.EnvVarNames(ctx, context)
+		if err != nil {
+			return nil, err
+		}
+		restrictions, err := contexts.Restrictions(ctx, context)
+		if err != nil {
+			return nil, err
+		}
+		live = append(live, liveContext{context, names, restrictions})
+	}
+	return live, nil
+}
+
+// computeContextDrift compares the declared contexts with the org's, reporting
+// missing and undeclared contexts, env vars and project restrictions.
+func computeContextDrift(declarations map[string]*contextDeclaration, live []liveContext) DriftReport {
+	var report DriftReport
+	byName := make(map[string]liveContext)
+	for _, context := range live {
+		byName[context.context.Name] = context
+	}
+
+	names := make([]string, 0, len(declarations))
+	for name := range declarations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		declaration := declarations[name]
+		context, ok := byName[name]
+		if !ok {
+			report = append(report, Drift{circleci.ResourceContext, name, driftMissing, ""})
+			continue
+		}
+
+		if declaration.declared {
+			liveNames := make(map[string]bool)
+			for _, envVar := range context.envVarNames {
+				liveNames[envVar] = true
+			}
+			for _, envVar := range sortedBoolKeys(declaration.envVars) {
+				if !liveNames[envVar] {
+					report = append(report, Drift{circleci.ResourceEnvVar, name + "/" + envVar, driftMissing, ""})
+				}
+			}
+			for _, envVar := range sortedBoolKeys(liveNames) {
+				if !declaration.envVars[envVar] {
+					report = append(report, Drift{circleci.ResourceEnvVar, name + "/" + envVar, driftExtra, "undeclared"})
+				}
+			}
+		}
+
+		restricted := make(map[string]bool)
+		for _, restriction := range context.restrictions {
+			if restriction.Type != circleci.RestrictionProject {
+				continue
+			}
+			restricted[restriction.Value] = true
+			if _, ok := declaration.projects[restriction.Value]; !ok {
+				report = append(report, Drift{resourceRestriction, name + "/" + restriction.Name, driftExtra, "undeclared"})
+			}
+		}
+		for _, id := range sortedKeys(declaration.projects) {
+			if !restricted[id] {
+				report = append(report, Drift{resourceRestriction, name + "/" + declaration.projects[id], driftMissing, ""})
+			}
+		}
+		if declaration.groups != nil {
+			report = append(report, groupDrift(name, declaration.groups, context.restrictions)...)
+		}
+	}
+
+	for _, context := range live {
+		if _, ok := declarations[context.context.Name]; !ok {
+			report = append(report, Drift{circleci.ResourceContext, context.context.Name, driftExtra, "undeclared"})
+		}
+	}
+	return report
+}
+
+// groupDrift compares the security groups declared for a context with those
+// it is restricted to.
+func groupDrift(name string, groups map[string]bool, restrictions []circleci.ContextRestriction) DriftReport {
+	var report DriftReport
+	restricted := make(map[string]bool)
+	for _, restriction := range restrictions {
+		if restriction.Type != circleci.RestrictionGroup {
+			continue
+		}
+		restricted[restriction.Value] = true
+		if !groups[restriction.Value] {
+			report = append(report, Drift{resourceRestriction, name + "/group " + restriction.Value, driftExtra, "undeclared"})
+		}
+	}
+	for _, group := range sortedBoolKeys(groups) {
+		if !restricted[group] {
+			report = append(report, Drift{resourceRestriction, name + "/group " + group, driftMissing, ""})
+		}
+	}
+	return report
+}
+
+// sortedBoolKeys returns the keys of the set in order.
+func sortedBoolKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func runContexts(args []string) error {
+	_, args, err := subcommand("contexts", args, "diff")
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("contexts diff", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s contexts diff [flags] [CONFIG|DIR...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	paths, err := common.configArgs(fs.Args())
+	if err != nil {
+		fs.Usage()
+		return err
+	}
+
+	// Each org's contexts are compared with the configs of its projects.
+	type org struct{ vcsType, owner string }
+	var orgs []org
+	configs := make(map[org][]Config)
+	for _, file := range paths {
+		read, err := s.readConfigs(file)
+		if err != nil {
+			return err
+		}
+		for _, config := range read {
+			key := org{config.VcsType, config.Owner}
+			if configs[key] == nil {
+				orgs = append(orgs, key)
+			}
+			configs[key] = append(configs[key], config)
+		}
+	}
+
+	drifted := false
+	for _, o := range orgs {
+		declarations, err := contextDeclarations(configs[o], func(config Config) (string, error) {
+			scoped, err := s.forConfig(config)
+			if err != nil {
+				return "", err
+			}
+			return scoped.v2Project(config.VcsType, config.Owner, config.ProjectName).ID(s.ctx)
+		})
+		if err != nil {
+			return err
+		}
+		// An org's projects are all on the same installation.
+		scoped, err := s.forConfig(configs[o][0])
+		if err != nil {
+			return err
+		}
+		live, err := fetchContexts(s.ctx, scoped.contexts(o.vcsType, o.owner))
+		if err != nil {
+			return fmt.Errorf("could not read the contexts of %s: %v", o.owner, err)
+		}
+		report := computeContextDrift(declarations, live)
+		report.print(s.stdout, "contexts of "+o.owner)
+		drifted = drifted || len(report) > 0
+	}
+	if drifted {
+		return errContextDrift
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+func TestComputeContextDrift(t *testing.T) {
+	configs := []Config{
+		{
+			Owner:          "org",
+			ProjectName:    "a",
+			Contexts:       []ContextConfig{{Name: "shared", EnvVars: map[string]string{"SAME": "1", "MISSING": "2"}}},
+			AttachContexts: []string{"shared"},
+		},
+		{
+			Owner:          "org",
+			ProjectName:    "b",
+			Contexts:       []ContextConfig{{Name: "gone"}},
+			AttachContexts: []string{"shared", "deploy"},
+		},
+	}
+	ids := map[string]string{"a": "id-a", "b": "id-b"}
+	declarations, err := contextDeclarations(configs, func(config Config) (string, error) {
+		return ids[config.ProjectName], nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+
+	live := []liveContext{
+		{
+			context:     circleci.Context{ID: "1", Name: "shared"},
+			envVarNames: []string{"SAME", "EXTRA"},
+			restrictions: []circleci.ContextRestriction{
+				{Type: circleci.RestrictionProject, Value: "id-a", Name: "a"},
+				{Type: circleci.RestrictionProject, Value: "id-c", Name: "c"},
+				{Type: circleci.RestrictionGroup, Value: "group", Name: "admins"},
+			},
+		},
+		// Only attached to, so its env vars are not compared
+		{context: circleci.Context{ID: "2", Name: "deploy"}, envVarNames: []string{"TOKEN"}},
+		{context: circleci.Context{ID: "3", Name: "stray"}},
+	}
+
+	report := computeContextDrift(declarations, live)
+	expected := DriftReport{
+		{resourceRestriction, "deploy/org/b", driftMissing, ""},
+		{circleci.ResourceContext, "gone", driftMissing, ""},
+		{circleci.ResourceEnvVar, "shared/MISSING", driftMissing, ""},
+		{circleci.ResourceEnvVar, "shared/EXTRA", driftExtra, "undeclared"},
+		{resourceRestriction, "shared/c", driftExtra, "undeclared"},
+		{resourceRestriction, "shared/org/b", driftMissing, ""},
+		{circleci.ResourceContext, "stray", driftExtra, "undeclared"},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Expected drift %v, found %v", expected, report)
+	}
+}
+
+func TestFetchContexts(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/context":
+			io.WriteString(w, `{"items": [{"id": "1", "name": "shared"}]}`)
+		case "/context/1/environment-variable":
+			io.WriteString(w, `{"items": [{"variable": "A"}, {"variable": "B"}]}`)
+		case "/context/1/restrictions":
+			io.WriteString(w, `{"items": [{"restriction_type": "project", "restriction_value": "id-a", "name": "a"}]}`)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+	svr := httptest.NewServer(handler)
+	defer svr.Close()
+
+	client := circleci.NewHTTPClient(svr.URL, nil)
+	client.HTTP = svr.Client()
+	contexts := circleci.NewContextsWithClient("github", "test", client)
+	live, err := fetchContexts(context.Background(), contexts)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	declarations := map[string]*contextDeclaration{
+		"shared": {declared: true, envVars: map[string]bool{"A": true}, projects: map[string]string{"id-a": "test/a"}},
+	}
+
+	var out bytes.Buffer
+	computeContextDrift(declarations, live).print(&out, "contexts of test")
+	if !strings.Contains(out.String(), "Drift for contexts of test:") || !strings.Contains(out.String(), "shared/B") {
+		t.Errorf("Unexpected drift %q", out.String())
+	}
+	if strings.Contains(out.String(), "restriction") {
+		t.Errorf("Expected no restriction drift, found %q", out.String())
+	}
+}
+
+func TestGroupDrift(t *testing.T) {
+	restrictions := []circleci.ContextRestriction{
+		{Type: circleci.RestrictionProject, Value: "id-a", Name: "a"},
+		{Type: circleci.RestrictionGroup, Value: "admins", Name: "Admins"},
+		{Type: circleci.RestrictionGroup, Value: "everyone", Name: "Everyone"},
+	}
+	report := groupDrift("deploy", map[string]bool{"admins": true, "release": true}, restrictions)
+	expected := DriftReport{
+		{resourceRestriction, "deploy/group everyone", driftExtra, "undeclared"},
+		{resourceRestriction, "deploy/group release", driftMissing, ""},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Expected drift %v, found %v", expected, report)
+	}
+}
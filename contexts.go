@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// ContextConfig is the configuration of an organisation context.
+type ContextConfig struct {
+	Name     string            `yaml:"name"`     // Name of the context, created if it does not exist
+	EnvVars  map[string]string `yaml:"envVars"`  // Env vars to set in the context
+	EnvFiles []string          `yaml:"envFiles"` // Dotenv or JSON files of env vars to set in the context
+
+	SecurityGroups []string `yaml:"securityGroups"` // IDs of the org security groups allowed to use the context
+}
+
+// provisionContexts creates the configured contexts, sets their environment
+// variables and restricts them to their security groups. In canonical mode
+// variables and security groups not in the config are removed from the
+// configured contexts; other contexts are left untouched.
+// With a managed state only the owner's variables it records are removed, and
+// the variables set are recorded in it. Variables are only removed once
+// approve agrees, if it is set.
+func provisionContexts(ctx context.Context, contexts *circleci.Contexts, configs []ContextConfig, canonical bool,
+	managed *ManagedState, owner string, approve confirmFunc) error {
+	existing, err := contexts.List(ctx)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]circleci.Context)
+	for _, context := range existing {
+		byName[context.Name] = context
+	}
+
+	for _, config := range configs {
+		context, ok := byName[config.Name]
+		if !ok {
+			logInfof("Creating context %s", config.Name)
+			context, err = contexts.Create(ctx, config.Name)
+			if err != nil {
+				return err
+			}
+		}
+
+		previous := managed.Context(owner, config.Name)
+		if canonical {
+			names, err := contexts.EnvVarNames(ctx, context)
+			if err != nil {
+				return err
+			}
+			var stale, changes []string
+			for _, name := range names {
+				if _, ok := config.EnvVars[name]; ok {
+					continue
+				}
+				if managed != nil && !containsName(previous.EnvVars, name) {
+					continue
+				}
+				stale = append(stale, name)
+				changes = append(changes, fmt.Sprintf("%s %s %s from context %s", opRemove, circleci.ResourceEnvVar,
+					name, context.Name))
+			}
+			if approve != nil && len(changes) > 0 {
+				if err := approve(owner, changes); err != nil {
+					return fmt.Errorf("could not get approval to remove environment variables from context %s: %v",
+						context.Name, err)
+				}
+			}
+			for _, name := range stale {
+				logInfof("Removing environment variable %s from context %s", name, context.Name)
+				err = contexts.Deleteenv(ctx, context, name)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, name := range sortedKeys(config.EnvVars) {
+			logInfof("Setting environment variable %s in context %s", name, context.Name)
+			err = contexts.Setenv(ctx, context, name, config.EnvVars[name])
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(config.SecurityGroups) > 0 {
+			err = restrictGroups(ctx, contexts, context, config.SecurityGroups, canonical)
+			if err != nil {
+				return err
+			}
+		}
+
+		if managed != nil {
+			// Managed variables that were not removed stay managed.
+			resources := ManagedResources{EnvVars: sortedKeys(config.EnvVars)}
+			if !canonical {
+				for _, name := range previous.EnvVars {
+					if _, ok := config.EnvVars[name]; !ok {
+						resources.EnvVars = append(resources.EnvVars, name)
+					}
+				}
+				sort.Strings(resources.EnvVars)
+			}
+			err = managed.SetContext(owner, config.Name, resources)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restrictGroups restricts the context to the security groups. In canonical
+// mode, restrictions to other groups are removed.
+func restrictGroups(ctx context.Context, contexts *circleci.Contexts, context circleci.Context, groups []string,
+	canonical bool) error {
+	restrictions, err := contexts.Restrictions(ctx, context)
+	if err != nil {
+		return err
+	}
+	restricted := make(map[string]bool)
+	for _, restriction := range restrictions {
+		if restriction.Type != circleci.RestrictionGroup {
+			continue
+		}
+		restricted[restriction.Value] = true
+		if canonical && !containsName(groups, restriction.Value) {
+			logInfof("Removing restriction of context %s to security group %s", context.Name, restriction.Value)
+			err = contexts.DeleteRestriction(ctx, context, restriction)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, group := range groups {
+		if restricted[group] {
+			continue
+		}
+		logInfof("Restricting context %s to security group %s", context.Name, group)
+		err = contexts.AddRestriction(ctx, context, circleci.RestrictionGroup, group)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachmentPreview describes what attaching a project to a context grants.
+type attachmentPreview struct {
+	Context       circleci.Context
+	OtherProjects []string // Projects already using the context
+	EnvVarNames   []string // Secrets the project gains access to
+}
+
+// Print writes the preview to w.
+func (preview attachmentPreview) Print(w io.Writer, projectName string) {
+	fmt.Fprintf(w, "Attaching %s to context %s grants access to %d secret(s):\n",
+		projectName, preview.Context.Name, len(preview.EnvVarNames))
+	for _, name := range preview.EnvVarNames {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+	if len(preview.OtherProjects) == 0 {
+		fmt.Fprintf(w, "No other projects use context %s\n", preview.Context.Name)
+		return
+	}
+	fmt.Fprintf(w, "Context %s is already used by:\n", preview.Context.Name)
+	for _, name := range preview.OtherProjects {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+}
+
+// projectIdentifier is a project with a CircleCI project ID.
+type projectIdentifier interface {
+	FullName() string
+	ID(ctx context.Context) (string, error)
+}
+
+// attachContexts restricts the named contexts to the project, showing the
+// impact of each attachment and asking confirm before making it.
+func attachContexts(ctx context.Context, contexts *circleci.Contexts, project projectIdentifier, names []string,
+	out io.Writer, confirm func(question string) (bool, error)) error {
+	projectID, err := project.ID(ctx)
+	if err != nil {
+		return err
+	}
+	existing, err := contexts.List(ctx)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]circleci.Context)
+	for _, context := range existing {
+		byName[context.Name] = context
+	}
+
+	for _, name := range names {
+		context, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("context %s does not exist", name)
+		}
+		restrictions, err := contexts.Restrictions(ctx, context)
+		if err != nil {
+			return err
+		}
+
+		preview := attachmentPreview{Context: context}
+		attached := false
+		for _, restriction := range restrictions {
+			if restriction.Type != circleci.RestrictionProject {
+				continue
+			}
+			if restriction.Value == projectID {
+				attached = true
+			} else {
+				preview.OtherProjects = append(preview.OtherProjects, restriction.Name)
+			}
+		}
+		if attached {
+			logInfof("Project %s is already attached to context %s", project.FullName(), name)
+			continue
+		}
+
+		preview.EnvVarNames, err = contexts.EnvVarNames(ctx, context)
+		if err != nil {
+			return err
+		}
+		preview.Print(out, project.FullName())
+		ok, err = confirm(fmt.Sprintf("Attach %s to context %s?", project.FullName(), name))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			logInfof("Not attaching %s to context %s", project.FullName(), name)
+			continue
+		}
+
+		err = contexts.AddRestriction(ctx, context, circleci.RestrictionProject, projectID)
+		if err != nil {
+			return err
+		}
+		logInfof("Attached %s to context %s", project.FullName(), name)
+	}
+	return nil
+}
+
+// detachContexts removes the project from the org's contexts other than the
+// named ones, so that it can only use those.
+func detachContexts(ctx context.Context, contexts *circleci.Contexts, project projectIdentifier, names []string,
+	approve confirmFunc) error {
+	projectID, err := project.ID(ctx)
+	if err != nil {
+		return err
+	}
+	existing, err := contexts.List(ctx)
+	if err != nil {
+		return err
+	}
+	type detachment struct {
+		context     circleci.Context
+		restriction circleci.ContextRestriction
+	}
+	var detachments []detachment
+	var changes []string
+	for _, context := range existing {
+		if containsName(names, context.Name) {
+			continue
+		}
+		restrictions, err := contexts.Restrictions(ctx, context)
+		if err != nil {
+			return err
+		}
+		for _, restriction := range restrictions {
+			if restriction.Type != circleci.RestrictionProject || restriction.Value != projectID {
+				continue
+			}
+			detachments = append(detachments, detachment{context, restriction})
+			changes = append(changes, fmt.Sprintf("detach %s %s", circleci.ResourceContext, context.Name))
+		}
+	}
+	if approve != nil && len(changes) > 0 {
+		if err := approve(project.FullName(), changes); err != nil {
+			return fmt.Errorf("could not get approval to detach %s from contexts: %v", project.FullName(), err)
+		}
+	}
+	for _, d := range detachments {
+		logInfof("Detaching %s from context %s", project.FullName(), d.context.Name)
+		err = contexts.DeleteRestriction(ctx, d.context, d.restriction)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+func TestProvisionContexts(t *testing.T) {
+	var calls []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.Header.Get("Circle-Token"); token != "org" {
+			t.Errorf("Expected the org token to be used, found %q", token)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		calls = append(calls, r.Method+" "+r.URL.Path+" "+string(body))
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/context":
+			if r.URL.Query().Get("owner-slug") != "gh/test" {
+				t.Errorf("Unexpected owner slug %s", r.URL.Query().Get("owner-slug"))
+			}
+			io.WriteString(w, `{"items": [{"id": "1", "name": "shared"}]}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/context/1/environment-variable":
+			io.WriteString(w, `{"items": [{"variable": "KEEP"}, {"variable": "STALE"}]}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/context":
+			io.WriteString(w, `{"id": "2", "name": "new"}`)
+		default:
+			io.WriteString(w, `{"message": "ok"}`)
+		}
+	})
+	svr := httptest.NewServer(handler)
+	defer svr.Close()
+
+	client := circleci.NewHTTPClient(svr.URL, nil)
+	client.HTTP = svr.Client()
+	client.Token = "personal"
+	contexts := circleci.NewContextsWithClient("github", "test", client.WithToken("org"))
+	configs := []ContextConfig{
+		{Name: "shared", EnvVars: map[string]string{"KEEP": "1"}},
+		{Name: "new", EnvVars: map[string]string{"A": "2"}},
+	}
+
+	deny := func(string, []string) error { return fmt.Errorf("denied") }
+	err := provisionContexts(context.Background(), contexts, configs, true, nil, "test", deny)
+	if err == nil || len(calls) != 2 {
+		t.Fatalf("Expected nothing to be removed without approval, found calls %q (%v)", calls, err)
+	}
+	calls = nil
+
+	err = provisionContexts(context.Background(), contexts, configs, true, nil, "test", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+
+	expected := []string{
+		"GET /context ",
+		"GET /context/1/environment-variable ",
+		"DELETE /context/1/environment-variable/STALE ",
+		`PUT /context/1/environment-variable/KEEP {"value":"1"}`,
+		`POST /context {"name":"new","owner":{"slug":"gh/test","type":"organization"}}`,
+		"GET /context/2/environment-variable ",
+		`PUT /context/2/environment-variable/A {"value":"2"}`,
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected calls %q, found %q", expected, calls)
+	}
+}
+
+func TestRestrictions(t *testing.T) {
+	var calls []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != http.MethodGet {
+			calls = append(calls, r.Method+" "+r.URL.Path+" "+string(body))
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /project/gh/test/test":
+			io.WriteString(w, `{"id": "project-id"}`)
+		case "GET /context":
+			io.WriteString(w, `{"items": [{"id": "1", "name": "deploy"}, {"id": "2", "name": "stale"}]}`)
+		case "GET /context/1/restrictions":
+			io.WriteString(w, `{"items": [
+				{"id": "r1", "restriction_type": "group", "restriction_value": "admins"},
+				{"id": "r2", "restriction_type": "group", "restriction_value": "everyone"},
+				{"id": "r3", "restriction_type": "project", "restriction_value": "project-id"}]}`)
+		case "GET /context/2/restrictions":
+			io.WriteString(w, `{"items": [
+				{"id": "r4", "restriction_type": "project", "restriction_value": "other-id"},
+				{"id": "r5", "restriction_type": "project", "restriction_value": "project-id"}]}`)
+		default:
+			io.WriteString(w, `{"message": "ok"}`)
+		}
+	}))
+	defer svr.Close()
+
+	client := circleci.NewHTTPClient(svr.URL, nil)
+	client.HTTP = svr.Client()
+	contexts := circleci.NewContextsWithClient("github", "test", client)
+	deploy := circleci.Context{ID: "1", Name: "deploy"}
+	ctx := context.Background()
+
+	if err := restrictGroups(ctx, contexts, deploy, []string{"admins", "release"}, false); err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if err := restrictGroups(ctx, contexts, deploy, []string{"admins"}, true); err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	project := circleci.NewProjectV2WithClient("gh", "test", "test", client, client)
+	if err := detachContexts(ctx, contexts, project, []string{"deploy"}, nil); err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+
+	expected := []string{
+		`POST /context/1/restrictions {"restriction_type":"group","restriction_value":"release"}`,
+		"DELETE /context/1/restrictions/r2 ",
+		"DELETE /context/2/restrictions/r5 ",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected calls %q, found %q", expected, calls)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// sshKeyDeduper is what dedupe-keys needs of a project.
+type sshKeyDeduper interface {
+	FullName() string
+	GetSSHKeys(ctx context.Context) ([]circleci.SSHKey, error)
+	DeleteSSHKey(ctx context.Context, hostname, fingerprint string) error
+}
+
+// duplicateSSHKeys returns the keys to remove so that each hostname with a
+// configured fingerprint is left with only that key. Hostnames that are not
+// configured, or whose configured key is not on the project, are left alone
+// as there is no way to tell which of their keys is wanted. Keys in
+// overlapping are previous keys still in their rotation window and are kept.
+func duplicateSSHKeys(keys []circleci.SSHKey, configured map[string]string, overlapping map[string][]string) []circleci.SSHKey {
+	byHostname := make(map[string][]circleci.SSHKey)
+	for _, key := range keys {
+		byHostname[key.Hostname] = append(byHostname[key.Hostname], key)
+	}
+	hostnames := make([]string, 0, len(byHostname))
+	for hostname := range byHostname {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	var duplicates []circleci.SSHKey
+	for _, hostname := range hostnames {
+		group := byHostname[hostname]
+		fingerprint, ok := configured[hostname]
+		if len(group) < 2 || !ok {
+			continue
+		}
+		kept := false
+		var others []circleci.SSHKey
+		for _, key := range group {
+			if key.Fingerprint == fingerprint && !kept {
+				kept = true
+			} else if !containsName(overlapping[hostname], key.Fingerprint) {
+				others = append(others, key)
+			}
+		}
+		if !kept {
+			logWarnf("Not removing SSH keys for %s: none of them matches the configured key", hostname)
+			continue
+		}
+		duplicates = append(duplicates, others...)
+	}
+	return duplicates
+}
+
+// configuredFingerprints returns the fingerprints of the config's SSH keys,
+// keyed by hostname.
+func configuredFingerprints(sshKeys map[string]string) (map[string]string, error) {
+	fingerprints := make(map[string]string, len(sshKeys))
+	for hostname, path := range sshKeys {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read SSH key at path %s: %v", path, err)
+		}
+		fingerprints[hostname], err = sshKeyFingerprint(content)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse SSH key at path %s: %v", path, err)
+		}
+	}
+	return fingerprints, nil
+}
+
+// dedupeSSHKeys removes the duplicate SSH keys of the project, once approve
+// agrees if it is set, and returns them. Nothing is removed if dryRun is set.
+func dedupeSSHKeys(ctx context.Context, project sshKeyDeduper, configured map[string]string, overlapping map[string][]string,
+	dryRun bool, approve confirmFunc) ([]circleci.SSHKey, error) {
+	keys, err := project.GetSSHKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get SSH keys of project %s: %v", project.FullName(), err)
+	}
+	duplicates := duplicateSSHKeys(keys, configured, overlapping)
+	if !dryRun && approve != nil && len(duplicates) > 0 {
+		changes := make([]string, len(duplicates))
+		for i, key := range duplicates {
+			changes[i] = fmt.Sprintf("%s %s %s (%s)", opRemove, circleci.ResourceSSHKey, key.Hostname, key.Fingerprint)
+		}
+		if err := approve(project.FullName(), changes); err != nil {
+			return nil, fmt.Errorf("could not get approval to remove duplicate SSH keys of project %s: %v",
+				project.FullName(), err)
+		}
+	}
+	for _, key := range duplicates {
+		if dryRun {
+			logInfof("Would remove duplicate SSH key %s for %s", key.Fingerprint, key.Hostname)
+			continue
+		}
+		logInfof("Removing duplicate SSH key %s for %s", key.Fingerprint, key.Hostname)
+		err = project.DeleteSSHKey(ctx, key.Hostname, key.Fingerprint)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return duplicates, nil
+}
+
+func runDedupeKeys(args []string) error {
+	fs := flag.NewFlagSet("dedupe-keys", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "Print the keys that would be removed without removing them")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	config, err := s.config()
+	if err != nil {
+		return err
+	}
+	configured, err := configuredFingerprints(config.SSHKeys)
+	if err != nil {
+		return err
+	}
+	overlapping, err := overlappingFingerprints(config, time.Now())
+	if err != nil {
+		return err
+	}
+	s, err = s.forConfig(config)
+	if err != nil {
+		return err
+	}
+	project := s.project(config.VcsType, config.Owner, config.ProjectName)
+	approve := approver(s.ctx, s.approval.merge(config.Approval), "dedupe-keys")
+	duplicates, err := dedupeSSHKeys(s.ctx, project, configured, overlapping, *dryRun, approve)
+	if err != nil {
+		return err
+	}
+	logInfof("Found %d duplicate SSH keys in project %s", len(duplicates), project.FullName())
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+type fakeSSHKeyDeduper struct {
+	keys    []circleci.SSHKey
+	deleted []circleci.SSHKey
+}
+
+func (p *fakeSSHKeyDeduper) FullName() string { return "owner/project" }
+
+func (p *fakeSSHKeyDeduper) GetSSHKeys(ctx context.Context) ([]circleci.SSHKey, error) {
+	return p.keys, nil
+}
+
+func (p *fakeSSHKeyDeduper) DeleteSSHKey(ctx context.Context, hostname, fingerprint string) error {
+	p.deleted = append(p.deleted, circleci.SSHKey{Hostname: hostname, Fingerprint: fingerprint})
+	return nil
+}
+
+func TestDuplicateSSHKeys(t *testing.T) {
+	keys := []circleci.SSHKey{
+		{Hostname: "github.com", Fingerprint: "old"},
+		{Hostname: "github.com", Fingerprint: "current"},
+		{Hostname: "github.com", Fingerprint: "older"},
+		{Hostname: "gitlab.com", Fingerprint: "a"},
+		{Hostname: "gitlab.com", Fingerprint: "b"},
+		{Hostname: "bitbucket.org", Fingerprint: "x"},
+		{Hostname: "bitbucket.org", Fingerprint: "y"},
+		{Hostname: "example.com", Fingerprint: "only"},
+	}
+	configured := map[string]string{
+		"github.com":    "current",
+		"bitbucket.org": "missing",
+		"example.com":   "only",
+	}
+
+	duplicates := duplicateSSHKeys(keys, configured, nil)
+	expected := []circleci.SSHKey{{Hostname: "github.com", Fingerprint: "old"}, {Hostname: "github.com", Fingerprint: "older"}}
+	if !reflect.DeepEqual(duplicates, expected) {
+		t.Errorf("Expected duplicates %v, found %v", expected, duplicates)
+	}
+}
+
+func TestDedupeSSHKeys(t *testing.T) {
+	project := &fakeSSHKeyDeduper{keys: []circleci.SSHKey{
+		{Hostname: "github.com", Fingerprint: "old"},
+		{Hostname: "github.com", Fingerprint: "current"},
+	}}
+	configured := map[string]string{"github.com": "current"}
+
+	_, err := dedupeSSHKeys(context.Background(), project, configured, nil, true, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if len(project.deleted) != 0 {
+		t.Errorf("Expected a dry run to remove nothing, found %v", project.deleted)
+	}
+
+	deny := func(string, []string) error { return fmt.Errorf("denied") }
+	_, err = dedupeSSHKeys(context.Background(), project, configured, nil, false, deny)
+	if err == nil || len(project.deleted) != 0 {
+		t.Errorf("Expected nothing to be removed without approval, found %v (%v)", project.deleted, err)
+	}
+
+	var approved []string
+	approve := func(_ string, changes []string) error {
+		approved = changes
+		return nil
+	}
+	duplicates, err := dedupeSSHKeys(context.Background(), project, configured, nil, false, approve)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := []circleci.SSHKey{{Hostname: "github.com", Fingerprint: "old"}}
+	if !reflect.DeepEqual(duplicates, expected) || !reflect.DeepEqual(project.deleted, expected) {
+		t.Errorf("Expected %v to be removed, found %v", expected, project.deleted)
+	}
+	if !reflect.DeepEqual(approved, []string{"remove ssh-key github.com (old)"}) {
+		t.Errorf("Expected the removal to be approved, found %q", approved)
+	}
+}
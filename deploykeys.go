@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+	"golang.org/x/crypto/ssh"
+)
+
+// Access a deploy key can be given to its repository.
+const (
+	deployKeyReadOnly  = "read-only"
+	deployKeyReadWrite = "read-write"
+)
+
+// resourceDeployKey is the report resource of deploy keys, which live on
+// GitHub rather than CircleCI.
+const resourceDeployKey = "deploy-key"
+
+// deployKeyRegistry lists and adds the deploy keys of a repository.
+type deployKeyRegistry interface {
+	DeployKeys(ctx context.Context, owner, repo string) ([]GitHubDeployKey, error)
+	AddDeployKey(ctx context.Context, owner, repo string, key GitHubDeployKey) error
+}
+
+// collectDeployKeys parses the access of the keys of specs to register as
+// deploy keys into the config. Only the current key of a hostname can be.
+func (c *Config) collectDeployKeys(specs map[string]sshKeySpecs) error {
+	for hostname, keys := range specs {
+		for _, spec := range keys {
+			switch {
+			case spec.DeployKey == "":
+				continue
+			case spec.ActiveUntil != "":
+				return fmt.Errorf("invalid SSH keys of %s: only the current key can be a deploy key", hostname)
+			case spec.DeployKey != deployKeyReadOnly && spec.DeployKey != deployKeyReadWrite:
+				return fmt.Errorf("invalid SSH keys of %s: deployKey must be %s or %s, found %s",
+					hostname, deployKeyReadOnly, deployKeyReadWrite, spec.DeployKey)
+			}
+			if c.DeployKeys == nil {
+				c.DeployKeys = make(map[string]string)
+			}
+			c.DeployKeys[hostname] = spec.DeployKey
+		}
+	}
+	return nil
+}
+
+// deployPublicKey returns the public key of the current key of hostname, or
+// nil if it is generated and neither generated by this run nor written to a
+// file.
+func deployPublicKey(config Config, hostname string, generated map[string][]byte) (ssh.PublicKey, error) {
+	if path, ok := config.SSHKeys[hostname]; ok {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read SSH key at path %s: %v", path, err)
+		}
+		signer, err := ssh.ParsePrivateKey(content)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse SSH key at path %s: %v", path, err)
+		}
+		return signer.PublicKey(), nil
+	}
+	content := generated[hostname]
+	if path := config.GeneratedSSHKeys[hostname].PublicKey; content == nil && path != "" {
+		var err error
+		content, err = ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read public key at path %s: %v", path, err)
+		}
+	}
+	if content == nil {
+		return nil, nil
+	}
+	public, _, _, _, err := ssh.ParseAuthorizedKey(content)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key of %s: %v", hostname, err)
+	}
+	return public, nil
+}
+
+// registerDeployKeys adds the public keys of the hostnames registered as
+// deploy keys to the project's GitHub repository, unless it already has them.
+// Each key is recorded in report.
+func registerDeployKeys(ctx context.Context, registry deployKeyRegistry, project circleci.Project, config Config,
+	generated map[string][]byte, report *Report) error {
+	name := project.FullName()
+	if !isGitHub(config.VcsType) {
+		logWarnf("Not registering the deploy keys of %s, only GitHub is supported", name)
+		return nil
+	}
+	if registry == nil {
+		logWarnf("Not registering the deploy keys of %s, pass -github-token or set GITHUB_TOKEN", name)
+		return nil
+	}
+	logInfof("Registering deploy keys of %s", name)
+	existing, err := registry.DeployKeys(ctx, config.Owner, config.ProjectName)
+	if err != nil {
+		return err
+	}
+	registered := make(map[string]bool)
+	for _, key := range existing {
+		if public, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key.Key)); err == nil {
+			registered[string(public.Marshal())] = true
+		}
+	}
+
+	var errs []error
+	for _, hostname := range sortedKeys(config.DeployKeys) {
+		public, err := deployPublicKey(config, hostname, generated)
+		if err == nil && public == nil {
+			logWarnf("Not registering the deploy key %s of %s, its public key is unknown, set publicKey to keep it",
+				hostname, name)
+			report.Record(name, resourceDeployKey, hostname, outcomeSkipped, nil)
+			continue
+		}
+		if err == nil && registered[string(public.Marshal())] {
+			logInfof("Deploy key %s of %s is already registered", hostname, name)
+			continue
+		}
+		if err == nil {
+			logInfof("Registering deploy key %s of %s", hostname, name)
+			err = registry.AddDeployKey(ctx, config.Owner, config.ProjectName, GitHubDeployKey{
+				Title:    "CircleCI " + hostname,
+				Key:      string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(public))),
+				ReadOnly: config.DeployKeys[hostname] == deployKeyReadOnly,
+			})
+		}
+		report.Record(name, resourceDeployKey, hostname, outcomeCreated, err)
+		errs = append(errs, err)
+	}
+	return joinErrors(errs)
+}
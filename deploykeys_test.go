@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestReadDeployKeys(t *testing.T) {
+	data := `
+sshKeys:
+  example.com: example.com.key
+  github.com:
+    - path: new.key
+      deployKey: read-write
+    - path: old.key
+      activeUntil: 2026-11-01
+  deploy.com:
+    generate: ed25519
+    deployKey: read-only
+`
+	var config Config
+	err := yaml.Unmarshal([]byte(data), &config)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := map[string]string{"github.com": deployKeyReadWrite, "deploy.com": deployKeyReadOnly}
+	if !reflect.DeepEqual(config.DeployKeys, expected) {
+		t.Errorf("Expected deploy keys %v, found %v", expected, config.DeployKeys)
+	}
+
+	invalid := []string{
+		"sshKeys:\n  github.com:\n    path: a.key\n    deployKey: yes\n",
+		"sshKeys:\n  github.com:\n    - path: a.key\n    - path: b.key\n      activeUntil: 2026-11-01\n      deployKey: read-only\n",
+	}
+	for _, data := range invalid {
+		if err := yaml.Unmarshal([]byte(data), &Config{}); err == nil {
+			t.Errorf("Expected an error reading %q", data)
+		}
+	}
+}
+
+func TestRegisterDeployKeys(t *testing.T) {
+	path, _ := writeTestSSHKey(t)
+	defer os.Remove(path)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.ParsePrivateKey(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, generated, err := generateSSHKey(sshKeyED25519, "git/test/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, registered, err := generateSSHKey(sshKeyED25519, "git/test/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var added []GitHubDeployKey
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/keys" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Method == http.MethodGet {
+			fmt.Fprintf(w, `[{"title": "CircleCI registered.com", "key": %q, "read_only": true}]`, registered)
+			return
+		}
+		var key GitHubDeployKey
+		if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+			t.Errorf("Could not decode deploy key: %v", err)
+		}
+		added = append(added, key)
+		w.WriteHeader(http.StatusCreated)
+	})
+	svr := httptest.NewServer(handler)
+	defer svr.Close()
+	github := &GitHubClient{svr.URL, "token", svr.Client()}
+
+	config := Config{VcsType: "github", Owner: "owner", ProjectName: "repo",
+		SSHKeys: SSHKeys{"github.com": path},
+		GeneratedSSHKeys: map[string]GeneratedSSHKey{
+			"deploy.com":     {sshKeyED25519, ""},
+			"registered.com": {sshKeyED25519, ""},
+			"unknown.com":    {sshKeyED25519, ""},
+		},
+		DeployKeys: map[string]string{"github.com": deployKeyReadWrite, "deploy.com": deployKeyReadOnly,
+			"registered.com": deployKeyReadOnly, "unknown.com": deployKeyReadOnly},
+	}
+	publicKeys := map[string][]byte{"deploy.com": generated, "registered.com": registered}
+	report := NewReport(nil)
+	circleCI := newFakeCircleCI(nil)
+	defer circleCI.Close()
+	err = registerDeployKeys(context.Background(), github, circleCI.project(), config, publicKeys, report)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+
+	public, _, _, _, err := ssh.ParseAuthorizedKey(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorizedKey := func(key ssh.PublicKey) string {
+		return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+	}
+	expected := []GitHubDeployKey{
+		{"CircleCI deploy.com", authorizedKey(public), true},
+		{"CircleCI github.com", authorizedKey(signer.PublicKey()), false},
+	}
+	if !reflect.DeepEqual(added, expected) {
+		t.Errorf("Expected deploy keys %v to be added, found %v", expected, added)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// Kinds of drift between the config and a project's live state.
+const (
+	driftMissing = "missing" // In the config but not on the project
+	driftExtra   = "extra"   // On the project but not in the config
+	driftChanged = "changed" // On both but different
+)
+
+// errDrift is returned by diff when the project has drifted from its config.
+var errDrift = errors.New("project has drifted from its config")
+
+// Drift is a difference between the config and a project's live state.
+type Drift struct {
+	Resource string
+	Name     string
+	Kind     string
+	Detail   string
+}
+
+// DriftReport lists every difference found for a project.
+type DriftReport []Drift
+
+// maskPrefix is what CircleCI replaces all but the last few characters of
+// environment variable values with.
+const maskPrefix = "xxxx"
+
+// maskedMatches reports whether a masked value could be the configured value,
+// by comparing the characters CircleCI leaves unmasked.
+func maskedMatches(masked, value string) bool {
+	return strings.HasSuffix(value, strings.TrimPrefix(masked, maskPrefix))
+}
+
+// sshKeyFingerprint returns the MD5 fingerprint CircleCI shows for a private key.
+func sshKeyFingerprint(privateKey []byte) (string, error) {
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return ssh.FingerprintLegacyMD5(signer.PublicKey()), nil
+}
+
+// computeDrift compares the config with the project's live state.
+func computeDrift(config Config, state ProjectState) (DriftReport, error) {
+	var report DriftReport
+	if !state.Following {
+		report = append(report, Drift{circleci.ResourceFollow, "", driftMissing, "project is not followed"})
+	}
+
+	for _, name := range sortedKeys(config.EnvVars) {
+		masked, ok := state.EnvVars[name]
+		if !ok {
+			report = append(report, Drift{circleci.ResourceEnvVar, name, driftMissing, ""})
+		} else if !maskedMatches(masked, config.EnvVars[name]) {
+			report = append(report, Drift{circleci.ResourceEnvVar, name, driftChanged, "value " + masked + " differs"})
+		}
+	}
+	for _, name := range sortedKeys(state.EnvVars) {
+		if _, ok := config.EnvVars[name]; !ok {
+			report = append(report, Drift{circleci.ResourceEnvVar, name, driftExtra, ""})
+		}
+	}
+
+	live := make(map[string][]string)
+	for _, key := range state.SSHKeys {
+		live[key.Hostname] = append(live[key.Hostname], key.Fingerprint)
+	}
+	for _, hostname := range sortedKeys(config.SSHKeys) {
+		content, err := ioutil.ReadFile(config.SSHKeys[hostname])
+		if err != nil {
+			return nil, fmt.Errorf("could not read SSH key at path %s: %v", config.SSHKeys[hostname], err)
+		}
+		fingerprint, err := sshKeyFingerprint(content)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse SSH key at path %s: %v", config.SSHKeys[hostname], err)
+		}
+		fingerprints, ok := live[hostname]
+		if !ok {
+			report = append(report, Drift{circleci.ResourceSSHKey, hostname, driftMissing, fingerprint})
+			continue
+		}
+		found := false
+		for _, f := range fingerprints {
+			found = found || f == fingerprint
+		}
+		if !found {
+			report = append(report, Drift{circleci.ResourceSSHKey, hostname, driftChanged,
+				fmt.Sprintf("fingerprint %s, configured %s", strings.Join(fingerprints, ", "), fingerprint)})
+		}
+	}
+	for _, hostname := range config.generatedHostnames() {
+		if _, ok := live[hostname]; !ok {
+			report = append(report, Drift{circleci.ResourceSSHKey, hostname, driftMissing, "generated"})
+		}
+	}
+	hostnames := make([]string, 0, len(live))
+	for hostname := range live {
+		if _, ok := config.SSHKeys[hostname]; !ok && !config.generatesSSHKey(hostname) {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	sort.Strings(hostnames)
+	for _, hostname := range hostnames {
+		report = append(report, Drift{circleci.ResourceSSHKey, hostname, driftExtra, strings.Join(live[hostname], ", ")})
+	}
+	return report, nil
+}
+
+var driftSymbols = map[string]string{
+	driftMissing: "-",
+	driftExtra:   "+",
+	driftChanged: "~",
+}
+
+// Print writes a human readable description of the drift to w.
+func (report DriftReport) Print(w io.Writer, projectName string) {
+	report.print(w, "project "+projectName)
+}
+
+// print writes the drift of subject (e.g. project nick96/test) to w.
+func (report DriftReport) print(w io.Writer, subject string) {
+	if len(report) == 0 {
+		fmt.Fprintf(w, "No drift for %s\n", subject)
+		return
+	}
+	fmt.Fprintf(w, "Drift for %s:\n", subject)
+	for _, drift := range report {
+		symbol := driftSymbols[drift.Kind]
+		line := fmt.Sprintf("  %s %s", paint(w, symbolColors[symbol], symbol), drift.Resource)
+		if drift.Name != "" {
+			line += " " + drift.Name
+		}
+		line += ": " + drift.Kind
+		if drift.Detail != "" {
+			line += " (" + drift.Detail + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	config, err := s.config()
+	if err != nil {
+		return err
+	}
+	s, err = s.forConfig(config)
+	if err != nil {
+		return err
+	}
+	project := s.v2Project(config.VcsType, config.Owner, config.ProjectName)
+	state, err := fetchState(s.ctx, project)
+	if err != nil {
+		return err
+	}
+	report, err := computeDrift(config, state)
+	if err != nil {
+		return err
+	}
+	report.Print(s.stdout, project.FullName())
+	if len(report) > 0 {
+		return errDrift
+	}
+	return nil
+}
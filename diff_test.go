@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+func TestComputeDrift(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	fh, err := ioutil.TempFile("", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fh.Name())
+	fh.Write(keyPEM)
+	fh.Close()
+	fingerprint, err := sshKeyFingerprint(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{
+		EnvVars: map[string]string{"SAME": "value1234", "CHANGED": "value1234", "MISSING": "x"},
+		SSHKeys: map[string]string{"ok.example.com": fh.Name(), "rotated.example.com": fh.Name()},
+	}
+	state := ProjectState{
+		Following: true,
+		EnvVars:   map[string]string{"SAME": "xxxx1234", "CHANGED": "xxxx9999", "EXTRA": "xxxx0000"},
+		SSHKeys: []circleci.SSHKey{
+			{Hostname: "ok.example.com", Fingerprint: fingerprint},
+			{Hostname: "rotated.example.com", Fingerprint: "aa:bb"},
+			{Hostname: "extra.example.com", Fingerprint: "cc:dd"},
+		},
+	}
+
+	report, err := computeDrift(config, state)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := DriftReport{
+		{circleci.ResourceEnvVar, "CHANGED", driftChanged, "value xxxx9999 differs"},
+		{circleci.ResourceEnvVar, "MISSING", driftMissing, ""},
+		{circleci.ResourceEnvVar, "EXTRA", driftExtra, ""},
+		{circleci.ResourceSSHKey, "rotated.example.com", driftChanged, "fingerprint aa:bb, configured " + fingerprint},
+		{circleci.ResourceSSHKey, "extra.example.com", driftExtra, "cc:dd"},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Expected drift %v, found %v", expected, report)
+	}
+
+	report, err = computeDrift(Config{}, ProjectState{})
+	if err != nil || !reflect.DeepEqual(report, DriftReport{{circleci.ResourceFollow, "", driftMissing, "project is not followed"}}) {
+		t.Errorf("Expected unfollowed project to drift, found %v, %v", report, err)
+	}
+}
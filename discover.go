@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// splitList splits a comma separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// discoverConfig describes discovering the repos of the org carrying one of
+// the topics or with a name matching one of the patterns, all provisioned
+// with the same config.
+func discoverConfig(org, topics, names, configFile string) (SyncConfig, error) {
+	if org == "" {
+		return SyncConfig{}, fmt.Errorf("-org is required")
+	}
+	if configFile == "" {
+		return SyncConfig{}, fmt.Errorf("-config is required or CIRCLECI_CONFIG should be set")
+	}
+	profile := SyncProfile{Name: "discover", Topics: splitList(topics), Names: splitList(names), Config: configFile}
+	if len(profile.Topics) == 0 && len(profile.Names) == 0 {
+		return SyncConfig{}, fmt.Errorf("-topic or -name is required, to provision every repo use -name '*'")
+	}
+	for _, pattern := range profile.Names {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return SyncConfig{}, fmt.Errorf("invalid -name %q: %v", pattern, err)
+		}
+	}
+	return SyncConfig{VcsType: "gh", Owner: org, Profiles: []SyncProfile{profile}}, nil
+}
+
+func runDiscover(args []string) error {
+	fs := flag.NewFlagSet("discover", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	flags := addSyncFlags(fs)
+	org := fs.String("org", os.Getenv("CIRCLECI_DISCOVER_ORG"), "GitHub organisation whose repos are discovered")
+	topics := fs.String("topic", "", "Provision repos carrying any of these comma separated topics")
+	names := fs.String("name", "", "Provision repos whose name matches any of these comma separated globs, e.g. svc-*")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	syncConfig, err := discoverConfig(*org, *topics, *names, *common.configFile)
+	if err != nil {
+		fs.Usage()
+		return err
+	}
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	err = flags.sync(s, syncConfig)
+	if err != nil {
+		return err
+	}
+	logInfof("Discovered repos of %s have been successfully provisioned", *org)
+	return nil
+}
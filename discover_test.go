@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestDiscoverConfig(t *testing.T) {
+	config, err := discoverConfig("acme", "service, go", "svc-*,", "project.yml")
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	profile := config.Profiles[0]
+	if config.Owner != "acme" || len(profile.Topics) != 2 || profile.Topics[1] != "go" ||
+		len(profile.Names) != 1 || profile.Config != "project.yml" {
+		t.Errorf("Unexpected sync config %+v", config)
+	}
+
+	for _, args := range [][4]string{
+		{"", "service", "", "project.yml"},
+		{"acme", "", "", "project.yml"},
+		{"acme", "service", "", ""},
+		{"acme", "", "[", "project.yml"},
+	} {
+		if _, err := discoverConfig(args[0], args[1], args[2], args[3]); err == nil {
+			t.Errorf("Expected an error for %q", args)
+		}
+	}
+}
+
+func TestDiscoverProvisionsMatchingRepos(t *testing.T) {
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "1" {
+			io.WriteString(w, `[{"name": "svc-a"}, {"name": "tools"}, {"name": "svc-b", "archived": true}]`)
+			return
+		}
+		io.WriteString(w, `[]`)
+	}))
+	defer github.Close()
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"POST /project/git/test/test/follow": {Status: http.StatusCreated, Body: `{"following": true}`},
+		"GET /project/git/test/test/envvar":  {Status: http.StatusOK, Body: `[]`},
+		"POST /project/git/test/test/envvar": {Status: http.StatusCreated, Body: `{}`},
+	})
+	defer svr.Close()
+
+	fh, err := ioutil.TempFile("", "project*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fh.Name())
+	fh.WriteString("version: 1\nenvVars:\n  SERVICE: '{{ .repo.owner }}/{{ .repo.name }}'\n")
+	fh.Close()
+
+	syncConfig, err := discoverConfig("acme", "", "svc-*", fh.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var provisioned []string
+	newProject := func(vcsType, owner, projectName string) circleci.Project {
+		provisioned = append(provisioned, owner+"/"+projectName)
+		return svr.project()
+	}
+	err = syncRepos(context.Background(), syncConfig, configOptions{}, &GitHubClient{github.URL, "token", github.Client()},
+		newProject, provisionOptions{report: NewReport(nil)})
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if len(provisioned) != 1 || provisioned[0] != "acme/svc-a" {
+		t.Errorf("Expected only acme/svc-a to be provisioned, found %v", provisioned)
+	}
+	set := false
+	for _, request := range svr.Requests() {
+		set = set || strings.HasPrefix(request, "POST /project/git/test/test/envvar") && strings.Contains(request, "acme/svc-a")
+	}
+	if !set {
+		t.Errorf("Expected the config to be rendered for the repo, found requests %v", svr.Requests())
+	}
+}
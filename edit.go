@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// editorCommand returns the command line of the user's editor: $VISUAL,
+// then $EDITOR, then vi.
+func editorCommand(getenv func(string) string) []string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(getenv(name)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
+
+// runEditor opens file in the user's editor and waits for it to exit.
+func runEditor(file string) error {
+	command := editorCommand(os.Getenv)
+	cmd := exec.Command(command[0], append(command[1:], file)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %v", command[0], err)
+	}
+	return nil
+}
+
+// editConfig copies the config file to a scratch file next to it, so that
+// relative paths in it still resolve, and has edit change it until read
+// accepts it or the user gives up. It returns the scratch file, which the
+// caller removes, and the config read from it.
+func editConfig(file string, edit func(string) error, read func(string) (Config, error), prompt prompter) (string, Config, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", Config{}, err
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", Config{}, err
+	}
+	if isSOPSEncrypted(data) {
+		return "", Config{}, fmt.Errorf("%s is SOPS encrypted, edit it with sops instead", file)
+	}
+	fh, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".*"+filepath.Ext(file))
+	if err != nil {
+		return "", Config{}, err
+	}
+	scratch := fh.Name()
+	_, err = fh.Write(data)
+	if err == nil {
+		err = fh.Chmod(info.Mode())
+	}
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(scratch)
+		return "", Config{}, err
+	}
+
+	for {
+		if err := edit(scratch); err != nil {
+			os.Remove(scratch)
+			return "", Config{}, err
+		}
+		config, err := read(scratch)
+		if err == nil {
+			return scratch, config, nil
+		}
+		logErrorf("Invalid config: %v", err)
+		again, perr := confirm(prompt, "Edit it again?")
+		if perr != nil || !again {
+			os.Remove(scratch)
+			if perr != nil {
+				return "", Config{}, perr
+			}
+			return "", Config{}, fmt.Errorf("%s was left unchanged as the edited config is invalid", file)
+		}
+	}
+}
+
+func runEdit(args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	flags := addProvisionFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s edit [flags] [CONFIG]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	file := *common.configFile
+	if fs.NArg() == 1 {
+		file = fs.Arg(0)
+	} else if fs.NArg() > 1 || file == "" {
+		fs.Usage()
+		return fmt.Errorf("edit takes exactly one config")
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	opts, err := flags.options(s.events)
+	if err != nil {
+		return err
+	}
+
+	prompt := newTerminalPrompter()
+	scratch, config, err := editConfig(file, runEditor, s.readConfig, prompt)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(scratch)
+
+	s, err = s.forConfig(config)
+	if err != nil {
+		return err
+	}
+	project := s.project(config.VcsType, config.Owner, config.ProjectName)
+	state, err := fetchPlanState(s.ctx, project, config, opts)
+	if err != nil {
+		return err
+	}
+	computePlan(config, state, opts).Print(s.stdout, project.FullName())
+
+	apply := *flags.assumeYes
+	if !apply {
+		apply, err = confirm(prompt, "Save and apply these changes?")
+		if err != nil {
+			return err
+		}
+	}
+	if !apply {
+		keep, err := confirm(prompt, fmt.Sprintf("Save the changes to %s without applying them?", file))
+		if err != nil || !keep {
+			return err
+		}
+	}
+	if err := os.Rename(scratch, file); err != nil {
+		return fmt.Errorf("could not save %s: %v", file, err)
+	}
+	logInfof("Saved %s", file)
+	if !apply {
+		return nil
+	}
+	// The plan printed above already showed what will be removed.
+	opts.confirm = nil
+	err = flags.provisionConfig(s, config, file, opts)
+	s.printReport(opts.report)
+	return err
+}
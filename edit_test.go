@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEditorCommand(t *testing.T) {
+	testCases := []struct {
+		env      map[string]string
+		expected []string
+	}{
+		{map[string]string{"VISUAL": "code -w", "EDITOR": "nano"}, []string{"code", "-w"}},
+		{map[string]string{"VISUAL": " ", "EDITOR": "nano"}, []string{"nano"}},
+		{map[string]string{}, []string{"vi"}},
+	}
+	for _, tc := range testCases {
+		actual := editorCommand(func(name string) string { return tc.env[name] })
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("Expected %v for %v, found %v", tc.expected, tc.env, actual)
+		}
+	}
+}
+
+func TestEditConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "project.yml")
+	original := "version: 1\nvcsType: gh\nowner: test\nprojectName: test\n"
+	if err := ioutil.WriteFile(file, []byte(original), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first edit is invalid, the second fixes it.
+	edits := []string{"envVars: [\n", original + "envVars:\n  A: \"1\"\n"}
+	edit := func(path string) error {
+		err := ioutil.WriteFile(path, []byte(edits[0]), 0600)
+		edits = edits[1:]
+		return err
+	}
+	read := func(path string) (Config, error) { return readConfig(path, configOptions{noTemplate: true}) }
+
+	scratch, config, err := editConfig(file, edit, read, fakePrompter{"again": "y"})
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	defer os.Remove(scratch)
+	if config.EnvVars["A"] != "1" || len(edits) != 0 {
+		t.Errorf("Expected the second edit to be read, found %+v", config)
+	}
+	if filepath.Dir(scratch) != dir || filepath.Ext(scratch) != ".yml" {
+		t.Errorf("Expected a scratch file next to the config, found %s", scratch)
+	}
+	if info, err := os.Stat(scratch); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("Expected the scratch file to keep the config's mode, found %v (%v)", info.Mode(), err)
+	}
+	if data, _ := ioutil.ReadFile(file); string(data) != original {
+		t.Errorf("Expected the config to be left alone until saved, found %q", data)
+	}
+
+	edits = []string{"envVars: [\n"}
+	_, _, err = editConfig(file, edit, read, fakePrompter{"again": "n"})
+	if err == nil {
+		t.Error("Expected an error when giving up on an invalid edit")
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, ".project.yml.*"))
+	if len(matches) != 1 {
+		t.Errorf("Expected the abandoned scratch file to be removed, found %v", matches)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// parseDotenv parses KEY=VALUE lines. Blank lines, # comments and a leading
+// "export " are ignored, and values may be single or double quoted.
+func parseDotenv(data []byte) (map[string]string, error) {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+		eq := strings.Index(text, "=")
+		if eq < 1 {
+			return nil, fmt.Errorf("line %d: expected NAME=VALUE", line)
+		}
+		name := strings.TrimSpace(text[:eq])
+		value := strings.TrimSpace(text[eq+1:])
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid quoted value: %v", line, err)
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		}
+		vars[name] = value
+	}
+	return vars, scanner.Err()
+}
+
+// readEnvFile reads the env vars in a .json file holding an object of
+// strings, or in a dotenv file otherwise.
+func readEnvFile(file string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(file) != ".json" {
+		return parseDotenv(data)
+	}
+	var vars map[string]string
+	err = json.Unmarshal(data, &vars)
+	if err != nil {
+		return nil, fmt.Errorf("expected an object of strings: %v", err)
+	}
+	return vars, nil
+}
+
+// loadEnvFiles adds the env vars in files, relative to dir, to vars. Later
+// files override earlier ones, and vars already set are left as they are.
+func loadEnvFiles(vars map[string]string, dir string, files []string) error {
+	loaded := make(map[string]string)
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		fileVars, err := readEnvFile(file)
+		if err != nil {
+			return fmt.Errorf("could not read env file %s: %v", file, err)
+		}
+		for name, value := range fileVars {
+			loaded[name] = value
+		}
+	}
+	for name, value := range loaded {
+		if _, ok := vars[name]; !ok {
+			vars[name] = value
+		}
+	}
+	return nil
+}
+
+// expandEnvFiles loads the env files of the config and its contexts into
+// their env vars, so that they go through interpolation and secret resolution
+// like the rest.
+func expandEnvFiles(config *Config, dir string) error {
+	if len(config.EnvFiles) > 0 {
+		if config.EnvVars == nil {
+			config.EnvVars = make(EnvVars)
+		}
+		err := loadEnvFiles(config.EnvVars, dir, config.EnvFiles)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range config.Contexts {
+		ctx := &config.Contexts[i]
+		if len(ctx.EnvFiles) == 0 {
+			continue
+		}
+		if ctx.EnvVars == nil {
+			ctx.EnvVars = make(map[string]string)
+		}
+		err := loadEnvFiles(ctx.EnvVars, dir, ctx.EnvFiles)
+		if err != nil {
+			return fmt.Errorf("context %s: %v", ctx.Name, err)
+		}
+	}
+	return nil
+}
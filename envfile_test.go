@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	data := []byte(`# Shared credentials
+export A=1
+B = two words
+C="quoted \"value\""
+D='single $quoted'
+
+E=
+`)
+	vars, err := parseDotenv(data)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := map[string]string{"A": "1", "B": "two words", "C": `quoted "value"`, "D": "single $quoted", "E": ""}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, found %v", expected, vars)
+	}
+
+	_, err = parseDotenv([]byte("A=1\nnot a variable\n"))
+	if err == nil || err.Error() != "line 2: expected NAME=VALUE" {
+		t.Errorf("Expected an error for line 2, found: %v", err)
+	}
+}
+
+func TestReadConfigEnvFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"project.env":  "A=from file\nB=${ENVFILE_TEST_B}\n",
+		"shared.json":  `{"TOKEN": "${ENVFILE_TEST_B}", "REGION": "eu"}`,
+		"override.env": "REGION=us\n",
+		"config.yml": `envFiles: [project.env]
+envVars:
+  A: from config
+contexts:
+  - name: shared
+    envFiles: [shared.json, override.env]
+`,
+	}
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Setenv("ENVFILE_TEST_B", "b")
+	defer os.Unsetenv("ENVFILE_TEST_B")
+
+	config, err := readConfig(filepath.Join(dir, "config.yml"), configOptions{noTemplate: true})
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := EnvVars{"A": "from config", "B": "b"}
+	if !reflect.DeepEqual(config.EnvVars, expected) {
+		t.Errorf("Expected env vars %v, found %v", expected, config.EnvVars)
+	}
+	expectedContext := map[string]string{"TOKEN": "b", "REGION": "us"}
+	if !reflect.DeepEqual(config.Contexts[0].EnvVars, expectedContext) {
+		t.Errorf("Expected context env vars %v, found %v", expectedContext, config.Contexts[0].EnvVars)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "config.yml"), []byte("envFiles: [missing.env]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = readConfig(filepath.Join(dir, "config.yml"), configOptions{noTemplate: true})
+	if err == nil {
+		t.Error("Expected an error for a missing env file")
+	}
+}
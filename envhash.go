@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// envHashKeySize is the size of the key of a project's env var hashes.
+const envHashKeySize = 32
+
+// EnvHashes are the hashes of the values last set for a project's env vars,
+// so that values can be compared without being stored. They are HMACs keyed
+// with a secret of the project's hashes file, so that low-entropy values
+// cannot be brute-forced from the hashes alone.
+type EnvHashes struct {
+	key    []byte
+	hashes map[string]string // Keyed by name
+}
+
+// hash returns the hash of the env var's value.
+func (e *EnvHashes) hash(name, value string) string {
+	mac := hmac.New(sha256.New, e.key)
+	mac.Write([]byte(name + "\x00" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// record records the value the env var was set to.
+func (e *EnvHashes) record(name, value string) {
+	e.hashes[name] = e.hash(name, value)
+}
+
+// envVarUnchanged reports whether setting the env var to value can be
+// skipped, which is only when the hash of the value last set matches it and
+// CircleCI's masked value agrees. Without a recorded hash the env var is
+// always set, as the masked value only shows its last characters.
+func envVarUnchanged(name, value string, masked string, exists bool, hashes *EnvHashes) bool {
+	if !exists || hashes == nil || !maskedMatches(masked, value) {
+		return false
+	}
+	hash, ok := hashes.hashes[name]
+	return ok && hmac.Equal([]byte(hash), []byte(hashes.hash(name, value)))
+}
+
+// hashesPath returns the file holding the hashes of the project's env vars.
+func (h *History) hashesPath(project string) string {
+	return h.path(project) + ".hashes"
+}
+
+// hashKeyPath returns the file holding the key of the project's env var
+// hashes.
+func (h *History) hashKeyPath(project string) string {
+	return h.hashesPath(project) + ".key"
+}
+
+// EnvHashes returns the hashes of the values last set for the project's env
+// vars, creating their key the first time. Hashes recorded without a key are
+// dropped.
+func (h *History) EnvHashes(project string) (*EnvHashes, error) {
+	return h.envHashes(project, true)
+}
+
+// plannedEnvHashes returns the hashes EnvHashes would, without creating
+// their key, so that planning writes nothing. There are none, and so every
+// env var is set, if the key was never created.
+func (h *History) plannedEnvHashes(project string) (*EnvHashes, error) {
+	return h.envHashes(project, false)
+}
+
+func (h *History) envHashes(project string, create bool) (*EnvHashes, error) {
+	unlock, err := h.lock(project)
+	if err != nil {
+		return nil, fmt.Errorf("could not lock history for %s: %v", project, err)
+	}
+	defer unlock()
+
+	hashes := &EnvHashes{hashes: make(map[string]string)}
+	hashes.key, err = ioutil.ReadFile(h.hashKeyPath(project))
+	if os.IsNotExist(err) && !create {
+		return nil, nil
+	} else if os.IsNotExist(err) {
+		hashes.key = make([]byte, envHashKeySize)
+		if _, err := rand.Read(hashes.key); err != nil {
+			return nil, fmt.Errorf("could not generate env var hash key of %s: %v", project, err)
+		}
+		err = ioutil.WriteFile(h.hashKeyPath(project), hashes.key, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("could not write env var hash key of %s: %v", project, err)
+		}
+		return hashes, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read env var hash key of %s: %v", project, err)
+	}
+
+	data, err := ioutil.ReadFile(h.hashesPath(project))
+	if os.IsNotExist(err) {
+		return hashes, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read env var hashes of %s: %v", project, err)
+	}
+	err = json.Unmarshal(data, &hashes.hashes)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt env var hashes of %s: %v", project, err)
+	}
+	return hashes, nil
+}
+
+// SaveEnvHashes replaces the hashes of the project's env vars.
+func (h *History) SaveEnvHashes(project string, hashes *EnvHashes) error {
+	data, err := json.MarshalIndent(hashes.hashes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal env var hashes: %v", err)
+	}
+
+	unlock, err := h.lock(project)
+	if err != nil {
+		return fmt.Errorf("could not lock history for %s: %v", project, err)
+	}
+	defer unlock()
+	err = ioutil.WriteFile(h.hashesPath(project), append(data, '\n'), 0600)
+	if err != nil {
+		return fmt.Errorf("could not write env var hashes of %s: %v", project, err)
+	}
+	return nil
+}
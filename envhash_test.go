@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestEnvVarUnchanged(t *testing.T) {
+	hashes := &EnvHashes{key: []byte("key"), hashes: make(map[string]string)}
+	hashes.record("A", "secret-1234")
+	stale := &EnvHashes{key: []byte("key"), hashes: map[string]string{"A": "stale"}}
+	otherKey := &EnvHashes{key: []byte("other"), hashes: hashes.hashes}
+	type test struct {
+		name     string
+		masked   string
+		exists   bool
+		hashes   *EnvHashes
+		expected bool
+	}
+
+	testCases := []test{
+		{"new", "", false, hashes, false},
+		{"masked differs", "xxxx9999", true, hashes, false},
+		{"masked matches without hashes", "xxxx1234", true, nil, false},
+		{"masked matches without a hash", "xxxx1234", true, &EnvHashes{key: []byte("key")}, false},
+		{"hash matches", "xxxx1234", true, hashes, true},
+		{"hash differs", "xxxx1234", true, stale, false},
+		{"hash of another key", "xxxx1234", true, otherKey, false},
+	}
+
+	for _, tc := range testCases {
+		actual := envVarUnchanged("A", "secret-1234", tc.masked, tc.exists, tc.hashes)
+		if actual != tc.expected {
+			t.Errorf("%s: expected %v, found %v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestEnvHashes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	history, err := OpenHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashes, err := history.EnvHashes("gh/test/test")
+	if err != nil || len(hashes.hashes) != 0 || len(hashes.key) != envHashKeySize {
+		t.Fatalf("Expected a new key and no hashes yet, found %+v (%v)", hashes, err)
+	}
+	hashes.record("A", "1")
+	err = history.SaveEnvHashes("gh/test/test", hashes)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	saved, err := history.EnvHashes("gh/test/test")
+	if err != nil || !reflect.DeepEqual(saved, hashes) {
+		t.Errorf("Expected hashes %+v, found %+v (%v)", hashes, saved, err)
+	}
+	other, err := history.EnvHashes("gh/test/other")
+	if err != nil || reflect.DeepEqual(other.key, hashes.key) {
+		t.Errorf("Expected each project to have its own key, found %+v (%v)", other, err)
+	}
+}
+
+func TestSetEnvVarsSkipsUnchanged(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"POST /project/git/test/test/envvar": {Status: http.StatusCreated, Body: `{}`},
+	})
+	defer svr.Close()
+
+	project := svr.project()
+	envVars := map[string]string{"SAME": "value1234", "STALE": "new-5678", "CHANGED": "value9999", "NEW": "1",
+		"UNKNOWN": "value4321"}
+	existing := map[string]string{"SAME": "xxxx1234", "STALE": "xxxx5678", "CHANGED": "xxxx1234", "UNKNOWN": "xxxx4321"}
+	hashes := &EnvHashes{key: []byte("key"), hashes: make(map[string]string)}
+	hashes.record("SAME", "value1234")
+	hashes.record("STALE", "old-5678")
+	report := NewReport(nil)
+	err := setEnvVars(context.Background(), project, envVars, existing, hashes, 1, report)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+
+	if len(svr.Requests()) != 4 {
+		t.Errorf("Expected every env var but SAME to be set, found requests %v", svr.Requests())
+	}
+	for _, name := range []string{"STALE", "CHANGED", "NEW", "UNKNOWN"} {
+		if hashes.hashes[name] != hashes.hash(name, envVars[name]) {
+			t.Errorf("Expected the hash of %s to be updated, found %q", name, hashes.hashes[name])
+		}
+	}
+	if report.entries[2].name != "SAME" || report.entries[2].outcome != outcomeSkipped {
+		t.Errorf("Expected SAME to be skipped, found %+v", report.entries)
+	}
+}
+
+func TestSetEnvVarsWithoutHashes(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"POST /project/git/test/test/envvar": {Status: http.StatusCreated, Body: `{}`},
+	})
+	defer svr.Close()
+
+	// Only the last characters match, which does not mean the value is unchanged.
+	envVars := map[string]string{"A": "new-1234"}
+	existing := map[string]string{"A": "xxxx1234"}
+	err := setEnvVars(context.Background(), svr.project(), envVars, existing, nil, 1, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if len(svr.Requests()) != 1 {
+		t.Errorf("Expected the env var to be set, found requests %v", svr.Requests())
+	}
+}
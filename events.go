@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// eventsFormatNDJSON streams events as newline delimited JSON.
+const eventsFormatNDJSON = "ndjson"
+
+// Types of events.
+const (
+	eventProjectStarted  = "project_started"
+	eventResource        = "resource"
+	eventProjectFinished = "project_finished"
+	eventRunCancelled    = "run_cancelled"
+)
+
+// Event is something that happened during a run, streamed as it happens so
+// that wrappers can follow its progress.
+type Event struct {
+	Time     time.Time `json:"time"`
+	RunID    string    `json:"runId,omitempty"` // Run in the -audit-log the event is part of
+	Type     string    `json:"type"`
+	Project  string    `json:"project,omitempty"`
+	Resource string    `json:"resource,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Outcome  string    `json:"outcome,omitempty"` // created, updated, skipped or failed
+	Error    string    `json:"error,omitempty"`
+	ID       string    `json:"id,omitempty"` // Of the resource, or the slug of the project
+}
+
+// EventLog writes events to a stream, one JSON object per line.
+type EventLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	runID  string
+}
+
+// NewEventLog streams events to w.
+func NewEventLog(w io.Writer) *EventLog {
+	return &EventLog{w: w}
+}
+
+// openEventLog returns the event log in format written to path, or to stdout
+// if path is empty. There is no event log if format is empty.
+func openEventLog(format, path string) (*EventLog, error) {
+	if format == "" {
+		return nil, nil
+	}
+	if format != eventsFormatNDJSON {
+		return nil, fmt.Errorf("invalid -events %q, expected %s", format, eventsFormatNDJSON)
+	}
+	if path == "" {
+		return NewEventLog(os.Stdout), nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open event log %s: %v", path, err)
+	}
+	return &EventLog{w: f, closer: f}, nil
+}
+
+// SetRunID tags every event emitted from now on with the run's ID.
+func (l *EventLog) SetRunID(runID string) {
+	if l != nil {
+		l.runID = runID
+	}
+}
+
+// Emit writes the event, timestamping it if it has no time.
+func (l *EventLog) Emit(event Event) {
+	if l == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.RunID == "" {
+		event.RunID = l.runID
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		logWarnf("Could not marshal event: %v", err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(data, '\n'))
+	if err != nil {
+		logWarnf("Could not write event: %v", err)
+	}
+}
+
+// Close closes the file the events are written to, if any.
+func (l *EventLog) Close() error {
+	if l == nil || l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestReportStreamsEvents(t *testing.T) {
+	var buf bytes.Buffer
+	report := NewReport(NewEventLog(&buf))
+	report.Started("test/test", "gh/test/test")
+	report.Record("test/test", "envvar", "A", outcomeCreated, nil)
+	report.Record("test/test", "sshkey", "github.com", outcomeCreated, fmt.Errorf("boom"))
+	report.Finished("test/test", fmt.Errorf("could not add SSH keys"))
+
+	expected := []Event{
+		{Type: eventProjectStarted, Project: "test/test", ID: "gh/test/test"},
+		{Type: eventResource, Project: "test/test", Resource: "envvar", Name: "A", Outcome: outcomeCreated,
+			ID: "gh/test/test/envvar/A"},
+		{Type: eventResource, Project: "test/test", Resource: "sshkey", Name: "github.com", Outcome: outcomeFailed,
+			Error: "boom", ID: "gh/test/test/sshkey/github.com"},
+		{Type: eventProjectFinished, Project: "test/test", Outcome: outcomeFailed, Error: "could not add SSH keys",
+			ID: "gh/test/test"},
+	}
+	scanner := bufio.NewScanner(&buf)
+	var events []Event
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Expected a JSON event per line, found %q: %v", scanner.Text(), err)
+		}
+		if event.Time.IsZero() {
+			t.Errorf("Expected event %+v to be timestamped", event)
+		}
+		event.Time = expected[0].Time
+		events = append(events, event)
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, found %+v", len(expected), events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Expected event %+v, found %+v", expected[i], events[i])
+		}
+	}
+}
+
+func TestOpenEventLog(t *testing.T) {
+	events, err := openEventLog("", "")
+	if events != nil || err != nil {
+		t.Errorf("Expected no event log without a format, found %v (%v)", events, err)
+	}
+	_, err = openEventLog("xml", "")
+	if err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"sync/atomic"
+)
+
+// Exit codes of the CLI, so that automation can tell a bad token from a
+// single resource failing to provision.
+const (
+	exitOK      = 0 // Everything succeeded
+	exitUsage   = 1 // Invalid flags, arguments or config
+	exitPartial = 2 // Some resources or projects could not be provisioned
+	exitAPI     = 3 // CircleCI could not be reached or rejected the credentials
+	exitDrift   = 4 // diff found drift from the config
+)
+
+// exitError is an error ending the run with a specific exit code.
+type exitError struct {
+	code   int
+	err    error
+	silent bool // The error has already been reported, e.g. by the flag package
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// usageError marks err as caused by invalid flags, arguments or config. Errors
+// that already carry an exit code keep it.
+func usageError(err error) error {
+	return withExitCode(exitUsage, err)
+}
+
+// withExitCode marks err as ending the run with code, unless it already
+// carries an exit code.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*exitError); ok {
+		return err
+	}
+	return &exitError{code: code, err: err}
+}
+
+// parseFlags parses the flags of a command. The flag package reports invalid
+// flags along with their usage, so the error it returns is not logged again.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return &exitError{code: exitOK, err: err, silent: true}
+	case err != nil:
+		return &exitError{code: exitUsage, err: err, silent: true}
+	}
+	return nil
+}
+
+// apiFailed is set once CircleCI rejects the credentials or cannot be
+// reached, which is what most likely failed the run.
+var apiFailed int32
+
+// recordAPIFailure records that CircleCI rejected the credentials or could
+// not be reached.
+func recordAPIFailure() {
+	atomic.StoreInt32(&apiFailed, 1)
+}
+
+// exitCode returns the exit code of a run that ended with err.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if e, ok := err.(*exitError); ok {
+		return e.code
+	}
+	if err == errDrift || err == errContextDrift {
+		return exitDrift
+	}
+	if atomic.LoadInt32(&apiFailed) != 0 {
+		return exitAPI
+	}
+	return exitPartial
+}
+
+// reportError reports whether err should be logged when the run ends.
+func reportError(err error) bool {
+	if e, ok := err.(*exitError); ok {
+		return !e.silent
+	}
+	return err != nil
+}
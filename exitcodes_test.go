@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"sync/atomic"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	defer atomic.StoreInt32(&apiFailed, 0)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	help := parseFlags(fs, []string{"-h"})
+	invalid := parseFlags(fs, []string{"-unknown"})
+	failed := errors.New("could not set environment variable API_KEY")
+
+	for _, tt := range []struct {
+		name      string
+		err       error
+		apiFailed bool
+		want      int
+		reported  bool
+	}{
+		{name: "success", want: exitOK},
+		{name: "help", err: help, want: exitOK},
+		{name: "invalid flag", err: invalid, want: exitUsage},
+		{name: "invalid config", err: usageError(errors.New("could not read config file")), want: exitUsage, reported: true},
+		{name: "keeps code", err: usageError(withExitCode(exitAPI, failed)), want: exitAPI, reported: true},
+		{name: "partial failure", err: failed, want: exitPartial, reported: true},
+		{name: "API failure", err: failed, apiFailed: true, want: exitAPI, reported: true},
+		{name: "drift", err: errDrift, want: exitDrift, reported: true},
+		{name: "context drift", err: errContextDrift, want: exitDrift, reported: true},
+	} {
+		atomic.StoreInt32(&apiFailed, 0)
+		if tt.apiFailed {
+			recordAPIFailure()
+		}
+		if code := exitCode(tt.err); code != tt.want {
+			t.Errorf("%s: expected exit code %d, found %d", tt.name, tt.want, code)
+		}
+		if reportError(tt.err) != tt.reported {
+			t.Errorf("%s: expected the error to be reported to be %v", tt.name, tt.reported)
+		}
+	}
+}
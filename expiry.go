@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// expiryLayouts are the accepted formats of expiresAt.
+var expiryLayouts = []string{time.RFC3339, "2006-01-02"}
+
+// EnvVars are env vars to set, keyed by name. Each value is either given
+// directly, as a list of sources to try in order, or as a mapping of value
+// (or sources, or fromFile) and the expiresAt of the credential.
+type EnvVars map[string]string
+
+// envVarSpec is an env var value, optionally with its expiry.
+type envVarSpec struct {
+	Value     string   `yaml:"value"`
+	Sources   []string `yaml:"sources"`  // Sources the value is resolved from, the first resolvable one is used
+	FromFile  string   `yaml:"fromFile"` // File the value is read from, relative to the config
+	Base64    bool     `yaml:"base64"`   // Whether to base64 encode the file's content
+	ExpiresAt string   `yaml:"expiresAt"`
+}
+
+// UnmarshalYAML reads a plain value, a list of sources or a mapping.
+func (s *envVarSpec) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&s.Value); err == nil {
+		return nil
+	}
+	if err := unmarshal(&s.Sources); err == nil {
+		return nil
+	}
+	type plain envVarSpec
+	return unmarshal((*plain)(s))
+}
+
+// UnmarshalYAML reads the env var values, ignoring their expiry which is
+// collected by Config.
+func (e *EnvVars) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var specs map[string]envVarSpec
+	err := unmarshal(&specs)
+	if err != nil {
+		return err
+	}
+	*e = make(EnvVars, len(specs))
+	for name, spec := range specs {
+		(*e)[name] = spec.Value
+	}
+	return nil
+}
+
+// collectExpiry parses the expiresAt of specs into the config's expiry,
+// prefixing their names with prefix.
+func (c *Config) collectExpiry(prefix string, specs map[string]envVarSpec) error {
+	for name, spec := range specs {
+		if spec.ExpiresAt == "" {
+			continue
+		}
+		expiresAt, err := parseExpiry(spec.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("invalid expiresAt of %s: %v", name, err)
+		}
+		if c.Expiry == nil {
+			c.Expiry = make(map[string]time.Time)
+		}
+		c.Expiry[prefix+name] = expiresAt
+	}
+	return nil
+}
+
+func parseExpiry(value string) (time.Time, error) {
+	var err error
+	for _, layout := range expiryLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected a date (2006-01-02) or RFC 3339 time, found %q", value)
+}
+
+// Expiry states reported by an audit.
+const (
+	expiryExpired  = "expired"
+	expiryExpiring = "expiring"
+)
+
+// ExpiryFinding is an env var that has expired or is about to.
+type ExpiryFinding struct {
+	Name      string
+	ExpiresAt time.Time
+	State     string
+}
+
+// auditExpiry returns the env vars of config that have expired by now, or
+// will within warn, ordered by name.
+func auditExpiry(config Config, now time.Time, warn time.Duration) []ExpiryFinding {
+	var findings []ExpiryFinding
+	for _, name := range sortedKeys(config.EnvVars) {
+		expiresAt, ok := config.Expiry[name]
+		if !ok {
+			continue
+		}
+		if !now.Before(expiresAt) {
+			findings = append(findings, ExpiryFinding{name, expiresAt, expiryExpired})
+		} else if now.Add(warn).After(expiresAt) {
+			findings = append(findings, ExpiryFinding{name, expiresAt, expiryExpiring})
+		}
+	}
+	return findings
+}
+
+// printAudit writes the findings to w.
+func printAudit(w io.Writer, projectName string, findings []ExpiryFinding) {
+	if len(findings) == 0 {
+		fmt.Fprintf(w, "No expired environment variables for project %s\n", projectName)
+		return
+	}
+	fmt.Fprintf(w, "Expiring environment variables for project %s:\n", projectName)
+	for _, finding := range findings {
+		color := colorYellow
+		if finding.State == expiryExpired {
+			color = colorRed
+		}
+		fmt.Fprintf(w, "  %s %s (expires %s)\n", finding.Name, paint(w, color, finding.State),
+			finding.ExpiresAt.Format(time.RFC3339))
+	}
+}
+
+// fixExpired replaces the expired env vars of the project with the values
+// resolved from their sources if replace is set, or deletes them otherwise,
+// and returns those fixed. Env vars without a source to resolve a new value
+// from cannot be replaced. Deletions are only made once confirm and approve
+// agree to them, if set. Nothing is changed if dryRun is set.
+func fixExpired(ctx context.Context, project circleci.Project, config Config, expired []string, replace, dryRun bool,
+	confirm, approve confirmFunc) ([]string, error) {
+	name := project.FullName()
+	if replace {
+		var replaced []string
+		for _, envVar := range expired {
+			source := config.Provenance[envVar]
+			if source == "" || source == sourceLiteral {
+				logWarnf("Not replacing expired environment variable %s of project %s, it has no source to "+
+					"resolve a new value from", envVar, name)
+				continue
+			}
+			replaced = append(replaced, envVar)
+			if dryRun {
+				logInfof("Would replace expired environment variable %s of project %s from %s", envVar, name, source)
+				continue
+			}
+			logInfof("Replacing expired environment variable %s of project %s from %s", envVar, name, source)
+			err := project.Setenv(ctx, envVar, config.EnvVars[envVar])
+			if err != nil {
+				return nil, fmt.Errorf("could not replace environment variable %s of project %s: %v", envVar, name, err)
+			}
+		}
+		return replaced, nil
+	}
+
+	if !dryRun && len(expired) > 0 {
+		changes := make([]string, len(expired))
+		for i, envVar := range expired {
+			changes[i] = fmt.Sprintf("%s %s %s", opRemove, circleci.ResourceEnvVar, envVar)
+		}
+		for _, agree := range []confirmFunc{confirm, approve} {
+			if agree == nil {
+				continue
+			}
+			if err := agree(name, changes); err != nil {
+				return nil, fmt.Errorf("could not get approval to delete the expired environment variables of "+
+					"project %s: %v", name, err)
+			}
+		}
+	}
+	for _, envVar := range expired {
+		if dryRun {
+			logInfof("Would delete expired environment variable %s from project %s", envVar, name)
+			continue
+		}
+		logInfof("Deleting expired environment variable %s from project %s", envVar, name)
+		err := project.Deleteenv(ctx, envVar)
+		if err != nil {
+			return nil, fmt.Errorf("could not delete environment variable %s from project %s: %v", envVar, name, err)
+		}
+	}
+	return expired, nil
+}
+
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	warn := fs.Duration("warn", 7*24*time.Hour, "Also report env vars expiring within this long")
+	deleteExpired := fs.Bool("delete-expired", false, "Delete expired env vars from the project")
+	replaceExpired := fs.Bool("replace-expired", false,
+		"Replace expired env vars with the values resolved from their sources, e.g. a secret store")
+	dryRun := fs.Bool("dry-run", false, "Print the env vars that would be deleted or replaced without changing them")
+	assumeYes := addYesFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *deleteExpired && *replaceExpired {
+		return usageError(fmt.Errorf("-delete-expired and -replace-expired are exclusive"))
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	config, err := s.config()
+	if err != nil {
+		return err
+	}
+	s, err = s.forConfig(config)
+	if err != nil {
+		return err
+	}
+	project := s.project(config.VcsType, config.Owner, config.ProjectName)
+
+	findings := auditExpiry(config, time.Now(), *warn)
+	printAudit(s.stdout, project.FullName(), findings)
+	var expired []string
+	for _, finding := range findings {
+		if finding.State == expiryExpired {
+			expired = append(expired, finding.Name)
+		}
+	}
+	if *deleteExpired || *replaceExpired {
+		var confirm confirmFunc
+		if !*assumeYes {
+			confirm = newRemovalConfirmer().confirm
+		}
+		approve := approver(s.ctx, s.approval.merge(config.Approval), "delete-expired")
+		fixed, err := fixExpired(s.ctx, project, config, expired, *replaceExpired, *dryRun, confirm, approve)
+		if err != nil {
+			return err
+		}
+		if !*dryRun {
+			var remaining []string
+			for _, name := range expired {
+				if !containsName(fixed, name) {
+					remaining = append(remaining, name)
+				}
+			}
+			expired = remaining
+		}
+	}
+	var errs []error
+	if len(expired) > 0 {
+		errs = append(errs, fmt.Errorf("%d environment variable(s) of project %s have expired", len(expired), project.FullName()))
+	}
+
+	if config.Insights.declared() {
+		// Insights are only available through API v2.
+		v2Project := s.v2Project(config.VcsType, config.Owner, config.ProjectName)
+		metrics, err := v2Project.WorkflowInsights(s.ctx, config.Insights.Branch)
+		if err != nil {
+			return joinErrors(append(errs, err))
+		}
+		breaches := auditInsights(metrics, config.Insights)
+		printInsightsAudit(s.stdout, project.FullName(), breaches)
+		if len(breaches) > 0 {
+			errs = append(errs, fmt.Errorf("%d workflow threshold(s) of project %s are broken", len(breaches), project.FullName()))
+		}
+	}
+	return joinErrors(errs)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestConfigExpiry(t *testing.T) {
+	data := `
+envVars:
+  PLAIN: plain
+  AWS_KEY:
+    value: key
+    expiresAt: 2026-01-31
+  GCP_KEY:
+    value: other
+    expiresAt: "2026-02-01T12:00:00Z"
+namespaces:
+  svc-a:
+    envVars:
+      TOKEN:
+        value: token
+        expiresAt: 2026-03-01
+`
+	var config Config
+	err := yaml.Unmarshal([]byte(data), &config)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	err = expandNamespaces(&config)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+
+	expectedEnvVars := EnvVars{"PLAIN": "plain", "AWS_KEY": "key", "GCP_KEY": "other", "SVC_A__TOKEN": "token"}
+	if !reflect.DeepEqual(config.EnvVars, expectedEnvVars) {
+		t.Errorf("Expected env vars %v, found %v", expectedEnvVars, config.EnvVars)
+	}
+	expectedExpiry := map[string]time.Time{
+		"AWS_KEY":      time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC),
+		"GCP_KEY":      time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC),
+		"SVC_A__TOKEN": time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(config.Expiry, expectedExpiry) {
+		t.Errorf("Expected expiry %v, found %v", expectedExpiry, config.Expiry)
+	}
+
+	err = yaml.Unmarshal([]byte("envVars:\n  KEY:\n    value: v\n    expiresAt: soon\n"), &Config{})
+	if err == nil {
+		t.Error("Expected an invalid expiresAt to be an error")
+	}
+}
+
+func TestAuditExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	config := Config{
+		EnvVars: EnvVars{"EXPIRED": "", "SOON": "", "LATER": "", "NEVER": ""},
+		Expiry: map[string]time.Time{
+			"EXPIRED": now.Add(-time.Hour),
+			"SOON":    now.Add(48 * time.Hour),
+			"LATER":   now.Add(30 * 24 * time.Hour),
+		},
+	}
+
+	findings := auditExpiry(config, now, 7*24*time.Hour)
+	expected := []ExpiryFinding{
+		{"EXPIRED", now.Add(-time.Hour), expiryExpired},
+		{"SOON", now.Add(48 * time.Hour), expiryExpiring},
+	}
+	if !reflect.DeepEqual(findings, expected) {
+		t.Errorf("Expected findings %v, found %v", expected, findings)
+	}
+}
+
+func TestFixExpired(t *testing.T) {
+	config := Config{
+		EnvVars:    EnvVars{"STORED": "new", "LITERAL": "old"},
+		Provenance: map[string]string{"STORED": "vault:secret/app#token", "LITERAL": sourceLiteral},
+	}
+	expired := []string{"LITERAL", "STORED"}
+	approve := func(project string, changes []string) error { return nil }
+	deny := func(project string, changes []string) error { return fmt.Errorf("denied") }
+	testCases := []struct {
+		name     string
+		replace  bool
+		dryRun   bool
+		approve  confirmFunc
+		fixed    []string
+		requests []string
+		err      bool
+	}{
+		{"delete", false, false, approve, expired, []string{
+			"DELETE /project/git/test/test/envvar/LITERAL", "DELETE /project/git/test/test/envvar/STORED"}, false},
+		{"delete denied", false, false, deny, nil, nil, true},
+		{"delete dry run", false, true, deny, expired, nil, false},
+		{"replace", true, false, deny, []string{"STORED"}, []string{
+			`POST /project/git/test/test/envvar {"name":"STORED","value":"new"}`}, false},
+		{"replace dry run", true, true, deny, []string{"STORED"}, nil, false},
+	}
+	for _, tc := range testCases {
+		svr := newFakeCircleCI(map[string]circlecitest.Response{
+			"DELETE /project/git/test/test/envvar/LITERAL": {Status: http.StatusOK, Body: `{"message": "ok"}`},
+			"DELETE /project/git/test/test/envvar/STORED":  {Status: http.StatusOK, Body: `{"message": "ok"}`},
+			"POST /project/git/test/test/envvar":           {Status: http.StatusCreated, Body: `{}`},
+		})
+		fixed, err := fixExpired(context.Background(), svr.project(), config, expired, tc.replace, tc.dryRun, nil, tc.approve)
+		svr.Close()
+		if (err != nil) != tc.err {
+			t.Errorf("%s: expected error %v, found: %v", tc.name, tc.err, err)
+		}
+		if !reflect.DeepEqual(fixed, tc.fixed) {
+			t.Errorf("%s: expected %v to be fixed, found %v", tc.name, tc.fixed, fixed)
+		}
+		if requests := svr.Requests(); !reflect.DeepEqual(requests, tc.requests) {
+			t.Errorf("%s: expected requests %q, found %q", tc.name, tc.requests, requests)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// writeExport writes a config skeleton describing the project's state to w.
+// Env var values are masked by CircleCI and private keys cannot be read back,
+// so they are left as placeholders to fill in.
+func writeExport(w io.Writer, vcsType, owner, projectName string, state ProjectState) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Exported from %s/%s/%s.\n", vcsType, owner, projectName)
+	if !state.Following {
+		fmt.Fprintln(bw, "# The project is not followed, provisioning this config will follow it.")
+	}
+	fmt.Fprintf(bw, "version: %d\nvcsType: %q\nowner: %q\nprojectName: %q\n", configVersion, vcsType, owner, projectName)
+
+	if len(state.EnvVars) > 0 {
+		fmt.Fprintln(bw, "# Values are masked by CircleCI and must be filled in.")
+		fmt.Fprintln(bw, "envVars:")
+		for _, name := range sortedKeys(state.EnvVars) {
+			fmt.Fprintf(bw, "  %q: \"\" # currently %s\n", name, state.EnvVars[name])
+		}
+	}
+
+	if len(state.SSHKeys) > 0 {
+		fingerprints := make(map[string][]string)
+		for _, key := range state.SSHKeys {
+			fingerprints[key.Hostname] = append(fingerprints[key.Hostname], key.Fingerprint)
+		}
+		hostnames := make([]string, 0, len(fingerprints))
+		for hostname := range fingerprints {
+			hostnames = append(hostnames, hostname)
+		}
+		sort.Strings(hostnames)
+
+		fmt.Fprintln(bw, "# Paths to the private keys, which cannot be exported.")
+		fmt.Fprintln(bw, "sshKeys:")
+		for _, hostname := range hostnames {
+			fmt.Fprintf(bw, "  # fingerprint %s\n", strings.Join(fingerprints[hostname], ", "))
+			fmt.Fprintf(bw, "  %q: %q\n", hostname, hostname+".key")
+		}
+	}
+	return bw.Flush()
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	out := fs.String("out", "", "File to write the config to (default stdout)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	vcsType, owner, projectName, err := s.projectSlug()
+	if err != nil {
+		return err
+	}
+	state, err := fetchState(s.ctx, s.project(vcsType, owner, projectName))
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		fh, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("could not create %s: %v", *out, err)
+		}
+		defer fh.Close()
+		w = fh
+	}
+	err = writeExport(w, vcsType, owner, projectName, state)
+	if err != nil {
+		return fmt.Errorf("could not write config: %v", err)
+	}
+	if *out != "" {
+		logInfof("Project %s/%s has been exported to %s", owner, projectName, *out)
+	}
+	return nil
+}
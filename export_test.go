@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+func TestWriteExport(t *testing.T) {
+	state := ProjectState{
+		Following: false,
+		EnvVars:   map[string]string{"B": "xxxx2", "A": "xxxx1"},
+		SSHKeys: []circleci.SSHKey{
+			{Hostname: "github.com", Fingerprint: "aa:bb"},
+			{Hostname: "github.com", Fingerprint: "cc:dd"},
+			{Hostname: "example.com", Fingerprint: "ee:ff"},
+		},
+	}
+	var buf bytes.Buffer
+	err := writeExport(&buf, "gh", "owner", "project", state)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+
+	var config Config
+	err = yaml.Unmarshal(buf.Bytes(), &config)
+	if err != nil {
+		t.Fatalf("Expected a valid config, found: %v\n%s", err, buf.String())
+	}
+	expected := Config{
+		Version:     configVersion,
+		VcsType:     "gh",
+		Owner:       "owner",
+		ProjectName: "project",
+		EnvVars:     map[string]string{"A": "", "B": ""},
+		SSHKeys:     map[string]string{"example.com": "example.com.key", "github.com": "github.com.key"},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected config %+v, found %+v", expected, config)
+	}
+	for _, want := range []string{"not followed", "fingerprint aa:bb, cc:dd"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected export to mention %q, found:\n%s", want, buf.String())
+		}
+	}
+}
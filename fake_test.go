@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/nick96/circleci-provision/pkg/circleci"
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+// fakeCircleCI is a fake CircleCI API managing the project git/test/test.
+type fakeCircleCI struct {
+	*circlecitest.Server
+}
+
+func newFakeCircleCI(responses map[string]circlecitest.Response) *fakeCircleCI {
+	return &fakeCircleCI{circlecitest.NewServer(responses)}
+}
+
+// client returns a client of the fake API that does not retry requests.
+func (f *fakeCircleCI) client() *circleci.HTTPClient {
+	client := circleci.NewHTTPClient(f.URL, nil)
+	client.HTTP = f.Client()
+	client.MaxRetries = 0
+	return client
+}
+
+// project returns the project git/test/test managed through the fake API.
+func (f *fakeCircleCI) project() *circleci.ProjectV1 {
+	return circleci.NewProjectV1WithClient("git", "test", "test", f.client())
+}
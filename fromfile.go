@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// envVarFile is a file an env var's value is read from, such as a
+// certificate or a service account's JSON key.
+type envVarFile struct {
+	Path   string
+	Base64 bool // Whether the content is base64 encoded once read
+}
+
+// collectFiles records the files of specs declared with fromFile, prefixing
+// their names with prefix.
+func (c *Config) collectFiles(prefix string, specs map[string]envVarSpec) error {
+	for name, spec := range specs {
+		if spec.FromFile == "" {
+			if spec.Base64 {
+				return fmt.Errorf("%s sets base64 without fromFile", name)
+			}
+			continue
+		}
+		if spec.Value != "" || len(spec.Sources) > 0 {
+			return fmt.Errorf("%s sets fromFile along with a value or sources", name)
+		}
+		if c.Files == nil {
+			c.Files = make(map[string]envVarFile)
+		}
+		c.Files[prefix+name] = envVarFile{spec.FromFile, spec.Base64}
+	}
+	return nil
+}
+
+// readEnvVarFiles sets the env vars declared with fromFile to the content of
+// their files, relative to dir, base64 encoding those that ask for it. The
+// content is used as it is, without interpolation or secret resolution.
+func readEnvVarFiles(config *Config, dir string) error {
+	for _, name := range sortedKeys(config.EnvVars) {
+		file, ok := config.Files[name]
+		if !ok {
+			continue
+		}
+		path := file.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read %s for environment variable %s: %v", path, name, err)
+		}
+		value := string(content)
+		if file.Base64 {
+			value = base64.StdEncoding.EncodeToString(content)
+		}
+		config.EnvVars[name] = value
+	}
+	return nil
+}
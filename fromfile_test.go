@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestReadEnvVarFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fromfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "sa.json"), []byte("{\n  \"type\": \"${NOT_INTERPOLATED}\"\n}\n"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "cert.pem"), []byte("cert\n"), 0600)
+	file := filepath.Join(dir, "config.yml")
+	ioutil.WriteFile(file, []byte(`
+owner: test
+projectName: test
+envVars:
+  SERVICE_ACCOUNT:
+    fromFile: sa.json
+  CERT:
+    fromFile: cert.pem
+    base64: true
+namespaces:
+  svc:
+    envVars:
+      CERT:
+        fromFile: cert.pem
+`), 0600)
+
+	config, err := readConfig(file, configOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := map[string]string{
+		"SERVICE_ACCOUNT": "{\n  \"type\": \"${NOT_INTERPOLATED}\"\n}\n",
+		"CERT":            "Y2VydAo=",
+		"SVC__CERT":       "cert\n",
+	}
+	for name, value := range expected {
+		if config.EnvVars[name] != value {
+			t.Errorf("Expected %s to be %q, found %q", name, value, config.EnvVars[name])
+		}
+	}
+
+	os.Remove(filepath.Join(dir, "cert.pem"))
+	if _, err = readConfig(file, configOptions{}); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestUnmarshalFromFile(t *testing.T) {
+	for _, data := range []string{
+		"envVars:\n  A:\n    fromFile: a.pem\n    value: a\n",
+		"envVars:\n  A:\n    fromFile: a.pem\n    sources: [env:A]\n",
+		"envVars:\n  A:\n    value: a\n    base64: true\n",
+	} {
+		if err := yaml.Unmarshal([]byte(data), &Config{}); err == nil {
+			t.Errorf("Expected an error for %q", data)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+)
+
+// GitHubClient is a minimal client for the GitHub REST API.
+type GitHubClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// GitHubRepo is the subset of a GitHub repository we care about.
+type GitHubRepo struct {
+	Name     string   `json:"name"`
+	Archived bool     `json:"archived"`
+	Topics   []string `json:"topics"`
+}
+
+// NewGitHubClient creates a GitHub client authenticating with token.
+func NewGitHubClient(token string) *GitHubClient {
+	return &GitHubClient{"https://api.github.com", token, &http.Client{}}
+}
+
+func (g *GitHubClient) get(ctx context.Context, resource string, query url.Values, out interface{}) error {
+	return g.do(ctx, http.MethodGet, resource, query, nil, http.StatusOK, out)
+}
+
+// do sends in as the JSON body of a request to resource, if set, and
+// decodes the response into out unless it is nil.
+func (g *GitHubClient) do(ctx context.Context, method, resource string, query url.Values, in interface{},
+	status int, out interface{}) error {
+	u, err := url.Parse(g.baseURL)
+	if err != nil {
+		return err
+	}
+	u.Path = path.Join(u.Path, resource)
+	u.RawQuery = query.Encode()
+
+	var reqBody []byte
+	if in != nil {
+		reqBody, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	// Topics are only included in responses with the mercy preview.
+	req.Header.Set("Accept", "application/vnd.github.mercy-preview+json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if g.token != "" {
+		req.Header.Set("Authorization", "token "+g.token)
+	}
+
+	logDebugf("> %s %s", method, u)
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response body: %v", err)
+	}
+	logDebugf("< %s %s %d %s", method, u, resp.StatusCode, bytes.TrimSpace(body))
+	if resp.StatusCode != status {
+		return fmt.Errorf("expected status %d, found %d: %s", status, resp.StatusCode, body)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+// OrgRepos lists every repository in the GitHub organisation.
+func (g *GitHubClient) OrgRepos(ctx context.Context, org string) ([]GitHubRepo, error) {
+	var repos []GitHubRepo
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("per_page", "100")
+		query.Set("page", strconv.Itoa(page))
+
+		var batch []GitHubRepo
+		err := g.get(ctx, path.Join("orgs", org, "repos"), query, &batch)
+		if err != nil {
+			return nil, fmt.Errorf("could not list repositories for %s: %v", org, err)
+		}
+		if len(batch) == 0 {
+			return repos, nil
+		}
+		repos = append(repos, batch...)
+	}
+}
+
+// GitHubHook is the subset of a GitHub repository webhook we care about.
+type GitHubHook struct {
+	Active bool `json:"active"`
+	Config struct {
+		URL string `json:"url"`
+	} `json:"config"`
+}
+
+// RepoHooks lists the webhooks of the GitHub repository. The token needs
+// admin access to the repository.
+func (g *GitHubClient) RepoHooks(ctx context.Context, owner, repo string) ([]GitHubHook, error) {
+	query := url.Values{}
+	query.Set("per_page", "100")
+
+	var hooks []GitHubHook
+	err := g.get(ctx, path.Join("repos", owner, repo, "hooks"), query, &hooks)
+	if err != nil {
+		return nil, fmt.Errorf("could not list webhooks for %s/%s: %v", owner, repo, err)
+	}
+	return hooks, nil
+}
+
+// GitHubRelease is the subset of a GitHub release we care about.
+type GitHubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// Release gets the release of the GitHub repository tagged tag, or its
+// latest release if tag is empty.
+func (g *GitHubClient) Release(ctx context.Context, owner, repo, tag string) (GitHubRelease, error) {
+	resource := path.Join("repos", owner, repo, "releases", "latest")
+	if tag != "" {
+		resource = path.Join("repos", owner, repo, "releases", "tags", tag)
+	}
+	var release GitHubRelease
+	err := g.get(ctx, resource, url.Values{}, &release)
+	if err != nil {
+		return release, fmt.Errorf("could not get release of %s/%s: %v", owner, repo, err)
+	}
+	return release, nil
+}
+
+// GitHubDeployKey is a deploy key of a GitHub repository.
+type GitHubDeployKey struct {
+	Title    string `json:"title"`
+	Key      string `json:"key"` // Public key in authorized_keys format
+	ReadOnly bool   `json:"read_only"`
+}
+
+// DeployKeys lists the deploy keys of the GitHub repository. The token needs
+// admin access to the repository.
+func (g *GitHubClient) DeployKeys(ctx context.Context, owner, repo string) ([]GitHubDeployKey, error) {
+	query := url.Values{}
+	query.Set("per_page", "100")
+
+	var keys []GitHubDeployKey
+	err := g.get(ctx, path.Join("repos", owner, repo, "keys"), query, &keys)
+	if err != nil {
+		return nil, fmt.Errorf("could not list deploy keys for %s/%s: %v", owner, repo, err)
+	}
+	return keys, nil
+}
+
+// AddDeployKey adds a deploy key to the GitHub repository. The token needs
+// admin access to the repository.
+func (g *GitHubClient) AddDeployKey(ctx context.Context, owner, repo string, key GitHubDeployKey) error {
+	err := g.do(ctx, http.MethodPost, path.Join("repos", owner, repo, "keys"), url.Values{}, key, http.StatusCreated, nil)
+	if err != nil {
+		return fmt.Errorf("could not add deploy key %s to %s/%s: %v", key.Title, owner, repo, err)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Kinds of resources in a resource graph.
+const (
+	nodeProject = "project"
+	nodeContext = "context"
+	nodeSSHKey  = "ssh-key"
+	nodeEnvFile = "env-file"
+)
+
+// Output formats of a resource graph.
+const (
+	graphDOT     = "dot"
+	graphMermaid = "mermaid"
+)
+
+// graphNode is a project or a resource projects share.
+type graphNode struct {
+	ID    string
+	Kind  string
+	Label string
+}
+
+// graphEdge is a relation from one node to another.
+type graphEdge struct {
+	From  string
+	To    string
+	Label string
+}
+
+// ResourceGraph relates the configured projects to the contexts, SSH keys
+// and env files they use, and to the projects they depend on through them.
+type ResourceGraph struct {
+	Nodes []graphNode
+	Edges []graphEdge
+
+	ids   map[string]string // Node IDs, keyed by kind and name
+	edges map[graphEdge]bool
+}
+
+// node returns the ID of the node of the kind named name, adding it if it is
+// not in the graph yet.
+func (g *ResourceGraph) node(kind, name, label string) string {
+	key := kind + " " + name
+	if id, ok := g.ids[key]; ok {
+		return id
+	}
+	id := fmt.Sprintf("n%d", len(g.Nodes))
+	g.ids[key] = id
+	g.Nodes = append(g.Nodes, graphNode{ID: id, Kind: kind, Label: label})
+	return id
+}
+
+// edge adds an edge to the graph, unless it is already there.
+func (g *ResourceGraph) edge(from, to, label string) {
+	e := graphEdge{From: from, To: to, Label: label}
+	if g.edges[e] {
+		return
+	}
+	g.edges[e] = true
+	g.Edges = append(g.Edges, e)
+}
+
+// configuredProject is a config and the file it was read from.
+type configuredProject struct {
+	file   string
+	config Config
+}
+
+// buildGraph returns the graph of the projects. A project attaching a
+// context another project provisions depends on that project. With
+// sharedOnly, resources used by a single project are left out.
+func buildGraph(projects []configuredProject, sharedOnly bool) ResourceGraph {
+	g := ResourceGraph{ids: make(map[string]string), edges: make(map[graphEdge]bool)}
+	type use struct{ project, resource, label string }
+	var uses []use
+	users := make(map[string]map[string]bool)
+	provisioners := make(map[string][]string) // Projects provisioning each context
+	attachers := make(map[string][]string)    // Projects attaching each context
+	addUse := func(project, resource, label string) {
+		uses = append(uses, use{project, resource, label})
+		if users[resource] == nil {
+			users[resource] = make(map[string]bool)
+		}
+		users[resource][project] = true
+	}
+
+	for _, p := range projects {
+		c := p.config
+		name := c.Owner + "/" + c.ProjectName
+		project := g.node(nodeProject, name, name)
+		for _, context := range c.Contexts {
+			id := g.node(nodeContext, c.Owner+"/"+context.Name, "context "+context.Name)
+			addUse(project, id, "provisions")
+			provisioners[id] = append(provisioners[id], project)
+			for _, file := range context.EnvFiles {
+				addUse(project, envFileNode(&g, p.file, file), "reads for "+context.Name)
+			}
+		}
+		for _, context := range c.AttachContexts {
+			id := g.node(nodeContext, c.Owner+"/"+context, "context "+context)
+			addUse(project, id, "attaches")
+			attachers[id] = append(attachers[id], project)
+		}
+		for _, hostname := range sortedKeys(c.SSHKeys) {
+			addUse(project, sshKeyNode(&g, c.SSHKeys[hostname]), "key for "+hostname)
+		}
+		for _, file := range c.EnvFiles {
+			addUse(project, envFileNode(&g, p.file, file), "reads")
+		}
+	}
+
+	for _, u := range uses {
+		if sharedOnly && len(users[u.resource]) < 2 {
+			continue
+		}
+		g.edge(u.project, u.resource, u.label)
+	}
+	for _, node := range g.Nodes {
+		for _, attacher := range attachers[node.ID] {
+			for _, provisioner := range provisioners[node.ID] {
+				if attacher != provisioner {
+					g.edge(attacher, provisioner, "depends on")
+				}
+			}
+		}
+	}
+	if sharedOnly {
+		g.prune()
+	}
+	return g
+}
+
+// prune removes the resources no edge leads to.
+func (g *ResourceGraph) prune() {
+	linked := make(map[string]bool)
+	for _, e := range g.Edges {
+		linked[e.To] = true
+	}
+	nodes := g.Nodes[:0]
+	for _, node := range g.Nodes {
+		if node.Kind == nodeProject || linked[node.ID] {
+			nodes = append(nodes, node)
+		}
+	}
+	g.Nodes = nodes
+}
+
+// sshKeyNode returns the node of the SSH key at path. Keys are told apart by
+// their fingerprint, so that copies of a key count as the same key.
+func sshKeyNode(g *ResourceGraph, path string) string {
+	content, err := ioutil.ReadFile(path)
+	var fingerprint string
+	if err == nil {
+		fingerprint, err = sshKeyFingerprint(content)
+	}
+	if err != nil {
+		logWarnf("Could not fingerprint SSH key %s, telling it apart by its path: %v", path, err)
+		return g.node(nodeSSHKey, path, "SSH key "+path)
+	}
+	return g.node(nodeSSHKey, fingerprint, "SSH key "+fingerprint)
+}
+
+// envFileNode returns the node of the env file, relative to the config file.
+func envFileNode(g *ResourceGraph, configFile, file string) string {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(filepath.Dir(configFile), file)
+	}
+	file = filepath.Clean(file)
+	return g.node(nodeEnvFile, file, "env file "+file)
+}
+
+// writeDOT writes the graph in Graphviz's DOT language.
+func (g ResourceGraph) writeDOT(w io.Writer) error {
+	shapes := map[string]string{nodeProject: "box", nodeContext: "ellipse", nodeSSHKey: "diamond", nodeEnvFile: "note"}
+	var b strings.Builder
+	b.WriteString("digraph resources {\n  rankdir=LR;\n")
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&b, "  %s [label=%q, shape=%s];\n", node.ID, node.Label, shapes[node.Kind])
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%q];\n", e.From, e.To, e.Label)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMermaid writes the graph as a Mermaid flowchart.
+func (g ResourceGraph) writeMermaid(w io.Writer) error {
+	shapes := map[string][2]string{
+		nodeProject: {"[", "]"}, nodeContext: {"([", "])"}, nodeSSHKey: {"{{", "}}"}, nodeEnvFile: {"[/", "/]"},
+	}
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for _, node := range g.Nodes {
+		shape := shapes[node.Kind]
+		fmt.Fprintf(&b, "  %s%s\"%s\"%s\n", node.ID, shape[0], mermaidEscape(node.Label), shape[1])
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", e.From, mermaidEscape(e.Label), e.To)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidEscape escapes the characters that end a quoted Mermaid label.
+func mermaidEscape(label string) string {
+	return strings.NewReplacer(`"`, "#quot;", "\n", "<br>").Replace(label)
+}
+
+func runGraph(args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	format := fs.String("format", graphDOT, "Format of the graph (dot or mermaid)")
+	sharedOnly := fs.Bool("shared", false, "Only show contexts, SSH keys and env files used by more than one project")
+	out := fs.String("out", "", "File to write the graph to (default stdout)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s graph [flags] [CONFIG|DIR...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *format != graphDOT && *format != graphMermaid {
+		return fmt.Errorf("invalid -format %q, expected %s or %s", *format, graphDOT, graphMermaid)
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	files, err := common.configArgs(fs.Args())
+	if err != nil {
+		fs.Usage()
+		return err
+	}
+	var projects []configuredProject
+	for _, file := range files {
+		configs, err := s.readConfigs(file)
+		if err != nil {
+			return err
+		}
+		for _, config := range configs {
+			projects = append(projects, configuredProject{file, config})
+		}
+	}
+	graph := buildGraph(projects, *sharedOnly)
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		fh, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("could not create %s: %v", *out, err)
+		}
+		defer fh.Close()
+		w = fh
+	}
+	if *format == graphMermaid {
+		err = graph.writeMermaid(w)
+	} else {
+		err = graph.writeDOT(w)
+	}
+	if err != nil {
+		return fmt.Errorf("could not write graph: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildGraph(t *testing.T) {
+	keyFile, fingerprint := writeTestSSHKey(t)
+	defer os.Remove(keyFile)
+	projects := []configuredProject{
+		{"configs/platform.yml", Config{Owner: "org", ProjectName: "platform",
+			Contexts: []ContextConfig{{Name: "shared", EnvFiles: []string{"shared.env"}}},
+			SSHKeys:  SSHKeys{"github.com": keyFile}}},
+		{"configs/api.yml", Config{Owner: "org", ProjectName: "api", AttachContexts: []string{"shared"},
+			SSHKeys: SSHKeys{"github.com": keyFile}, EnvFiles: []string{"./api.env"}}},
+	}
+
+	graph := buildGraph(projects, false)
+	var labels []string
+	for _, node := range graph.Nodes {
+		labels = append(labels, node.Label)
+	}
+	expected := []string{"org/platform", "context shared", "env file configs/shared.env", "SSH key " + fingerprint,
+		"org/api", "env file configs/api.env"}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected nodes %q, found %q", expected, labels)
+	}
+	if last := graph.Edges[len(graph.Edges)-1]; last != (graphEdge{"n4", "n0", "depends on"}) {
+		t.Errorf("Expected org/api to depend on org/platform, found %v", last)
+	}
+
+	shared := buildGraph(projects, true)
+	if len(shared.Nodes) != 4 || len(shared.Edges) != 5 {
+		t.Errorf("Expected the env files to be left out, found %+v and %+v", shared.Nodes, shared.Edges)
+	}
+}
+
+func TestWriteGraph(t *testing.T) {
+	graph := buildGraph([]configuredProject{
+		{"a.yml", Config{Owner: "org", ProjectName: `a"b`, AttachContexts: []string{"ctx"}}},
+	}, false)
+
+	var dot bytes.Buffer
+	if err := graph.writeDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{`n0 [label="org/a\"b", shape=box];`, `n0 -> n1 [label="attaches"];`} {
+		if !strings.Contains(dot.String(), line) {
+			t.Errorf("Expected %s in:\n%s", line, dot.String())
+		}
+	}
+
+	var mermaid bytes.Buffer
+	if err := graph.writeMermaid(&mermaid); err != nil {
+		t.Fatal(err)
+	}
+	expected := "graph LR\n  n0[\"org/a#quot;b\"]\n  n1([\"context ctx\"])\n  n0 -->|\"attaches\"| n1\n"
+	if mermaid.String() != expected {
+		t.Errorf("Expected:\n%s\nfound:\n%s", expected, mermaid.String())
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// HistoryEntry records the outcome of provisioning a project.
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`
+	Project string    `json:"project"`
+	ID      string    `json:"id,omitempty"` // Slug of the project
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+	Failed  []string  `json:"failed,omitempty"` // IDs of the resources that failed
+}
+
+// History is an append-only log of provisioning runs, kept as one JSON lines
+// file per project. Writes are serialised per project both within the process
+// and, through a lock file, between processes sharing the directory.
+type History struct {
+	dir   string
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// OpenHistory opens the history kept in dir, creating it if necessary.
+func OpenHistory(dir string) (*History, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("could not create history directory %s: %v", dir, err)
+	}
+	return &History{dir: dir, locks: make(map[string]*sync.Mutex)}, nil
+}
+
+// path returns the file holding the project's history, named after the
+// project escaped as a path segment so that no two projects share one.
+func (h *History) path(project string) string {
+	return filepath.Join(h.dir, url.PathEscape(project)+".jsonl")
+}
+
+// lock acquires the in-process and inter-process locks for the project and
+// returns a function releasing them.
+func (h *History) lock(project string) (func(), error) {
+	h.mu.Lock()
+	mu, ok := h.locks[project]
+	if !ok {
+		mu = &sync.Mutex{}
+		h.locks[project] = mu
+	}
+	h.mu.Unlock()
+
+	mu.Lock()
+	unlock, err := lockFile(h.path(project) + ".lock")
+	if err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		mu.Unlock()
+	}, nil
+}
+
+// Append adds an entry to the project's history.
+func (h *History) Append(entry HistoryEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("could not marshal history entry: %v", err)
+	}
+
+	unlock, err := h.lock(entry.Project)
+	if err != nil {
+		return fmt.Errorf("could not lock history for %s: %v", entry.Project, err)
+	}
+	defer unlock()
+
+	fh, err := os.OpenFile(h.path(entry.Project), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open history for %s: %v", entry.Project, err)
+	}
+	defer fh.Close()
+
+	_, err = fh.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("could not write history for %s: %v", entry.Project, err)
+	}
+	return fh.Sync()
+}
+
+// Entries returns the project's history, oldest first.
+func (h *History) Entries(project string) ([]HistoryEntry, error) {
+	unlock, err := h.lock(project)
+	if err != nil {
+		return nil, fmt.Errorf("could not lock history for %s: %v", project, err)
+	}
+	defer unlock()
+
+	fh, err := os.Open(h.path(project))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not open history for %s: %v", project, err)
+	}
+	defer fh.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt history for %s: %v", project, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
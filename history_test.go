@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistoryConcurrentAppends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const projects = 8
+	const runs = 4
+	const entriesPerRun = 50
+
+	// Each run opens its own History, as separate processes on the same
+	// host would, so writes are serialised by the lock file as well as the
+	// in-process mutex.
+	var wg sync.WaitGroup
+	errs := make(chan error, projects*runs*entriesPerRun)
+	for r := 0; r < runs; r++ {
+		history, err := OpenHistory(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for p := 0; p < projects; p++ {
+			for e := 0; e < entriesPerRun; e++ {
+				wg.Add(1)
+				go func(project string) {
+					defer wg.Done()
+					errs <- history.Append(HistoryEntry{Time: time.Now(), Project: project, Success: true})
+				}(fmt.Sprintf("owner/project-%d", p))
+			}
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Expected no error appending history, found: %v", err)
+		}
+	}
+
+	history, err := OpenHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p := 0; p < projects; p++ {
+		project := fmt.Sprintf("owner/project-%d", p)
+		entries, err := history.Entries(project)
+		if err != nil {
+			t.Fatalf("Expected no error reading history for %s, found: %v", project, err)
+		}
+		if len(entries) != runs*entriesPerRun {
+			t.Errorf("Expected %d entries for %s, found %d", runs*entriesPerRun, project, len(entries))
+		}
+		for _, entry := range entries {
+			if entry.Project != project {
+				t.Errorf("Expected entry for %s, found %s", project, entry.Project)
+			}
+		}
+	}
+}
+
+func TestHistoryEntriesMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	history, err := OpenHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := history.Entries("owner/none")
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Expected no entries and no error, found %v, %v", entries, err)
+	}
+}
+
+func TestHistoryProjectsDoNotShareFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	history, err := OpenHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects := []string{"a_b/c", "a/b_c"}
+	for _, project := range projects {
+		if err := history.Append(HistoryEntry{Time: time.Now(), Project: project, Success: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, project := range projects {
+		entries, err := history.Entries(project)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Project != project {
+			t.Errorf("Expected only the entry of %s, found %v", project, entries)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// What to do with a project whose recent workflows are failing.
+const (
+	insightsSkip = "skip" // Do not trigger it
+	insightsFlag = "flag" // Trigger it anyway, warning about it
+)
+
+// insightsGate holds back triggering projects whose pipelines are already
+// failing, going by their recent workflow success rate in Insights.
+type insightsGate struct {
+	minSuccessRate float64 // Between 0 and 1, no check if 0
+	action         string  // skip or flag
+}
+
+// successRate returns the share of the project's recent workflow runs on
+// branch that succeeded, and the number of runs it is based on.
+func successRate(ctx context.Context, project circleci.Project, branch string) (float64, int, error) {
+	metrics, err := project.WorkflowInsights(ctx, branch)
+	if err != nil {
+		return 0, 0, err
+	}
+	runs, successful := 0, 0
+	for _, workflow := range metrics {
+		runs += workflow.TotalRuns
+		successful += workflow.SuccessfulRuns
+	}
+	if runs == 0 {
+		return 0, 0, nil
+	}
+	return float64(successful) / float64(runs), runs, nil
+}
+
+// allowTrigger reports whether the project should be triggered on branch.
+// Projects without recent runs, or whose insights cannot be read, are
+// triggered.
+func (g insightsGate) allowTrigger(ctx context.Context, project circleci.Project, branch string) bool {
+	if g.minSuccessRate <= 0 {
+		return true
+	}
+	rate, runs, err := successRate(ctx, project, branch)
+	if err != nil {
+		logWarnf("Could not check the recent workflows of %s, triggering it anyway: %v", project.FullName(), err)
+		return true
+	}
+	if runs == 0 || rate >= g.minSuccessRate {
+		return true
+	}
+	if g.action == insightsFlag {
+		logWarnf("Recent workflows of %s succeeded %.0f%% of %d runs, triggering it anyway",
+			project.FullName(), rate*100, runs)
+		return true
+	}
+	logWarnf("Not triggering %s as its recent workflows succeeded %.0f%% of %d runs, below %.0f%%",
+		project.FullName(), rate*100, runs, g.minSuccessRate*100)
+	return false
+}
+
+// validate checks the gate's settings.
+func (g insightsGate) validate() error {
+	if g.minSuccessRate < 0 || g.minSuccessRate > 1 {
+		return fmt.Errorf("invalid -trigger-min-success-rate %v, expected between 0 and 1", g.minSuccessRate)
+	}
+	if g.action != insightsSkip && g.action != insightsFlag {
+		return fmt.Errorf("invalid -trigger-gate %q, expected skip or flag", g.action)
+	}
+	return nil
+}
+
+// WorkflowThresholds are the pipeline health a workflow is expected to keep.
+type WorkflowThresholds struct {
+	MinSuccessRate float64       `yaml:"minSuccessRate"` // Between 0 and 1, no check if 0
+	MaxDuration    time.Duration `yaml:"maxDuration"`    // Longest 95th percentile duration, no check if 0
+}
+
+// InsightsThresholds declare the pipeline health of a project, checked by
+// the audit command against Insights.
+type InsightsThresholds struct {
+	WorkflowThresholds `yaml:",inline"` // Thresholds of every workflow
+
+	Branch    string                        `yaml:"branch"`    // Branch whose runs are checked, the default branch if empty
+	Workflows map[string]WorkflowThresholds `yaml:"workflows"` // Thresholds of specific workflows, keyed by name
+}
+
+// declared reports whether any threshold is set.
+func (t InsightsThresholds) declared() bool {
+	if t.MinSuccessRate > 0 || t.MaxDuration > 0 {
+		return true
+	}
+	for _, workflow := range t.Workflows {
+		if workflow.MinSuccessRate > 0 || workflow.MaxDuration > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// forWorkflow returns the thresholds of the named workflow, its own ones
+// taking precedence over those of every workflow.
+func (t InsightsThresholds) forWorkflow(name string) WorkflowThresholds {
+	thresholds := t.WorkflowThresholds
+	if workflow, ok := t.Workflows[name]; ok {
+		if workflow.MinSuccessRate > 0 {
+			thresholds.MinSuccessRate = workflow.MinSuccessRate
+		}
+		if workflow.MaxDuration > 0 {
+			thresholds.MaxDuration = workflow.MaxDuration
+		}
+	}
+	return thresholds
+}
+
+// validate checks the thresholds' values.
+func (t InsightsThresholds) validate() error {
+	check := func(thresholds WorkflowThresholds) error {
+		if thresholds.MinSuccessRate < 0 || thresholds.MinSuccessRate > 1 {
+			return fmt.Errorf("invalid minSuccessRate %v, expected between 0 and 1", thresholds.MinSuccessRate)
+		}
+		if thresholds.MaxDuration < 0 {
+			return fmt.Errorf("invalid maxDuration %v, expected a positive duration", thresholds.MaxDuration)
+		}
+		return nil
+	}
+	if err := check(t.WorkflowThresholds); err != nil {
+		return err
+	}
+	for name, workflow := range t.Workflows {
+		if err := check(workflow); err != nil {
+			return fmt.Errorf("workflow %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// InsightsFinding is a workflow breaking one of its thresholds.
+type InsightsFinding struct {
+	Workflow string
+	Metric   string // success rate or duration
+	Actual   string
+	Expected string
+}
+
+// auditInsights returns the workflows of the project breaking the
+// thresholds, ordered by name. Workflows without recent runs are not
+// checked.
+func auditInsights(metrics []circleci.WorkflowMetrics, thresholds InsightsThresholds) []InsightsFinding {
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	var findings []InsightsFinding
+	for _, workflow := range metrics {
+		if workflow.TotalRuns == 0 {
+			continue
+		}
+		expected := thresholds.forWorkflow(workflow.Name)
+		rate := float64(workflow.SuccessfulRuns) / float64(workflow.TotalRuns)
+		if expected.MinSuccessRate > 0 && rate < expected.MinSuccessRate {
+			findings = append(findings, InsightsFinding{workflow.Name, "success rate",
+				fmt.Sprintf("%.0f%% of %d runs", rate*100, workflow.TotalRuns),
+				fmt.Sprintf("at least %.0f%%", expected.MinSuccessRate*100)})
+		}
+		if expected.MaxDuration > 0 && workflow.P95Duration > expected.MaxDuration {
+			findings = append(findings, InsightsFinding{workflow.Name, "duration",
+				fmt.Sprintf("%v at the 95th percentile", workflow.P95Duration.Round(time.Second)),
+				fmt.Sprintf("at most %v", expected.MaxDuration)})
+		}
+	}
+	return findings
+}
+
+// printInsightsAudit writes the findings to w.
+func printInsightsAudit(w io.Writer, projectName string, findings []InsightsFinding) {
+	if len(findings) == 0 {
+		fmt.Fprintf(w, "All workflows of project %s are within their thresholds\n", projectName)
+		return
+	}
+	fmt.Fprintf(w, "Workflows of project %s breaking their thresholds:\n", projectName)
+	for _, finding := range findings {
+		fmt.Fprintf(w, "  %s %s %s (expected %s)\n", finding.Workflow, paint(w, colorRed, finding.Metric),
+			finding.Actual, finding.Expected)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestInsightsGateAllowTrigger(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"GET /insights/gh/test/test/workflows": {Status: http.StatusOK, Body: `{"items": [
+			{"name": "build", "metrics": {"total_runs": 8, "successful_runs": 2}},
+			{"name": "deploy", "metrics": {"total_runs": 2, "successful_runs": 2}}]}`},
+	})
+	defer svr.Close()
+	client := svr.client()
+	project := circleci.NewProjectV2WithClient("gh", "test", "test", client, client)
+
+	type test struct {
+		gate     insightsGate
+		expected bool
+	}
+
+	// 4 of the 10 recent runs succeeded.
+	testCases := []test{
+		{insightsGate{}, true},
+		{insightsGate{minSuccessRate: 0.4, action: insightsSkip}, true},
+		{insightsGate{minSuccessRate: 0.9, action: insightsSkip}, false},
+		{insightsGate{minSuccessRate: 0.9, action: insightsFlag}, true},
+	}
+
+	for _, tc := range testCases {
+		actual := tc.gate.allowTrigger(context.Background(), project, "main")
+		if actual != tc.expected {
+			t.Errorf("Expected %v for %+v, found %v", tc.expected, tc.gate, actual)
+		}
+	}
+
+	// Insights are not available through API v1.1, so the gate lets it through.
+	gate := insightsGate{minSuccessRate: 0.9, action: insightsSkip}
+	if !gate.allowTrigger(context.Background(), svr.project(), "main") {
+		t.Error("Expected a project without insights to be triggered")
+	}
+}
+
+func TestInsightsGateValidate(t *testing.T) {
+	if err := (insightsGate{minSuccessRate: 1.5, action: insightsSkip}).validate(); err == nil {
+		t.Error("Expected an error for a success rate above 1")
+	}
+	if err := (insightsGate{action: "ignore"}).validate(); err == nil {
+		t.Error("Expected an error for an unknown action")
+	}
+	if err := (insightsGate{minSuccessRate: 0.5, action: insightsFlag}).validate(); err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+}
+
+func TestInsightsThresholds(t *testing.T) {
+	data := `
+insights:
+  branch: main
+  minSuccessRate: 0.8
+  maxDuration: 10m
+  workflows:
+    deploy:
+      maxDuration: 30m
+`
+	var config Config
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	thresholds := config.Insights
+	if !thresholds.declared() || thresholds.Branch != "main" {
+		t.Fatalf("Expected thresholds on main, found %+v", thresholds)
+	}
+	expected := WorkflowThresholds{MinSuccessRate: 0.8, MaxDuration: 30 * time.Minute}
+	if actual := thresholds.forWorkflow("deploy"); actual != expected {
+		t.Errorf("Expected %+v for deploy, found %+v", expected, actual)
+	}
+	if err := thresholds.validate(); err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+
+	thresholds.Workflows["deploy"] = WorkflowThresholds{MinSuccessRate: 2}
+	if err := thresholds.validate(); err == nil {
+		t.Error("Expected an error for a success rate above 1")
+	}
+	if (InsightsThresholds{}).declared() {
+		t.Error("Expected no thresholds to be declared")
+	}
+}
+
+func TestAuditInsights(t *testing.T) {
+	thresholds := InsightsThresholds{
+		WorkflowThresholds: WorkflowThresholds{MinSuccessRate: 0.8, MaxDuration: 10 * time.Minute},
+		Workflows:          map[string]WorkflowThresholds{"deploy": {MaxDuration: 30 * time.Minute}},
+	}
+	metrics := []circleci.WorkflowMetrics{
+		{Name: "test", TotalRuns: 10, SuccessfulRuns: 6, P95Duration: 12 * time.Minute},
+		{Name: "deploy", TotalRuns: 4, SuccessfulRuns: 4, P95Duration: 20 * time.Minute},
+		{Name: "build", TotalRuns: 10, SuccessfulRuns: 9, P95Duration: 5 * time.Minute},
+		{Name: "nightly"},
+	}
+
+	expected := []InsightsFinding{
+		{"test", "success rate", "60% of 10 runs", "at least 80%"},
+		{"test", "duration", "12m0s at the 95th percentile", "at most 10m0s"},
+	}
+	actual := auditInsights(metrics, thresholds)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, found %+v", expected, actual)
+	}
+}
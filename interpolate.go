@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// envReference matches ${NAME} references in env var values, and $${NAME}
+// escapes of them.
+var envReference = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// interpolate replaces the ${NAME} references in value with the variables
+// returned by lookup, failing if one of them is unset. $${NAME} is left as
+// the literal ${NAME}.
+func interpolate(value string, lookup func(string) (string, bool)) (string, error) {
+	var err error
+	result := envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		if ref[1] == '$' {
+			return ref[1:]
+		}
+		name := envReference.FindStringSubmatch(ref)[1]
+		resolved, ok := lookup(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return resolved
+	})
+	return result, err
+}
+
+// interpolateEnvVars expands the ${NAME} references in the values of the
+// config's project and context env vars.
+func interpolateEnvVars(config *Config, lookup func(string) (string, bool)) error {
+	err := interpolateMap(config.EnvVars, lookup)
+	if err != nil {
+		return err
+	}
+	for _, context := range config.Contexts {
+		err = interpolateMap(context.EnvVars, lookup)
+		if err != nil {
+			return fmt.Errorf("context %s: %v", context.Name, err)
+		}
+	}
+	return nil
+}
+
+func interpolateMap(envVars map[string]string, lookup func(string) (string, bool)) error {
+	for _, name := range sortedKeys(envVars) {
+		value, err := interpolate(envVars[name], lookup)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		envVars[name] = value
+	}
+	return nil
+}
+
+// placeholderMarker matches what is left of a template action or ${NAME}
+// reference that was not resolved, e.g. a ${NAME with no closing brace.
+var placeholderMarker = regexp.MustCompile(`\{\{.*?\}\}|\{\{|\}\}|\$\{[^}]*\}?`)
+
+// leftoverPlaceholder returns the first placeholder left in value besides
+// the ${NAME} references and $${NAME} escapes interpolation resolves, or ""
+// if there is none.
+func leftoverPlaceholder(value string) string {
+	return placeholderMarker.FindString(envReference.ReplaceAllString(value, ""))
+}
+
+// unresolvedPlaceholders lists the env vars and SSH key paths of the config
+// with a placeholder left in them, along with the placeholder. Values are
+// not listed, as they may be secrets.
+func unresolvedPlaceholders(config Config) []string {
+	var found []string
+	check := func(format string, values map[string]string) {
+		for _, name := range sortedKeys(values) {
+			if marker := leftoverPlaceholder(values[name]); marker != "" {
+				found = append(found, fmt.Sprintf(format, name)+" ("+marker+")")
+			}
+		}
+	}
+	check("env var %s", config.EnvVars)
+	for _, context := range config.Contexts {
+		check("env var %s of context "+context.Name, context.EnvVars)
+	}
+	check("SSH key path of %s", config.SSHKeys)
+	return found
+}
+
+// checkPlaceholders warns about the placeholders left in the config read
+// from configFile, or fails on them in strict mode.
+func checkPlaceholders(configFile string, config Config, strict bool) error {
+	found := unresolvedPlaceholders(config)
+	if len(found) == 0 {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("unresolved placeholders in %s: %s", configFile, strings.Join(found, ", "))
+	}
+	for _, placeholder := range found {
+		logWarnf("Unresolved placeholder in %s: %s, pass -strict to fail on it", configFile, placeholder)
+	}
+	return nil
+}
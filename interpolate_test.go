@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"TOKEN": "s3cret", "HOST": "example.com"}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"${TOKEN}", "s3cret"},
+		{"https://${HOST}/?t=${TOKEN}", "https://example.com/?t=s3cret"},
+		{"$${TOKEN}", "${TOKEN}"},
+		{"$TOKEN", "$TOKEN"},
+	}
+	for _, tc := range testCases {
+		actual, err := interpolate(tc.input, lookup)
+		if err != nil {
+			t.Errorf("Expected no error interpolating %q, found: %v", tc.input, err)
+		} else if actual != tc.expected {
+			t.Errorf("Expected %q to interpolate to %q, found %q", tc.input, tc.expected, actual)
+		}
+	}
+
+	_, err := interpolate("${MISSING}", lookup)
+	if err == nil || err.Error() != "environment variable MISSING is not set" {
+		t.Errorf("Expected an unset variable error, found: %v", err)
+	}
+}
+
+func TestInterpolateEnvVars(t *testing.T) {
+	lookup := func(name string) (string, bool) { return "", false }
+	config := Config{
+		Contexts: []ContextConfig{{Name: "deploy", EnvVars: map[string]string{"KEY": "${DEPLOY_KEY}"}}},
+	}
+	err := interpolateEnvVars(&config, lookup)
+	expected := "context deploy: KEY: environment variable DEPLOY_KEY is not set"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, found: %v", expected, err)
+	}
+}
+
+func TestLeftoverPlaceholder(t *testing.T) {
+	testCases := map[string]string{
+		"plain":                    "",
+		"${TOKEN} and $${LITERAL}": "",
+		"${DATADOG_API_KEY":        "${DATADOG_API_KEY",
+		"${ TOKEN }":               "${ TOKEN }",
+		"{{ .Values.token }}":      "{{ .Values.token }}",
+		"prefix-{{ env \"X\" ":     "{{",
+	}
+	for value, expected := range testCases {
+		if actual := leftoverPlaceholder(value); actual != expected {
+			t.Errorf("Expected %q to leave %q, found %q", value, expected, actual)
+		}
+	}
+}
+
+func TestCheckPlaceholders(t *testing.T) {
+	config := Config{
+		EnvVars:  EnvVars{"OK": "${TOKEN}", "TYPO": "${TOKEN"},
+		Contexts: []ContextConfig{{Name: "shared", EnvVars: map[string]string{"KEY": "{{ .key }}"}}},
+	}
+	if err := checkPlaceholders("project.yml", config, false); err != nil {
+		t.Errorf("Expected placeholders to only be warned about, found: %v", err)
+	}
+	err := checkPlaceholders("project.yml", config, true)
+	expected := "unresolved placeholders in project.yml: env var TYPO (${TOKEN), env var KEY of context shared ({{ .key }})"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, found: %v", expected, err)
+	}
+}
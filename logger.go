@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logLevel is the severity of a log message.
+type logLevel int
+
+// Log levels, from least to most severe.
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var levelNames = map[logLevel]string{
+	levelDebug: "debug",
+	levelInfo:  "info",
+	levelWarn:  "warn",
+	levelError: "error",
+}
+
+// Log formats.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logger writes leveled log messages as text or as JSON lines.
+type logger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	level  logLevel // Least severe level written
+	format string
+	now    func() time.Time
+}
+
+// logs is the logger used by the whole run, configured by the common flags.
+var logs = &logger{w: os.Stderr, level: levelInfo, format: logFormatText, now: time.Now}
+
+// configureLogging sets the format of the run's logs, and writes debug
+// messages if verbose is set.
+func configureLogging(format string, verbose bool) error {
+	if format != logFormatText && format != logFormatJSON {
+		return fmt.Errorf("invalid -log-format %q, expected text or json", format)
+	}
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	logs.format = format
+	logs.level = levelInfo
+	if verbose {
+		logs.level = levelDebug
+	}
+	return nil
+}
+
+func (l *logger) logf(level logLevel, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	now := l.now()
+	if l.format == logFormatJSON {
+		line, _ := json.Marshal(struct {
+			Time  string `json:"time"`
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}{now.Format(time.RFC3339), levelNames[level], msg})
+		fmt.Fprintf(l.w, "%s\n", line)
+		return
+	}
+	fmt.Fprintf(l.w, "%s %-5s %s\n", now.Format("2006/01/02 15:04:05"), strings.ToUpper(levelNames[level]), msg)
+}
+
+// debugEnabled reports whether debug messages are written, for callers
+// that need to do extra work to produce them.
+func debugEnabled() bool {
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	return logs.level <= levelDebug
+}
+
+// logDebugf logs a message only shown with -verbose.
+func logDebugf(format string, args ...interface{}) { logs.logf(levelDebug, format, args...) }
+
+// logInfof logs the progress of the run.
+func logInfof(format string, args ...interface{}) { logs.logf(levelInfo, format, args...) }
+
+// logWarnf logs something that did not stop the run but may need attention.
+func logWarnf(format string, args ...interface{}) { logs.logf(levelWarn, format, args...) }
+
+// logErrorf logs a failure.
+func logErrorf(format string, args ...interface{}) { logs.logf(levelError, format, args...) }
+
+// Debugf logs a debug message, so that the logger can take the messages of
+// the circleci package.
+func (l *logger) Debugf(format string, args ...interface{}) { l.logf(levelDebug, format, args...) }
+
+// Warnf logs a warning.
+func (l *logger) Warnf(format string, args ...interface{}) { l.logf(levelWarn, format, args...) }
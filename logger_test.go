@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	now := func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	testCases := []struct {
+		format   string
+		level    logLevel
+		expected string
+	}{
+		{logFormatText, levelInfo, "2026/01/02 03:04:05 INFO  Following a\n2026/01/02 03:04:05 WARN  Retrying b\n"},
+		{logFormatText, levelDebug, "2026/01/02 03:04:05 DEBUG > GET\n2026/01/02 03:04:05 INFO  Following a\n" +
+			"2026/01/02 03:04:05 WARN  Retrying b\n"},
+		{logFormatJSON, levelInfo, `{"time":"2026-01-02T03:04:05Z","level":"info","msg":"Following a"}` + "\n" +
+			`{"time":"2026-01-02T03:04:05Z","level":"warn","msg":"Retrying b"}` + "\n"},
+	}
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		l := &logger{w: &buf, level: tc.level, format: tc.format, now: now}
+		l.logf(levelDebug, "> GET")
+		l.logf(levelInfo, "Following %s", "a")
+		l.logf(levelWarn, "Retrying %s", "b")
+		if buf.String() != tc.expected {
+			t.Errorf("Expected %s logs at level %d to be %q, found %q", tc.format, tc.level, tc.expected, buf.String())
+		}
+	}
+}
+
+func TestConfigureLogging(t *testing.T) {
+	defer configureLogging(logFormatText, false)
+	err := configureLogging(logFormatJSON, true)
+	if err != nil || !debugEnabled() || logs.format != logFormatJSON {
+		t.Errorf("Expected verbose JSON logs, found %s at level %d (%v)", logs.format, logs.level, err)
+	}
+	if err = configureLogging("xml", false); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
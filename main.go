@@ -1,170 +1,299 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
-	"strconv"
+	"path/filepath"
+	"strings"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
 )
 
 // Config represents the configuration of a CircleCI project
 type Config struct {
-	VcsType     string            `yaml:"vcsType"`     // Type of VCS used (e.g. git)
-	Owner       string            `yaml:"owner"`       // Project owner (e.g. user or org)
-	ProjectName string            `yaml:"projectName"` // Project to be followed
-	EnvVars     map[string]string `yaml:"envVars"`     // Env vars to set
-	SSHKeys     map[string]string `yaml:"sshKeys"`     // SSH keys to add
+	Version          int                       `yaml:"version"`           // Version of the config schema
+	VcsType          string                    `yaml:"vcsType"`           // Type of VCS used (e.g. git)
+	Owner            string                    `yaml:"owner"`             // Project owner (e.g. user or org)
+	ProjectName      string                    `yaml:"projectName"`       // Project to be followed
+	EnvVars          EnvVars                   `yaml:"envVars"`           // Env vars to set
+	EnvFiles         []string                  `yaml:"envFiles"`          // Dotenv or JSON files of env vars to set
+	SSHKeys          SSHKeys                   `yaml:"sshKeys"`           // SSH keys to add
+	ProtectedEnvVars []string                  `yaml:"protectedEnvVars"`  // Env vars canonical mode never removes, e.g. set by other systems
+	ProtectedSSHKeys []string                  `yaml:"protectedSSHKeys"`  // Hostnames whose SSH keys canonical mode never removes
+	SSHProbe         SSHProbe                  `yaml:"sshProbe"`          // Pipelines checking the SSH keys authenticate
+	CheckoutKeys     []string                  `yaml:"checkoutKeys"`      // Checkout key types the project should have (deploy-key, user-key)
+	Settings         *circleci.BuildSettings   `yaml:"settings"`          // Build settings toggles to set
+	TriggerParams    map[string]interface{}    `yaml:"triggerParameters"` // Pipeline parameters of builds triggered once provisioned
+	Integrations     Integrations              `yaml:"integrations"`      // Third party integrations to configure
+	Contexts         []ContextConfig           `yaml:"contexts"`          // Organisation contexts to provision
+	AttachContexts   []string                  `yaml:"attachContexts"`    // Contexts the project should be able to use
+	Namespaces       map[string]Namespace      `yaml:"namespaces"`        // Per sub-project env vars, prefixed with the namespace
+	Reserved         ReservedEnvVars           `yaml:"reservedEnvVars"`   // What to do with env vars CircleCI sets itself
+	Approval         ApprovalConfig            `yaml:"approval"`          // Approval webhook overriding the -approval-url one
+	APIURL           string                    `yaml:"apiURL"`            // CircleCI installation of the project, overriding -api-url
+	Insights         InsightsThresholds        `yaml:"insights"`          // Pipeline health the audit command checks
+	Webhooks         map[string]WebhookConfig  `yaml:"webhooks"`          // Outbound webhooks, keyed by name
+	Schedules        map[string]ScheduleConfig `yaml:"schedules"`         // Scheduled pipelines, keyed by name
+	OIDC             *OIDCConfig               `yaml:"oidc"`              // Custom claims of the OIDC tokens of the project's jobs
+	PipelineValues   PipelineValuesConfig      `yaml:"pipelineValues"`    // Env vars to also write as pipeline values for dynamic config
+	Storage          *StorageConfig            `yaml:"storage"`           // How long artifacts, workspaces and caches are kept
+
+	Expiry     map[string]time.Time  `yaml:"-"` // When env vars declaring expiresAt expire, keyed by full name
+	Sources    map[string][]string   `yaml:"-"` // Sources of env vars declared as fallback chains, keyed by full name
+	Provenance map[string]string     `yaml:"-"` // Source each fallback chain was resolved from, keyed by full name
+	Files      map[string]envVarFile `yaml:"-"` // Files of env vars declared with fromFile, keyed by full name
+
+	RetiringSSHKeys  map[string][]RetiringSSHKey `yaml:"-"` // Previous keys kept during rotation, keyed by hostname
+	GeneratedSSHKeys map[string]GeneratedSSHKey  `yaml:"-"` // Keys generated rather than read from a file, keyed by hostname
+	DeployKeys       map[string]string           `yaml:"-"` // Access of the keys registered as GitHub deploy keys, keyed by hostname
 }
 
-func main() {
-	tokenEnv := os.Getenv("CIRCLECI_TOKEN")
-	configFileEnv := os.Getenv("CIRCLECI_CONFIG")
-	isCanonicalEnv, err := strconv.ParseBool(os.Getenv("CIRCLECI_CANONICAL"))
+// UnmarshalYAML reads the config, collecting the expiresAt and sources of its
+// env vars, the previous SSH keys being rotated out, those to generate and
+// those to register as deploy keys.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Config
+	err := unmarshal((*plain)(c))
 	if err != nil {
-		isCanonicalEnv = false
+		return err
+	}
+
+	var specs struct {
+		EnvVars    map[string]envVarSpec `yaml:"envVars"`
+		Namespaces map[string]struct {
+			EnvVars map[string]envVarSpec `yaml:"envVars"`
+		} `yaml:"namespaces"`
+		SSHKeys map[string]sshKeySpecs `yaml:"sshKeys"`
 	}
-	shouldTriggerEnv, err := strconv.ParseBool(os.Getenv("CIRCLECI_TRIGGER"))
+	err = unmarshal(&specs)
 	if err != nil {
-		shouldTriggerEnv = false
+		return err
+	}
+	err = c.collectRetiringSSHKeys(specs.SSHKeys)
+	if err == nil {
+		err = c.collectGeneratedSSHKeys(specs.SSHKeys)
+	}
+	if err == nil {
+		err = c.collectDeployKeys(specs.SSHKeys)
 	}
-	shouldUnfollowEnv, err := strconv.ParseBool(os.Getenv("CIRCLECI_UNFOLLOW"))
 	if err != nil {
-		shouldUnfollowEnv = false
+		return err
 	}
-
-	token := flag.String("token", tokenEnv, "Circle CI token")
-	configFile := flag.String("config", configFileEnv, "Circle CI provisioning config")
-	isCanonical := flag.Bool("canonical", isCanonicalEnv,
-		"Project should be exactly as described in the config. "+
-			" WARNING: This may remove environment variables and ssh keys")
-	shouldTrigger := flag.Bool("trigger", shouldTriggerEnv, "Trigger a build of the project once it is setup")
-	shouldUnfollow := flag.Bool("unfollow", shouldUnfollowEnv, "Unfollow the project")
-	flag.Parse()
-
-	if token == nil || *token == "" {
-		log.Fatal("-token is required or CIRCLECI_TOKEN should be set")
+	err = c.collectExpiry("", specs.EnvVars)
+	if err == nil {
+		err = c.collectSources("", specs.EnvVars)
 	}
-
-	if configFile == nil || *configFile == "" {
-		log.Fatal("-config is required or CIRCLECI_CONFIG should be set")
+	if err == nil {
+		err = c.collectFiles("", specs.EnvVars)
 	}
-
-	config, err := readConfig(*configFile)
 	if err != nil {
-		log.Fatalf("Could not read config file %s: %v", *configFile, err)
+		return err
 	}
+	for namespace, spec := range specs.Namespaces {
+		prefix := namespacePrefix(namespace) + namespaceSeparator
+		err = c.collectExpiry(prefix, spec.EnvVars)
+		if err == nil {
+			err = c.collectSources(prefix, spec.EnvVars)
+		}
+		if err == nil {
+			err = c.collectFiles(prefix, spec.EnvVars)
+		}
+		if err != nil {
+			return fmt.Errorf("namespace %s: %v", namespace, err)
+		}
+	}
+	return nil
+}
+
+// buildSettingsFlags returns the feature flags setting the config's build
+// settings, if any.
+func (c Config) buildSettingsFlags() map[string]interface{} {
+	if c.Settings == nil {
+		return nil
+	}
+	return c.Settings.FeatureFlags()
+}
 
-	project := NewCircleCIProject(config.VcsType, config.Owner, config.ProjectName, *token)
+// Integrations configures the third party integrations of a project.
+type Integrations struct {
+	Jira *circleci.JiraIntegration `yaml:"jira"` // Issue tracker linking
+}
 
-	if *shouldUnfollow {
-		log.Printf("Unfollowing %s", project.FullName())
-		project.Unfollow()
-		return
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(exitUsage)
 	}
 
-	log.Printf("Following %s", project.FullName())
-	err = project.Follow()
-	if err != nil {
-		log.Fatalf("Error: Could not follow %s: %v", project.FullName(), err)
+	name, args := os.Args[1], os.Args[2:]
+	switch {
+	case name == "help" || name == "-h" || name == "-help" || name == "--help":
+		usage(os.Stdout)
+		return
+	case strings.HasPrefix(name, "-"):
+		// Flags without a subcommand are how the tool used to be run.
+		logWarnf("Running %s without a command is deprecated, use '%[1]s provision'", os.Args[0])
+		name, args = "provision", os.Args[1:]
 	}
 
-	if *isCanonical {
-		log.Printf("Making config %s canonical for project %s", *configFile, project.FullName())
-		err = cleanProject(project)
-		if err != nil {
-			log.Fatalf("Error: Could not make config %s canonical for project %s: %v",
-				*configFile, project.FullName(), err)
-		}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		usage(os.Stderr)
+		os.Exit(exitUsage)
+	}
+	err := cmd.run(args)
+	if reportError(err) {
+		logErrorf("%v", err)
 	}
+	os.Exit(exitCode(err))
+}
 
-	log.Printf("Setting environment variables for project %s", project.FullName())
-	err = setEnvVars(project, config.EnvVars)
+func readConfig(configFile string, opts configOptions) (Config, error) {
+	data, err := loadConfig(configFile, opts)
 	if err != nil {
-		log.Fatalf("Error: Could not set environment variables for project %s: %v", project.FullName(), err)
+		return Config{}, err
+	}
+	if isOrgConfig(data) {
+		return Config{}, fmt.Errorf("%s is an org config, which only provision and apply read", configFile)
 	}
+	return parseConfig(configFile, data, opts)
+}
 
-	log.Printf("Adding ssh keys for project %s", project.FullName())
-	err = addSSHKeys(project, config.SSHKeys)
+// loadConfig reads configFile, decrypting and rendering it, and returns it
+// as YAML.
+func loadConfig(configFile string, opts configOptions) ([]byte, error) {
+	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		log.Fatalf("Error: Could not add SSH Keys for project %s: %v", project.FullName(), err)
+		return nil, err
 	}
-
-	if *shouldTrigger {
-		log.Printf("Triggering build of %s", project.FullName())
-		err := project.Trigger()
+	if isSOPSEncrypted(data) {
+		data, err = decryptSOPS(configFile)
 		if err != nil {
-			log.Fatalf("Error: Could not trigger build for project %s: %v", project.FullName(), err)
+			return nil, fmt.Errorf("could not decrypt %s: %v", configFile, err)
 		}
 	}
-
-	log.Printf("Project %s has been successfully provisioned using %s", project.FullName(), *configFile)
+	if !opts.noTemplate {
+		data, err = renderTemplate(configFile, data, opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not render %s: %v", configFile, err)
+		}
+	}
+	format, err := configFormat(configFile, opts.format)
+	if err == nil {
+		data, err = convertToYAML(data, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", configFile, err)
+	}
+	return data, nil
 }
 
-func readConfig(configFile string) (Config, error) {
+// parseConfig parses the YAML config data read from configFile, resolving
+// its env vars.
+func parseConfig(configFile string, data []byte, opts configOptions) (Config, error) {
 	config := Config{}
-	fh, err := os.Open(configFile)
+	data, err := upgradeConfig(configFile, data)
 	if err != nil {
-		return config, err
+		return config, fmt.Errorf("invalid config %s: %v", configFile, err)
 	}
-	defer fh.Close()
-
-	data, err := ioutil.ReadAll(fh)
+	err = yaml.Unmarshal(data, &config)
 	if err != nil {
-		return config, fmt.Errorf("could not read %s: %v", configFile, err)
+		return config, fmt.Errorf("could not unmarshal %s: %v", configFile, err)
 	}
-	err = yaml.Unmarshal([]byte(data), &config)
+	err = checkVCS(config.VcsType, config.Owner, config.ProjectName)
 	if err != nil {
-		return config, fmt.Errorf("could not unmarshal %s: %v", configFile, err)
+		return config, fmt.Errorf("invalid project in %s: %v", configFile, err)
 	}
-
-	return config, nil
-}
-
-func addSSHKeys(project Project, sshKeys map[string]string) error {
-	for name, path := range sshKeys {
-		fh, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("could not open SSH key at path %s: %v", path, err)
+	if config.APIURL != "" {
+		if _, _, err := circleci.BaseURLs(config.APIURL); err != nil {
+			return config, fmt.Errorf("invalid apiURL in %s: %v", configFile, err)
 		}
-		defer fh.Close()
-		content, err := ioutil.ReadAll(fh)
-		if err != nil {
-			return fmt.Errorf("could not read SSH Key at path %s: %v", path, err)
+	}
+	err = config.Insights.validate()
+	if err != nil {
+		return config, fmt.Errorf("invalid insights thresholds in %s: %v", configFile, err)
+	}
+	err = checkWebhooks(config)
+	if err != nil {
+		return config, fmt.Errorf("invalid webhooks in %s: %v", configFile, err)
+	}
+	if _, err = projectSchedules(config); err != nil {
+		return config, fmt.Errorf("invalid schedules in %s: %v", configFile, err)
+	}
+	if config.OIDC != nil {
+		if err = config.OIDC.validate(); err != nil {
+			return config, fmt.Errorf("invalid oidc in %s: %v", configFile, err)
 		}
-		err = project.AddSSHKey(name, string(content))
-		if err != nil {
-			return fmt.Errorf("could not add SSH key %s for project %s: %v", path, project.FullName(), err)
+	}
+	if config.Storage != nil {
+		if err = config.Storage.validate(); err != nil {
+			return config, fmt.Errorf("invalid storage in %s: %v", configFile, err)
 		}
 	}
-	return nil
+	err = expandEnvFiles(&config, filepath.Dir(configFile))
+	if err != nil {
+		return config, fmt.Errorf("invalid env files in %s: %v", configFile, err)
+	}
+	err = expandNamespaces(&config)
+	if err != nil {
+		return config, fmt.Errorf("invalid namespaces in %s: %v", configFile, err)
+	}
+	err = checkReservedEnvVars(&config)
+	if err != nil {
+		return config, fmt.Errorf("invalid env vars in %s: %v", configFile, err)
+	}
+	err = checkPlaceholders(configFile, config, opts.strict)
+	if err != nil {
+		return config, err
+	}
+	err = interpolateEnvVars(&config, os.LookupEnv)
+	if err != nil {
+		return config, fmt.Errorf("could not interpolate env vars in %s: %v", configFile, err)
+	}
+	err = checkPipelineValues(&config, filepath.Dir(configFile), opts.secrets)
+	if err != nil {
+		return config, fmt.Errorf("invalid pipelineValues in %s: %v", configFile, err)
+	}
+	err = resolveSecrets(&config, opts.secrets)
+	if err != nil {
+		return config, fmt.Errorf("could not resolve secrets in %s: %v", configFile, err)
+	}
+	err = resolveWebhookSecrets(&config, opts.secrets, os.LookupEnv)
+	if err != nil {
+		return config, fmt.Errorf("could not resolve webhook secrets in %s: %v", configFile, err)
+	}
+	err = resolveSources(&config, opts.secrets, os.LookupEnv)
+	if err != nil {
+		return config, fmt.Errorf("could not resolve env var sources in %s: %v", configFile, err)
+	}
+	err = readEnvVarFiles(&config, filepath.Dir(configFile))
+	if err != nil {
+		return config, fmt.Errorf("could not read env var files in %s: %v", configFile, err)
+	}
+	return config, nil
 }
 
-func cleanProject(project Project) error {
-	err := project.Clearenv()
+// readYAML unmarshals the YAML file into out.
+func readYAML(file string, out interface{}) error {
+	fh, err := os.Open(file)
 	if err != nil {
-		return fmt.Errorf("there was an error clearing environment variables from project %s: %v",
-			project.FullName(), err)
+		return err
 	}
+	defer fh.Close()
 
-	err = project.ClearSSHKeys()
+	data, err := ioutil.ReadAll(fh)
 	if err != nil {
-		return fmt.Errorf("there was an error clearing SSH keys from project %s: %v", project.FullName(), err)
+		return fmt.Errorf("could not read %s: %v", file, err)
 	}
-	return nil
-}
-
-func setEnvVars(project Project, envVars map[string]string) error {
-	for k, v := range envVars {
-		log.Printf("Setting environment variable %s for project %s", k, project.FullName())
-		err := project.Setenv(k, v)
-		if err != nil {
-			return fmt.Errorf("could not set environment variable %s for project %s: %v",
-				k, project.FullName(), err)
-		}
+	err = yaml.Unmarshal([]byte(data), out)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal %s: %v", file, err)
 	}
+
 	return nil
 }
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// ManagedState records the resources provisioning manages, so that canonical
+// mode only removes resources it put in place rather than everything on a
+// project. It is kept as a JSON file.
+type ManagedState struct {
+	path string
+	mu   sync.Mutex
+
+	Projects map[string]ManagedResources `json:"projects"` // Keyed by project name
+	Contexts map[string]ManagedResources `json:"contexts"` // Keyed by owner/context
+}
+
+// ManagedResources are the names of the resources managed in a project or
+// context.
+type ManagedResources struct {
+	EnvVars []string `json:"envVars,omitempty"`
+	SSHKeys []string `json:"sshKeys,omitempty"` // Hostnames
+}
+
+// containsName reports whether name is one of names.
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenManagedState reads the state file at path, starting an empty state if
+// it does not exist yet.
+func OpenManagedState(path string) (*ManagedState, error) {
+	state := &ManagedState{path: path}
+	err := state.load()
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// load replaces the state with the one saved in the state file, if any.
+func (s *ManagedState) load() error {
+	var saved struct {
+		Projects map[string]ManagedResources `json:"projects"`
+		Contexts map[string]ManagedResources `json:"contexts"`
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not read state file %s: %v", s.path, err)
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &saved)
+		if err != nil {
+			return fmt.Errorf("corrupt state file %s: %v", s.path, err)
+		}
+	}
+	if saved.Projects == nil {
+		saved.Projects = make(map[string]ManagedResources)
+	}
+	if saved.Contexts == nil {
+		saved.Contexts = make(map[string]ManagedResources)
+	}
+	s.Projects, s.Contexts = saved.Projects, saved.Contexts
+	return nil
+}
+
+// Project returns the resources managed in the project.
+func (s *ManagedState) Project(name string) ManagedResources {
+	if s == nil {
+		return ManagedResources{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Projects[name]
+}
+
+// Context returns the env vars managed in the context.
+func (s *ManagedState) Context(owner, name string) ManagedResources {
+	if s == nil {
+		return ManagedResources{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Contexts[owner+"/"+name]
+}
+
+// SetProject records the resources managed in the project and saves the state.
+func (s *ManagedState) SetProject(name string, resources ManagedResources) error {
+	return s.update(func() { s.Projects[name] = resources })
+}
+
+// SetContext records the env vars managed in the context and saves the state.
+func (s *ManagedState) SetContext(owner, name string, resources ManagedResources) error {
+	return s.update(func() { s.Contexts[owner+"/"+name] = resources })
+}
+
+// update makes the change to the state saved in the state file and saves it,
+// holding the file's lock throughout, so that runs sharing the state file do
+// not drop each other's changes.
+func (s *ManagedState) update(change func()) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("could not lock state file %s: %v", s.path, err)
+	}
+	defer unlock()
+	err = s.load()
+	if err != nil {
+		return err
+	}
+	change()
+	return s.save()
+}
+
+// save writes the state to a temporary file renamed over the state file, so
+// that an interrupted write does not lose it.
+func (s *ManagedState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal state: %v", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("could not write state file %s: %v", s.path, err)
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write state file %s: %v", s.path, err)
+	}
+	return nil
+}
+
+// managedResources returns the resources of the config, plus those in
+// previous that are no longer configured but are still in place.
+func managedResources(config Config, previous ManagedResources, remaining ManagedResources) ManagedResources {
+	resources := ManagedResources{EnvVars: sortedKeys(config.EnvVars), SSHKeys: sortedKeys(config.SSHKeys)}
+	resources.SSHKeys = append(resources.SSHKeys, config.generatedHostnames()...)
+	for _, name := range remaining.EnvVars {
+		if containsName(previous.EnvVars, name) && !containsName(resources.EnvVars, name) {
+			resources.EnvVars = append(resources.EnvVars, name)
+		}
+	}
+	for _, hostname := range remaining.SSHKeys {
+		if containsName(previous.SSHKeys, hostname) && !containsName(resources.SSHKeys, hostname) {
+			resources.SSHKeys = append(resources.SSHKeys, hostname)
+		}
+	}
+	sort.Strings(resources.EnvVars)
+	sort.Strings(resources.SSHKeys)
+	return resources
+}
+
+// pruneManaged removes the managed env vars and SSH keys that are no longer
+// in the config, leaving resources the tool does not manage alone. The
+// managed resources that are left in place are returned.
+func pruneManaged(ctx context.Context, project circleci.Project, config Config, managed ManagedResources) (ManagedResources, error) {
+	remaining := ManagedResources{}
+	var err error
+	for i, name := range managed.EnvVars {
+		if _, ok := config.EnvVars[name]; ok || config.protectsEnvVar(name) {
+			continue
+		}
+		logInfof("Removing managed environment variable %s from project %s", name, project.FullName())
+		err = project.Deleteenv(ctx, name)
+		if err != nil {
+			remaining.EnvVars = append(remaining.EnvVars, managed.EnvVars[i:]...)
+			remaining.SSHKeys = managed.SSHKeys
+			return remaining, err
+		}
+	}
+	for i, hostname := range managed.SSHKeys {
+		if _, ok := config.SSHKeys[hostname]; ok || config.generatesSSHKey(hostname) || config.protectsSSHKey(hostname) {
+			continue
+		}
+		logInfof("Removing managed SSH key %s from project %s", hostname, project.FullName())
+		err = project.RemoveSSHKey(ctx, hostname)
+		if err != nil {
+			remaining.SSHKeys = append(remaining.SSHKeys, managed.SSHKeys[i:]...)
+			return remaining, err
+		}
+	}
+	return remaining, nil
+}
+
+// quarantineManaged quarantines the managed env vars that are no longer in
+// the config, returning them. Env vars the tool does not manage are left
+// alone.
+func quarantineManaged(ctx context.Context, project circleci.Project, config Config, managed ManagedResources) ([]string, error) {
+	current, err := project.Getenvs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Env vars the tool does not manage are treated as configured, unless
+	// they were quarantined before the state file was used.
+	markers := quarantineMarkers(current)
+	keep := make(map[string]string)
+	for name := range current {
+		if !containsName(managed.EnvVars, name) && markers[name] == "" {
+			keep[name] = ""
+		}
+	}
+	for name, value := range config.keptEnvVars() {
+		keep[name] = value
+	}
+	quarantined, err := quarantineEnvVars(ctx, project, keep, time.Now())
+	if err != nil {
+		return managed.EnvVars, err
+	}
+	// Quarantined env vars stay in place, and managed, until purged.
+	for _, name := range managed.EnvVars {
+		if _, ok := config.EnvVars[name]; !ok && !config.protectsEnvVar(name) && !containsName(quarantined, name) {
+			if _, exists := current[name]; exists {
+				quarantined = append(quarantined, name)
+			}
+		}
+	}
+	return quarantined, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestManagedStateRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	state, err := OpenManagedState(path)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	resources := ManagedResources{EnvVars: []string{"A", "B"}, SSHKeys: []string{"github.com"}}
+	err = state.SetProject("test/test", resources)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	err = state.SetContext("test", "shared", ManagedResources{EnvVars: []string{"C"}})
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+
+	state, err = OpenManagedState(path)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if actual := state.Project("test/test"); !reflect.DeepEqual(actual, resources) {
+		t.Errorf("Expected %+v, found %+v", resources, actual)
+	}
+	if actual := state.Context("test", "shared").EnvVars; !reflect.DeepEqual(actual, []string{"C"}) {
+		t.Errorf("Expected context env vars [C], found %v", actual)
+	}
+	var unset *ManagedState
+	if actual := unset.Project("test/test"); len(actual.EnvVars) != 0 {
+		t.Errorf("Expected nothing to be managed without a state, found %+v", actual)
+	}
+}
+
+func TestManagedStateConcurrentRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	first, err := OpenManagedState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := OpenManagedState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.SetProject("test/first", ManagedResources{EnvVars: []string{"A"}}); err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if err := second.SetProject("test/second", ManagedResources{EnvVars: []string{"B"}}); err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+
+	state, err := OpenManagedState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := state.Project("test/first").EnvVars; !reflect.DeepEqual(actual, []string{"A"}) {
+		t.Errorf("Expected the first run's env vars [A] to be kept, found %v", actual)
+	}
+	if actual := state.Project("test/second").EnvVars; !reflect.DeepEqual(actual, []string{"B"}) {
+		t.Errorf("Expected the second run's env vars [B], found %v", actual)
+	}
+}
+
+func TestPruneManaged(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"DELETE /project/git/test/test/envvar/OLD": {Status: http.StatusOK, Body: `{"message": "ok"}`},
+		"GET " + circlecitest.SettingsPath:         {Status: http.StatusOK, Body: circlecitest.Settings},
+		"DELETE /project/git/test/test/ssh-key":    {Status: http.StatusOK, Body: `{"message": "ok"}`},
+	})
+	defer svr.Close()
+
+	// The github.com keys are not managed, so are left alone.
+	config := Config{EnvVars: map[string]string{"KEEP": "1"}}
+	managed := ManagedResources{EnvVars: []string{"KEEP", "OLD"}, SSHKeys: []string{"example.com"}}
+	remaining, err := pruneManaged(context.Background(), svr.project(), config, managed)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if len(remaining.EnvVars) != 0 || len(remaining.SSHKeys) != 0 {
+		t.Errorf("Expected nothing to remain, found %+v", remaining)
+	}
+	expected := []string{
+		"DELETE /project/git/test/test/envvar/OLD",
+		"GET " + circlecitest.SettingsPath,
+		`DELETE /project/git/test/test/ssh-key {"hostname":"example.com","fingerprint":"cc"}`,
+	}
+	if !reflect.DeepEqual(svr.Requests(), expected) {
+		t.Errorf("Expected requests %q, found %q", expected, svr.Requests())
+	}
+}
+
+func TestPruneManagedKeepsFailures(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"DELETE /project/git/test/test/envvar/A": {Status: http.StatusInternalServerError, Body: `{}`},
+	})
+	defer svr.Close()
+
+	managed := ManagedResources{EnvVars: []string{"A", "B"}, SSHKeys: []string{"github.com"}}
+	remaining, err := pruneManaged(context.Background(), svr.project(), Config{}, managed)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !reflect.DeepEqual(remaining, managed) {
+		t.Errorf("Expected %+v to remain, found %+v", managed, remaining)
+	}
+}
+
+func TestManagedResources(t *testing.T) {
+	config := Config{EnvVars: map[string]string{"B": "1"}, SSHKeys: map[string]string{"github.com": "key"}}
+	previous := ManagedResources{EnvVars: []string{"A", "C"}}
+	remaining := ManagedResources{EnvVars: []string{"A", "D"}}
+
+	expected := ManagedResources{EnvVars: []string{"A", "B"}, SSHKeys: []string{"github.com"}}
+	actual := managedResources(config, previous, remaining)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, found %+v", expected, actual)
+	}
+}
+
+func TestComputePlanOnlyRemovesManaged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	managed, err := OpenManagedState(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = managed.SetProject("test/test", ManagedResources{EnvVars: []string{"OLD"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{Owner: "test", ProjectName: "test"}
+	state := ProjectState{Following: true, EnvVars: map[string]string{"OLD": "xxxx", "MANUAL": "xxxx"}}
+	plan := computePlan(config, state, provisionOptions{canonical: true, managed: managed})
+	expected := Plan{{"test/test/envvar/OLD", "envvar", "OLD", opRemove}}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("Expected plan %v, found %v", expected, plan)
+	}
+}
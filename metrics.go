@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// Metrics summarises a provisioning run.
+type Metrics struct {
+	mu             sync.Mutex
+	start          time.Time
+	actions        map[string]map[string]int
+	apiCalls       int
+	retries        int
+	quotaRemaining *int
+}
+
+// metricsSummary is the JSON representation of Metrics.
+type metricsSummary struct {
+	StartedAt       time.Time                 `json:"startedAt"`
+	DurationSeconds float64                   `json:"durationSeconds"`
+	Resources       map[string]map[string]int `json:"resources"`
+	APICalls        int                       `json:"apiCalls"`
+	Retries         int                       `json:"retries"`
+	QuotaRemaining  *int                      `json:"quotaRemaining,omitempty"`
+}
+
+// NewMetrics starts collecting metrics for a run.
+func NewMetrics() *Metrics {
+	return &Metrics{start: time.Now(), actions: make(map[string]map[string]int)}
+}
+
+// Action records an action taken on a resource (e.g. envvar set).
+func (m *Metrics) Action(resource, action string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.actions[resource] == nil {
+		m.actions[resource] = make(map[string]int)
+	}
+	m.actions[resource][action]++
+}
+
+// APICall records a request made to the API and the quota left after it.
+func (m *Metrics) APICall(resp *http.Response) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apiCalls++
+	if resp == nil {
+		return
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		recordAPIFailure()
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err == nil {
+		m.quotaRemaining = &remaining
+	}
+}
+
+// APIFailure records a request that got no response from the API.
+func (m *Metrics) APIFailure(err error) {
+	recordAPIFailure()
+}
+
+// Retry records a retried request, as the API client tells its
+// circleci.RetryRecorder before waiting to retry.
+func (m *Metrics) Retry() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries++
+}
+
+func (m *Metrics) summary() metricsSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resources := make(map[string]map[string]int)
+	for resource, actions := range m.actions {
+		resources[resource] = make(map[string]int)
+		for action, count := range actions {
+			resources[resource][action] = count
+		}
+	}
+	return metricsSummary{
+		StartedAt:       m.start,
+		DurationSeconds: time.Since(m.start).Seconds(),
+		Resources:       resources,
+		APICalls:        m.apiCalls,
+		Retries:         m.retries,
+		QuotaRemaining:  m.quotaRemaining,
+	}
+}
+
+// WriteFile writes the metrics summary as JSON to path.
+func (m *Metrics) WriteFile(path string) error {
+	data, err := json.MarshalIndent(m.summary(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal metrics: %v", err)
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// prometheusText renders the metrics in the Prometheus text exposition format.
+func (m *Metrics) prometheusText() string {
+	summary := m.summary()
+	var b strings.Builder
+	fmt.Fprintf(&b, "# TYPE circleci_provision_resource_actions gauge\n")
+	resources := make([]string, 0, len(summary.Resources))
+	for resource := range summary.Resources {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		actions := make([]string, 0, len(summary.Resources[resource]))
+		for action := range summary.Resources[resource] {
+			actions = append(actions, action)
+		}
+		sort.Strings(actions)
+		for _, action := range actions {
+			fmt.Fprintf(&b, "circleci_provision_resource_actions{resource=%q,action=%q} %d\n",
+				resource, action, summary.Resources[resource][action])
+		}
+	}
+	fmt.Fprintf(&b, "# TYPE circleci_provision_api_calls gauge\ncircleci_provision_api_calls %d\n", summary.APICalls)
+	fmt.Fprintf(&b, "# TYPE circleci_provision_retries gauge\ncircleci_provision_retries %d\n", summary.Retries)
+	fmt.Fprintf(&b, "# TYPE circleci_provision_duration_seconds gauge\ncircleci_provision_duration_seconds %f\n",
+		summary.DurationSeconds)
+	if summary.QuotaRemaining != nil {
+		fmt.Fprintf(&b, "# TYPE circleci_provision_quota_remaining gauge\ncircleci_provision_quota_remaining %d\n",
+			*summary.QuotaRemaining)
+	}
+	return b.String()
+}
+
+// Push sends the metrics to a Prometheus Pushgateway, replacing the
+// previous run's metrics.
+func (m *Metrics) Push(gatewayURL string) error {
+	url := strings.TrimSuffix(gatewayURL, "/") + "/metrics/job/circleci_provision"
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBufferString(m.prometheusText()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not push metrics to %s: %v", gatewayURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("could not push metrics to %s: status %s", gatewayURL, resp.Status)
+	}
+	return nil
+}
+
+// instrumentedProject records the actions taken on a project.
+type instrumentedProject struct {
+	circleci.Project
+	metrics *Metrics
+}
+
+func (p instrumentedProject) record(resource, action string, err error) error {
+	if err == nil {
+		p.metrics.Action(resource, action)
+	}
+	return err
+}
+
+func (p instrumentedProject) Follow(ctx context.Context) error {
+	return p.record(circleci.ResourceFollow, "follow", p.Project.Follow(ctx))
+}
+
+func (p instrumentedProject) Unfollow(ctx context.Context) error {
+	return p.record(circleci.ResourceFollow, "unfollow", p.Project.Unfollow(ctx))
+}
+
+func (p instrumentedProject) Setenv(ctx context.Context, name, value string) error {
+	return p.record(circleci.ResourceEnvVar, "set", p.Project.Setenv(ctx, name, value))
+}
+
+func (p instrumentedProject) Deleteenv(ctx context.Context, name string) error {
+	return p.record(circleci.ResourceEnvVar, "delete", p.Project.Deleteenv(ctx, name))
+}
+
+func (p instrumentedProject) Clearenv(ctx context.Context) error {
+	return p.record(circleci.ResourceEnvVar, "clear", p.Project.Clearenv(ctx))
+}
+
+func (p instrumentedProject) AddSSHKey(ctx context.Context, name, privateKey string) error {
+	return p.record(circleci.ResourceSSHKey, "add", p.Project.AddSSHKey(ctx, name, privateKey))
+}
+
+func (p instrumentedProject) RemoveSSHKey(ctx context.Context, name string) error {
+	return p.record(circleci.ResourceSSHKey, "remove", p.Project.RemoveSSHKey(ctx, name))
+}
+
+func (p instrumentedProject) DeleteSSHKey(ctx context.Context, hostname, fingerprint string) error {
+	return p.record(circleci.ResourceSSHKey, "remove", p.Project.DeleteSSHKey(ctx, hostname, fingerprint))
+}
+
+func (p instrumentedProject) ClearSSHKeys(ctx context.Context) error {
+	return p.record(circleci.ResourceSSHKey, "clear", p.Project.ClearSSHKeys(ctx))
+}
+
+func (p instrumentedProject) CreateCheckoutKey(ctx context.Context, keyType string) (circleci.CheckoutKey, error) {
+	key, err := p.Project.CreateCheckoutKey(ctx, keyType)
+	return key, p.record(circleci.ResourceCheckoutKey, "add", err)
+}
+
+func (p instrumentedProject) DeleteCheckoutKey(ctx context.Context, fingerprint string) error {
+	return p.record(circleci.ResourceCheckoutKey, "remove", p.Project.DeleteCheckoutKey(ctx, fingerprint))
+}
+
+func (p instrumentedProject) Trigger(ctx context.Context, opts circleci.TriggerOptions) (circleci.Build, error) {
+	build, err := p.Project.Trigger(ctx, opts)
+	return build, p.record(circleci.ResourceBuild, "trigger", err)
+}
+
+func (p instrumentedProject) SetJiraIntegration(ctx context.Context, jira circleci.JiraIntegration) error {
+	return p.record(circleci.ResourceSettings, "jira", p.Project.SetJiraIntegration(ctx, jira))
+}
+
+func (p instrumentedProject) SetFeatureFlags(ctx context.Context, flags map[string]interface{}) error {
+	return p.record(circleci.ResourceSettings, "feature-flags", p.Project.SetFeatureFlags(ctx, flags))
+}
+
+func (p instrumentedProject) CreateWebhook(ctx context.Context, webhook circleci.Webhook) (circleci.Webhook, error) {
+	created, err := p.Project.CreateWebhook(ctx, webhook)
+	return created, p.record(circleci.ResourceWebhook, "add", err)
+}
+
+func (p instrumentedProject) UpdateWebhook(ctx context.Context, webhook circleci.Webhook) error {
+	return p.record(circleci.ResourceWebhook, "update", p.Project.UpdateWebhook(ctx, webhook))
+}
+
+func (p instrumentedProject) DeleteWebhook(ctx context.Context, id string) error {
+	return p.record(circleci.ResourceWebhook, "remove", p.Project.DeleteWebhook(ctx, id))
+}
+
+func (p instrumentedProject) CreateSchedule(ctx context.Context, schedule circleci.Schedule) (circleci.Schedule, error) {
+	created, err := p.Project.CreateSchedule(ctx, schedule)
+	return created, p.record(circleci.ResourceSchedule, "add", err)
+}
+
+func (p instrumentedProject) UpdateSchedule(ctx context.Context, schedule circleci.Schedule) error {
+	return p.record(circleci.ResourceSchedule, "update", p.Project.UpdateSchedule(ctx, schedule))
+}
+
+func (p instrumentedProject) DeleteSchedule(ctx context.Context, id string) error {
+	return p.record(circleci.ResourceSchedule, "remove", p.Project.DeleteSchedule(ctx, id))
+}
+
+func (p instrumentedProject) SetOIDCClaims(ctx context.Context, claims circleci.OIDCClaims) error {
+	return p.record(circleci.ResourceOIDC, "update", p.Project.SetOIDCClaims(ctx, claims))
+}
+
+func (p instrumentedProject) DeleteOIDCClaims(ctx context.Context) error {
+	return p.record(circleci.ResourceOIDC, "remove", p.Project.DeleteOIDCClaims(ctx))
+}
+
+func (p instrumentedProject) SetStoragePolicy(ctx context.Context, policy circleci.StoragePolicy) error {
+	return p.record(circleci.ResourceStorage, "update", p.Project.SetStoragePolicy(ctx, policy))
+}
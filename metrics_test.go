@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+func TestMetricsRecordRetries(t *testing.T) {
+	requests := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("X-RateLimit-Remaining", "99")
+	}))
+	defer svr.Close()
+
+	metrics := NewMetrics()
+	client := circleci.NewHTTPClient(svr.URL, metrics)
+	client.RetryDelay = time.Millisecond
+	resp, err := client.Get(context.Background(), svr.URL+"/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	dir, err := ioutil.TempDir("", "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "metrics.json")
+	if err := metrics.WriteFile(file); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary metricsSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Retries != 1 || summary.APICalls != 2 {
+		t.Errorf("Expected 2 API calls and 1 retry, found %d and %d in %s", summary.APICalls, summary.Retries, data)
+	}
+	if summary.QuotaRemaining == nil || *summary.QuotaRemaining != 99 {
+		t.Errorf("Expected 99 requests of quota left, found %s", data)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// namespaceSeparator separates a namespace prefix from the variable name.
+const namespaceSeparator = "__"
+
+// Namespace groups the variables of one sub-project in a monorepo.
+type Namespace struct {
+	EnvVars EnvVars `yaml:"envVars"` // Env vars to set, prefixed with the namespace
+}
+
+// namespacePrefix turns a namespace name (e.g. svc-a) into its variable
+// prefix (e.g. SVC_A).
+func namespacePrefix(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, name)
+}
+
+// expandNamespaces adds each namespace's variables to the config's env vars
+// as PREFIX__NAME, failing if two variables end up with the same name.
+func expandNamespaces(config *Config) error {
+	if len(config.Namespaces) == 0 {
+		return nil
+	}
+	if config.EnvVars == nil {
+		config.EnvVars = make(EnvVars)
+	}
+	origins := make(map[string]string)
+	for name := range config.EnvVars {
+		origins[name] = "envVars"
+	}
+
+	names := make([]string, 0, len(config.Namespaces))
+	for name := range config.Namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, namespace := range names {
+		prefix := namespacePrefix(namespace)
+		for name, value := range config.Namespaces[namespace].EnvVars {
+			full := prefix + namespaceSeparator + name
+			if origin, ok := origins[full]; ok {
+				return fmt.Errorf("environment variable %s from namespace %s collides with %s", full, namespace, origin)
+			}
+			origins[full] = "namespace " + namespace
+			config.EnvVars[full] = value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandNamespaces(t *testing.T) {
+	config := Config{
+		EnvVars: map[string]string{"SHARED": "1"},
+		Namespaces: map[string]Namespace{
+			"svc-a": {EnvVars: map[string]string{"DB_URL": "a"}},
+			"svc_b": {EnvVars: map[string]string{"DB_URL": "b"}},
+		},
+	}
+	err := expandNamespaces(&config)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := EnvVars{"SHARED": "1", "SVC_A__DB_URL": "a", "SVC_B__DB_URL": "b"}
+	if !reflect.DeepEqual(config.EnvVars, expected) {
+		t.Errorf("Expected %v, found %v", expected, config.EnvVars)
+	}
+}
+
+func TestExpandNamespacesCollision(t *testing.T) {
+	testCases := []Config{
+		{
+			EnvVars:    map[string]string{"SVC__DB_URL": "top"},
+			Namespaces: map[string]Namespace{"svc": {EnvVars: map[string]string{"DB_URL": "a"}}},
+		},
+		{
+			Namespaces: map[string]Namespace{
+				"svc-a": {EnvVars: map[string]string{"DB_URL": "a"}},
+				"svc_a": {EnvVars: map[string]string{"DB_URL": "b"}},
+			},
+		},
+	}
+
+	for _, config := range testCases {
+		err := expandNamespaces(&config)
+		if err == nil {
+			t.Errorf("Expected collision error for %v", config.Namespaces)
+		}
+	}
+}
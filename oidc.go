@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// OIDCConfig is the custom claims of the OIDC tokens of the project's jobs.
+type OIDCConfig struct {
+	Enabled  *bool    `yaml:"enabled"`  // false removes the custom claims, so the org's apply (default true)
+	Audience []string `yaml:"audience"` // aud claim of the tokens
+	TTL      string   `yaml:"ttl"`      // Lifetime of the tokens, e.g. 1h
+}
+
+// enabled reports whether the project should have custom claims.
+func (c OIDCConfig) enabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// validate checks the claims of an enabled config.
+func (c OIDCConfig) validate() error {
+	if !c.enabled() {
+		return nil
+	}
+	if len(c.Audience) == 0 && c.TTL == "" {
+		return fmt.Errorf("audience or ttl is required unless enabled is false")
+	}
+	if c.TTL != "" {
+		ttl, err := time.ParseDuration(c.TTL)
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid ttl %q, expected a duration such as 1h", c.TTL)
+		}
+	}
+	return nil
+}
+
+// oidcManager is what managing OIDC claims needs of a project.
+type oidcManager interface {
+	FullName() string
+	OIDCClaims(ctx context.Context) (circleci.OIDCClaims, error)
+	SetOIDCClaims(ctx context.Context, claims circleci.OIDCClaims) error
+	DeleteOIDCClaims(ctx context.Context) error
+}
+
+// ensureOIDCClaims sets the project's custom claims to the config's, or
+// removes them if it is disabled.
+func ensureOIDCClaims(ctx context.Context, project oidcManager, config OIDCConfig) error {
+	current, err := project.OIDCClaims(ctx)
+	if err != nil {
+		return err
+	}
+	hasClaims := len(current.Audience) > 0 || current.TTL != ""
+	if !config.enabled() {
+		if !hasClaims {
+			return nil
+		}
+		logInfof("Removing OIDC claims of project %s", project.FullName())
+		return project.DeleteOIDCClaims(ctx)
+	}
+
+	wanted := circleci.OIDCClaims{Audience: config.Audience, TTL: config.TTL}
+	if current.TTL == wanted.TTL &&
+		(len(current.Audience) == 0 && len(wanted.Audience) == 0 || reflect.DeepEqual(current.Audience, wanted.Audience)) {
+		return nil
+	}
+	// Setting claims leaves those not given alone, so claims dropped from
+	// the config are removed first.
+	if len(current.Audience) > 0 && len(wanted.Audience) == 0 || current.TTL != "" && wanted.TTL == "" {
+		logInfof("Removing OIDC claims of project %s", project.FullName())
+		err = project.DeleteOIDCClaims(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	logInfof("Setting OIDC claims of project %s", project.FullName())
+	return project.SetOIDCClaims(ctx, wanted)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+type fakeOIDCManager struct {
+	claims circleci.OIDCClaims
+	calls  []string
+}
+
+func (p *fakeOIDCManager) FullName() string { return "owner/project" }
+
+func (p *fakeOIDCManager) OIDCClaims(ctx context.Context) (circleci.OIDCClaims, error) {
+	return p.claims, nil
+}
+
+func (p *fakeOIDCManager) SetOIDCClaims(ctx context.Context, claims circleci.OIDCClaims) error {
+	p.calls = append(p.calls, "set")
+	p.claims = claims
+	return nil
+}
+
+func (p *fakeOIDCManager) DeleteOIDCClaims(ctx context.Context) error {
+	p.calls = append(p.calls, "delete")
+	p.claims = circleci.OIDCClaims{}
+	return nil
+}
+
+func TestEnsureOIDCClaims(t *testing.T) {
+	no := false
+	tests := []struct {
+		name     string
+		current  circleci.OIDCClaims
+		config   OIDCConfig
+		expected []string
+	}{
+		{"unchanged", circleci.OIDCClaims{Audience: []string{"vault"}, TTL: "1h"},
+			OIDCConfig{Audience: []string{"vault"}, TTL: "1h"}, nil},
+		{"set", circleci.OIDCClaims{}, OIDCConfig{Audience: []string{"vault"}}, []string{"set"}},
+		{"changed", circleci.OIDCClaims{Audience: []string{"vault"}}, OIDCConfig{Audience: []string{"sts"}},
+			[]string{"set"}},
+		{"claim dropped", circleci.OIDCClaims{Audience: []string{"vault"}, TTL: "1h"}, OIDCConfig{TTL: "1h"},
+			[]string{"delete", "set"}},
+		{"disabled", circleci.OIDCClaims{TTL: "1h"}, OIDCConfig{Enabled: &no}, []string{"delete"}},
+		{"already disabled", circleci.OIDCClaims{}, OIDCConfig{Enabled: &no}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := &fakeOIDCManager{claims: tt.current}
+			err := ensureOIDCClaims(context.Background(), project, tt.config)
+			if err != nil {
+				t.Fatalf("Expected no error, found: %v", err)
+			}
+			if !reflect.DeepEqual(project.calls, tt.expected) {
+				t.Errorf("Expected calls %v, found %v", tt.expected, project.calls)
+			}
+		})
+	}
+}
+
+func TestOIDCConfigValidate(t *testing.T) {
+	no := false
+	for _, tt := range []struct {
+		config OIDCConfig
+		valid  bool
+	}{
+		{OIDCConfig{Audience: []string{"vault"}}, true},
+		{OIDCConfig{TTL: "30m"}, true},
+		{OIDCConfig{Enabled: &no}, true},
+		{OIDCConfig{}, false},
+		{OIDCConfig{TTL: "an hour"}, false},
+	} {
+		if err := tt.config.validate(); (err == nil) != tt.valid {
+			t.Errorf("Expected %+v to be valid: %v, found: %v", tt.config, tt.valid, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// orgConfigKeys are the keys that make a config an org config, describing
+// every project of an org rather than a single one.
+const (
+	orgDefaultsKey = "defaults" // Config every project starts from
+	orgProjectsKey = "projects" // Overrides of each project, keyed by project name
+)
+
+// isOrgConfig reports whether the YAML config data is an org config.
+func isOrgConfig(data []byte) bool {
+	var raw map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return false
+	}
+	_, ok := raw[orgProjectsKey]
+	return ok
+}
+
+// orgProjectConfigs merges the org config data into the YAML config of each
+// of its projects, in order of name. A project's config is the org config's
+// top level fields, such as vcsType and owner, then its defaults, then the
+// project's own overrides.
+func orgProjectConfigs(data []byte) ([]string, map[string][]byte, error) {
+	var raw map[interface{}]interface{}
+	err := yaml.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	projects, ok := raw[orgProjectsKey].(map[interface{}]interface{})
+	if !ok || len(projects) == 0 {
+		return nil, nil, fmt.Errorf("%s should map project names to their overrides", orgProjectsKey)
+	}
+	defaults := raw[orgDefaultsKey]
+	if _, ok := defaults.(map[interface{}]interface{}); defaults != nil && !ok {
+		return nil, nil, fmt.Errorf("%s should be a config", orgDefaultsKey)
+	}
+	common := make(map[interface{}]interface{})
+	for key, value := range raw {
+		if key != orgDefaultsKey && key != orgProjectsKey {
+			common[key] = value
+		}
+	}
+	base := mergeYAML(common, defaults)
+
+	names := make([]string, 0, len(projects))
+	configs := make(map[string][]byte, len(projects))
+	for key, overrides := range projects {
+		name, ok := key.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid project name %v", key)
+		}
+		if overrides == nil {
+			overrides = make(map[interface{}]interface{})
+		}
+		if _, ok := overrides.(map[interface{}]interface{}); !ok {
+			return nil, nil, fmt.Errorf("overrides of project %s should be a config", name)
+		}
+		merged := mergeYAML(base, overrides).(map[interface{}]interface{})
+		if _, ok := merged["projectName"]; !ok {
+			merged["projectName"] = name
+		}
+		configs[name], err = yaml.Marshal(merged)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not merge project %s: %v", name, err)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, configs, nil
+}
+
+// mergeYAML merges override on top of base. Mappings are merged key by key,
+// with a null value removing the key, while anything else in override
+// replaces what is in base.
+func mergeYAML(base, override interface{}) interface{} {
+	baseMap, baseOK := base.(map[interface{}]interface{})
+	overrideMap, overrideOK := override.(map[interface{}]interface{})
+	if override == nil {
+		return base
+	}
+	if !baseOK || !overrideOK {
+		return override
+	}
+	merged := make(map[interface{}]interface{}, len(baseMap)+len(overrideMap))
+	for key, value := range baseMap {
+		merged[key] = value
+	}
+	for key, value := range overrideMap {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = mergeYAML(merged[key], value)
+	}
+	return merged
+}
+
+// readConfigs reads configFile, which is either the config of one project or
+// an org config describing several.
+func readConfigs(configFile string, opts configOptions) ([]Config, error) {
+	data, err := loadConfig(configFile, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !isOrgConfig(data) {
+		config, err := parseConfig(configFile, data, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []Config{config}, nil
+	}
+
+	names, projects, err := orgProjectConfigs(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid org config %s: %v", configFile, err)
+	}
+	configs := make([]Config, 0, len(names))
+	for _, name := range names {
+		config, err := parseConfig(configFile, projects[name], opts)
+		if err != nil {
+			return nil, fmt.Errorf("project %s: %v", name, err)
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
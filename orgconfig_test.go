@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadOrgConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "org.yml")
+	data := `
+version: 1
+vcsType: gh
+owner: acme
+defaults:
+  envVars:
+    DATADOG_API_KEY: dd
+    LOG_LEVEL: info
+  checkoutKeys: [deploy-key]
+projects:
+  web:
+  api:
+    envVars:
+      LOG_LEVEL: debug
+      DATABASE_URL: postgres://api
+  legacy:
+    projectName: Legacy-App
+    envVars:
+      DATADOG_API_KEY: null
+    checkoutKeys: []
+`
+	if err := ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	configs, err := readConfigs(file, configOptions{noTemplate: true})
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if len(configs) != 3 {
+		t.Fatalf("Expected 3 projects, found %+v", configs)
+	}
+	expected := []struct {
+		name         string
+		envVars      EnvVars
+		checkoutKeys []string
+	}{
+		{"api", EnvVars{"DATADOG_API_KEY": "dd", "LOG_LEVEL": "debug", "DATABASE_URL": "postgres://api"}, []string{"deploy-key"}},
+		{"Legacy-App", EnvVars{"LOG_LEVEL": "info"}, []string{}},
+		{"web", EnvVars{"DATADOG_API_KEY": "dd", "LOG_LEVEL": "info"}, []string{"deploy-key"}},
+	}
+	for i, e := range expected {
+		config := configs[i]
+		if config.ProjectName != e.name || config.Owner != "acme" || config.VcsType != "gh" {
+			t.Errorf("Expected project gh/acme/%s, found %s/%s/%s", e.name, config.VcsType, config.Owner, config.ProjectName)
+		}
+		if !reflect.DeepEqual(config.EnvVars, e.envVars) || !reflect.DeepEqual(config.CheckoutKeys, e.checkoutKeys) {
+			t.Errorf("Expected %s to have %v and %v, found %v and %v", e.name, e.envVars, e.checkoutKeys,
+				config.EnvVars, config.CheckoutKeys)
+		}
+	}
+
+	if _, err := readConfig(file, configOptions{noTemplate: true}); err == nil {
+		t.Error("Expected reading an org config as a single config to fail")
+	}
+}
+
+func TestOrgProjectConfigsInvalid(t *testing.T) {
+	for _, data := range []string{
+		"projects: []\n",
+		"projects:\n  api: [a]\n",
+		"defaults: [a]\nprojects:\n  api:\n",
+	} {
+		if _, _, err := orgProjectConfigs([]byte(data)); err == nil {
+			t.Errorf("Expected an error for %q", data)
+		}
+	}
+}
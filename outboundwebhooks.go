@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// WebhookConfig is an outbound webhook notifying a URL of the project's
+// workflow and job events.
+type WebhookConfig struct {
+	URL           string   `yaml:"url"`
+	Events        []string `yaml:"events"`        // workflow-completed and/or job-completed
+	SigningSecret string   `yaml:"signingSecret"` // Secret signing deliveries, may be ${NAME} or a secret store reference
+	VerifyTLS     *bool    `yaml:"verifyTLS"`     // Whether the URL's certificate is verified, true if not set
+}
+
+// validate checks the webhook's URL and events.
+func (w WebhookConfig) validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return fmt.Errorf("invalid url %q, expected an http or https URL", w.URL)
+	}
+	if len(w.Events) == 0 {
+		return fmt.Errorf("no events, expected %s and/or %s",
+			circleci.WebhookWorkflowCompleted, circleci.WebhookJobCompleted)
+	}
+	for _, event := range w.Events {
+		if event != circleci.WebhookWorkflowCompleted && event != circleci.WebhookJobCompleted {
+			return fmt.Errorf("unknown event %q, expected %s or %s",
+				event, circleci.WebhookWorkflowCompleted, circleci.WebhookJobCompleted)
+		}
+	}
+	return nil
+}
+
+// webhook returns the webhook to create or update.
+func (w WebhookConfig) webhook(name string) circleci.Webhook {
+	events := append([]string(nil), w.Events...)
+	sort.Strings(events)
+	return circleci.Webhook{
+		Name:          name,
+		URL:           w.URL,
+		Events:        events,
+		VerifyTLS:     w.VerifyTLS == nil || *w.VerifyTLS,
+		SigningSecret: w.SigningSecret,
+	}
+}
+
+// checkWebhooks validates the config's webhooks.
+func checkWebhooks(config Config) error {
+	for _, name := range sortedWebhookNames(config.Webhooks) {
+		if err := config.Webhooks[name].validate(); err != nil {
+			return fmt.Errorf("webhook %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// resolveWebhookSecrets expands the ${NAME} references and secret store
+// references of the webhooks' signing secrets.
+func resolveWebhookSecrets(config *Config, stores map[string]SecretStore, lookup func(string) (string, bool)) error {
+	for _, name := range sortedWebhookNames(config.Webhooks) {
+		webhook := config.Webhooks[name]
+		secret, err := interpolate(webhook.SigningSecret, lookup)
+		if err != nil {
+			return fmt.Errorf("signing secret of webhook %s: %v", name, err)
+		}
+		if store, path, key, ok := secretReference(secret, stores); ok {
+			secret, err = store.Secret(path, key)
+			if err != nil {
+				return fmt.Errorf("signing secret of webhook %s: could not resolve %s: %v", name, webhook.SigningSecret, err)
+			}
+		}
+		webhook.SigningSecret = secret
+		config.Webhooks[name] = webhook
+	}
+	return nil
+}
+
+func sortedWebhookNames(webhooks map[string]WebhookConfig) []string {
+	names := make([]string, 0, len(webhooks))
+	for name := range webhooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// webhookManager is what managing outbound webhooks needs of a project.
+type webhookManager interface {
+	FullName() string
+	Webhooks(ctx context.Context) ([]circleci.Webhook, error)
+	CreateWebhook(ctx context.Context, webhook circleci.Webhook) (circleci.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook circleci.Webhook) error
+	DeleteWebhook(ctx context.Context, id string) error
+}
+
+// matchWebhooks returns the existing webhooks of the config keyed by name,
+// and the others, which canonical mode deletes. Only the first webhook of a
+// name is matched, later ones are duplicates.
+func matchWebhooks(existing []circleci.Webhook, webhooks map[string]WebhookConfig) (map[string]circleci.Webhook, []circleci.Webhook) {
+	byName := make(map[string]circleci.Webhook, len(existing))
+	var stale []circleci.Webhook
+	for _, webhook := range existing {
+		if _, ok := webhooks[webhook.Name]; ok {
+			if _, seen := byName[webhook.Name]; !seen {
+				byName[webhook.Name] = webhook
+				continue
+			}
+		}
+		stale = append(stale, webhook)
+	}
+	return byName, stale
+}
+
+// ensureWebhooks creates the webhooks the project is missing and updates
+// those that differ from the config, matching them by name. Signing secrets
+// are not returned by the API, so webhooks with one are always updated. If
+// canonical is set, webhooks not in the config are deleted.
+func ensureWebhooks(ctx context.Context, project webhookManager, webhooks map[string]WebhookConfig, canonical bool) error {
+	existing, err := project.Webhooks(ctx)
+	if err != nil {
+		return err
+	}
+	byName, stale := matchWebhooks(existing, webhooks)
+	if canonical {
+		for _, webhook := range stale {
+			logInfof("Deleting webhook %s from project %s", webhook.Name, project.FullName())
+			err = project.DeleteWebhook(ctx, webhook.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range sortedWebhookNames(webhooks) {
+		wanted := webhooks[name].webhook(name)
+		current, ok := byName[name]
+		if !ok {
+			logInfof("Creating webhook %s for project %s", name, project.FullName())
+			if _, err = project.CreateWebhook(ctx, wanted); err != nil {
+				return err
+			}
+			continue
+		}
+		sort.Strings(current.Events)
+		if wanted.SigningSecret == "" && current.URL == wanted.URL && current.VerifyTLS == wanted.VerifyTLS &&
+			reflect.DeepEqual(current.Events, wanted.Events) {
+			continue
+		}
+		logInfof("Updating webhook %s of project %s", name, project.FullName())
+		wanted.ID = current.ID
+		if err = project.UpdateWebhook(ctx, wanted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type fakeWebhookManager struct {
+	webhooks []circleci.Webhook
+	created  []string
+	updated  []string
+	deleted  []string
+}
+
+func (p *fakeWebhookManager) FullName() string { return "owner/project" }
+
+func (p *fakeWebhookManager) Webhooks(ctx context.Context) ([]circleci.Webhook, error) {
+	return p.webhooks, nil
+}
+
+func (p *fakeWebhookManager) CreateWebhook(ctx context.Context, webhook circleci.Webhook) (circleci.Webhook, error) {
+	p.created = append(p.created, webhook.Name)
+	return webhook, nil
+}
+
+func (p *fakeWebhookManager) UpdateWebhook(ctx context.Context, webhook circleci.Webhook) error {
+	p.updated = append(p.updated, webhook.ID)
+	return nil
+}
+
+func (p *fakeWebhookManager) DeleteWebhook(ctx context.Context, id string) error {
+	p.deleted = append(p.deleted, id)
+	return nil
+}
+
+func TestEnsureWebhooks(t *testing.T) {
+	existing := []circleci.Webhook{
+		{ID: "1", Name: "slack", URL: "https://example.com/slack", Events: []string{"job-completed", "workflow-completed"}, VerifyTLS: true},
+		{ID: "2", Name: "metrics", URL: "https://example.com/old", Events: []string{"workflow-completed"}, VerifyTLS: true},
+		{ID: "3", Name: "manual", URL: "https://example.com/manual", Events: []string{"workflow-completed"}},
+	}
+	webhooks := map[string]WebhookConfig{
+		"slack":   {URL: "https://example.com/slack", Events: []string{"workflow-completed", "job-completed"}},
+		"metrics": {URL: "https://example.com/metrics", Events: []string{"workflow-completed"}},
+		"deploys": {URL: "https://example.com/deploys", Events: []string{"job-completed"}, SigningSecret: "secret"},
+	}
+	testCases := []struct {
+		name      string
+		canonical bool
+		deleted   []string
+	}{
+		{"additive", false, nil},
+		{"canonical", true, []string{"3"}},
+	}
+
+	for _, tc := range testCases {
+		project := &fakeWebhookManager{webhooks: existing}
+		err := ensureWebhooks(context.Background(), project, webhooks, tc.canonical)
+		if err != nil {
+			t.Errorf("%s: expected no error, found: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(project.created, []string{"deploys"}) || !reflect.DeepEqual(project.updated, []string{"2"}) ||
+			!reflect.DeepEqual(project.deleted, tc.deleted) {
+			t.Errorf("%s: expected deploys created, 2 updated and %v deleted, found %v, %v and %v",
+				tc.name, tc.deleted, project.created, project.updated, project.deleted)
+		}
+	}
+}
+
+func TestWebhookConfig(t *testing.T) {
+	data := `
+webhooks:
+  slack:
+    url: https://example.com/hook
+    events: [workflow-completed]
+    signingSecret: ${HOOK_SECRET}
+    verifyTLS: false
+`
+	var config Config
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if err := checkWebhooks(config); err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+	lookup := func(name string) (string, bool) { return "s3cret", name == "HOOK_SECRET" }
+	if err := resolveWebhookSecrets(&config, nil, lookup); err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := circleci.Webhook{Name: "slack", URL: "https://example.com/hook",
+		Events: []string{"workflow-completed"}, SigningSecret: "s3cret"}
+	if actual := config.Webhooks["slack"].webhook("slack"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, found %+v", expected, actual)
+	}
+
+	invalid := []WebhookConfig{
+		{URL: "example.com", Events: []string{"workflow-completed"}},
+		{URL: "https://example.com"},
+		{URL: "https://example.com", Events: []string{"build-started"}},
+	}
+	for _, webhook := range invalid {
+		if err := webhook.validate(); err == nil {
+			t.Errorf("Expected an error for %+v", webhook)
+		}
+	}
+}
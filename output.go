@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// ANSI colors used in human readable output.
+const (
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+	colorCyan   = "36"
+)
+
+// symbolColors are the colors of the +/~/-/>/! markers used by plans and diffs.
+var symbolColors = map[string]string{
+	"+": colorGreen,
+	"~": colorYellow,
+	"-": colorRed,
+	">": colorCyan,
+	"!": colorYellow,
+}
+
+// output is a writer for human readable output that is colored when color
+// is set.
+type output struct {
+	io.Writer
+	color bool
+}
+
+// newOutput returns an output writing to f, colored if f is a terminal
+// unless noColor is set or NO_COLOR is set in the environment.
+func newOutput(f *os.File, noColor bool) *output {
+	_, noColorEnv := os.LookupEnv("NO_COLOR")
+	color := !noColor && !noColorEnv && terminal.IsTerminal(int(f.Fd()))
+	return &output{Writer: f, color: color}
+}
+
+// paint colors s if w is an output with color enabled.
+func paint(w io.Writer, color, s string) string {
+	if o, ok := w.(*output); !ok || !o.color || color == "" {
+		return s
+	}
+	return "\x1b[" + color + "m" + s + "\x1b[0m"
+}
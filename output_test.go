@@ -0,0 +1,19 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPaint(t *testing.T) {
+	var buf bytes.Buffer
+	if actual := paint(&buf, colorRed, "gone"); actual != "gone" {
+		t.Errorf("Expected plain writers to be left uncolored, found %q", actual)
+	}
+	if actual := paint(&output{&buf, false}, colorRed, "gone"); actual != "gone" {
+		t.Errorf("Expected output without color to be left uncolored, found %q", actual)
+	}
+	if actual := paint(&output{&buf, true}, colorRed, "gone"); actual != "\x1b[31mgone\x1b[0m" {
+		t.Errorf("Expected output with color to be colored, found %q", actual)
+	}
+}
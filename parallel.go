@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// runParallel calls fn for every index from 0 to n-1, on at most workers
+// goroutines at once, and returns the error of each call by index. Calls
+// are made in order if workers is 1 or less.
+func runParallel(workers, n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
+
+// joinErrors returns the errors that are not nil as one, or nil if there
+// are none.
+func joinErrors(errs []error) error {
+	var failed []error
+	var msgs []string
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+			msgs = append(msgs, err.Error())
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0]
+	}
+	return fmt.Errorf("%d errors: %s", len(msgs), strings.Join(msgs, "; "))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestRunParallel(t *testing.T) {
+	var mu sync.Mutex
+	running, most := 0, 0
+	calls := make([]int, 10)
+	errs := runParallel(3, len(calls), func(i int) error {
+		mu.Lock()
+		calls[i]++
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if i%4 == 0 {
+			return fmt.Errorf("call %d failed", i)
+		}
+		return nil
+	})
+
+	if most > 3 {
+		t.Errorf("Expected at most 3 calls at once, found %d", most)
+	}
+	for i, n := range calls {
+		if n != 1 {
+			t.Errorf("Expected call %d to be made once, found %d", i, n)
+		}
+		if (errs[i] != nil) != (i%4 == 0) {
+			t.Errorf("Unexpected error of call %d: %v", i, errs[i])
+		}
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	if err := joinErrors([]error{nil, nil}); err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+	first := fmt.Errorf("first")
+	if err := joinErrors([]error{nil, first}); err != first {
+		t.Errorf("Expected the only error to be returned as is, found: %v", err)
+	}
+	err := joinErrors([]error{first, nil, fmt.Errorf("second")})
+	if err == nil || err.Error() != "2 errors: first; second" {
+		t.Errorf("Unexpected joined error: %v", err)
+	}
+}
+
+func TestSetEnvVarsReportsEveryFailure(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"POST /project/git/test/test/envvar": {Status: http.StatusBadRequest, Body: `{"message": "invalid"}`},
+	})
+	defer svr.Close()
+
+	err := setEnvVars(context.Background(), svr.project(), map[string]string{"A": "1", "B": "2", "C": "3"}, nil, nil, 2, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "3 errors: ") {
+		t.Errorf("Expected an error for every env var, found: %v", err)
+	}
+	if len(svr.Requests()) != 3 {
+		t.Errorf("Expected every env var to be tried, found requests %v", svr.Requests())
+	}
+}
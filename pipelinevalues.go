@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// defaultPipelineValuesFile is where pipeline values are written, relative to
+// the config.
+const defaultPipelineValuesFile = "pipeline-values.yml"
+
+// PipelineValuesConfig exports env vars that are not secret as pipeline
+// values, for dynamic config to read alongside the project's env vars.
+type PipelineValuesConfig struct {
+	EnvVars []string `yaml:"envVars"` // Env vars to export, which must not come from a secret store
+	File    string   `yaml:"file"`    // File to write, relative to the config (default pipeline-values.yml)
+}
+
+// checkPipelineValues checks that the exported env vars exist and do not
+// refer to a secret store, and makes the file relative to dir. It must run
+// before secrets are resolved.
+func checkPipelineValues(config *Config, dir string, stores map[string]SecretStore) error {
+	values := &config.PipelineValues
+	if len(values.EnvVars) == 0 {
+		return nil
+	}
+	for _, name := range values.EnvVars {
+		value, ok := config.EnvVars[name]
+		if !ok {
+			return fmt.Errorf("env var %s is not in envVars", name)
+		}
+		if _, _, _, ok := secretReference(value, stores); ok {
+			return fmt.Errorf("env var %s is a secret, so it cannot be a pipeline value", name)
+		}
+		if _, ok := config.Sources[name]; ok {
+			return fmt.Errorf("env var %s may come from a secret store, so it cannot be a pipeline value", name)
+		}
+	}
+	if values.File == "" {
+		values.File = defaultPipelineValuesFile
+	}
+	if !filepath.IsAbs(values.File) {
+		values.File = filepath.Join(dir, values.File)
+	}
+	return nil
+}
+
+// pipelineValues renders the config's exported env vars as YAML.
+func pipelineValues(config Config, configFile string) ([]byte, error) {
+	values := make(map[string]string, len(config.PipelineValues.EnvVars))
+	for _, name := range config.PipelineValues.EnvVars {
+		values[name] = config.EnvVars[name]
+	}
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated by circleci-provision from %s, do not edit.\n", filepath.Base(configFile))
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
+
+// writePipelineValues writes the config's pipeline values file, if it
+// exports any, leaving it alone if it is up to date.
+func writePipelineValues(config Config, configFile string) error {
+	if len(config.PipelineValues.EnvVars) == 0 {
+		return nil
+	}
+	data, err := pipelineValues(config, configFile)
+	if err != nil {
+		return err
+	}
+	file := config.PipelineValues.File
+	if current, err := ioutil.ReadFile(file); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	logInfof("Writing %d pipeline values to %s", len(config.PipelineValues.EnvVars), file)
+	return ioutil.WriteFile(file, data, 0644)
+}
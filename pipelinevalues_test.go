@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPipelineValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pipelinevalues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "project.yml")
+	err = ioutil.WriteFile(file, []byte(`
+owner: owner
+projectName: project
+envVars:
+  REGION: eu-west-1
+  SERVICE: payments
+  TOKEN: vault:secret/app#token
+pipelineValues:
+  envVars: [REGION, SERVICE]
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := configOptions{noTemplate: true,
+		secrets: map[string]SecretStore{"vault": fakeSecretStore{"secret/app#token": "s3cret"}}}
+	config, err := readConfig(file, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if expected := filepath.Join(dir, defaultPipelineValuesFile); config.PipelineValues.File != expected {
+		t.Errorf("Expected the values to be written to %s, found %s", expected, config.PipelineValues.File)
+	}
+
+	err = writePipelineValues(config, file)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	data, err := ioutil.ReadFile(config.PipelineValues.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# Generated by circleci-provision from project.yml, do not edit.\nREGION: eu-west-1\nSERVICE: payments\n"
+	if string(data) != expected {
+		t.Errorf("Expected pipeline values %q, found %q", expected, data)
+	}
+}
+
+func TestCheckPipelineValues(t *testing.T) {
+	stores := map[string]SecretStore{"vault": fakeSecretStore{}}
+	for _, tt := range []struct {
+		config  Config
+		wantErr string
+	}{
+		{Config{EnvVars: EnvVars{"TOKEN": "vault:secret/app#token"},
+			PipelineValues: PipelineValuesConfig{EnvVars: []string{"TOKEN"}}}, "is a secret"},
+		{Config{EnvVars: EnvVars{"KEY": ""}, Sources: map[string][]string{"KEY": {"env:KEY"}},
+			PipelineValues: PipelineValuesConfig{EnvVars: []string{"KEY"}}}, "may come from a secret store"},
+		{Config{PipelineValues: PipelineValuesConfig{EnvVars: []string{"MISSING"}}}, "not in envVars"},
+	} {
+		err := checkPipelineValues(&tt.config, ".", stores)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Expected an error containing %q, found: %v", tt.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package circleci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to a request on top of the API token, for
+// CircleCI Server installs behind an SSO proxy.
+type Authenticator interface {
+	Authenticate(ctx context.Context, req *http.Request) error
+}
+
+// SessionCookie authenticates requests with the session cookie of an SSO
+// proxy, e.g. one copied from a logged in browser.
+type SessionCookie struct {
+	Name  string
+	Value string
+}
+
+// Authenticate adds the cookie to the request.
+func (c SessionCookie) Authenticate(ctx context.Context, req *http.Request) error {
+	req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	return nil
+}
+
+// tokenExpiryMargin is how long before it expires an OAuth2 access token is
+// replaced, so that it doesn't expire in flight.
+const tokenExpiryMargin = 30 * time.Second
+
+// OAuth2ClientCredentials authenticates requests with a bearer token from the
+// OAuth2 client credentials flow. The token is reused until it expires.
+type OAuth2ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	HTTP         *http.Client // Client used to get tokens, http.DefaultClient if nil
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	now     func() time.Time
+}
+
+// Authenticate adds a bearer token to the request, getting a new one if
+// there is none yet or it is about to expire.
+func (o *OAuth2ClientCredentials) Authenticate(ctx context.Context, req *http.Request) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now
+	if o.now != nil {
+		now = o.now
+	}
+	if o.token == "" || now().Add(tokenExpiryMargin).After(o.expires) {
+		err := o.fetchToken(ctx, now())
+		if err != nil {
+			return fmt.Errorf("could not get OAuth2 token from %s: %v", o.TokenURL, err)
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+o.token)
+	return nil
+}
+
+// fetchToken gets an access token from the token endpoint.
+func (o *OAuth2ClientCredentials) fetchToken(ctx context.Context, now time.Time) error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+	req, err := http.NewRequest(http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+
+	client := o.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"` // Seconds, the token does not expire if 0
+	}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal token: %v", err)
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("response has no access token")
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return fmt.Errorf("unsupported token type %q", token.TokenType)
+	}
+	o.token = token.AccessToken
+	o.expires = time.Unix(1<<62, 0)
+	if token.ExpiresIn > 0 {
+		o.expires = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	logger.Debugf("Got OAuth2 token from %s, expiring at %s", o.TokenURL, o.expires.Format(time.RFC3339))
+	return nil
+}
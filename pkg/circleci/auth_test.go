@@ -0,0 +1,94 @@
+package circleci
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSessionCookie(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("_oauth2_proxy")
+		if err != nil || cookie.Value != "session" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer svr.Close()
+
+	client := &HTTPClient{HTTP: svr.Client(), Auth: SessionCookie{Name: "_oauth2_proxy", Value: "session"}}
+	resp, err := client.Get(context.Background(), svr.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the session cookie to be sent, found status %d", resp.StatusCode)
+	}
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	tokens := 0
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		if id != "client" || secret != "secret" || r.Form.Get("grant_type") != "client_credentials" ||
+			r.Form.Get("scope") != "read write" {
+			t.Errorf("Unexpected token request for %s:%s with %v", id, secret, r.Form)
+		}
+		tokens++
+		fmt.Fprintf(w, `{"access_token": "token%d", "token_type": "Bearer", "expires_in": 3600}`, tokens)
+	}))
+	defer tokenServer.Close()
+	var authorizations []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+	}))
+	defer svr.Close()
+
+	now := time.Unix(1563703183, 0)
+	auth := &OAuth2ClientCredentials{TokenURL: tokenServer.URL, ClientID: "client", ClientSecret: "secret",
+		Scopes: []string{"read", "write"}, now: func() time.Time { return now }}
+	client := &HTTPClient{HTTP: svr.Client(), Auth: auth}
+	for _, wait := range []time.Duration{0, time.Minute, time.Hour} {
+		now = now.Add(wait)
+		resp, err := client.Get(context.Background(), svr.URL)
+		if err != nil {
+			t.Fatalf("Expected no error, found: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	expected := []string{"Bearer token1", "Bearer token1", "Bearer token2"}
+	if len(authorizations) != len(expected) {
+		t.Fatalf("Expected %d requests, found %v", len(expected), authorizations)
+	}
+	for i := range expected {
+		if authorizations[i] != expected[i] {
+			t.Errorf("Expected request %d to send %q, found %q", i, expected[i], authorizations[i])
+		}
+	}
+}
+
+func TestOAuth2ClientCredentialsFailure(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer tokenServer.Close()
+	requests := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { requests++ }))
+	defer svr.Close()
+
+	auth := &OAuth2ClientCredentials{TokenURL: tokenServer.URL, ClientID: "client", ClientSecret: "wrong"}
+	client := &HTTPClient{HTTP: svr.Client(), Auth: auth}
+	resp, err := client.Get(context.Background(), svr.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Error("Expected an error when no token can be had")
+	}
+	if requests != 0 {
+		t.Errorf("Expected no API requests without a token, found %d", requests)
+	}
+}
@@ -0,0 +1,138 @@
+package circleci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// APIVersion is a generation of the CircleCI API.
+type APIVersion string
+
+// Supported CircleCI API versions.
+const (
+	APIv1 APIVersion = "v1.1"
+	APIv2 APIVersion = "v2"
+)
+
+// Platform is the kind of CircleCI installation being provisioned.
+type Platform string
+
+// Known CircleCI platforms.
+const (
+	PlatformCloud   Platform = "cloud"
+	PlatformServer2 Platform = "server-2"
+	PlatformServer3 Platform = "server-3"
+)
+
+// Resource types managed through the CircleCI API.
+const (
+	ResourceFollow      = "follow"
+	ResourceEnvVar      = "envvar"
+	ResourceSSHKey      = "ssh-key"
+	ResourceCheckoutKey = "checkout-key"
+	ResourceBuild       = "build"
+	ResourcePipeline    = "pipeline"
+	ResourceSettings    = "settings"
+	ResourceInsights    = "insights"
+	ResourceWebhook     = "webhook"
+	ResourceSchedule    = "schedule"
+	ResourceOIDC        = "oidc"
+	ResourceStorage     = "storage"
+)
+
+// platformAPIs lists the API versions available on each platform.
+var platformAPIs = map[Platform][]APIVersion{
+	PlatformCloud:   {APIv2, APIv1},
+	PlatformServer2: {APIv1},
+	PlatformServer3: {APIv2, APIv1},
+}
+
+// capabilities maps each resource type to the API versions that support it,
+// in order of preference.
+var capabilities = map[string][]APIVersion{
+	ResourceFollow:      {APIv1},
+	ResourceEnvVar:      {APIv2, APIv1},
+	ResourceSSHKey:      {APIv1},
+	ResourceCheckoutKey: {APIv2, APIv1},
+	ResourceBuild:       {APIv1},
+	ResourcePipeline:    {APIv2},
+	ResourceContext:     {APIv2},
+	ResourceSettings:    {APIv1},
+	ResourceInsights:    {APIv2},
+	ResourceWebhook:     {APIv2},
+	ResourceSchedule:    {APIv2},
+	ResourceOIDC:        {APIv2},
+	ResourceStorage:     {APIv2},
+}
+
+var platformNames = map[Platform]string{
+	PlatformCloud:   "CircleCI cloud",
+	PlatformServer2: "Server 2.x",
+	PlatformServer3: "Server 3.x",
+}
+
+// CapabilityError is returned when a resource cannot be managed on a
+// platform or with a given API version.
+type CapabilityError struct {
+	Resource string
+	Platform Platform
+	Required []APIVersion
+}
+
+func (e *CapabilityError) Error() string {
+	versions := make([]string, len(e.Required))
+	for i, v := range e.Required {
+		versions[i] = "API " + string(v)
+	}
+	name, ok := platformNames[e.Platform]
+	if !ok {
+		name = string(e.Platform)
+	}
+	return fmt.Sprintf("%s requires %s / not available on %s",
+		e.Resource, strings.Join(versions, " or "), name)
+}
+
+// ParsePlatform parses a platform name as given on the command line.
+func ParsePlatform(name string) (Platform, error) {
+	platform := Platform(name)
+	if _, ok := platformAPIs[platform]; !ok {
+		return "", fmt.Errorf("unknown platform %q", name)
+	}
+	return platform, nil
+}
+
+// supports reports whether the resource can be managed with the given API
+// version on the platform.
+func supports(platform Platform, resource string, version APIVersion) bool {
+	return contains(platformAPIs[platform], version) && contains(capabilities[resource], version)
+}
+
+// negotiateAPI returns the most preferred API version that supports the
+// resource on the platform, limited to the versions the caller implements.
+func negotiateAPI(platform Platform, resource string, implemented ...APIVersion) (APIVersion, error) {
+	for _, version := range capabilities[resource] {
+		if contains(implemented, version) && supports(platform, resource, version) {
+			return version, nil
+		}
+	}
+	return "", &CapabilityError{resource, platform, capabilities[resource]}
+}
+
+// requireAPI checks that the resource can be managed with the given API
+// version on the platform, defaulting to CircleCI cloud.
+func requireAPI(platform Platform, resource string, version APIVersion) error {
+	if platform == "" {
+		platform = PlatformCloud
+	}
+	_, err := negotiateAPI(platform, resource, version)
+	return err
+}
+
+func contains(versions []APIVersion, version APIVersion) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
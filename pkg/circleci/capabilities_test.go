@@ -0,0 +1,40 @@
+package circleci
+
+import "testing"
+
+func TestNegotiateAPI(t *testing.T) {
+	type test struct {
+		platform    Platform
+		resource    string
+		implemented []APIVersion
+		expected    APIVersion
+		err         string
+	}
+
+	testCases := []test{
+		{PlatformCloud, ResourceEnvVar, []APIVersion{APIv2, APIv1}, APIv2, ""},
+		{PlatformCloud, ResourceEnvVar, []APIVersion{APIv1}, APIv1, ""},
+		{PlatformServer2, ResourceEnvVar, []APIVersion{APIv2, APIv1}, APIv1, ""},
+		{PlatformServer3, ResourceFollow, []APIVersion{APIv2, APIv1}, APIv1, ""},
+		{PlatformServer2, ResourceContext, []APIVersion{APIv2, APIv1}, "",
+			"context requires API v2 / not available on Server 2.x"},
+		{PlatformCloud, ResourcePipeline, []APIVersion{APIv1}, "",
+			"pipeline requires API v2 / not available on CircleCI cloud"},
+	}
+
+	for _, tc := range testCases {
+		actual, err := negotiateAPI(tc.platform, tc.resource, tc.implemented...)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("Expected error %q for %s on %s, found %v", tc.err, tc.resource, tc.platform, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected no error for %s on %s, found %v", tc.resource, tc.platform, err)
+		}
+		if actual != tc.expected {
+			t.Errorf("Expected %s for %s on %s, found %s", tc.expected, tc.resource, tc.platform, actual)
+		}
+	}
+}
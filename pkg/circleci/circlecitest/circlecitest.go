@@ -0,0 +1,69 @@
+// Package circlecitest provides a fake CircleCI API serving canned
+// responses, for testing code that manages projects through it.
+package circlecitest
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Response is a canned response of the fake API.
+type Response struct {
+	Status int
+	Body   string
+}
+
+// Server is a fake CircleCI API serving canned responses keyed by
+// "METHOD /path", and 404 to any other request. It records the requests it
+// receives.
+type Server struct {
+	*httptest.Server
+	responses map[string]Response
+
+	mu       sync.Mutex
+	requests []string // METHOD /path body
+}
+
+// NewServer starts a fake API serving responses. The caller should call
+// Close once done.
+func NewServer(responses map[string]Response) *Server {
+	s := &Server{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		route := r.Method + " " + r.URL.Path
+		s.mu.Lock()
+		s.requests = append(s.requests, strings.TrimSpace(route+" "+string(body)))
+		s.mu.Unlock()
+
+		resp, ok := s.responses[route]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"message": "Project not found"}`)
+			return
+		}
+		w.WriteHeader(resp.Status)
+		io.WriteString(w, resp.Body)
+	}))
+	return s
+}
+
+// Requests returns the requests received so far as "METHOD /path body", in
+// the order they were received.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Canned API v1.1 responses of the project git/test/test.
+const (
+	SettingsPath = "/project/git/test/test/settings"
+	Settings     = `{"following": true, "feature_flags": {"oss": true},
+		"ssh_keys": [{"hostname": "github.com", "fingerprint": "aa"}, {"hostname": "github.com", "fingerprint": "bb"},
+		{"hostname": "example.com", "fingerprint": "cc"}]}`
+	EnvVars = `[{"name": "A", "value": "xxxxa"}, {"name": "B", "value": "xxxxb"}]`
+)
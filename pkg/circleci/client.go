@@ -0,0 +1,298 @@
+package circleci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+)
+
+// Client makes requests to the CircleCI API.
+type Client interface {
+	BaseURL() string
+	Get(ctx context.Context, url string) (*http.Response, error)
+	Post(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error)
+	Put(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error)
+	Patch(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error)
+	Delete(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error)
+}
+
+// Recorder is told about every response the API sends, e.g. to count
+// requests and track the remaining rate limit quota.
+type Recorder interface {
+	APICall(resp *http.Response)
+}
+
+// FailureRecorder is a Recorder that is also told about requests that got no
+// response, e.g. because the API could not be reached.
+type FailureRecorder interface {
+	Recorder
+	APIFailure(err error)
+}
+
+// RetryRecorder is a Recorder that is also told about every request that is
+// retried, before waiting to retry it.
+type RetryRecorder interface {
+	Recorder
+	Retry()
+}
+
+// HTTPClient is a Client for the CircleCI API.
+type HTTPClient struct {
+	baseURL  string
+	recorder Recorder
+
+	HTTP       *http.Client  // Client used to make requests
+	MaxRetries int           // Times to retry a rate limited or failed request
+	RetryDelay time.Duration // Wait before the first retry, doubled for each one after
+	Trace      bool          // Log every request and response at debug level
+	Token      string        // API token sent in the Circle-Token header, if set
+	Auth       Authenticator // Adds credentials besides the API token, if set
+}
+
+// Default request retries. CircleCI rate limits bursts of requests, such as
+// setting many env vars in a row.
+const (
+	DefaultMaxRetries = 4
+	DefaultRetryDelay = time.Second
+	maxRetryDelay     = 30 * time.Second
+	maxRetryAfter     = 2 * time.Minute // Longest Retry-After waited for, however long the API asks
+)
+
+// NewHTTPClient creates a client for the API at baseURL, telling recorder
+// (which may be nil) about the responses it gets.
+func NewHTTPClient(baseURL string, recorder Recorder) *HTTPClient {
+	return &HTTPClient{baseURL: baseURL, recorder: recorder, HTTP: &http.Client{},
+		MaxRetries: DefaultMaxRetries, RetryDelay: DefaultRetryDelay}
+}
+
+// BaseURL gets the base URL for the client
+func (c *HTTPClient) BaseURL() string {
+	return c.baseURL
+}
+
+// tokenHeader is the header requests carry the API token in, rather than
+// the query, so that it does not end up in proxy logs or error messages.
+const tokenHeader = "Circle-Token"
+
+// WithToken returns a copy of the client sending token instead, e.g. the
+// organisation token for org scoped resources.
+func (c *HTTPClient) WithToken(token string) *HTTPClient {
+	scoped := *c
+	scoped.Token = token
+	return &scoped
+}
+
+// endpoint returns the URL of the API endpoint whose path is made of parts
+// under baseURL, carrying query. The scheme, host and path of baseURL, e.g.
+// https://circleci.com/api/v2, are kept whatever the parts hold.
+func endpoint(baseURL string, query url.Values, parts ...string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		// do reports the invalid URL.
+		return baseURL
+	}
+	u.Path = path.Join(append([]string{"/", u.Path}, parts...)...)
+	u.RawPath = ""
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// resolve returns the URL a request to rawURL is made to. Absolute URLs are
+// used as they are, while paths such as /context?owner-slug=gh/org are
+// endpoints under the client's base URL, which need not be project scoped.
+func (c *HTTPClient) resolve(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.IsAbs() || c.baseURL == "" {
+		return u, err
+	}
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	resolved := *base
+	resolved.Path = path.Join("/", base.Path, u.Path)
+	resolved.RawPath = ""
+	resolved.RawQuery = u.RawQuery
+	return &resolved, nil
+}
+
+// do makes a request, retrying it with exponential backoff while CircleCI
+// rate limits it, fails with a server error or cannot be reached. POST
+// requests are not idempotent, so they are not retried on server errors,
+// which may come after the resource was created.
+func (c *HTTPClient) do(ctx context.Context, method, rawURL, contentType string, body io.Reader) (*http.Response, error) {
+	u, err := c.resolve(rawURL)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("invalid request URL: %v", err)
+	}
+	// The body is buffered so that it can be sent again on a retry.
+	var content []byte
+	if body != nil {
+		content, err = ioutil.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("could not read request body: %v", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, u.String(), bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		if c.Token != "" {
+			req.Header.Set(tokenHeader, c.Token)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if c.Auth != nil {
+			err = c.Auth.Authenticate(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+		}
+		c.traceRequest(req, content)
+		resp, err := c.HTTP.Do(req)
+		if c.recorder != nil {
+			c.recorder.APICall(resp)
+		}
+		if failures, ok := c.recorder.(FailureRecorder); ok && err != nil && ctx.Err() == nil {
+			failures.APIFailure(err)
+		}
+		c.traceResponse(req, resp)
+		if attempt >= c.MaxRetries || ctx.Err() != nil || !retryable(method, resp, err) {
+			return resp, err
+		}
+
+		delay := retryAfter(resp, c.RetryDelay<<uint(attempt))
+		if err != nil {
+			logger.Warnf("%s %s could not connect, retrying in %v: %v", method, req.URL.Path, delay, err)
+		} else {
+			resp.Body.Close()
+			logger.Warnf("%s %s returned %d, retrying in %v", method, req.URL.Path, resp.StatusCode, delay)
+		}
+		if retries, ok := c.recorder.(RetryRecorder); ok {
+			retries.Retry()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// traceRequest logs the request at debug level. The body is not logged as it
+// carries the secrets being provisioned.
+func (c *HTTPClient) traceRequest(req *http.Request, body []byte) {
+	if !c.Trace {
+		return
+	}
+	logger.Debugf("> %s %s (%d byte body)", req.Method, req.URL, len(body))
+}
+
+// traceResponse logs the response to req, if there is one, at debug level.
+func (c *HTTPClient) traceResponse(req *http.Request, resp *http.Response) {
+	if resp == nil || !c.Trace {
+		return
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		logger.Debugf("< %s %s %d (could not read body: %v)", req.Method, req.URL, resp.StatusCode, err)
+		return
+	}
+	logger.Debugf("< %s %s %d %s", req.Method, req.URL, resp.StatusCode, bytes.TrimSpace(body))
+}
+
+// retryable reports whether a request made with method that got resp, or
+// failed with err, is worth retrying. Only requests that never reached the
+// API are retried after an error.
+func retryable(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		return dialFailed(err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return method != http.MethodPost && resp.StatusCode >= http.StatusInternalServerError
+}
+
+// dialFailed reports whether err is a failure to connect, before anything
+// of the request was sent.
+func dialFailed(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
+
+// retryAfter returns how long to wait before retrying the request resp
+// answered, as given by its Retry-After header, up to maxRetryAfter, or
+// backoff otherwise. resp is nil if the request got no response.
+func retryAfter(resp *http.Response, backoff time.Duration) time.Duration {
+	var header string
+	if resp != nil {
+		header = resp.Header.Get("Retry-After")
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		// Compared in seconds, as a huge header would overflow a Duration.
+		if seconds > int(maxRetryAfter/time.Second) {
+			return maxRetryAfter
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		delay := time.Until(at)
+		switch {
+		case delay > maxRetryAfter:
+			return maxRetryAfter
+		case delay > 0:
+			return delay
+		}
+		return 0
+	}
+	if backoff > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return backoff
+}
+
+// Get performs a GET request
+func (c *HTTPClient) Get(ctx context.Context, url string) (*http.Response, error) {
+	return c.do(ctx, http.MethodGet, url, "", nil)
+}
+
+// Post performs a POST request
+func (c *HTTPClient) Post(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	return c.do(ctx, http.MethodPost, url, contentType, body)
+}
+
+// Put performs a PUT request
+func (c *HTTPClient) Put(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	return c.do(ctx, http.MethodPut, url, contentType, body)
+}
+
+// Patch performs a PATCH request
+func (c *HTTPClient) Patch(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	return c.do(ctx, http.MethodPatch, url, contentType, body)
+}
+
+// Delete performs a DELETE request
+func (c *HTTPClient) Delete(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	return c.do(ctx, http.MethodDelete, url, contentType, body)
+}
@@ -0,0 +1,217 @@
+package circleci
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestCancelled(t *testing.T) {
+	stalled := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stalled
+	})
+	svr := httptest.NewServer(handler)
+	defer svr.Close()
+	defer close(stalled)
+
+	client := &HTTPClient{baseURL: svr.URL, HTTP: &http.Client{}}
+	project := ProjectV1{vcsType: "git", owner: "test", projectName: "test",
+		client: client}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := project.Getenvs(ctx)
+	if err == nil || !strings.Contains(err.Error(), "context deadline exceeded") {
+		t.Errorf("Expected the request to be cancelled, found: %v", err)
+	}
+}
+
+func TestRequestRetries(t *testing.T) {
+	testCases := []struct {
+		method   string
+		statuses []int
+		requests int
+		status   int
+	}{
+		{http.MethodPut, []int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusOK}, 3, http.StatusOK},
+		{http.MethodPut, []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, 3, http.StatusBadGateway},
+		{http.MethodPut, []int{http.StatusBadRequest}, 1, http.StatusBadRequest},
+		{http.MethodPost, []int{http.StatusTooManyRequests, http.StatusOK}, 2, http.StatusOK},
+		{http.MethodPost, []int{http.StatusServiceUnavailable}, 1, http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		requests := 0
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			if string(body) != "body" {
+				t.Errorf("Expected the body to be sent on every attempt, found %q", body)
+			}
+			if tc.statuses[requests] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "0")
+			}
+			w.WriteHeader(tc.statuses[requests])
+			requests++
+		})
+		svr := httptest.NewServer(handler)
+
+		recorder := &failures{}
+		client := &HTTPClient{baseURL: svr.URL, recorder: recorder, HTTP: &http.Client{}, MaxRetries: 2,
+			RetryDelay: time.Millisecond}
+		resp, err := client.do(context.Background(), tc.method, svr.URL, "text/plain", strings.NewReader("body"))
+		if err != nil {
+			t.Fatalf("Expected no error for %s statuses %v, found: %v", tc.method, tc.statuses, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("Expected status %d for %s statuses %v, found %d", tc.status, tc.method, tc.statuses, resp.StatusCode)
+		}
+		if requests != tc.requests {
+			t.Errorf("Expected %d requests for %s statuses %v, found %d", tc.requests, tc.method, tc.statuses, requests)
+		}
+		if recorder.retries != tc.requests-1 {
+			t.Errorf("Expected %d retries to be recorded for %s statuses %v, found %d", tc.requests-1, tc.method,
+				tc.statuses, recorder.retries)
+		}
+		svr.Close()
+	}
+}
+
+func TestRequestRetriesUnreachable(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unreachable := svr.URL
+	svr.Close()
+
+	recorder := &failures{}
+	client := NewHTTPClient(unreachable, recorder)
+	client.MaxRetries, client.RetryDelay = 2, time.Millisecond
+	_, err := client.Post(context.Background(), unreachable+"/me", "text/plain", strings.NewReader("body"))
+	if err == nil {
+		t.Fatal("Expected an error for an unreachable API")
+	}
+	if recorder.failed != 3 || recorder.retries != 2 {
+		t.Errorf("Expected a POST that could not connect to be retried twice, found %d attempts and %d retries",
+			recorder.failed, recorder.retries)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	testCases := []struct {
+		header   string
+		backoff  time.Duration
+		expected time.Duration
+	}{
+		{"", time.Second, time.Second},
+		{"", time.Hour, maxRetryDelay},
+		{"3", time.Second, 3 * time.Second},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), time.Second, 0},
+		{"soon", 2 * time.Second, 2 * time.Second},
+		{"86400", time.Second, maxRetryAfter},
+		{"99999999999999999", time.Second, maxRetryAfter},
+		{time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat), time.Second, maxRetryAfter},
+	}
+	for _, tc := range testCases {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Retry-After", tc.header)
+		if actual := retryAfter(resp, tc.backoff); actual != tc.expected {
+			t.Errorf("Expected to wait %v for Retry-After %q, found %v", tc.expected, tc.header, actual)
+		}
+	}
+}
+
+func TestRequestTokenHeader(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.Header.Get("Circle-Token"); token != "secret" {
+			t.Errorf("Expected the token in the Circle-Token header, found %q", token)
+		}
+		if r.URL.RawQuery != "limit=1" {
+			t.Errorf("Expected the token to be left out of the query, found %q", r.URL.RawQuery)
+		}
+	})
+	svr := httptest.NewServer(handler)
+	client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client(), Token: "secret"}
+	resp, err := client.Get(context.Background(), svr.URL+"/me?limit=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	svr.Close()
+	_, err = client.Get(context.Background(), svr.URL+"/me")
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("Expected an error without the token, found: %v", err)
+	}
+}
+
+func TestWithToken(t *testing.T) {
+	client := &HTTPClient{Token: "personal", MaxRetries: 2}
+	scoped := client.WithToken("org")
+	if scoped.Token != "org" || scoped.MaxRetries != 2 || client.Token != "personal" {
+		t.Errorf("Expected a copy of the client sending the org token, found %+v from %+v", scoped, client)
+	}
+}
+
+type failures struct{ calls, failed, retries int }
+
+func (f *failures) APICall(resp *http.Response) { f.calls++ }
+func (f *failures) APIFailure(err error)        { f.failed++ }
+func (f *failures) Retry()                      { f.retries++ }
+
+func TestRequestFailureRecorder(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unreachable := svr.URL
+	svr.Close()
+
+	recorder := &failures{}
+	client := NewHTTPClient(unreachable, recorder)
+	client.MaxRetries = 0
+	if _, err := client.Get(context.Background(), unreachable+"/me"); err == nil {
+		t.Fatal("Expected an error for an unreachable API")
+	}
+	if recorder.calls != 1 || recorder.failed != 1 {
+		t.Errorf("Expected 1 call and 1 failure, found %+v", recorder)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.Get(ctx, unreachable+"/me")
+	if recorder.failed != 1 {
+		t.Errorf("Expected cancelled requests not to count as failures, found %d", recorder.failed)
+	}
+}
+
+func TestRequestPaths(t *testing.T) {
+	var paths []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.RequestURI())
+	}))
+	defer svr.Close()
+
+	client := &HTTPClient{baseURL: svr.URL + "/api/v2/", HTTP: svr.Client()}
+	for _, rawURL := range []string{"/context?owner-slug=gh%2Forg", "me", svr.URL + "/api/v1.1/project/gh/org/repo"} {
+		resp, err := client.Get(context.Background(), rawURL)
+		if err != nil {
+			t.Fatalf("Expected %s to be requested, found: %v", rawURL, err)
+		}
+		resp.Body.Close()
+	}
+	expected := []string{"/api/v2/context?owner-slug=gh%2Forg", "/api/v2/me", "/api/v1.1/project/gh/org/repo"}
+	if strings.Join(paths, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected requests to %v, found %v", expected, paths)
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	query := url.Values{"limit": {"1"}}
+	actual := endpoint("https://circleci.com/api/v2", query, "project", "gh/org/repo", "envvar", "A B")
+	expected := "https://circleci.com/api/v2/project/gh/org/repo/envvar/A%20B?limit=1"
+	if actual != expected {
+		t.Errorf("Expected %s, found %s", expected, actual)
+	}
+}
@@ -0,0 +1,250 @@
+package circleci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// Context is a CircleCI organisation context.
+type Context struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Contexts manages the contexts of an organisation through API v2. Its client
+// should carry the organisation token where one is configured.
+type Contexts struct {
+	vcsType  string
+	owner    string
+	client   Client
+	Platform Platform // Platform being managed, CircleCI cloud if empty
+}
+
+// NewContextsWithClient creates a manager for the contexts of the
+// organisation that makes requests using client.
+func NewContextsWithClient(vcsType, owner string, client Client) *Contexts {
+	return &Contexts{vcsType: vcsType, owner: owner, client: client}
+}
+
+// OwnerSlug returns the organisation slug (e.g. gh/owner, or circleci/org-id
+// for standalone organisations).
+func (c *Contexts) OwnerSlug() string {
+	return path.Join(vcsSlug(c.vcsType), c.owner)
+}
+
+// fmtURI formats a URI for a context resource.
+func (c *Contexts) fmtURI(query url.Values, parts ...string) string {
+	return endpoint(c.client.BaseURL(), query, append([]string{"context"}, parts...)...)
+}
+
+// List lists the organisation's contexts.
+func (c *Contexts) List(ctx context.Context) ([]Context, error) {
+	if err := requireAPI(c.Platform, ResourceContext, APIv2); err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if IsStandalone(c.vcsType) {
+		query.Set("owner-id", c.owner)
+	} else {
+		query.Set("owner-slug", c.OwnerSlug())
+	}
+	var contexts []Context
+	err := getItems(ctx, c.client, c.fmtURI(query), func(item json.RawMessage) error {
+		var context Context
+		err := json.Unmarshal(item, &context)
+		contexts = append(contexts, context)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list contexts for %s: %v", c.OwnerSlug(), err)
+	}
+	return contexts, nil
+}
+
+// Create creates a context in the organisation.
+func (c *Contexts) Create(ctx context.Context, name string) (Context, error) {
+	var context Context
+	if err := requireAPI(c.Platform, ResourceContext, APIv2); err != nil {
+		return context, err
+	}
+	postBody := struct {
+		Name  string `json:"name"`
+		Owner struct {
+			ID   string `json:"id,omitempty"`
+			Slug string `json:"slug,omitempty"`
+			Type string `json:"type"`
+		} `json:"owner"`
+	}{Name: name}
+	// Standalone organisations have no slug on a VCS, so are given by ID.
+	if IsStandalone(c.vcsType) {
+		postBody.Owner.ID = c.owner
+	} else {
+		postBody.Owner.Slug = c.OwnerSlug()
+	}
+	postBody.Owner.Type = "organization"
+	postBodyJSON, err := json.Marshal(postBody)
+	if err != nil {
+		return context, fmt.Errorf("could not marshal context %s: %v", name, err)
+	}
+
+	resp, err := c.client.Post(ctx, c.fmtURI(nil), "application/json", bytes.NewReader(postBodyJSON))
+	if err != nil {
+		return context, fmt.Errorf("could not create context %s: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return context, fmt.Errorf("context %s not created: status %s", name, resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&context)
+	if err != nil {
+		return context, fmt.Errorf("could not unmarshal context %s: %v", name, err)
+	}
+	return context, nil
+}
+
+// EnvVarNames lists the names of the context's environment variables.
+func (c *Contexts) EnvVarNames(ctx context.Context, context Context) ([]string, error) {
+	if err := requireAPI(c.Platform, ResourceContext, APIv2); err != nil {
+		return nil, err
+	}
+	var names []string
+	err := getItems(ctx, c.client, c.fmtURI(nil, context.ID, "environment-variable"), func(item json.RawMessage) error {
+		var envVar struct {
+			Variable string `json:"variable"`
+		}
+		err := json.Unmarshal(item, &envVar)
+		names = append(names, envVar.Variable)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list environment variables of context %s: %v", context.Name, err)
+	}
+	return names, nil
+}
+
+// Setenv creates or updates an environment variable in the context.
+func (c *Contexts) Setenv(ctx context.Context, context Context, name, value string) error {
+	if err := requireAPI(c.Platform, ResourceContext, APIv2); err != nil {
+		return err
+	}
+	putBodyJSON, err := json.Marshal(struct {
+		Value string `json:"value"`
+	}{value})
+	if err != nil {
+		return fmt.Errorf("could not marshal environment variable %s: %v", name, err)
+	}
+
+	uri := c.fmtURI(nil, context.ID, "environment-variable", name)
+	resp, err := c.client.Put(ctx, uri, "application/json", bytes.NewReader(putBodyJSON))
+	if err != nil {
+		return fmt.Errorf("could not set environment variable %s in context %s: %v", name, context.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("environment variable %s not set in context %s: status %s", name, context.Name, resp.Status)
+	}
+	return nil
+}
+
+// Deleteenv removes an environment variable from the context.
+func (c *Contexts) Deleteenv(ctx context.Context, context Context, name string) error {
+	if err := requireAPI(c.Platform, ResourceContext, APIv2); err != nil {
+		return err
+	}
+	resp, err := c.client.Delete(ctx, c.fmtURI(nil, context.ID, "environment-variable", name), "", nil)
+	if err != nil {
+		return fmt.Errorf("could not remove environment variable %s from context %s: %v", name, context.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("environment variable %s not removed from context %s: status %s",
+			name, context.Name, resp.Status)
+	}
+	return nil
+}
+
+// Context restriction types.
+const (
+	RestrictionProject = "project"
+	RestrictionGroup   = "group"
+)
+
+// ContextRestriction limits which projects or groups can use a context.
+type ContextRestriction struct {
+	ID    string `json:"id"`
+	Type  string `json:"restriction_type"`
+	Value string `json:"restriction_value"`
+	Name  string `json:"name"` // Name of the restricted project or group
+}
+
+// Restrictions lists the context's restrictions.
+func (c *Contexts) Restrictions(ctx context.Context, context Context) ([]ContextRestriction, error) {
+	if err := requireAPI(c.Platform, ResourceContext, APIv2); err != nil {
+		return nil, err
+	}
+	var restrictions []ContextRestriction
+	err := getItems(ctx, c.client, c.fmtURI(nil, context.ID, "restrictions"), func(item json.RawMessage) error {
+		var restriction ContextRestriction
+		err := json.Unmarshal(item, &restriction)
+		restrictions = append(restrictions, restriction)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list restrictions of context %s: %v", context.Name, err)
+	}
+	return restrictions, nil
+}
+
+// AddRestriction restricts the context to a project or group.
+func (c *Contexts) AddRestriction(ctx context.Context, context Context, restrictionType, value string) error {
+	if err := requireAPI(c.Platform, ResourceContext, APIv2); err != nil {
+		return err
+	}
+	postBodyJSON, err := json.Marshal(struct {
+		Type  string `json:"restriction_type"`
+		Value string `json:"restriction_value"`
+	}{restrictionType, value})
+	if err != nil {
+		return fmt.Errorf("could not marshal restriction: %v", err)
+	}
+
+	uri := c.fmtURI(nil, context.ID, "restrictions")
+	resp, err := c.client.Post(ctx, uri, "application/json", bytes.NewReader(postBodyJSON))
+	if err != nil {
+		return fmt.Errorf("could not restrict context %s: %v", context.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("context %s not restricted to %s %s: status %s",
+			context.Name, restrictionType, value, resp.Status)
+	}
+	return nil
+}
+
+// DeleteRestriction removes one of the context's restrictions.
+func (c *Contexts) DeleteRestriction(ctx context.Context, context Context, restriction ContextRestriction) error {
+	if err := requireAPI(c.Platform, ResourceContext, APIv2); err != nil {
+		return err
+	}
+	uri := c.fmtURI(nil, context.ID, "restrictions", restriction.ID)
+	resp, err := c.client.Delete(ctx, uri, "", nil)
+	if err != nil {
+		return fmt.Errorf("could not remove restriction of context %s: %v", context.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("restriction of context %s to %s %s not removed: status %s",
+			context.Name, restriction.Type, restriction.Value, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package circleci
+
+// Resource types used to pick a credential for an API operation.
+const (
+	ResourceProject = "project"
+	ResourceContext = "context"
+)
+
+// orgScopedResources are the resource types that organization tokens have
+// access to but personal tokens may not.
+var orgScopedResources = map[string]bool{
+	ResourceContext: true,
+}
+
+// Credentials holds the tokens used to authenticate with CircleCI.
+type Credentials struct {
+	Token    string // Personal API token
+	OrgToken string // Organization-scoped token (optional)
+}
+
+// TokenFor returns the token to use for operations on the given resource
+// type. Organization-scoped resources (e.g. contexts) use the org token when
+// one is configured, everything else uses the personal token.
+func (c Credentials) TokenFor(resource string) string {
+	if orgScopedResources[resource] && c.OrgToken != "" {
+		return c.OrgToken
+	}
+	return c.Token
+}
@@ -0,0 +1,25 @@
+package circleci
+
+import "testing"
+
+func TestCredentialsTokenFor(t *testing.T) {
+	type test struct {
+		creds    Credentials
+		resource string
+		expected string
+	}
+
+	testCases := []test{
+		{Credentials{Token: "personal"}, ResourceProject, "personal"},
+		{Credentials{Token: "personal"}, ResourceContext, "personal"},
+		{Credentials{Token: "personal", OrgToken: "org"}, ResourceProject, "personal"},
+		{Credentials{Token: "personal", OrgToken: "org"}, ResourceContext, "org"},
+	}
+
+	for _, tc := range testCases {
+		actual := tc.creds.TokenFor(tc.resource)
+		if actual != tc.expected {
+			t.Errorf("Expected token %s for %s, found %s", tc.expected, tc.resource, actual)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+// Package circleci manages CircleCI projects and organisation contexts
+// through the CircleCI API v1.1 and v2.
+//
+// ProjectV2 uses API v2 where it covers a resource and the platform supports
+// it, falling back to API v1.1 (ProjectV1) otherwise.
+package circleci
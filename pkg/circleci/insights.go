@@ -0,0 +1,73 @@
+package circleci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// WorkflowMetrics summarises the recent runs of one of a project's
+// workflows, as reported by Insights.
+type WorkflowMetrics struct {
+	Name           string
+	TotalRuns      int
+	SuccessfulRuns int
+	FailedRuns     int
+	SuccessRate    float64 // Between 0 and 1
+	MedianDuration time.Duration
+	P95Duration    time.Duration // 95th percentile of the successful runs' durations
+}
+
+// WorkflowInsights is not available through API v1.1.
+func (p *ProjectV1) WorkflowInsights(ctx context.Context, branch string) ([]WorkflowMetrics, error) {
+	return nil, p.require(ResourceInsights)
+}
+
+// WorkflowInsights returns the metrics of the project's workflows on branch,
+// or on the default branch if branch is empty, over Insights' default
+// reporting window.
+func (p *ProjectV2) WorkflowInsights(ctx context.Context, branch string) ([]WorkflowMetrics, error) {
+	if err := p.require(ResourceInsights); err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if branch != "" {
+		query.Set("branch", branch)
+	}
+	uri := endpoint(p.client.BaseURL(), query, "insights", p.Slug(), "workflows")
+
+	var metrics []WorkflowMetrics
+	err := getItems(ctx, p.client, uri, func(item json.RawMessage) error {
+		var workflow struct {
+			Name    string `json:"name"`
+			Metrics struct {
+				TotalRuns      int     `json:"total_runs"`
+				SuccessfulRuns int     `json:"successful_runs"`
+				FailedRuns     int     `json:"failed_runs"`
+				SuccessRate    float64 `json:"success_rate"`
+				Durations      struct {
+					Median float64 `json:"median"` // Seconds
+					P95    float64 `json:"p95"`
+				} `json:"duration_metrics"`
+			} `json:"metrics"`
+		}
+		err := json.Unmarshal(item, &workflow)
+		m := workflow.Metrics
+		metrics = append(metrics, WorkflowMetrics{
+			Name:           workflow.Name,
+			TotalRuns:      m.TotalRuns,
+			SuccessfulRuns: m.SuccessfulRuns,
+			FailedRuns:     m.FailedRuns,
+			SuccessRate:    m.SuccessRate,
+			MedianDuration: time.Duration(m.Durations.Median * float64(time.Second)),
+			P95Duration:    time.Duration(m.Durations.P95 * float64(time.Second)),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get workflow insights of project %s: %v", p.FullName(), err)
+	}
+	return metrics, nil
+}
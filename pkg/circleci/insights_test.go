@@ -0,0 +1,40 @@
+package circleci
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWorkflowInsights(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/insights/gh/test/test/workflows" || r.URL.Query().Get("branch") != "main" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		io.WriteString(w, `{"items": [{"name": "build", "metrics": {"total_runs": 10, "successful_runs": 8,
+			"failed_runs": 2, "success_rate": 0.8, "duration_metrics": {"median": 90, "p95": 120.5}}}],
+			"next_page_token": null}`)
+	}))
+	defer svr.Close()
+
+	client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+	project := NewProjectV2WithClient("gh", "test", "test", client, client)
+	metrics, err := project.WorkflowInsights(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := []WorkflowMetrics{{Name: "build", TotalRuns: 10, SuccessfulRuns: 8, FailedRuns: 2, SuccessRate: 0.8,
+		MedianDuration: 90 * time.Second, P95Duration: 120500 * time.Millisecond}}
+	if !reflect.DeepEqual(metrics, expected) {
+		t.Errorf("Expected %+v, found %+v", expected, metrics)
+	}
+
+	_, err = NewProjectV1WithClient("gh", "test", "test", client).WorkflowInsights(context.Background(), "")
+	if _, ok := err.(*CapabilityError); !ok {
+		t.Errorf("Expected a capability error through API v1.1, found: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package circleci
+
+// Logger receives the messages the package logs, such as retried requests
+// and, for clients with Trace set, request traces.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Warnf(format string, args ...interface{})  {}
+
+// logger is where the package's messages go, nowhere until SetLogger is
+// called.
+var logger Logger = nopLogger{}
+
+// SetLogger sends the package's log messages to l.
+func SetLogger(l Logger) {
+	logger = l
+}
@@ -0,0 +1,120 @@
+package circleci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// OIDCClaims are the custom claims of the OIDC tokens issued to a project's
+// jobs. Claims left empty take the organization's value.
+type OIDCClaims struct {
+	Audience []string `json:"audience,omitempty"` // aud claim of the tokens
+	TTL      string   `json:"ttl,omitempty"`      // Lifetime of the tokens, e.g. 1h
+}
+
+// OIDCClaims is not available through API v1.1.
+func (p *ProjectV1) OIDCClaims(ctx context.Context) (OIDCClaims, error) {
+	return OIDCClaims{}, p.require(ResourceOIDC)
+}
+
+// SetOIDCClaims is not available through API v1.1.
+func (p *ProjectV1) SetOIDCClaims(ctx context.Context, claims OIDCClaims) error {
+	return p.require(ResourceOIDC)
+}
+
+// DeleteOIDCClaims is not available through API v1.1.
+func (p *ProjectV1) DeleteOIDCClaims(ctx context.Context) error {
+	return p.require(ResourceOIDC)
+}
+
+// oidcURI formats the URI of the project's OIDC custom claims, which are
+// scoped to its organization and ID rather than its slug.
+func (p *ProjectV2) oidcURI(ctx context.Context, query url.Values) (string, error) {
+	_, id, orgID, err := p.get(ctx)
+	if err != nil {
+		return "", err
+	}
+	return endpoint(p.client.BaseURL(), query, "org", orgID, "project", id, "oidc-custom-claims"), nil
+}
+
+// OIDCClaims gets the custom claims of the project's OIDC tokens.
+func (p *ProjectV2) OIDCClaims(ctx context.Context) (OIDCClaims, error) {
+	var claims OIDCClaims
+	if err := p.require(ResourceOIDC); err != nil {
+		return claims, err
+	}
+	uri, err := p.oidcURI(ctx, nil)
+	if err != nil {
+		return claims, err
+	}
+	resp, err := p.client.Get(ctx, uri)
+	if err != nil {
+		return claims, fmt.Errorf("could not get OIDC claims of project %s: %v", p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return claims, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return claims, fmt.Errorf("could not get OIDC claims of project %s: status %s", p.FullName(), resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&claims)
+	if err != nil {
+		return claims, fmt.Errorf("could not unmarshal OIDC claims of project %s: %v", p.FullName(), err)
+	}
+	return claims, nil
+}
+
+// SetOIDCClaims sets the custom claims of the project's OIDC tokens. Empty
+// claims are left unchanged.
+func (p *ProjectV2) SetOIDCClaims(ctx context.Context, claims OIDCClaims) error {
+	if err := p.require(ResourceOIDC); err != nil {
+		return err
+	}
+	uri, err := p.oidcURI(ctx, nil)
+	if err != nil {
+		return err
+	}
+	patchBodyJSON, err := json.Marshal(claims)
+	if err != nil {
+		return fmt.Errorf("could not marshal OIDC claims: %v", err)
+	}
+
+	resp, err := p.client.Patch(ctx, uri, "application/json", bytes.NewReader(patchBodyJSON))
+	if err != nil {
+		return fmt.Errorf("could not set OIDC claims of project %s: %v", p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not set OIDC claims of project %s: status %s", p.FullName(), resp.Status)
+	}
+	return nil
+}
+
+// DeleteOIDCClaims removes the project's custom claims, so that its tokens
+// take the organization's.
+func (p *ProjectV2) DeleteOIDCClaims(ctx context.Context) error {
+	if err := p.require(ResourceOIDC); err != nil {
+		return err
+	}
+	uri, err := p.oidcURI(ctx, url.Values{"claims": {"audience,ttl"}})
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Delete(ctx, uri, "", nil)
+	if err != nil {
+		return fmt.Errorf("could not delete OIDC claims of project %s: %v", p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("could not delete OIDC claims of project %s: status %s", p.FullName(), resp.Status)
+	}
+	return nil
+}
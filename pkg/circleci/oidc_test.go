@@ -0,0 +1,55 @@
+package circleci
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestOIDCClaims(t *testing.T) {
+	var patched OIDCClaims
+	var deleted string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /project/gh/test/test":
+			io.WriteString(w, `{"id": "project-id", "organization_id": "org-id"}`)
+		case "GET /org/org-id/project/project-id/oidc-custom-claims":
+			io.WriteString(w, `{"audience": ["sts.amazonaws.com"], "ttl": "1h", "org_id": "org-id"}`)
+		case "PATCH /org/org-id/project/project-id/oidc-custom-claims":
+			json.NewDecoder(r.Body).Decode(&patched)
+		case "DELETE /org/org-id/project/project-id/oidc-custom-claims":
+			deleted = r.URL.Query().Get("claims")
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+	project := NewProjectV2WithClient("gh", "test", "test", client, client)
+	ctx := context.Background()
+
+	claims, err := project.OIDCClaims(ctx)
+	expected := OIDCClaims{Audience: []string{"sts.amazonaws.com"}, TTL: "1h"}
+	if err != nil || !reflect.DeepEqual(claims, expected) {
+		t.Errorf("Expected %+v and no error, found %+v and %v", expected, claims, err)
+	}
+	wanted := OIDCClaims{Audience: []string{"vault"}, TTL: "30m"}
+	if err := project.SetOIDCClaims(ctx, wanted); err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+	if !reflect.DeepEqual(patched, wanted) {
+		t.Errorf("Expected claims %+v to be set, found %+v", wanted, patched)
+	}
+	if err := project.DeleteOIDCClaims(ctx); err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+	if deleted != "audience,ttl" {
+		t.Errorf("Expected the audience and ttl claims to be deleted, found %q", deleted)
+	}
+}
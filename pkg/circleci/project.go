@@ -0,0 +1,778 @@
+package circleci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Project represents a project
+type Project interface {
+	FullName() string
+	Slug() string
+	Follow(ctx context.Context) error
+	Unfollow(ctx context.Context) error
+	IsFollowing(ctx context.Context) (bool, error)
+	Setenv(ctx context.Context, name, value string) error
+	Getenv(ctx context.Context, name string) (string, error)
+	Getenvs(ctx context.Context) (map[string]string, error)
+	Deleteenv(ctx context.Context, name string) error
+	Clearenv(ctx context.Context) error
+	AddSSHKey(ctx context.Context, name string, privateKey string) error
+	GetSSHKeys(ctx context.Context) ([]SSHKey, error)
+	GetSSHKeyFingerprint(ctx context.Context, name string) (string, error)
+	RemoveSSHKey(ctx context.Context, name string) error
+	DeleteSSHKey(ctx context.Context, hostname, fingerprint string) error
+	ClearSSHKeys(ctx context.Context) error
+	CheckoutKeys(ctx context.Context) ([]CheckoutKey, error)
+	CreateCheckoutKey(ctx context.Context, keyType string) (CheckoutKey, error)
+	DeleteCheckoutKey(ctx context.Context, fingerprint string) error
+	Trigger(ctx context.Context, opts TriggerOptions) (Build, error)
+	BuildStatus(ctx context.Context, build Build) (BuildStatus, error)
+	WorkflowInsights(ctx context.Context, branch string) ([]WorkflowMetrics, error)
+	Webhooks(ctx context.Context) ([]Webhook, error)
+	CreateWebhook(ctx context.Context, webhook Webhook) (Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook Webhook) error
+	DeleteWebhook(ctx context.Context, id string) error
+	Schedules(ctx context.Context) ([]Schedule, error)
+	CreateSchedule(ctx context.Context, schedule Schedule) (Schedule, error)
+	UpdateSchedule(ctx context.Context, schedule Schedule) error
+	DeleteSchedule(ctx context.Context, id string) error
+	OIDCClaims(ctx context.Context) (OIDCClaims, error)
+	SetOIDCClaims(ctx context.Context, claims OIDCClaims) error
+	DeleteOIDCClaims(ctx context.Context) error
+	StoragePolicy(ctx context.Context) (StoragePolicy, error)
+	SetStoragePolicy(ctx context.Context, policy StoragePolicy) error
+	SetJiraIntegration(ctx context.Context, jira JiraIntegration) error
+	FeatureFlags(ctx context.Context) (map[string]interface{}, error)
+	SetFeatureFlags(ctx context.Context, flags map[string]interface{}) error
+}
+
+// ProjectV1 represents a CircleCI project
+type ProjectV1 struct {
+	vcsType     string
+	owner       string
+	projectName string
+	client      Client
+	Platform    Platform // Platform being managed, CircleCI cloud if empty
+	Version     string   // CircleCI Server version being managed, not checked if empty
+
+	FollowAttempts int           // Times to try following a project CircleCI does not know yet
+	FollowDelay    time.Duration // Wait between follow attempts
+}
+
+// DefaultBaseURL is the base URL of the CircleCI v1.1 API.
+const DefaultBaseURL = "https://circleci.com/api/v1.1"
+
+// Default follow retries. CircleCI can take a moment to learn about a
+// freshly created repository, during which following it returns 404.
+const (
+	DefaultFollowAttempts = 3
+	DefaultFollowDelay    = 2 * time.Second
+)
+
+// NewProjectV1 creates a Circle CI project representation.
+func NewProjectV1(vcsType, owner, projectName string, creds Credentials) *ProjectV1 {
+	client := NewHTTPClient(DefaultBaseURL, nil)
+	client.Token = creds.TokenFor(ResourceProject)
+	return NewProjectV1WithClient(vcsType, owner, projectName, client)
+}
+
+// NewProjectV1WithClient creates a Circle CI project representation
+// that makes requests using client.
+func NewProjectV1WithClient(vcsType, owner, projectName string, client Client) *ProjectV1 {
+	return &ProjectV1{
+		vcsType:     vcsType,
+		owner:       owner,
+		projectName: projectName,
+		client:      client,
+
+		FollowAttempts: DefaultFollowAttempts,
+		FollowDelay:    DefaultFollowDelay,
+	}
+}
+
+// fmtURI formats a URI to be used for Circle CI API requests.
+func (p *ProjectV1) fmtURI(resource, action string) string {
+	return endpoint(p.client.BaseURL(), nil, resource, vcsName(p.vcsType), p.owner, p.projectName, action)
+}
+
+// require checks that the resource can be managed over API v1.1 on the
+// project's platform.
+func (p *ProjectV1) require(resource string) error {
+	if IsStandalone(p.vcsType) {
+		return fmt.Errorf("%s is not available for standalone project %s, API v1.1 does not support them",
+			resource, p.FullName())
+	}
+	if err := requireAPI(p.Platform, resource, APIv1); err != nil {
+		return err
+	}
+	return requireServerVersion(p.Platform, p.Version, resource)
+}
+
+// FullName returns the full name of the project
+func (p *ProjectV1) FullName() string {
+	return fmt.Sprintf("%s/%s", p.owner, p.projectName)
+}
+
+// Slug returns the project slug, e.g. gh/owner/project
+func (p *ProjectV1) Slug() string {
+	return ProjectSlug(p.vcsType, p.owner, p.projectName)
+}
+
+// Follow follows the project. A project CircleCI does not know about yet
+// (404) is retried, while a project the token cannot access (403) fails
+// straight away.
+func (p *ProjectV1) Follow(ctx context.Context) error {
+	if err := p.require(ResourceFollow); err != nil {
+		return err
+	}
+	url := p.fmtURI("project", "follow")
+	for attempt := 1; ; attempt++ {
+		resp, err := p.client.Post(ctx, url, "", strings.NewReader(""))
+		if err != nil {
+			return fmt.Errorf("could not follow project %s: %v", p.FullName(), err)
+		}
+		message := apiMessage(resp)
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusCreated:
+			return nil
+		case resp.StatusCode == http.StatusForbidden:
+			return fmt.Errorf("no access to follow project %s (status 403): check that the token belongs to a user "+
+				"with admin access to the %s repository and that the organisation has authorised CircleCI",
+				p.FullName(), p.vcsType)
+		case resp.StatusCode == http.StatusNotFound && attempt < p.FollowAttempts:
+			logger.Warnf("Project %s not found, retrying in %s (attempt %d of %d)",
+				p.FullName(), p.FollowDelay, attempt, p.FollowAttempts)
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("could not follow project %s: %v", p.FullName(), ctx.Err())
+			case <-time.After(p.FollowDelay):
+			}
+		case resp.StatusCode == http.StatusNotFound:
+			return fmt.Errorf("project %s not found after %d attempt(s): check the vcsType, owner and projectName",
+				p.FullName(), attempt)
+		default:
+			return fmt.Errorf("error following project %s: expected status %d, found %d: %s",
+				p.FullName(), http.StatusCreated, resp.StatusCode, message)
+		}
+	}
+}
+
+// apiMessage returns the message of an API error response, or its body if
+// it has none.
+func apiMessage(resp *http.Response) string {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return ""
+	}
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+		return apiErr.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// maxBodyInError is how much of a response body decoding errors quote.
+const maxBodyInError = 512
+
+// decodeJSON decodes the JSON body of resp into v. The error quotes the body,
+// so that a response of an unexpected shape, e.g. an error message sent with
+// a success status, can be told apart from a malformed one.
+func decodeJSON(resp *http.Response, v interface{}) error {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response body: %v", err)
+	}
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		if len(body) > maxBodyInError {
+			body = append(body[:maxBodyInError:maxBodyInError], "..."...)
+		}
+		return fmt.Errorf("%v in response body %q", err, body)
+	}
+	return nil
+}
+
+// Unfollow unfollows the project.
+func (p *ProjectV1) Unfollow(ctx context.Context) error {
+	if err := p.require(ResourceFollow); err != nil {
+		return err
+	}
+	url := p.fmtURI("project", "unfollow")
+	resp, err := p.client.Post(ctx, url, "", strings.NewReader(""))
+	if err != nil {
+		return fmt.Errorf("could not unfollow project: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("expected status %d, found %d", http.StatusOK, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// EnvVar is an environment variable of a project. CircleCI masks the values
+// of those it lists.
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// deleteStatus is the API v1.1 response to deleting a resource.
+type deleteStatus struct {
+	Message string `json:"message"` // "ok" once deleted
+}
+
+// Setenv sets an environment variable in a project
+func (p *ProjectV1) Setenv(ctx context.Context, name, value string) error {
+	if err := p.require(ResourceEnvVar); err != nil {
+		return err
+	}
+	url := p.fmtURI("project", "envvar")
+	body, err := json.Marshal(EnvVar{name, value})
+	if err != nil {
+		return fmt.Errorf("could not marshal environment variable %s: %v", name, err)
+	}
+	resp, err := p.client.Post(ctx, url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not create environment variable %s: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("environment variable %s not created: status %s", name, resp.Status)
+	}
+	return nil
+}
+
+// Clearenv removes all environment variables from a project.
+func (p *ProjectV1) Clearenv(ctx context.Context) error {
+	envVars, err := p.Getenvs(ctx)
+	if err != nil {
+		return fmt.Errorf("could not clean environment variables for project %s: %v", p.FullName(), err)
+	}
+
+	for name := range envVars {
+		err = p.Deleteenv(ctx, name)
+		if err != nil {
+			return fmt.Errorf("could not remove environment variable %s from project %s: %v",
+				name, p.FullName(), err)
+		}
+	}
+	return nil
+}
+
+// Getenv gets the named environment variable in a project. The value is
+// masked by CircleCI.
+func (p *ProjectV1) Getenv(ctx context.Context, name string) (string, error) {
+	envVars, err := p.Getenvs(ctx)
+	if err != nil {
+		return "", err
+	}
+	value, ok := envVars[name]
+	if !ok {
+		return "", fmt.Errorf("environment variable %s not found in project %s", name, p.FullName())
+	}
+	return value, nil
+}
+
+// Getenvs gets all the environment variables in the project.
+func (p *ProjectV1) Getenvs(ctx context.Context) (map[string]string, error) {
+	if err := p.require(ResourceEnvVar); err != nil {
+		return nil, err
+	}
+	url := p.fmtURI("project", "envvar")
+	resp, err := p.client.Get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("could not get environment variables for project %s: %v", p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not get environment variables for project %s: status %s", p.FullName(), resp.Status)
+	}
+
+	var results []EnvVar
+	err = decodeJSON(resp, &results)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshall response body to get environment variables for project %s: %v",
+			p.FullName(), err)
+	}
+
+	envVars := make(map[string]string)
+	for _, result := range results {
+		envVars[result.Name] = result.Value
+	}
+
+	return envVars, nil
+}
+
+// Deleteenv deletes the named environment variable in the project.
+func (p *ProjectV1) Deleteenv(ctx context.Context, name string) error {
+	if err := p.require(ResourceEnvVar); err != nil {
+		return err
+	}
+	url := p.fmtURI("project", path.Join("envvar", name))
+	resp, err := p.client.Delete(ctx, url, "", nil)
+	if err != nil {
+		return fmt.Errorf("could not remove environment variable %s: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not remove environment variable %s: status %s", name, resp.Status)
+	}
+
+	var status deleteStatus
+	err = decodeJSON(resp, &status)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal response: %v", err)
+	}
+
+	if status.Message != "ok" {
+		return fmt.Errorf("failed to remove environment variable %s: expected status 'ok' but found '%s'",
+			name, status.Message)
+	}
+
+	return nil
+}
+
+// AddSSHKey adds an ssh key.
+func (p *ProjectV1) AddSSHKey(ctx context.Context, name, privateKey string) error {
+	if err := p.require(ResourceSSHKey); err != nil {
+		return err
+	}
+	url := p.fmtURI("project", "ssh-key")
+	postBody := struct {
+		Hostname   string `json:"hostname"`
+		PrivateKey string `json:"private_key"`
+	}{
+		Hostname:   name,
+		PrivateKey: privateKey,
+	}
+	postBodyJSON, err := json.Marshal(postBody)
+	if err != nil {
+		return fmt.Errorf("could not marshal ssh key %s: %v", name, err)
+	}
+
+	resp, err := p.client.Post(ctx, url, "application/json", bytes.NewReader(postBodyJSON))
+	if err != nil {
+		return fmt.Errorf("could not add ssh key %s to project %s: %v", name, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("expected status code %d but received %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// SSHKey is an SSH key added to a project for a host.
+type SSHKey struct {
+	Hostname    string `json:"hostname"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// projectSettings is the subset of the v1.1 project settings we read.
+type projectSettings struct {
+	Following    bool                   `json:"following"`
+	SSHKeys      []SSHKey               `json:"ssh_keys"`
+	FeatureFlags map[string]interface{} `json:"feature_flags"`
+}
+
+// settings gets the project's settings.
+func (p *ProjectV1) settings(ctx context.Context) (projectSettings, error) {
+	var settings projectSettings
+	if err := p.require(ResourceSettings); err != nil {
+		return settings, err
+	}
+	url := p.fmtURI("project", "settings")
+	resp, err := p.client.Get(ctx, url)
+	if err != nil {
+		return settings, fmt.Errorf("could not get settings for project %s: %v", p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return settings, fmt.Errorf("could not get settings for project %s: status %s", p.FullName(), resp.Status)
+	}
+
+	err = decodeJSON(resp, &settings)
+	if err != nil {
+		return settings, fmt.Errorf("could not unmarshal settings for project %s: %v", p.FullName(), err)
+	}
+	return settings, nil
+}
+
+// IsFollowing reports whether the project is followed.
+func (p *ProjectV1) IsFollowing(ctx context.Context) (bool, error) {
+	settings, err := p.settings(ctx)
+	return settings.Following, err
+}
+
+// FeatureFlags gets the project's feature flags (the build settings toggles).
+func (p *ProjectV1) FeatureFlags(ctx context.Context) (map[string]interface{}, error) {
+	settings, err := p.settings(ctx)
+	return settings.FeatureFlags, err
+}
+
+// SetFeatureFlags sets the given feature flags, leaving others untouched.
+func (p *ProjectV1) SetFeatureFlags(ctx context.Context, flags map[string]interface{}) error {
+	return p.putSettings(ctx, map[string]interface{}{"feature_flags": flags})
+}
+
+// BuildSettings are the build settings toggles of a project. Settings left
+// nil are not changed.
+type BuildSettings struct {
+	OnlyBuildPullRequests  *bool `yaml:"onlyBuildPullRequests"`  // Only build branches with an open pull request
+	AutoCancelBuilds       *bool `yaml:"autoCancelBuilds"`       // Cancel redundant builds of a branch
+	BuildForkPullRequests  *bool `yaml:"buildForkPullRequests"`  // Build pull requests from forks
+	ForkPullRequestSecrets *bool `yaml:"forkPullRequestSecrets"` // Pass secrets to builds of pull requests from forks
+	OSS                    *bool `yaml:"oss"`                    // Free and open source
+	IPRanges               *bool `yaml:"ipRanges"`               // Allow jobs to run from CircleCI's published IP ranges
+}
+
+// FeatureFlags returns the feature flags that apply the settings, for
+// SetFeatureFlags.
+func (s BuildSettings) FeatureFlags() map[string]interface{} {
+	flags := make(map[string]interface{})
+	for name, value := range map[string]*bool{
+		"build-prs-only":                s.OnlyBuildPullRequests,
+		"autocancel-builds":             s.AutoCancelBuilds,
+		"build-fork-prs":                s.BuildForkPullRequests,
+		"forks-receive-secret-env-vars": s.ForkPullRequestSecrets,
+		"oss":                           s.OSS,
+		"ip-ranges":                     s.IPRanges,
+	} {
+		if value != nil {
+			flags[name] = *value
+		}
+	}
+	return flags
+}
+
+// putSettings updates the project's settings.
+func (p *ProjectV1) putSettings(ctx context.Context, settings interface{}) error {
+	if err := p.require(ResourceSettings); err != nil {
+		return err
+	}
+	url := p.fmtURI("project", "settings")
+	putBodyJSON, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("could not marshal settings: %v", err)
+	}
+
+	resp, err := p.client.Put(ctx, url, "application/json", bytes.NewReader(putBodyJSON))
+	if err != nil {
+		return fmt.Errorf("could not update settings for project %s: %v", p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("expected status code %d but received %d", http.StatusOK, resp.StatusCode)
+	}
+	return nil
+}
+
+// GetSSHKeys gets the SSH keys added to the project.
+func (p *ProjectV1) GetSSHKeys(ctx context.Context) ([]SSHKey, error) {
+	settings, err := p.settings(ctx)
+	return settings.SSHKeys, err
+}
+
+// GetSSHKeyFingerprint gets the fingerprint of the named SSH key.
+func (p *ProjectV1) GetSSHKeyFingerprint(ctx context.Context, name string) (string, error) {
+	keys, err := p.GetSSHKeys(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, key := range keys {
+		if key.Hostname == name {
+			return key.Fingerprint, nil
+		}
+	}
+	return "", fmt.Errorf("no SSH key for %s in project %s", name, p.FullName())
+}
+
+// RemoveSSHKey removes every SSH key for the named host from the project.
+func (p *ProjectV1) RemoveSSHKey(ctx context.Context, name string) error {
+	keys, err := p.GetSSHKeys(ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if key.Hostname != name {
+			continue
+		}
+		err = p.DeleteSSHKey(ctx, key.Hostname, key.Fingerprint)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteSSHKey removes the SSH key with the given fingerprint for hostname.
+func (p *ProjectV1) DeleteSSHKey(ctx context.Context, hostname, fingerprint string) error {
+	if err := p.require(ResourceSSHKey); err != nil {
+		return err
+	}
+	url := p.fmtURI("project", "ssh-key")
+	deleteBody := struct {
+		Hostname    string `json:"hostname"`
+		Fingerprint string `json:"fingerprint"`
+	}{hostname, fingerprint}
+	deleteBodyJSON, err := json.Marshal(deleteBody)
+	if err != nil {
+		return fmt.Errorf("could not marshal SSH key: %v", err)
+	}
+
+	resp, err := p.client.Delete(ctx, url, "application/json", bytes.NewReader(deleteBodyJSON))
+	if err != nil {
+		return fmt.Errorf("could not remove SSH key %s for %s from project %s: %v",
+			fingerprint, hostname, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("expected status code %d but received %d", http.StatusOK, resp.StatusCode)
+	}
+	return nil
+}
+
+// Build identifies a build or pipeline started by Trigger.
+type Build struct {
+	Number     int    // Build number through API v1.1, pipeline number through API v2
+	PipelineID string // Empty for builds triggered through API v1.1
+	URL        string // Web UI URL of the build or pipeline
+}
+
+// buildSummaryV1 is the build summary API v1.1 responds with when a build is
+// triggered.
+type buildSummaryV1 struct {
+	BuildNum int    `json:"build_num"`
+	BuildURL string `json:"build_url"`
+	Status   string `json:"status"`
+}
+
+// TriggerOptions selects what a triggered build or pipeline runs.
+type TriggerOptions struct {
+	Branch     string                 // Branch to build, the default branch if empty
+	Tag        string                 // Tag to build instead of a branch
+	Parameters map[string]interface{} // Pipeline parameters, which need API v2
+}
+
+// validate checks the options don't ask for both a branch and a tag.
+func (opts TriggerOptions) validate() error {
+	if opts.Branch != "" && opts.Tag != "" {
+		return fmt.Errorf("cannot trigger both branch %s and tag %s", opts.Branch, opts.Tag)
+	}
+	return nil
+}
+
+// Trigger triggers a build of the project. Builds triggered through API v1.1
+// cannot take pipeline parameters.
+func (p *ProjectV1) Trigger(ctx context.Context, opts TriggerOptions) (Build, error) {
+	var build Build
+	if err := p.require(ResourceBuild); err != nil {
+		return build, err
+	}
+	if err := opts.validate(); err != nil {
+		return build, err
+	}
+	if len(opts.Parameters) > 0 {
+		return build, fmt.Errorf("pipeline parameters cannot be passed to builds triggered through API v1.1")
+	}
+	postBody := struct {
+		Branch string `json:"branch,omitempty"`
+		Tag    string `json:"tag,omitempty"`
+	}{opts.Branch, opts.Tag}
+	postBodyJSON, err := json.Marshal(postBody)
+	if err != nil {
+		return build, fmt.Errorf("could not marshal build options: %v", err)
+	}
+
+	url := p.fmtURI("project", "build")
+	resp, err := p.client.Post(ctx, url, "application/json", bytes.NewReader(postBodyJSON))
+	if err != nil {
+		return build, fmt.Errorf("could not trigger build of project %s: %v", p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return build, fmt.Errorf("unexpected status code %d, expected %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	var summary buildSummaryV1
+	err = decodeJSON(resp, &summary)
+	if err != nil {
+		return build, fmt.Errorf("could not unmarshal build of project %s: %v", p.FullName(), err)
+	}
+	if summary.BuildNum == 0 {
+		return build, fmt.Errorf("triggered build of project %s has no build number", p.FullName())
+	}
+	logger.Debugf("Triggered build %d of %s with status %s", summary.BuildNum, p.FullName(), summary.Status)
+	return Build{Number: summary.BuildNum, URL: summary.BuildURL}, nil
+}
+
+// ClearSSHKeys clears all SSH keys for the project.
+func (p *ProjectV1) ClearSSHKeys(ctx context.Context) error {
+	keys, err := p.GetSSHKeys(ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		err = p.DeleteSSHKey(ctx, key.Hostname, key.Fingerprint)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JiraIntegration is the configuration of a project's Jira integration.
+type JiraIntegration struct {
+	ConnectionKey string `yaml:"connectionKey"` // Key generated by the CircleCI for Jira app
+}
+
+// SetJiraIntegration connects the project to Jira using the project settings endpoint.
+func (p *ProjectV1) SetJiraIntegration(ctx context.Context, jira JiraIntegration) error {
+	settings := struct {
+		Jira struct {
+			ConnectionKey string `json:"connection_key"`
+		} `json:"jira"`
+	}{}
+	settings.Jira.ConnectionKey = jira.ConnectionKey
+	err := p.putSettings(ctx, settings)
+	if err != nil {
+		return fmt.Errorf("could not set Jira integration: %v", err)
+	}
+	return nil
+}
+
+// CheckoutKey is a key CircleCI uses to check out a project's code.
+type CheckoutKey struct {
+	PublicKey   string `json:"public_key"`
+	Type        string `json:"type"` // "deploy-key" or "github-user-key"
+	Fingerprint string `json:"fingerprint"`
+	Preferred   bool   `json:"preferred"`
+}
+
+// Checkout key types, as given to CreateCheckoutKey.
+const (
+	CheckoutKeyDeploy = "deploy-key" // Read only key for the project's repository
+	CheckoutKeyUser   = "user-key"   // Key with the access of the user creating it
+)
+
+// userKeyV1 is the API v1.1 name of user keys.
+const userKeyV1 = "github-user-key"
+
+// Kind returns the type of the key as given to CreateCheckoutKey.
+func (k CheckoutKey) Kind() string {
+	if k.Type == userKeyV1 {
+		return CheckoutKeyUser
+	}
+	return k.Type
+}
+
+// validCheckoutKeyType checks the type can be given to CreateCheckoutKey.
+func validCheckoutKeyType(keyType string) error {
+	if keyType != CheckoutKeyDeploy && keyType != CheckoutKeyUser {
+		return fmt.Errorf("unknown checkout key type %q, expected %s or %s", keyType, CheckoutKeyDeploy, CheckoutKeyUser)
+	}
+	return nil
+}
+
+// CheckoutKeys lists the project's checkout keys.
+func (p *ProjectV1) CheckoutKeys(ctx context.Context) ([]CheckoutKey, error) {
+	if err := p.require(ResourceCheckoutKey); err != nil {
+		return nil, err
+	}
+	url := p.fmtURI("project", "checkout-key")
+	resp, err := p.client.Get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("could not get checkout keys for project %s: %v", p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not get checkout keys for project %s: status %s", p.FullName(), resp.Status)
+	}
+
+	var keys []CheckoutKey
+	err = decodeJSON(resp, &keys)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal checkout keys for project %s: %v", p.FullName(), err)
+	}
+	return keys, nil
+}
+
+// CreateCheckoutKey creates a checkout key of the given type, deploy-key or
+// user-key.
+func (p *ProjectV1) CreateCheckoutKey(ctx context.Context, keyType string) (CheckoutKey, error) {
+	var key CheckoutKey
+	if err := p.require(ResourceCheckoutKey); err != nil {
+		return key, err
+	}
+	if err := validCheckoutKeyType(keyType); err != nil {
+		return key, err
+	}
+	postBody := struct {
+		Type string `json:"type"`
+	}{keyType}
+	if keyType == CheckoutKeyUser {
+		postBody.Type = userKeyV1
+	}
+	postBodyJSON, err := json.Marshal(postBody)
+	if err != nil {
+		return key, fmt.Errorf("could not marshal checkout key: %v", err)
+	}
+
+	url := p.fmtURI("project", "checkout-key")
+	resp, err := p.client.Post(ctx, url, "application/json", bytes.NewReader(postBodyJSON))
+	if err != nil {
+		return key, fmt.Errorf("could not create %s for project %s: %v", keyType, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return key, fmt.Errorf("%s not created for project %s: status %s", keyType, p.FullName(), resp.Status)
+	}
+	err = decodeJSON(resp, &key)
+	if err != nil {
+		return key, fmt.Errorf("could not unmarshal checkout key of project %s: %v", p.FullName(), err)
+	}
+	return key, nil
+}
+
+// DeleteCheckoutKey deletes the checkout key with the given fingerprint.
+func (p *ProjectV1) DeleteCheckoutKey(ctx context.Context, fingerprint string) error {
+	if err := p.require(ResourceCheckoutKey); err != nil {
+		return err
+	}
+	url := p.fmtURI("project", path.Join("checkout-key", fingerprint))
+	resp, err := p.client.Delete(ctx, url, "", nil)
+	if err != nil {
+		return fmt.Errorf("could not remove checkout key %s from project %s: %v", fingerprint, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not remove checkout key %s from project %s: status %s",
+			fingerprint, p.FullName(), resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,578 @@
+package circleci
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestFmtUri(t *testing.T) {
+	type args struct {
+		resource string
+		action   string
+	}
+	type test struct {
+		input    args
+		project  *ProjectV1
+		expected string
+	}
+
+	testCases := []test{
+		{
+			input:    args{"project", "follow"},
+			project:  NewProjectV1("git", "test", "test", Credentials{Token: "token"}),
+			expected: "https://circleci.com/api/v1.1/project/git/test/test/follow",
+		},
+		{
+			input:    args{"resource", "action"},
+			project:  NewProjectV1("git", "owner", "project name", Credentials{Token: "token"}),
+			expected: "https://circleci.com/api/v1.1/resource/git/owner/project%20name/action",
+		},
+	}
+
+	for _, tc := range testCases {
+		actual := tc.project.fmtURI(tc.input.resource, tc.input.action)
+		if actual != tc.expected {
+			t.Errorf("Expected %s found %s", tc.expected, actual)
+		}
+	}
+}
+
+func TestFollowHappy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "ok")
+	})
+	svr := httptest.NewServer(handler)
+	defer svr.Close()
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, svr.Listener.Addr().String())
+			},
+		},
+	}
+	client := &HTTPClient{baseURL: "http://localhost", HTTP: httpClient}
+
+	project := ProjectV1{vcsType: "git", owner: "test", projectName: "test", client: client}
+
+	err := project.Follow(context.Background())
+	if err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+}
+
+func TestFollowUnhappy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "bad")
+	})
+	svr := httptest.NewServer(handler)
+	defer svr.Close()
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, svr.Listener.Addr().String())
+			},
+		},
+	}
+	client := &HTTPClient{baseURL: "http://localhost", HTTP: httpClient}
+
+	project := ProjectV1{vcsType: "git", owner: "test", projectName: "test", client: client}
+
+	// Sends POST request to
+	// https://circleci.com/api/v1.1/project/:vcs/:owner/:project/follow
+	// and returns nil on no error
+	err := project.Follow(context.Background())
+	if err == nil {
+		t.Errorf("Expected error, no error was found")
+	}
+}
+
+func TestUnfollow(t *testing.T) {
+	testCases := []struct {
+		status int
+		err    bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+	}
+	for _, tc := range testCases {
+		svr := newFakeCircleCI(map[string]circlecitest.Response{
+			"POST /project/git/test/test/unfollow": {Status: tc.status, Body: `{"following": false}`},
+		})
+		err := svr.project().Unfollow(context.Background())
+		if (err != nil) != tc.err {
+			t.Errorf("Expected error %v for status %d, found: %v", tc.err, tc.status, err)
+		}
+		svr.Close()
+	}
+
+	// Returns error if the request cannot be made.
+	svr := newFakeCircleCI(nil)
+	project := svr.project()
+	svr.Close()
+	if err := project.Unfollow(context.Background()); err == nil {
+		t.Error("Expected an error when the API is unreachable")
+	}
+}
+
+func TestSetJiraIntegration(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("Expected method %s, found %s", http.MethodPut, r.Method)
+		}
+		if r.URL.Path != "/project/git/test/test/settings" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected JSON content type, found %s", r.Header.Get("Content-Type"))
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != `{"jira":{"connection_key":"key"}}` {
+			t.Errorf("Unexpected body %s", body)
+		}
+		io.WriteString(w, "{}")
+	})
+	svr := httptest.NewServer(handler)
+	defer svr.Close()
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, svr.Listener.Addr().String())
+			},
+		},
+	}
+	client := &HTTPClient{baseURL: "http://localhost", HTTP: httpClient}
+
+	project := ProjectV1{vcsType: "git", owner: "test", projectName: "test", client: client}
+
+	err := project.SetJiraIntegration(context.Background(), JiraIntegration{ConnectionKey: "key"})
+	if err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+}
+
+func TestFollowRetries(t *testing.T) {
+	testCases := []struct {
+		statuses []int
+		body     string
+		requests int
+		err      string
+	}{
+		{[]int{http.StatusNotFound, http.StatusNotFound, http.StatusCreated}, "", 3, ""},
+		{[]int{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound}, "", 3, "not found after 3 attempt(s)"},
+		{[]int{http.StatusForbidden}, "", 1, "no access to follow project test/test"},
+		{[]int{http.StatusBadRequest}, `{"message": "Project is archived"}`, 1, "found 400: Project is archived"},
+	}
+
+	for _, tc := range testCases {
+		requests := 0
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.statuses[requests])
+			io.WriteString(w, tc.body)
+			requests++
+		})
+		svr := httptest.NewServer(handler)
+
+		client := &HTTPClient{baseURL: svr.URL, HTTP: &http.Client{}}
+		project := ProjectV1{vcsType: "git", owner: "test", projectName: "test",
+			client: client, FollowAttempts: 3}
+
+		err := project.Follow(context.Background())
+		if tc.err == "" && err != nil {
+			t.Errorf("Expected no error for statuses %v, found: %v", tc.statuses, err)
+		} else if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("Expected error containing %q for statuses %v, found: %v", tc.err, tc.statuses, err)
+		}
+		if requests != tc.requests {
+			t.Errorf("Expected %d requests for statuses %v, found %d", tc.requests, tc.statuses, requests)
+		}
+		svr.Close()
+	}
+}
+
+func TestFollowRetryCancelled(t *testing.T) {
+	svr := newFakeCircleCI(nil)
+	defer svr.Close()
+	project := svr.project()
+	project.FollowAttempts, project.FollowDelay = 3, time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := project.Follow(ctx)
+	if err == nil || !strings.Contains(err.Error(), "context deadline exceeded") {
+		t.Errorf("Expected the retry to be cancelled, found: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Errorf("Expected Follow to return once cancelled, it took %v", elapsed)
+	}
+}
+
+// fakeCircleCI is a fake CircleCI API managing the project git/test/test.
+type fakeCircleCI struct {
+	*circlecitest.Server
+}
+
+func newFakeCircleCI(responses map[string]circlecitest.Response) *fakeCircleCI {
+	return &fakeCircleCI{circlecitest.NewServer(responses)}
+}
+
+// project returns the project git/test/test managed through the fake API.
+func (f *fakeCircleCI) project() *ProjectV1 {
+	client := &HTTPClient{baseURL: f.URL, HTTP: f.Client()}
+	return NewProjectV1WithClient("git", "test", "test", client)
+}
+
+func TestProjectMethods(t *testing.T) {
+	testCases := []struct {
+		name      string
+		responses map[string]circlecitest.Response
+		call      func(p *ProjectV1) (interface{}, error)
+		expected  interface{}
+		err       bool
+		requests  []string
+	}{
+		{
+			name: "Setenv",
+			responses: map[string]circlecitest.Response{
+				"POST /project/git/test/test/envvar": {Status: http.StatusCreated, Body: `{}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) {
+				return nil, p.Setenv(context.Background(), "A", `quoted "value"`)
+			},
+			requests: []string{`POST /project/git/test/test/envvar {"name":"A","value":"quoted \"value\""}`},
+		},
+		{
+			name: "Setenv unhappy",
+			responses: map[string]circlecitest.Response{
+				"POST /project/git/test/test/envvar": {Status: http.StatusBadRequest, Body: `{}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) { return nil, p.Setenv(context.Background(), "A", "a") },
+			err:  true,
+		},
+		{
+			name: "Getenvs",
+			responses: map[string]circlecitest.Response{
+				"GET /project/git/test/test/envvar": {Status: http.StatusOK, Body: circlecitest.EnvVars},
+			},
+			call:     func(p *ProjectV1) (interface{}, error) { return p.Getenvs(context.Background()) },
+			expected: map[string]string{"A": "xxxxa", "B": "xxxxb"},
+		},
+		{
+			name: "Getenvs unhappy",
+			responses: map[string]circlecitest.Response{
+				"GET /project/git/test/test/envvar": {Status: http.StatusForbidden, Body: `{}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) { return p.Getenvs(context.Background()) },
+			err:  true,
+		},
+		{
+			name: "Getenvs malformed",
+			responses: map[string]circlecitest.Response{
+				"GET /project/git/test/test/envvar": {Status: http.StatusOK, Body: `{"name": "A"}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) { return p.Getenvs(context.Background()) },
+			err:  true,
+		},
+		{
+			name: "Deleteenv",
+			responses: map[string]circlecitest.Response{
+				"DELETE /project/git/test/test/envvar/A": {Status: http.StatusOK, Body: `{"message": "ok"}`},
+			},
+			call:     func(p *ProjectV1) (interface{}, error) { return nil, p.Deleteenv(context.Background(), "A") },
+			requests: []string{"DELETE /project/git/test/test/envvar/A"},
+		},
+		{
+			name: "Deleteenv not ok",
+			responses: map[string]circlecitest.Response{
+				"DELETE /project/git/test/test/envvar/A": {Status: http.StatusOK, Body: `{"message": "no"}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) { return nil, p.Deleteenv(context.Background(), "A") },
+			err:  true,
+		},
+		{
+			name: "Clearenv",
+			responses: map[string]circlecitest.Response{
+				"GET /project/git/test/test/envvar":      {Status: http.StatusOK, Body: circlecitest.EnvVars},
+				"DELETE /project/git/test/test/envvar/A": {Status: http.StatusOK, Body: `{"message": "ok"}`},
+				"DELETE /project/git/test/test/envvar/B": {Status: http.StatusOK, Body: `{"message": "ok"}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) { return nil, p.Clearenv(context.Background()) },
+		},
+		{
+			name: "Getenv",
+			responses: map[string]circlecitest.Response{
+				"GET /project/git/test/test/envvar": {Status: http.StatusOK, Body: circlecitest.EnvVars},
+			},
+			call:     func(p *ProjectV1) (interface{}, error) { return p.Getenv(context.Background(), "B") },
+			expected: "xxxxb",
+		},
+		{
+			name: "Getenv missing",
+			responses: map[string]circlecitest.Response{
+				"GET /project/git/test/test/envvar": {Status: http.StatusOK, Body: circlecitest.EnvVars},
+			},
+			call: func(p *ProjectV1) (interface{}, error) { return p.Getenv(context.Background(), "C") },
+			err:  true,
+		},
+		{
+			name: "AddSSHKey",
+			responses: map[string]circlecitest.Response{
+				"POST /project/git/test/test/ssh-key": {Status: http.StatusCreated, Body: ``},
+			},
+			call: func(p *ProjectV1) (interface{}, error) {
+				return nil, p.AddSSHKey(context.Background(), "github.com", "KEY")
+			},
+			requests: []string{`POST /project/git/test/test/ssh-key {"hostname":"github.com","private_key":"KEY"}`},
+		},
+		{
+			name: "AddSSHKey unhappy",
+			responses: map[string]circlecitest.Response{
+				"POST /project/git/test/test/ssh-key": {Status: http.StatusBadRequest, Body: ``},
+			},
+			call: func(p *ProjectV1) (interface{}, error) {
+				return nil, p.AddSSHKey(context.Background(), "github.com", "KEY")
+			},
+			err: true,
+		},
+		{
+			name: "GetSSHKeys",
+			responses: map[string]circlecitest.Response{
+				"GET " + circlecitest.SettingsPath: {Status: http.StatusOK, Body: circlecitest.Settings},
+			},
+			call:     func(p *ProjectV1) (interface{}, error) { return p.GetSSHKeys(context.Background()) },
+			expected: []SSHKey{{"github.com", "aa"}, {"github.com", "bb"}, {"example.com", "cc"}},
+		},
+		{
+			name: "GetSSHKeyFingerprint",
+			responses: map[string]circlecitest.Response{
+				"GET " + circlecitest.SettingsPath: {Status: http.StatusOK, Body: circlecitest.Settings},
+			},
+			call: func(p *ProjectV1) (interface{}, error) {
+				return p.GetSSHKeyFingerprint(context.Background(), "example.com")
+			},
+			expected: "cc",
+		},
+		{
+			name: "GetSSHKeyFingerprint missing",
+			responses: map[string]circlecitest.Response{
+				"GET " + circlecitest.SettingsPath: {Status: http.StatusOK, Body: circlecitest.Settings},
+			},
+			call: func(p *ProjectV1) (interface{}, error) {
+				return p.GetSSHKeyFingerprint(context.Background(), "gitlab.com")
+			},
+			err: true,
+		},
+		{
+			name: "RemoveSSHKey",
+			responses: map[string]circlecitest.Response{
+				"GET " + circlecitest.SettingsPath:      {Status: http.StatusOK, Body: circlecitest.Settings},
+				"DELETE /project/git/test/test/ssh-key": {Status: http.StatusOK, Body: `{}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) {
+				return nil, p.RemoveSSHKey(context.Background(), "github.com")
+			},
+			requests: []string{
+				"GET " + circlecitest.SettingsPath,
+				`DELETE /project/git/test/test/ssh-key {"hostname":"github.com","fingerprint":"aa"}`,
+				`DELETE /project/git/test/test/ssh-key {"hostname":"github.com","fingerprint":"bb"}`,
+			},
+		},
+		{
+			name: "DeleteSSHKey unhappy",
+			responses: map[string]circlecitest.Response{
+				"DELETE /project/git/test/test/ssh-key": {Status: http.StatusBadRequest, Body: `{}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) {
+				return nil, p.DeleteSSHKey(context.Background(), "github.com", "aa")
+			},
+			err: true,
+		},
+		{
+			name: "ClearSSHKeys",
+			responses: map[string]circlecitest.Response{
+				"GET " + circlecitest.SettingsPath:      {Status: http.StatusOK, Body: circlecitest.Settings},
+				"DELETE /project/git/test/test/ssh-key": {Status: http.StatusOK, Body: `{}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) { return nil, p.ClearSSHKeys(context.Background()) },
+			requests: []string{
+				"GET " + circlecitest.SettingsPath,
+				`DELETE /project/git/test/test/ssh-key {"hostname":"github.com","fingerprint":"aa"}`,
+				`DELETE /project/git/test/test/ssh-key {"hostname":"github.com","fingerprint":"bb"}`,
+				`DELETE /project/git/test/test/ssh-key {"hostname":"example.com","fingerprint":"cc"}`,
+			},
+		},
+		{
+			name: "Trigger",
+			responses: map[string]circlecitest.Response{
+				"POST /project/git/test/test/build": {Status: http.StatusCreated, Body: `{"build_num": 42, "build_url": "https://circleci.com/gh/test/test/42", "status": "not_running"}`},
+			},
+			call:     func(p *ProjectV1) (interface{}, error) { return p.Trigger(context.Background(), TriggerOptions{}) },
+			expected: Build{Number: 42, URL: "https://circleci.com/gh/test/test/42"},
+		},
+		{
+			name: "Trigger tag",
+			responses: map[string]circlecitest.Response{
+				"POST /project/git/test/test/build": {Status: http.StatusCreated, Body: `{"build_num": 43, "status": "not_running"}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) {
+				return p.Trigger(context.Background(), TriggerOptions{Tag: "v1.0.0"})
+			},
+			expected: Build{Number: 43},
+			requests: []string{`POST /project/git/test/test/build {"tag":"v1.0.0"}`},
+		},
+		{
+			name: "Trigger parameters",
+			call: func(p *ProjectV1) (interface{}, error) {
+				return p.Trigger(context.Background(), TriggerOptions{Parameters: map[string]interface{}{"deploy": true}})
+			},
+			err: true,
+		},
+		{
+			name: "Trigger unexpected body",
+			responses: map[string]circlecitest.Response{
+				"POST /project/git/test/test/build": {Status: http.StatusCreated, Body: `{"status": 400, "body": "Branch not found"}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) { return p.Trigger(context.Background(), TriggerOptions{}) },
+			err:  true,
+		},
+		{
+			name: "IsFollowing",
+			responses: map[string]circlecitest.Response{
+				"GET " + circlecitest.SettingsPath: {Status: http.StatusOK, Body: circlecitest.Settings},
+			},
+			call:     func(p *ProjectV1) (interface{}, error) { return p.IsFollowing(context.Background()) },
+			expected: true,
+		},
+		{
+			name: "IsFollowing unhappy",
+			responses: map[string]circlecitest.Response{
+				"GET " + circlecitest.SettingsPath: {Status: http.StatusInternalServerError, Body: ``},
+			},
+			call:     func(p *ProjectV1) (interface{}, error) { return p.IsFollowing(context.Background()) },
+			expected: false,
+			err:      true,
+		},
+		{
+			name: "FeatureFlags",
+			responses: map[string]circlecitest.Response{
+				"GET " + circlecitest.SettingsPath: {Status: http.StatusOK, Body: circlecitest.Settings},
+			},
+			call:     func(p *ProjectV1) (interface{}, error) { return p.FeatureFlags(context.Background()) },
+			expected: map[string]interface{}{"oss": true},
+		},
+		{
+			name: "SetFeatureFlags",
+			responses: map[string]circlecitest.Response{
+				"PUT " + circlecitest.SettingsPath: {Status: http.StatusOK, Body: `{}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) {
+				return nil, p.SetFeatureFlags(context.Background(), map[string]interface{}{"oss": false})
+			},
+			requests: []string{`PUT ` + circlecitest.SettingsPath + ` {"feature_flags":{"oss":false}}`},
+		},
+		{
+			name: "CheckoutKeys",
+			responses: map[string]circlecitest.Response{
+				"GET /project/git/test/test/checkout-key": {Status: http.StatusOK, Body: `[{"fingerprint": "dd", "type": "deploy-key"}]`},
+			},
+			call:     func(p *ProjectV1) (interface{}, error) { return p.CheckoutKeys(context.Background()) },
+			expected: []CheckoutKey{{Fingerprint: "dd", Type: "deploy-key"}},
+		},
+		{
+			name: "CreateCheckoutKey",
+			responses: map[string]circlecitest.Response{
+				"POST /project/git/test/test/checkout-key": {Status: http.StatusCreated, Body: `{"fingerprint": "ee", "type": "github-user-key", "preferred": true}`},
+			},
+			call: func(p *ProjectV1) (interface{}, error) {
+				return p.CreateCheckoutKey(context.Background(), CheckoutKeyUser)
+			},
+			expected: CheckoutKey{Fingerprint: "ee", Type: "github-user-key", Preferred: true},
+			requests: []string{`POST /project/git/test/test/checkout-key {"type":"github-user-key"}`},
+		},
+		{
+			name: "CreateCheckoutKey unknown type",
+			call: func(p *ProjectV1) (interface{}, error) {
+				return p.CreateCheckoutKey(context.Background(), "machine-key")
+			},
+			err: true,
+		},
+		{
+			name: "DeleteCheckoutKey",
+			responses: map[string]circlecitest.Response{
+				"DELETE /project/git/test/test/checkout-key/ee": {Status: http.StatusOK, Body: `{"message": "ok"}`},
+			},
+			call:     func(p *ProjectV1) (interface{}, error) { return nil, p.DeleteCheckoutKey(context.Background(), "ee") },
+			requests: []string{"DELETE /project/git/test/test/checkout-key/ee"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			svr := newFakeCircleCI(tc.responses)
+			defer svr.Close()
+
+			actual, err := tc.call(svr.project())
+			if (err != nil) != tc.err {
+				t.Fatalf("Expected error %v, found: %v", tc.err, err)
+			}
+			if tc.expected != nil && !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("Expected %#v, found %#v", tc.expected, actual)
+			}
+			if tc.requests != nil && !reflect.DeepEqual(svr.Requests(), tc.requests) {
+				t.Errorf("Expected requests %q, found %q", tc.requests, svr.Requests())
+			}
+		})
+	}
+}
+
+func TestBuildSettingsFeatureFlags(t *testing.T) {
+	yes, no := true, false
+	settings := BuildSettings{OnlyBuildPullRequests: &yes, ForkPullRequestSecrets: &no, OSS: &yes, IPRanges: &no}
+	expected := map[string]interface{}{"build-prs-only": true, "forks-receive-secret-env-vars": false, "oss": true,
+		"ip-ranges": false}
+	if flags := settings.FeatureFlags(); !reflect.DeepEqual(flags, expected) {
+		t.Errorf("Expected feature flags %v, found %v", expected, flags)
+	}
+	if flags := (BuildSettings{}).FeatureFlags(); len(flags) != 0 {
+		t.Errorf("Expected no feature flags for empty settings, found %v", flags)
+	}
+}
+
+func TestDecodeJSONQuotesBody(t *testing.T) {
+	var status deleteStatus
+	resp := &http.Response{Body: ioutil.NopCloser(strings.NewReader(`[{"message": "ok"}]`))}
+	err := decodeJSON(resp, &status)
+	if err == nil || !strings.Contains(err.Error(), `"[{\"message\": \"ok\"}]"`) {
+		t.Errorf("Expected the error to quote the body, found: %v", err)
+	}
+
+	long := `"` + strings.Repeat("a", 2*maxBodyInError) + `"`
+	resp = &http.Response{Body: ioutil.NopCloser(strings.NewReader(long))}
+	err = decodeJSON(resp, &status)
+	if err == nil || len(err.Error()) > 2*maxBodyInError || !strings.Contains(err.Error(), `..."`) {
+		t.Errorf("Expected the quoted body to be truncated, found: %v", err)
+	}
+
+	resp = &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"message": "ok"}`))}
+	if err = decodeJSON(resp, &status); err != nil || status.Message != "ok" {
+		t.Errorf("Expected the status to be decoded, found %+v (%v)", status, err)
+	}
+}
@@ -0,0 +1,486 @@
+package circleci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultV2BaseURL is the base URL of the CircleCI v2 API.
+const DefaultV2BaseURL = "https://circleci.com/api/v2"
+
+// ProjectV2 represents a CircleCI project accessed through API v2.
+// Resources that API v2 does not cover, or that are not available on the
+// project's platform, fall back to API v1.1.
+type ProjectV2 struct {
+	vcsType     string
+	owner       string
+	projectName string
+	client      Client
+	Platform    Platform   // Platform being managed, CircleCI cloud if empty
+	Version     string     // CircleCI Server version being managed, not checked if empty
+	Legacy      *ProjectV1 // API v1.1 representation of the project, used as a fallback
+}
+
+// NewProjectV2WithClient creates a representation of a project
+// accessed through API v2 using client, falling back to API v1.1 using
+// legacyClient.
+func NewProjectV2WithClient(vcsType, owner, projectName string, client, legacyClient Client) *ProjectV2 {
+	return &ProjectV2{
+		vcsType:     vcsType,
+		owner:       owner,
+		projectName: projectName,
+		client:      client,
+		Legacy:      NewProjectV1WithClient(vcsType, owner, projectName, legacyClient),
+	}
+}
+
+// Slug returns the v2 project slug (e.g. gh/owner/project, or
+// circleci/org-id/project-id for standalone projects).
+func (p *ProjectV2) Slug() string {
+	return ProjectSlug(p.vcsType, p.owner, p.projectName)
+}
+
+// FullName returns the full name of the project
+func (p *ProjectV2) FullName() string {
+	return fmt.Sprintf("%s/%s", p.owner, p.projectName)
+}
+
+// fmtURI formats a URI for a project scoped v2 resource.
+func (p *ProjectV2) fmtURI(resource string, parts ...string) string {
+	return endpoint(p.client.BaseURL(), nil, append([]string{"project", p.Slug(), resource}, parts...)...)
+}
+
+func (p *ProjectV2) require(resource string) error {
+	if err := requireAPI(p.Platform, resource, APIv2); err != nil {
+		return err
+	}
+	return requireServerVersion(p.Platform, p.Version, resource)
+}
+
+// v1 returns the API v1.1 project used as a fallback.
+func (p *ProjectV2) v1() *ProjectV1 {
+	p.Legacy.Platform, p.Legacy.Version = p.Platform, p.Version
+	return p.Legacy
+}
+
+// useV2 reports whether the resource should be managed through API v2, or
+// through the API v1.1 fallback.
+func (p *ProjectV2) useV2(resource string) (bool, error) {
+	platform := p.Platform
+	if platform == "" {
+		platform = PlatformCloud
+	}
+	version, err := negotiateAPI(platform, resource, APIv2, APIv1)
+	return version == APIv2, err
+}
+
+// getItems follows a paginated v2 list endpoint, passing every item to
+// appendItem.
+func getItems(ctx context.Context, client Client, uri string, appendItem func(json.RawMessage) error) error {
+	pageToken := ""
+	for {
+		pageURI := uri
+		if pageToken != "" {
+			u, _ := url.Parse(uri)
+			query := u.Query()
+			query.Set("page-token", pageToken)
+			u.RawQuery = query.Encode()
+			pageURI = u.String()
+		}
+
+		resp, err := client.Get(ctx, pageURI)
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Items         []json.RawMessage `json:"items"`
+			NextPageToken string            `json:"next_page_token"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("expected status %d, found %d", http.StatusOK, resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("could not unmarshal response: %v", err)
+		}
+
+		for _, item := range page.Items {
+			err = appendItem(item)
+			if err != nil {
+				return fmt.Errorf("could not unmarshal item: %v", err)
+			}
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// get gets the project, returning its status and, if found, its ID and the ID
+// of its organization.
+func (p *ProjectV2) get(ctx context.Context) (status int, id, orgID string, err error) {
+	resp, err := p.client.Get(ctx, endpoint(p.client.BaseURL(), nil, "project", p.Slug()))
+	if err != nil {
+		return 0, "", "", fmt.Errorf("could not get project %s: %v", p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, "", "", fmt.Errorf("could not get project %s: status %s", p.FullName(), resp.Status)
+	}
+	var project struct {
+		ID             string `json:"id"`
+		OrganizationID string `json:"organization_id"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&project)
+	if err != nil {
+		return resp.StatusCode, "", "", fmt.Errorf("could not unmarshal project %s: %v", p.FullName(), err)
+	}
+	return resp.StatusCode, project.ID, project.OrganizationID, nil
+}
+
+// ID gets the project's CircleCI ID.
+func (p *ProjectV2) ID(ctx context.Context) (string, error) {
+	_, id, _, err := p.get(ctx)
+	return id, err
+}
+
+// Follow follows the project. Standalone projects are not followed but
+// created in the CircleCI app, so for them Follow checks the project exists.
+func (p *ProjectV2) Follow(ctx context.Context) error {
+	if IsStandalone(p.vcsType) {
+		following, err := p.IsFollowing(ctx)
+		if err == nil && !following {
+			err = fmt.Errorf("standalone project %s not found, it must be created in the CircleCI app", p.FullName())
+		}
+		return err
+	}
+	return p.v1().Follow(ctx)
+}
+
+// Unfollow unfollows the project.
+func (p *ProjectV2) Unfollow(ctx context.Context) error {
+	if IsStandalone(p.vcsType) {
+		return fmt.Errorf("standalone project %s cannot be unfollowed, delete it in the CircleCI app", p.FullName())
+	}
+	return p.v1().Unfollow(ctx)
+}
+
+// IsFollowing reports whether the project is followed, or for standalone
+// projects whether it exists.
+func (p *ProjectV2) IsFollowing(ctx context.Context) (bool, error) {
+	if IsStandalone(p.vcsType) {
+		status, _, _, err := p.get(ctx)
+		if status == http.StatusNotFound {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return p.v1().IsFollowing(ctx)
+}
+
+// Setenv sets an environment variable in a project
+func (p *ProjectV2) Setenv(ctx context.Context, name, value string) error {
+	v2, err := p.useV2(ResourceEnvVar)
+	if err != nil {
+		return err
+	} else if !v2 {
+		return p.v1().Setenv(ctx, name, value)
+	}
+	postBody := struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}{name, value}
+	postBodyJSON, err := json.Marshal(postBody)
+	if err != nil {
+		return fmt.Errorf("could not marshal environment variable %s: %v", name, err)
+	}
+
+	resp, err := p.client.Post(ctx, p.fmtURI("envvar"), "application/json", bytes.NewReader(postBodyJSON))
+	if err != nil {
+		return fmt.Errorf("could not create environment variable %s: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("environment variable %s not created: status %s", name, resp.Status)
+	}
+	return nil
+}
+
+// Getenv gets the named environment variable in a project. The value is
+// masked by CircleCI.
+func (p *ProjectV2) Getenv(ctx context.Context, name string) (string, error) {
+	v2, err := p.useV2(ResourceEnvVar)
+	if err != nil {
+		return "", err
+	} else if !v2 {
+		return p.v1().Getenv(ctx, name)
+	}
+	resp, err := p.client.Get(ctx, p.fmtURI("envvar", name))
+	if err != nil {
+		return "", fmt.Errorf("could not get environment variable %s: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not get environment variable %s: status %s", name, resp.Status)
+	}
+
+	var envVar struct {
+		Value string `json:"value"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&envVar)
+	if err != nil {
+		return "", fmt.Errorf("could not unmarshal environment variable %s: %v", name, err)
+	}
+	return envVar.Value, nil
+}
+
+// Deleteenv deletes the named environment variable in the project.
+func (p *ProjectV2) Deleteenv(ctx context.Context, name string) error {
+	v2, err := p.useV2(ResourceEnvVar)
+	if err != nil {
+		return err
+	} else if !v2 {
+		return p.v1().Deleteenv(ctx, name)
+	}
+	resp, err := p.client.Delete(ctx, p.fmtURI("envvar", name), "", nil)
+	if err != nil {
+		return fmt.Errorf("could not remove environment variable %s: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not remove environment variable %s: status %s", name, resp.Status)
+	}
+	return nil
+}
+
+// Clearenv removes all environment variables from a project.
+func (p *ProjectV2) Clearenv(ctx context.Context) error {
+	envVars, err := p.Getenvs(ctx)
+	if err != nil {
+		return fmt.Errorf("could not clean environment variables for project %s: %v", p.FullName(), err)
+	}
+
+	for name := range envVars {
+		err = p.Deleteenv(ctx, name)
+		if err != nil {
+			return fmt.Errorf("could not remove environment variable %s from project %s: %v",
+				name, p.FullName(), err)
+		}
+	}
+	return nil
+}
+
+// AddSSHKey adds an ssh key.
+func (p *ProjectV2) AddSSHKey(ctx context.Context, name, privateKey string) error {
+	return p.v1().AddSSHKey(ctx, name, privateKey)
+}
+
+// GetSSHKeys gets the SSH keys added to the project.
+func (p *ProjectV2) GetSSHKeys(ctx context.Context) ([]SSHKey, error) {
+	return p.v1().GetSSHKeys(ctx)
+}
+
+// GetSSHKeyFingerprint gets the fingerprint of the named SSH key.
+func (p *ProjectV2) GetSSHKeyFingerprint(ctx context.Context, name string) (string, error) {
+	return p.v1().GetSSHKeyFingerprint(ctx, name)
+}
+
+// RemoveSSHKey removes the named SSH key from the project.
+func (p *ProjectV2) RemoveSSHKey(ctx context.Context, name string) error {
+	return p.v1().RemoveSSHKey(ctx, name)
+}
+
+// DeleteSSHKey removes the SSH key with the given fingerprint for hostname.
+func (p *ProjectV2) DeleteSSHKey(ctx context.Context, hostname, fingerprint string) error {
+	return p.v1().DeleteSSHKey(ctx, hostname, fingerprint)
+}
+
+// ClearSSHKeys clears all SSH keys for the project.
+func (p *ProjectV2) ClearSSHKeys(ctx context.Context) error {
+	return p.v1().ClearSSHKeys(ctx)
+}
+
+// Trigger triggers a pipeline of the project, or a build where pipelines are
+// not available.
+func (p *ProjectV2) Trigger(ctx context.Context, opts TriggerOptions) (Build, error) {
+	// Pipelines are only available through API v2, builds are the v1.1
+	// equivalent.
+	if v2, _ := p.useV2(ResourcePipeline); !v2 {
+		return p.v1().Trigger(ctx, opts)
+	}
+	pipeline, err := p.TriggerPipeline(ctx, opts)
+	if err != nil {
+		return Build{}, err
+	}
+	return Build{Number: pipeline.Number, PipelineID: pipeline.ID, URL: p.PipelineURL(pipeline)}, nil
+}
+
+// Pipeline is a triggered pipeline.
+type Pipeline struct {
+	ID        string    `json:"id"`
+	Number    int       `json:"number"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TriggerPipeline triggers a pipeline of the project.
+func (p *ProjectV2) TriggerPipeline(ctx context.Context, opts TriggerOptions) (Pipeline, error) {
+	var pipeline Pipeline
+	if err := p.require(ResourcePipeline); err != nil {
+		return pipeline, err
+	}
+	if err := opts.validate(); err != nil {
+		return pipeline, err
+	}
+	postBody := struct {
+		Branch     string                 `json:"branch,omitempty"`
+		Tag        string                 `json:"tag,omitempty"`
+		Parameters map[string]interface{} `json:"parameters,omitempty"`
+	}{opts.Branch, opts.Tag, opts.Parameters}
+	postBodyJSON, err := json.Marshal(postBody)
+	if err != nil {
+		return pipeline, fmt.Errorf("could not marshal pipeline parameters: %v", err)
+	}
+
+	resp, err := p.client.Post(ctx, p.fmtURI("pipeline"), "application/json", bytes.NewReader(postBodyJSON))
+	if err != nil {
+		return pipeline, fmt.Errorf("could not trigger pipeline of project %s: %v", p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return pipeline, fmt.Errorf("unexpected status code %d, expected %d", resp.StatusCode, http.StatusCreated)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&pipeline)
+	if err != nil {
+		return pipeline, fmt.Errorf("could not unmarshal pipeline of project %s: %v", p.FullName(), err)
+	}
+	if pipeline.ID == "" {
+		return pipeline, fmt.Errorf("triggered pipeline of project %s has no ID", p.FullName())
+	}
+	return pipeline, nil
+}
+
+// PipelineURL returns the web UI URL of the project's pipeline.
+func (p *ProjectV2) PipelineURL(pipeline Pipeline) string {
+	return fmt.Sprintf("%s/pipelines/%s/%d", appURL(p.Platform, p.client.BaseURL()), p.Slug(), pipeline.Number)
+}
+
+// SetJiraIntegration connects the project to Jira.
+func (p *ProjectV2) SetJiraIntegration(ctx context.Context, jira JiraIntegration) error {
+	return p.v1().SetJiraIntegration(ctx, jira)
+}
+
+// FeatureFlags gets the project's feature flags (the build settings toggles).
+func (p *ProjectV2) FeatureFlags(ctx context.Context) (map[string]interface{}, error) {
+	return p.v1().FeatureFlags(ctx)
+}
+
+// SetFeatureFlags sets the given feature flags, leaving others untouched.
+func (p *ProjectV2) SetFeatureFlags(ctx context.Context, flags map[string]interface{}) error {
+	return p.v1().SetFeatureFlags(ctx, flags)
+}
+
+// Getenvs gets all the environment variables in the project. Values are
+// masked by CircleCI.
+func (p *ProjectV2) Getenvs(ctx context.Context) (map[string]string, error) {
+	v2, err := p.useV2(ResourceEnvVar)
+	if err != nil {
+		return nil, err
+	} else if !v2 {
+		return p.v1().Getenvs(ctx)
+	}
+	envVars := make(map[string]string)
+	err = getItems(ctx, p.client, p.fmtURI("envvar"), func(item json.RawMessage) error {
+		var envVar EnvVar
+		err := json.Unmarshal(item, &envVar)
+		envVars[envVar.Name] = envVar.Value
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get environment variables for project %s: %v", p.FullName(), err)
+	}
+	return envVars, nil
+}
+
+// CheckoutKeys lists the project's checkout keys.
+func (p *ProjectV2) CheckoutKeys(ctx context.Context) ([]CheckoutKey, error) {
+	if err := p.require(ResourceCheckoutKey); err != nil {
+		return nil, err
+	}
+	var keys []CheckoutKey
+	err := getItems(ctx, p.client, p.fmtURI("checkout-key"), func(item json.RawMessage) error {
+		var key CheckoutKey
+		err := json.Unmarshal(item, &key)
+		keys = append(keys, key)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get checkout keys for project %s: %v", p.FullName(), err)
+	}
+	return keys, nil
+}
+
+// CreateCheckoutKey creates a checkout key of the given type, deploy-key or
+// user-key.
+func (p *ProjectV2) CreateCheckoutKey(ctx context.Context, keyType string) (CheckoutKey, error) {
+	var key CheckoutKey
+	if err := p.require(ResourceCheckoutKey); err != nil {
+		return key, err
+	}
+	if err := validCheckoutKeyType(keyType); err != nil {
+		return key, err
+	}
+	postBody := struct {
+		Type string `json:"type"`
+	}{keyType}
+	postBodyJSON, err := json.Marshal(postBody)
+	if err != nil {
+		return key, fmt.Errorf("could not marshal checkout key: %v", err)
+	}
+
+	resp, err := p.client.Post(ctx, p.fmtURI("checkout-key"), "application/json", bytes.NewReader(postBodyJSON))
+	if err != nil {
+		return key, fmt.Errorf("could not create %s for project %s: %v", keyType, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return key, fmt.Errorf("%s not created for project %s: status %s", keyType, p.FullName(), resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&key)
+	if err != nil {
+		return key, fmt.Errorf("could not unmarshal checkout key of project %s: %v", p.FullName(), err)
+	}
+	return key, nil
+}
+
+// DeleteCheckoutKey deletes the checkout key with the given fingerprint.
+func (p *ProjectV2) DeleteCheckoutKey(ctx context.Context, fingerprint string) error {
+	if err := p.require(ResourceCheckoutKey); err != nil {
+		return err
+	}
+	resp, err := p.client.Delete(ctx, p.fmtURI("checkout-key", fingerprint), "", nil)
+	if err != nil {
+		return fmt.Errorf("could not remove checkout key %s from project %s: %v", fingerprint, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not remove checkout key %s from project %s: status %s",
+			fingerprint, p.FullName(), resp.Status)
+	}
+	return nil
+}
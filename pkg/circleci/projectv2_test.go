@@ -0,0 +1,191 @@
+package circleci
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestV2SetenvFallback(t *testing.T) {
+	var v1Calls, v2Calls int
+	v1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v1Calls++
+		if r.URL.Path != "/project/github/test/test/envvar" {
+			t.Errorf("Unexpected v1.1 path %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer v1.Close()
+	v2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v2Calls++
+		if r.URL.Path != "/project/gh/test/test/envvar" {
+			t.Errorf("Unexpected v2 path %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"name": "A", "value": "xxxxb"}`)
+	}))
+	defer v2.Close()
+
+	type test struct {
+		platform Platform
+		v1Calls  int
+		v2Calls  int
+	}
+
+	testCases := []test{
+		{PlatformCloud, 0, 1},
+		{PlatformServer3, 0, 1},
+		{PlatformServer2, 1, 0},
+	}
+
+	for _, tc := range testCases {
+		v1Calls, v2Calls = 0, 0
+		project := NewProjectV2WithClient("github", "test", "test", &HTTPClient{baseURL: v2.URL, HTTP: v2.Client()},
+			&HTTPClient{baseURL: v1.URL, HTTP: v1.Client()})
+		project.Platform = tc.platform
+
+		err := project.Setenv(context.Background(), "A", "b")
+		if err != nil {
+			t.Errorf("Expected no error on %s, found: %v", tc.platform, err)
+		}
+		if v1Calls != tc.v1Calls || v2Calls != tc.v2Calls {
+			t.Errorf("Expected %d v1.1 and %d v2 calls on %s, found %d and %d",
+				tc.v1Calls, tc.v2Calls, tc.platform, v1Calls, v2Calls)
+		}
+	}
+}
+
+func TestV2GetenvsPaginates(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page-token") == "" {
+			io.WriteString(w, `{"items": [{"name": "A", "value": "xxxxa"}], "next_page_token": "next"}`)
+			return
+		}
+		io.WriteString(w, `{"items": [{"name": "B", "value": "xxxxb"}], "next_page_token": null}`)
+	}))
+	defer svr.Close()
+
+	client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+	project := NewProjectV2WithClient("gh", "test", "test", client, client)
+	envVars, err := project.Getenvs(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if len(envVars) != 2 || envVars["A"] != "xxxxa" || envVars["B"] != "xxxxb" {
+		t.Errorf("Unexpected environment variables %v", envVars)
+	}
+}
+
+func TestV2Trigger(t *testing.T) {
+	type test struct {
+		platform Platform
+		fixture  string
+		path     string
+		expected Build
+	}
+
+	testCases := []test{
+		{PlatformCloud, "trigger_v2.json", "/project/gh/test/test/pipeline", Build{
+			Number:     7,
+			PipelineID: "5034460f-c7c4-4c43-9457-de07e2029e7b",
+			URL:        "https://app.circleci.com/pipelines/gh/test/test/7",
+		}},
+		{PlatformServer2, "trigger_v1.json", "/project/github/test/test/build", Build{
+			Number: 42,
+			URL:    "https://circleci.com/gh/test/test/42",
+		}},
+	}
+
+	for _, tc := range testCases {
+		body, err := ioutil.ReadFile(filepath.Join("testdata", tc.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.URL.Path != tc.path {
+				t.Errorf("Unexpected request %s %s on %s", r.Method, r.URL.Path, tc.platform)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write(body)
+		}))
+
+		client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+		project := NewProjectV2WithClient("github", "test", "test", client, client)
+		project.Platform = tc.platform
+		build, err := project.Trigger(context.Background(), TriggerOptions{})
+		if err != nil {
+			t.Errorf("Expected no error on %s, found: %v", tc.platform, err)
+		} else if build != tc.expected {
+			t.Errorf("Expected build %+v on %s, found %+v", tc.expected, tc.platform, build)
+		}
+		svr.Close()
+	}
+}
+
+func TestV2TriggerPipelineWithoutID(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"message": "Build created"}`)
+	}))
+	defer svr.Close()
+
+	client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+	project := NewProjectV2WithClient("gh", "test", "test", client, client)
+	_, err := project.TriggerPipeline(context.Background(), TriggerOptions{Branch: "master"})
+	if err == nil {
+		t.Error("Expected an error for a pipeline without an ID")
+	}
+}
+
+func TestV2CreateCheckoutKey(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path != "/project/gh/test/test/checkout-key" || string(body) != `{"type":"user-key"}` {
+			t.Errorf("Unexpected request %s %s", r.URL.Path, body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"fingerprint": "ee", "type": "github-user-key", "preferred": true}`)
+	}))
+	defer svr.Close()
+
+	client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+	project := NewProjectV2WithClient("gh", "test", "test", client, client)
+	key, err := project.CreateCheckoutKey(context.Background(), CheckoutKeyUser)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if key.Fingerprint != "ee" || key.Kind() != CheckoutKeyUser {
+		t.Errorf("Unexpected checkout key %+v", key)
+	}
+}
+
+func TestV2TriggerPipelineOptions(t *testing.T) {
+	var bodies []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id": "5034460f-c7c4-4c43-9457-de07e2029e7b", "number": 8, "state": "pending"}`)
+	}))
+	defer svr.Close()
+
+	client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+	project := NewProjectV2WithClient("gh", "test", "test", client, client)
+	opts := TriggerOptions{Tag: "v1.0.0", Parameters: map[string]interface{}{"deploy": true}}
+	_, err := project.Trigger(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	_, err = project.Trigger(context.Background(), TriggerOptions{Branch: "master", Tag: "v1.0.0"})
+	if err == nil {
+		t.Error("Expected an error when triggering both a branch and a tag")
+	}
+	expected := `{"tag":"v1.0.0","parameters":{"deploy":true}}`
+	if len(bodies) != 1 || bodies[0] != expected {
+		t.Errorf("Expected one request with body %s, found %q", expected, bodies)
+	}
+}
@@ -0,0 +1,151 @@
+package circleci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Attribution actors of scheduled pipelines.
+const (
+	ActorCurrent = "current" // The user whose token creates the schedule
+	ActorSystem  = "system"  // A neutral actor, using no user's permissions
+)
+
+// Timetable is when a scheduled pipeline runs. Empty days or months mean
+// every one.
+type Timetable struct {
+	PerHour     int      `json:"per-hour" yaml:"perHour"`                    // Runs in each of the hours, between 1 and 60
+	HoursOfDay  []int    `json:"hours-of-day" yaml:"hoursOfDay"`             // UTC hours, between 0 and 23
+	DaysOfWeek  []string `json:"days-of-week,omitempty" yaml:"daysOfWeek"`   // MON to SUN
+	DaysOfMonth []int    `json:"days-of-month,omitempty" yaml:"daysOfMonth"` // Between 1 and 31
+	Months      []string `json:"months,omitempty" yaml:"months"`             // JAN to DEC
+}
+
+// Schedule is a scheduled pipeline of a project.
+type Schedule struct {
+	ID               string                 `json:"id,omitempty"`
+	Name             string                 `json:"name"`
+	Description      string                 `json:"description"`
+	Timetable        Timetable              `json:"timetable"`
+	Parameters       map[string]interface{} `json:"parameters"` // Pipeline parameters, including the branch or tag to build
+	AttributionActor string                 `json:"attribution-actor,omitempty"`
+}
+
+// Schedules is not available through API v1.1.
+func (p *ProjectV1) Schedules(ctx context.Context) ([]Schedule, error) {
+	return nil, p.require(ResourceSchedule)
+}
+
+// CreateSchedule is not available through API v1.1.
+func (p *ProjectV1) CreateSchedule(ctx context.Context, schedule Schedule) (Schedule, error) {
+	return Schedule{}, p.require(ResourceSchedule)
+}
+
+// UpdateSchedule is not available through API v1.1.
+func (p *ProjectV1) UpdateSchedule(ctx context.Context, schedule Schedule) error {
+	return p.require(ResourceSchedule)
+}
+
+// DeleteSchedule is not available through API v1.1.
+func (p *ProjectV1) DeleteSchedule(ctx context.Context, id string) error {
+	return p.require(ResourceSchedule)
+}
+
+// scheduleURI formats a URI of a schedule, which is not scoped to its
+// project.
+func (p *ProjectV2) scheduleURI(id string) string {
+	return endpoint(p.client.BaseURL(), nil, "schedule", id)
+}
+
+// Schedules lists the project's scheduled pipelines.
+func (p *ProjectV2) Schedules(ctx context.Context) ([]Schedule, error) {
+	if err := p.require(ResourceSchedule); err != nil {
+		return nil, err
+	}
+	var schedules []Schedule
+	err := getItems(ctx, p.client, p.fmtURI("schedule"), func(item json.RawMessage) error {
+		var schedule Schedule
+		err := json.Unmarshal(item, &schedule)
+		schedules = append(schedules, schedule)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get schedules of project %s: %v", p.FullName(), err)
+	}
+	return schedules, nil
+}
+
+// CreateSchedule creates a scheduled pipeline of the project.
+func (p *ProjectV2) CreateSchedule(ctx context.Context, schedule Schedule) (Schedule, error) {
+	var created Schedule
+	if err := p.require(ResourceSchedule); err != nil {
+		return created, err
+	}
+	schedule.ID = ""
+	postBodyJSON, err := json.Marshal(schedule)
+	if err != nil {
+		return created, fmt.Errorf("could not marshal schedule: %v", err)
+	}
+
+	resp, err := p.client.Post(ctx, p.fmtURI("schedule"), "application/json", bytes.NewReader(postBodyJSON))
+	if err != nil {
+		return created, fmt.Errorf("could not create schedule %s for project %s: %v", schedule.Name, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return created, fmt.Errorf("schedule %s not created for project %s: status %s",
+			schedule.Name, p.FullName(), resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&created)
+	if err != nil {
+		return created, fmt.Errorf("could not unmarshal schedule %s of project %s: %v", schedule.Name, p.FullName(), err)
+	}
+	return created, nil
+}
+
+// UpdateSchedule replaces the description, timetable, parameters and
+// attribution actor of the schedule with the given ID.
+func (p *ProjectV2) UpdateSchedule(ctx context.Context, schedule Schedule) error {
+	if err := p.require(ResourceSchedule); err != nil {
+		return err
+	}
+	id := schedule.ID
+	schedule.ID = ""
+	patchBodyJSON, err := json.Marshal(schedule)
+	if err != nil {
+		return fmt.Errorf("could not marshal schedule: %v", err)
+	}
+
+	resp, err := p.client.Patch(ctx, p.scheduleURI(id), "application/json", bytes.NewReader(patchBodyJSON))
+	if err != nil {
+		return fmt.Errorf("could not update schedule %s of project %s: %v", schedule.Name, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not update schedule %s of project %s: status %s",
+			schedule.Name, p.FullName(), resp.Status)
+	}
+	return nil
+}
+
+// DeleteSchedule deletes the schedule with the given ID.
+func (p *ProjectV2) DeleteSchedule(ctx context.Context, id string) error {
+	if err := p.require(ResourceSchedule); err != nil {
+		return err
+	}
+	resp, err := p.client.Delete(ctx, p.scheduleURI(id), "", nil)
+	if err != nil {
+		return fmt.Errorf("could not delete schedule %s from project %s: %v", id, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not delete schedule %s from project %s: status %s", id, p.FullName(), resp.Status)
+	}
+	return nil
+}
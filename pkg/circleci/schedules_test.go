@@ -0,0 +1,75 @@
+package circleci
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSchedules(t *testing.T) {
+	var created, patched map[string]interface{}
+	deleted := false
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /project/gh/test/test/schedule":
+			io.WriteString(w, `{"items": [{"id": "s-1", "name": "nightly", "description": "",
+				"timetable": {"per-hour": 1, "hours-of-day": [3], "days-of-week": ["MON"]},
+				"parameters": {"branch": "main"}}], "next_page_token": null}`)
+		case "POST /project/gh/test/test/schedule":
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"id": "s-2", "name": "weekly"}`)
+		case "PATCH /schedule/s-1":
+			json.NewDecoder(r.Body).Decode(&patched)
+		case "DELETE /schedule/s-1":
+			deleted = true
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+	project := NewProjectV2WithClient("gh", "test", "test", client, client)
+	ctx := context.Background()
+
+	schedules, err := project.Schedules(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	nightly := Schedule{ID: "s-1", Name: "nightly", Timetable: Timetable{PerHour: 1, HoursOfDay: []int{3},
+		DaysOfWeek: []string{"MON"}}, Parameters: map[string]interface{}{"branch": "main"}}
+	if !reflect.DeepEqual(schedules, []Schedule{nightly}) {
+		t.Errorf("Expected %+v, found %+v", nightly, schedules)
+	}
+
+	schedule, err := project.CreateSchedule(ctx, Schedule{Name: "weekly", AttributionActor: ActorSystem,
+		Timetable: Timetable{PerHour: 1, HoursOfDay: []int{0}}, Parameters: map[string]interface{}{"branch": "main"}})
+	if err != nil || schedule.ID != "s-2" {
+		t.Fatalf("Expected schedule s-2 and no error, found %+v and %v", schedule, err)
+	}
+	if created["attribution-actor"] != ActorSystem || created["timetable"].(map[string]interface{})["per-hour"] != 1.0 {
+		t.Errorf("Unexpected schedule created: %v", created)
+	}
+	if _, ok := created["id"]; ok {
+		t.Errorf("Expected no ID in the schedule created, found %v", created["id"])
+	}
+
+	nightly.Description = "Nightly build"
+	if err := project.UpdateSchedule(ctx, nightly); err != nil || patched["description"] != "Nightly build" {
+		t.Errorf("Expected the description to be updated, found %v and %v", patched, err)
+	}
+	if err := project.DeleteSchedule(ctx, "s-1"); err != nil || !deleted {
+		t.Errorf("Expected s-1 to be deleted, found: %v", err)
+	}
+
+	_, err = NewProjectV1WithClient("gh", "test", "test", client).Schedules(ctx)
+	if _, ok := err.(*CapabilityError); !ok {
+		t.Errorf("Expected a capability error through API v1.1, found: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package circleci
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// tlsVersions are the TLS versions a minimum can be set to, by name.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// cipherSuites are the TLS 1.0 to 1.2 cipher suites that can be required, by
+// their IANA name. TLS 1.3 suites are not configurable.
+var cipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_AES_128_CBC_SHA":                  tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":                  tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":               tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":               tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":       tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":       tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256": tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256":   tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+}
+
+// DefaultAPIURL is the address of CircleCI cloud.
+const DefaultAPIURL = "https://circleci.com"
+
+// BaseURLs returns the base URLs of API v1.1 and v2 of the CircleCI
+// installation at apiURL, e.g. https://circleci.example.com for a self-hosted
+// CircleCI Server. apiURL may also be given with an /api suffix.
+func BaseURLs(apiURL string) (v1, v2 string, err error) {
+	u, err := url.Parse(apiURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", "", fmt.Errorf("invalid API URL %q, expected one like https://circleci.example.com", apiURL)
+	}
+	root := strings.TrimRight(u.Path, "/")
+	for _, suffix := range []string{"/api/v1.1", "/api/v2", "/api"} {
+		root = strings.TrimSuffix(root, suffix)
+	}
+	u.Path, u.RawQuery, u.Fragment = root, "", ""
+	return u.String() + "/api/v1.1", u.String() + "/api/v2", nil
+}
+
+// appURL returns the address of the web app of the installation on platform
+// whose API v2 is at baseURL. The app of CircleCI Server is served from the
+// same host as its API.
+func appURL(platform Platform, baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if platform == "" || platform == PlatformCloud || err != nil || u.Host == "" {
+		return "https://app.circleci.com"
+	}
+	u.Path = strings.TrimSuffix(strings.TrimRight(u.Path, "/"), "/api/v2")
+	u.RawQuery, u.Fragment = "", ""
+	return u.String()
+}
+
+// TLSConfig returns the TLS config trusting the CA certificates in the PEM
+// file caBundle, if set, besides the system ones, and not verifying
+// certificates at all if insecureSkipVerify is set.
+func TLSConfig(caBundle string, insecureSkipVerify bool) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if caBundle == "" {
+		return config, nil
+	}
+	pem, err := ioutil.ReadFile(caBundle)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CA bundle: %v", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("CA bundle %s has no PEM encoded certificates", caBundle)
+	}
+	config.RootCAs = pool
+	return config, nil
+}
+
+// RestrictTLS sets the minimum TLS version of config, e.g. 1.2, and, if any
+// are named, the cipher suites it may negotiate.
+func RestrictTLS(config *tls.Config, minVersion string, ciphers []string) error {
+	if minVersion != "" {
+		version, ok := tlsVersions[minVersion]
+		if !ok {
+			return fmt.Errorf("unknown TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", minVersion)
+		}
+		config.MinVersion = version
+	}
+	config.CipherSuites = nil
+	for _, name := range ciphers {
+		suite, ok := cipherSuites[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return fmt.Errorf("unknown cipher suite %q, expected an IANA name such as "+
+				"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", name)
+		}
+		config.CipherSuites = append(config.CipherSuites, suite)
+	}
+	return nil
+}
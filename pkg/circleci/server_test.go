@@ -0,0 +1,110 @@
+package circleci
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestBaseURLs(t *testing.T) {
+	for apiURL, expected := range map[string]string{
+		"https://circleci.com":                      "https://circleci.com",
+		"https://circleci.example.com/":             "https://circleci.example.com",
+		"https://circleci.example.com/api/v2":       "https://circleci.example.com",
+		"http://ci.internal:8080/circleci/api/v1.1": "http://ci.internal:8080/circleci",
+	} {
+		v1, v2, err := BaseURLs(apiURL)
+		if err != nil || v1 != expected+"/api/v1.1" || v2 != expected+"/api/v2" {
+			t.Errorf("Expected %s/api/... for %s, found %s and %s (%v)", expected, apiURL, v1, v2, err)
+		}
+	}
+	for _, apiURL := range []string{"circleci.example.com", "ftp://circleci.example.com", "https://"} {
+		if _, _, err := BaseURLs(apiURL); err == nil {
+			t.Errorf("Expected an error for %q", apiURL)
+		}
+	}
+}
+
+func TestAppURL(t *testing.T) {
+	if actual := appURL(PlatformCloud, "https://circleci.com/api/v2"); actual != "https://app.circleci.com" {
+		t.Errorf("Expected the cloud app, found %s", actual)
+	}
+	if actual := appURL(PlatformServer3, "https://circleci.example.com/api/v2"); actual != "https://circleci.example.com" {
+		t.Errorf("Expected the server's own host, found %s", actual)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	svr := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer svr.Close()
+	if _, err := getThrough(t, svr.URL, NetworkOptions{}); err == nil {
+		t.Error("Expected the test server's certificate to be untrusted")
+	}
+
+	insecure, err := TLSConfig("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getThrough(t, svr.URL, NetworkOptions{TLS: insecure}); err != nil {
+		t.Errorf("Expected no error skipping verification, found: %v", err)
+	}
+
+	fh, err := ioutil.TempFile("", "ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fh.Name())
+	fh.WriteString("not a certificate")
+	fh.Close()
+	if _, err := TLSConfig(fh.Name(), false); err == nil {
+		t.Error("Expected an error for a bundle without certificates")
+	}
+}
+
+func TestRestrictTLS(t *testing.T) {
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	svr.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	svr.StartTLS()
+	defer svr.Close()
+
+	config, err := TLSConfig("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RestrictTLS(config, "1.3", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getThrough(t, svr.URL, NetworkOptions{TLS: config}); err == nil {
+		t.Error("Expected a TLS 1.2 server to be refused with a TLS 1.3 minimum")
+	}
+	err = RestrictTLS(config, "1.2", []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", " tls_ecdhe_rsa_with_aes_256_gcm_sha384"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getThrough(t, svr.URL, NetworkOptions{TLS: config}); err != nil {
+		t.Errorf("Expected no error with TLS 1.2 and modern ciphers, found: %v", err)
+	}
+	if len(config.CipherSuites) != 2 || config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Unexpected TLS config: min version %x, cipher suites %x", config.MinVersion, config.CipherSuites)
+	}
+
+	if err := RestrictTLS(config, "1.4", nil); err == nil {
+		t.Error("Expected an error for an unknown TLS version")
+	}
+	if err := RestrictTLS(config, "", []string{"TLS_RSA_WITH_RC4_128_SHA"}); err == nil {
+		t.Error("Expected an error for an unsupported cipher suite")
+	}
+}
+
+// getThrough gets url through a transport created with opts.
+func getThrough(t *testing.T, url string, opts NetworkOptions) (*http.Response, error) {
+	client := &http.Client{Transport: NewTransport(opts)}
+	resp, err := client.Get(url)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
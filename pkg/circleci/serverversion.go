@@ -0,0 +1,105 @@
+package circleci
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// serverVersionHeader is the response header CircleCI Server reports its
+// version in.
+const serverVersionHeader = "Circleci-Server-Version"
+
+// serverMinVersions are the first CircleCI Server versions supporting each
+// resource that not every Server 3.x or later supports.
+var serverMinVersions = map[string]string{
+	ResourceInsights: "3.1",
+	ResourceWebhook:  "4.0",
+	ResourceSchedule: "4.1",
+	ResourceOIDC:     "4.2",
+}
+
+// VersionError is returned when a resource cannot be managed on the version
+// of CircleCI Server being provisioned.
+type VersionError struct {
+	Resource string
+	Version  string // Version of the installation
+	Required string // First version supporting the resource
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("%s requires CircleCI Server %s or later / not available on Server %s",
+		e.Resource, e.Required, e.Version)
+}
+
+// DetectServerVersion asks the CircleCI Server whose API v2 client talks to
+// for its version.
+func DetectServerVersion(ctx context.Context, client Client) (string, error) {
+	resp, err := client.Get(ctx, endpoint(client.BaseURL(), nil, "me"))
+	if err != nil {
+		return "", fmt.Errorf("could not get the server version: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not get the server version: status %s", resp.Status)
+	}
+	version := strings.TrimPrefix(resp.Header.Get(serverVersionHeader), "v")
+	if version == "" {
+		return "", fmt.Errorf("the server does not report its version")
+	}
+	return version, nil
+}
+
+// UnsupportedResources returns the resources the Server version does not
+// support, sorted, with the first version supporting each.
+func UnsupportedResources(version string) []VersionError {
+	var unsupported []VersionError
+	for resource, required := range serverMinVersions {
+		if !versionAtLeast(version, required) {
+			unsupported = append(unsupported, VersionError{resource, version, required})
+		}
+	}
+	sort.Slice(unsupported, func(i, j int) bool { return unsupported[i].Resource < unsupported[j].Resource })
+	return unsupported
+}
+
+// requireServerVersion checks that the resource can be managed on the
+// version of the platform, which is not checked if unknown.
+func requireServerVersion(platform Platform, version, resource string) error {
+	if version == "" || platform == "" || platform == PlatformCloud {
+		return nil
+	}
+	required, ok := serverMinVersions[resource]
+	if !ok || versionAtLeast(version, required) {
+		return nil
+	}
+	return &VersionError{resource, version, required}
+}
+
+// versionAtLeast reports whether the dotted version is min or later. Parts
+// that are not numbers, e.g. of pre-releases, count as 0.
+func versionAtLeast(version, min string) bool {
+	parts := func(v string) []int {
+		var numbers []int
+		for _, part := range strings.Split(strings.TrimPrefix(v, "v"), ".") {
+			n, _ := strconv.Atoi(part)
+			numbers = append(numbers, n)
+		}
+		return numbers
+	}
+	have, want := parts(version), parts(min)
+	for i, n := range want {
+		var m int
+		if i < len(have) {
+			m = have[i]
+		}
+		if m != n {
+			return m > n
+		}
+	}
+	return true
+}
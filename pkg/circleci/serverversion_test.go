@@ -0,0 +1,55 @@
+package circleci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDetectServerVersion(t *testing.T) {
+	header := "4.1.3"
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/me" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if header != "" {
+			w.Header().Set("CircleCI-Server-Version", header)
+		}
+	}))
+	defer svr.Close()
+	client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+
+	version, err := DetectServerVersion(context.Background(), client)
+	if err != nil || version != "4.1.3" {
+		t.Errorf("Expected version 4.1.3 and no error, found %q and %v", version, err)
+	}
+	header = ""
+	if _, err := DetectServerVersion(context.Background(), client); err == nil {
+		t.Error("Expected an error when the server does not report its version")
+	}
+}
+
+func TestServerVersionRequirements(t *testing.T) {
+	project := NewProjectV2WithClient("gh", "test", "test", nil, nil)
+	project.Platform, project.Version = PlatformServer3, "3.4.0"
+	_, err := project.Schedules(context.Background())
+	expected := "schedule requires CircleCI Server 4.1 or later / not available on Server 3.4.0"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, found %v", expected, err)
+	}
+
+	unsupported := UnsupportedResources("4.0.2")
+	expectedUnsupported := []VersionError{{ResourceOIDC, "4.0.2", "4.2"}, {ResourceSchedule, "4.0.2", "4.1"}}
+	if !reflect.DeepEqual(unsupported, expectedUnsupported) {
+		t.Errorf("Expected %v to be unsupported, found %v", expectedUnsupported, unsupported)
+	}
+	if unsupported := UnsupportedResources("v4.10"); len(unsupported) != 0 {
+		t.Errorf("Expected everything to be supported on 4.10, found %v", unsupported)
+	}
+	if err := requireServerVersion(PlatformServer3, "", ResourceOIDC); err != nil {
+		t.Errorf("Expected no check of an unknown version, found %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package circleci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// StoragePolicy is how long CircleCI keeps what a project's jobs store, in
+// days. Zero days are left unchanged.
+type StoragePolicy struct {
+	ArtifactsDays  int `json:"artifacts_retention_days,omitempty"`  // Artifacts stored with store_artifacts
+	WorkspacesDays int `json:"workspaces_retention_days,omitempty"` // Workspaces persisted between jobs
+	CachesDays     int `json:"caches_retention_days,omitempty"`     // Dependency caches saved with save_cache
+}
+
+// storageSettings is the subset of the v2 project settings carrying the
+// storage policy.
+type storageSettings struct {
+	Storage StoragePolicy `json:"storage"`
+}
+
+// StoragePolicy is not available through API v1.1.
+func (p *ProjectV1) StoragePolicy(ctx context.Context) (StoragePolicy, error) {
+	return StoragePolicy{}, p.require(ResourceStorage)
+}
+
+// SetStoragePolicy is not available through API v1.1.
+func (p *ProjectV1) SetStoragePolicy(ctx context.Context, policy StoragePolicy) error {
+	return p.require(ResourceStorage)
+}
+
+// StoragePolicy gets how long the project's artifacts, workspaces and caches
+// are kept.
+func (p *ProjectV2) StoragePolicy(ctx context.Context) (StoragePolicy, error) {
+	var settings storageSettings
+	if err := p.require(ResourceStorage); err != nil {
+		return settings.Storage, err
+	}
+	resp, err := p.client.Get(ctx, p.fmtURI("settings"))
+	if err != nil {
+		return settings.Storage, fmt.Errorf("could not get storage policy of project %s: %v", p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return settings.Storage, fmt.Errorf("could not get storage policy of project %s: status %s", p.FullName(), resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&settings)
+	if err != nil {
+		return settings.Storage, fmt.Errorf("could not unmarshal storage policy of project %s: %v", p.FullName(), err)
+	}
+	return settings.Storage, nil
+}
+
+// SetStoragePolicy sets how long the project's artifacts, workspaces and
+// caches are kept. Zero days are left unchanged.
+func (p *ProjectV2) SetStoragePolicy(ctx context.Context, policy StoragePolicy) error {
+	if err := p.require(ResourceStorage); err != nil {
+		return err
+	}
+	patchBodyJSON, err := json.Marshal(storageSettings{policy})
+	if err != nil {
+		return fmt.Errorf("could not marshal storage policy: %v", err)
+	}
+
+	resp, err := p.client.Patch(ctx, p.fmtURI("settings"), "application/json", bytes.NewReader(patchBodyJSON))
+	if err != nil {
+		return fmt.Errorf("could not set storage policy of project %s: %v", p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not set storage policy of project %s: status %s", p.FullName(), resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package circleci
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStoragePolicy(t *testing.T) {
+	var patched map[string]map[string]int
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /project/gh/test/test/settings":
+			io.WriteString(w, `{"advanced": {"oss": true}, "storage": {"artifacts_retention_days": 30, "caches_retention_days": 15}}`)
+		case "PATCH /project/gh/test/test/settings":
+			json.NewDecoder(r.Body).Decode(&patched)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+	project := NewProjectV2WithClient("gh", "test", "test", client, client)
+	ctx := context.Background()
+
+	policy, err := project.StoragePolicy(ctx)
+	expected := StoragePolicy{ArtifactsDays: 30, CachesDays: 15}
+	if err != nil || policy != expected {
+		t.Errorf("Expected %+v and no error, found %+v and %v", expected, policy, err)
+	}
+	if err := project.SetStoragePolicy(ctx, StoragePolicy{ArtifactsDays: 7}); err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+	if len(patched["storage"]) != 1 || patched["storage"]["artifacts_retention_days"] != 7 {
+		t.Errorf("Expected only the artifacts retention to be set, found %v", patched)
+	}
+
+	v1 := NewProjectV1WithClient("gh", "test", "test", client)
+	if _, err := v1.StoragePolicy(ctx); err == nil {
+		t.Error("Expected the storage policy not to be available through API v1.1")
+	}
+}
@@ -0,0 +1,253 @@
+package circleci
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NetworkOptions tune how connections to the API are made, so that runs
+// survive brief DNS and network failures.
+type NetworkOptions struct {
+	DialTimeout   time.Duration // Timeout of each connection attempt
+	DialRetries   int           // Times to retry connecting when every address fails
+	FallbackDelay time.Duration // Wait before also trying the other IP family, negative to try addresses in order
+	DNSCacheTTL   time.Duration // How long resolved addresses are reused (no cache if 0)
+	DNSRetries    int           // Times to retry a lookup failing with a temporary error
+	RetryDelay    time.Duration // Wait before the first retry, doubled for each one after
+	TLS           *tls.Config   // TLS config of connections, the default one if nil
+}
+
+// DefaultNetworkOptions match the net/http defaults, with lookups cached and
+// failed lookups and connections retried.
+var DefaultNetworkOptions = NetworkOptions{
+	DialTimeout:   30 * time.Second,
+	DialRetries:   2,
+	FallbackDelay: 300 * time.Millisecond,
+	DNSCacheTTL:   5 * time.Minute,
+	DNSRetries:    3,
+	RetryDelay:    200 * time.Millisecond,
+}
+
+// NewTransport creates a transport connecting as opts describes. Clients
+// sharing it share its DNS cache.
+func NewTransport(opts NetworkOptions) *http.Transport {
+	d := &dialer{
+		dialer: &net.Dialer{Timeout: opts.DialTimeout, KeepAlive: 30 * time.Second},
+		cache:  newDNSCache(net.DefaultResolver.LookupIPAddr, opts.DNSCacheTTL, opts.DNSRetries, opts.RetryDelay),
+		opts:   opts,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           d.DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSClientConfig:       opts.TLS,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// dnsEntry is a cached lookup.
+type dnsEntry struct {
+	addrs   []net.IPAddr
+	expires time.Time
+}
+
+// dnsCache resolves hostnames, reusing addresses until they are ttl old and
+// falling back to expired ones when a lookup fails.
+type dnsCache struct {
+	lookup     func(ctx context.Context, host string) ([]net.IPAddr, error)
+	ttl        time.Duration
+	retries    int
+	retryDelay time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+func newDNSCache(lookup func(context.Context, string) ([]net.IPAddr, error), ttl time.Duration, retries int, retryDelay time.Duration) *dnsCache {
+	return &dnsCache{lookup: lookup, ttl: ttl, retries: retries, retryDelay: retryDelay, now: time.Now,
+		entries: make(map[string]dnsEntry)}
+}
+
+// resolve returns the addresses of host.
+func (c *dnsCache) resolve(ctx context.Context, host string) ([]net.IPAddr, error) {
+	c.mu.Lock()
+	entry, cached := c.entries[host]
+	c.mu.Unlock()
+	if cached && c.now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := c.lookupWithRetries(ctx, host)
+	if err != nil {
+		if cached {
+			logger.Warnf("Could not resolve %s, using the addresses it last resolved to: %v", host, err)
+			return entry.addrs, nil
+		}
+		return nil, err
+	}
+	if c.ttl > 0 {
+		c.mu.Lock()
+		c.entries[host] = dnsEntry{addrs: addrs, expires: c.now().Add(c.ttl)}
+		c.mu.Unlock()
+	}
+	return addrs, nil
+}
+
+// lookupWithRetries looks host up, retrying with exponential backoff while
+// the lookup fails with a temporary error.
+func (c *dnsCache) lookupWithRetries(ctx context.Context, host string) ([]net.IPAddr, error) {
+	for attempt := 0; ; attempt++ {
+		addrs, err := c.lookup(ctx, host)
+		if err == nil || attempt >= c.retries || !temporaryDNSError(err) {
+			return addrs, err
+		}
+		delay := c.retryDelay << uint(attempt)
+		logger.Warnf("Could not resolve %s, retrying in %v: %v", host, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// temporaryDNSError reports whether err is a lookup failure worth retrying.
+func temporaryDNSError(err error) bool {
+	dnsErr, ok := err.(*net.DNSError)
+	return ok && (dnsErr.Temporary() || dnsErr.Timeout())
+}
+
+// dialer connects to addresses resolved through its cache, racing the two IP
+// families as happy eyeballs (RFC 6555) does.
+type dialer struct {
+	dialer *net.Dialer
+	cache  *dnsCache
+	opts   NetworkOptions
+}
+
+// DialContext connects to address, retrying when every address fails.
+func (d *dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+	for attempt := 0; ; attempt++ {
+		var addrs []net.IPAddr
+		addrs, err = d.cache.resolve(ctx, host)
+		var conn net.Conn
+		if err == nil {
+			conn, err = d.dialAddrs(ctx, network, addrs, port)
+		}
+		if err == nil || attempt >= d.opts.DialRetries || ctx.Err() != nil {
+			return conn, err
+		}
+		delay := d.opts.RetryDelay << uint(attempt)
+		logger.Warnf("Could not connect to %s, retrying in %v: %v", address, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// dialResult is the outcome of trying a list of addresses.
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialAddrs connects to one of addrs, trying those of the first address's
+// family first and, after the fallback delay or once they fail, racing
+// those of the other family against them.
+func (d *dialer) dialAddrs(ctx context.Context, network string, addrs []net.IPAddr, port string) (net.Conn, error) {
+	primaries, fallbacks := partitionAddrs(addrs)
+	if len(fallbacks) == 0 || d.opts.FallbackDelay < 0 {
+		return d.dialSerial(ctx, network, append(primaries, fallbacks...), port)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult)
+	start := func(addrs []net.IPAddr) {
+		go func() {
+			conn, err := d.dialSerial(ctx, network, addrs, port)
+			select {
+			case results <- dialResult{conn, err}:
+			case <-ctx.Done():
+				if conn != nil {
+					conn.Close()
+				}
+			}
+		}()
+	}
+
+	start(primaries)
+	fallback := time.NewTimer(d.opts.FallbackDelay)
+	defer fallback.Stop()
+	started, pending := false, 1
+	var firstErr error
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-fallback.C:
+			if !started {
+				started, pending = true, pending+1
+				start(fallbacks)
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				return result.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			if !started {
+				started, pending = true, pending+1
+				start(fallbacks)
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// dialSerial tries addrs in order, returning the first connection made.
+func (d *dialer) dialSerial(ctx context.Context, network string, addrs []net.IPAddr, port string) (net.Conn, error) {
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// partitionAddrs splits addrs into those of the first address's IP family
+// and those of the other one.
+func partitionAddrs(addrs []net.IPAddr) (primaries, fallbacks []net.IPAddr) {
+	for _, addr := range addrs {
+		if (addr.IP.To4() != nil) == (addrs[0].IP.To4() != nil) {
+			primaries = append(primaries, addr)
+		} else {
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	return primaries, fallbacks
+}
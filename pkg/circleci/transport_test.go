@@ -0,0 +1,104 @@
+package circleci
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDNSCache(t *testing.T) {
+	addrs := []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}}
+	var lookups int
+	var failures []error
+	lookup := func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		lookups++
+		if len(failures) > 0 {
+			err := failures[0]
+			failures = failures[1:]
+			return nil, err
+		}
+		return addrs, nil
+	}
+	now := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	cache := newDNSCache(lookup, time.Minute, 2, time.Millisecond)
+	cache.now = func() time.Time { return now }
+
+	// Temporary failures are retried.
+	failures = []error{&net.DNSError{Err: "timeout", IsTimeout: true}, &net.DNSError{Err: "busy", IsTemporary: true}}
+	actual, err := cache.resolve(context.Background(), "circleci.com")
+	if err != nil || !reflect.DeepEqual(actual, addrs) || lookups != 3 {
+		t.Fatalf("Expected the lookup to be retried twice, found %v (%v) after %d lookups", actual, err, lookups)
+	}
+	if _, err := cache.resolve(context.Background(), "circleci.com"); err != nil || lookups != 3 {
+		t.Errorf("Expected the cached addresses to be reused, found %d lookups (%v)", lookups, err)
+	}
+
+	// Once expired, a failed lookup falls back to the cached addresses.
+	now = now.Add(2 * time.Minute)
+	failures = []error{&net.DNSError{Err: "no such host"}}
+	actual, err = cache.resolve(context.Background(), "circleci.com")
+	if err != nil || !reflect.DeepEqual(actual, addrs) || lookups != 4 {
+		t.Errorf("Expected the expired addresses after one lookup, found %v (%v) after %d lookups", actual, err, lookups)
+	}
+
+	failures = []error{&net.DNSError{Err: "no such host"}}
+	if _, err := cache.resolve(context.Background(), "example.com"); err == nil {
+		t.Error("Expected an error resolving a host that was never resolved")
+	}
+}
+
+func TestPartitionAddrs(t *testing.T) {
+	v6, v4 := net.IPAddr{IP: net.ParseIP("2001:db8::1")}, net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	primaries, fallbacks := partitionAddrs([]net.IPAddr{v6, v4, v6})
+	if !reflect.DeepEqual(primaries, []net.IPAddr{v6, v6}) || !reflect.DeepEqual(fallbacks, []net.IPAddr{v4}) {
+		t.Errorf("Expected the IPv6 addresses first, found %v and %v", primaries, fallbacks)
+	}
+}
+
+func TestDialFallsBack(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	// Nothing listens on the IPv6 address, so the connection falls back to
+	// the IPv4 one.
+	lookup := func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("::1")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+	opts := NetworkOptions{DialTimeout: time.Second, FallbackDelay: 10 * time.Millisecond}
+	d := &dialer{dialer: &net.Dialer{Timeout: opts.DialTimeout}, cache: newDNSCache(lookup, 0, 0, 0), opts: opts}
+	conn, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("circleci.test", port))
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	defer conn.Close()
+	if host, _, _ := net.SplitHostPort(conn.RemoteAddr().String()); host != "127.0.0.1" {
+		t.Errorf("Expected to connect to 127.0.0.1, found %s", host)
+	}
+
+	// Connections are retried when every address fails.
+	attempts := 0
+	d.opts.DialRetries = 2
+	d.cache = newDNSCache(func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		attempts++
+		return nil, fmt.Errorf("unreachable")
+	}, 0, 0, 0)
+	if _, err := d.DialContext(context.Background(), "tcp", "circleci.test:443"); err == nil || attempts != 3 {
+		t.Errorf("Expected 3 failed attempts, found %d (%v)", attempts, err)
+	}
+}
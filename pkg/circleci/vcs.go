@@ -0,0 +1,75 @@
+package circleci
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// VCS types projects can be hosted on. Standalone projects, such as those
+// of CircleCI's GitLab integration, are identified by the IDs of their
+// organisation and project rather than names on a VCS.
+const (
+	VCSGitHub     = "github"
+	VCSBitbucket  = "bitbucket"
+	VCSStandalone = "circleci"
+)
+
+// vcsNames maps the accepted forms of each VCS type to the name used in API
+// v1.1 paths.
+var vcsNames = map[string]string{
+	"github":    VCSGitHub,
+	"gh":        VCSGitHub,
+	"bitbucket": VCSBitbucket,
+	"bb":        VCSBitbucket,
+	"circleci":  VCSStandalone,
+	"gitlab":    VCSStandalone,
+}
+
+// vcsSlugs maps VCS types to the short form used in v2 project slugs.
+var vcsSlugs = map[string]string{
+	VCSGitHub:     "gh",
+	VCSBitbucket:  "bb",
+	VCSStandalone: "circleci",
+}
+
+// ParseVCSType returns the VCS type named by vcsType, which may be given in
+// its long (github, bitbucket) or short (gh, bb) form in any case, or as
+// circleci (or gitlab) for standalone projects.
+func ParseVCSType(vcsType string) (string, error) {
+	name, ok := vcsNames[strings.ToLower(vcsType)]
+	if !ok {
+		return "", fmt.Errorf("unknown VCS type %q, expected github (gh), bitbucket (bb) or circleci", vcsType)
+	}
+	return name, nil
+}
+
+// vcsName returns the name of the VCS type in API v1.1 paths, or vcsType
+// itself if it is not known.
+func vcsName(vcsType string) string {
+	if name, err := ParseVCSType(vcsType); err == nil {
+		return name
+	}
+	return vcsType
+}
+
+// vcsSlug returns the short form of the VCS type used in API v2 slugs, or
+// vcsType itself if it is not known.
+func vcsSlug(vcsType string) string {
+	if slug, ok := vcsSlugs[vcsName(vcsType)]; ok {
+		return slug
+	}
+	return vcsType
+}
+
+// ProjectSlug returns the slug of a project in API v2 paths, e.g.
+// gh/owner/project, which also identifies it across VCS types.
+func ProjectSlug(vcsType, owner, projectName string) string {
+	return path.Join(vcsSlug(vcsType), owner, projectName)
+}
+
+// IsStandalone reports whether vcsType is that of standalone projects, which
+// only API v2 supports.
+func IsStandalone(vcsType string) bool {
+	return vcsName(vcsType) == VCSStandalone
+}
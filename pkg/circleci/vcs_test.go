@@ -0,0 +1,121 @@
+package circleci
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseVCSType(t *testing.T) {
+	for vcsType, expected := range map[string]string{
+		"github": VCSGitHub, "GH": VCSGitHub, "bitbucket": VCSBitbucket, "Bitbucket": VCSBitbucket, "bb": VCSBitbucket,
+		"circleci": VCSStandalone, "GitLab": VCSStandalone,
+	} {
+		actual, err := ParseVCSType(vcsType)
+		if err != nil || actual != expected {
+			t.Errorf("Expected %s for %s, found %s (%v)", expected, vcsType, actual, err)
+		}
+	}
+	for _, vcsType := range []string{"", "git", "gl"} {
+		if _, err := ParseVCSType(vcsType); err == nil {
+			t.Errorf("Expected an error for %q", vcsType)
+		}
+	}
+}
+
+func TestBitbucketPaths(t *testing.T) {
+	var paths []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/v1/project/bitbucket/team/repo/follow":
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"following": true}`)
+		case "/v2/project/bb/team/repo/envvar":
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"name": "A", "value": "xxxxb"}`)
+		case "/v2/context":
+			if slug := r.URL.Query().Get("owner-slug"); slug != "bb/team" {
+				t.Errorf("Unexpected owner slug %s", slug)
+			}
+			io.WriteString(w, `{"items": []}`)
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer svr.Close()
+
+	for _, vcsType := range []string{"bitbucket", "bb"} {
+		paths = nil
+		project := NewProjectV2WithClient(vcsType, "team", "repo", &HTTPClient{baseURL: svr.URL + "/v2", HTTP: svr.Client()},
+			&HTTPClient{baseURL: svr.URL + "/v1", HTTP: svr.Client()})
+		if project.Slug() != "bb/team/repo" {
+			t.Errorf("Expected slug bb/team/repo for %s, found %s", vcsType, project.Slug())
+		}
+		if err := project.Legacy.Follow(context.Background()); err != nil {
+			t.Errorf("Expected no error following, found: %v", err)
+		}
+		if err := project.Setenv(context.Background(), "A", "b"); err != nil {
+			t.Errorf("Expected no error setting an env var, found: %v", err)
+		}
+		contexts := NewContextsWithClient(vcsType, "team", &HTTPClient{baseURL: svr.URL + "/v2", HTTP: svr.Client()})
+		if _, err := contexts.List(context.Background()); err != nil {
+			t.Errorf("Expected no error listing contexts, found: %v", err)
+		}
+		if len(paths) != 3 {
+			t.Errorf("Expected 3 requests for %s, found %v", vcsType, paths)
+		}
+	}
+}
+
+func TestStandalonePaths(t *testing.T) {
+	const org, id = "8a9c4b2e-1f3d-4e5a-9b6c-7d8e9f0a1b2c", "0f1e2d3c-4b5a-4968-8776-655443322110"
+	var paths []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/v2/project/circleci/" + org + "/" + id:
+			io.WriteString(w, `{"id": "`+id+`"}`)
+		case "/v2/project/circleci/" + org + "/" + id + "/envvar":
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"name": "A", "value": "xxxxb"}`)
+		case "/v2/context":
+			if owner := r.URL.Query().Get("owner-id"); owner != org {
+				t.Errorf("Unexpected owner ID %s", owner)
+			}
+			io.WriteString(w, `{"items": []}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	project := NewProjectV2WithClient("circleci", org, id, &HTTPClient{baseURL: svr.URL + "/v2", HTTP: svr.Client()},
+		&HTTPClient{baseURL: svr.URL + "/v1", HTTP: svr.Client()})
+	if err := project.Follow(context.Background()); err != nil {
+		t.Errorf("Expected an existing standalone project to count as followed, found: %v", err)
+	}
+	if err := project.Setenv(context.Background(), "A", "b"); err != nil {
+		t.Errorf("Expected no error setting an env var, found: %v", err)
+	}
+	if _, err := project.GetSSHKeys(context.Background()); err == nil {
+		t.Error("Expected API v1.1 resources to be unavailable")
+	}
+	contexts := NewContextsWithClient("circleci", org, &HTTPClient{baseURL: svr.URL + "/v2", HTTP: svr.Client()})
+	if _, err := contexts.List(context.Background()); err != nil {
+		t.Errorf("Expected no error listing contexts, found: %v", err)
+	}
+	if len(paths) != 3 {
+		t.Errorf("Expected 3 requests, none through API v1.1, found %v", paths)
+	}
+
+	missing := NewProjectV2WithClient("circleci", org, org, &HTTPClient{baseURL: svr.URL + "/v2", HTTP: svr.Client()}, nil)
+	if following, err := missing.IsFollowing(context.Background()); err != nil || following {
+		t.Errorf("Expected a missing standalone project to not be followed, found %v (%v)", following, err)
+	}
+	if err := missing.Follow(context.Background()); err == nil {
+		t.Error("Expected following a missing standalone project to fail")
+	}
+}
@@ -0,0 +1,154 @@
+package circleci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// BuildStatus is the status of a triggered build or pipeline.
+type BuildStatus struct {
+	Status   string // CircleCI's status of the build, or of the pipeline's failed workflow
+	Finished bool
+	Success  bool
+}
+
+// WaitForBuild polls the status of the build every interval until it
+// finishes or ctx is done.
+func WaitForBuild(ctx context.Context, project Project, build Build, interval time.Duration) (BuildStatus, error) {
+	for {
+		status, err := project.BuildStatus(ctx, build)
+		if err != nil || status.Finished {
+			return status, err
+		}
+		logger.Debugf("Build %d of %s is %s, checking again in %v", build.Number, project.FullName(), status.Status, interval)
+		select {
+		case <-ctx.Done():
+			return status, fmt.Errorf("gave up waiting for build %d of %s: %v", build.Number, project.FullName(), ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// BuildStatus gets the status of a build triggered through API v1.1.
+func (p *ProjectV1) BuildStatus(ctx context.Context, build Build) (BuildStatus, error) {
+	var status BuildStatus
+	if err := p.require(ResourceBuild); err != nil {
+		return status, err
+	}
+	url := p.fmtURI("project", strconv.Itoa(build.Number))
+	resp, err := p.client.Get(ctx, url)
+	if err != nil {
+		return status, fmt.Errorf("could not get build %d of project %s: %v", build.Number, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return status, fmt.Errorf("could not get build %d of project %s: status %s", build.Number, p.FullName(), resp.Status)
+	}
+	var summary struct {
+		Status    string `json:"status"`
+		Lifecycle string `json:"lifecycle"` // "finished" once the build is done
+		Outcome   string `json:"outcome"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&summary)
+	if err != nil {
+		return status, fmt.Errorf("could not unmarshal build %d of project %s: %v", build.Number, p.FullName(), err)
+	}
+	status.Status = summary.Status
+	status.Finished = summary.Lifecycle == "finished"
+	status.Success = summary.Outcome == "success"
+	return status, nil
+}
+
+// pipelineWorkflow is a workflow of a pipeline.
+type pipelineWorkflow struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// workflowFailures are the workflow statuses that fail a pipeline.
+var workflowFailures = map[string]bool{"failed": true, "error": true, "canceled": true, "unauthorized": true}
+
+// workflowRunning are the workflow statuses that are not final.
+var workflowRunning = map[string]bool{"running": true, "on_hold": true, "failing": true}
+
+// BuildStatus gets the status of a pipeline from the status of its workflows,
+// or of a build where the pipeline was triggered through API v1.1.
+func (p *ProjectV2) BuildStatus(ctx context.Context, build Build) (BuildStatus, error) {
+	var status BuildStatus
+	if build.PipelineID == "" {
+		return p.v1().BuildStatus(ctx, build)
+	}
+	if err := p.require(ResourcePipeline); err != nil {
+		return status, err
+	}
+
+	var workflows []pipelineWorkflow
+	err := getItems(ctx, p.client, p.pipelineURI(build.PipelineID, "workflow"), func(item json.RawMessage) error {
+		var workflow pipelineWorkflow
+		err := json.Unmarshal(item, &workflow)
+		workflows = append(workflows, workflow)
+		return err
+	})
+	if err != nil {
+		return status, fmt.Errorf("could not get workflows of pipeline %d of project %s: %v", build.Number, p.FullName(), err)
+	}
+
+	// A pipeline whose config could not be compiled has no workflows.
+	if len(workflows) == 0 {
+		state, err := p.pipelineState(ctx, build)
+		if err != nil {
+			return status, err
+		}
+		status.Status = state
+		status.Finished = state == "errored"
+		return status, nil
+	}
+
+	status.Status = "success"
+	status.Finished = true
+	status.Success = true
+	for _, workflow := range workflows {
+		if workflowRunning[workflow.Status] {
+			status.Status = workflow.Status
+			status.Finished = false
+			status.Success = false
+		}
+	}
+	for _, workflow := range workflows {
+		if workflowFailures[workflow.Status] {
+			logger.Debugf("Workflow %s of pipeline %d of %s is %s", workflow.Name, build.Number, p.FullName(), workflow.Status)
+			status.Status = workflow.Status
+			status.Success = false
+		}
+	}
+	return status, nil
+}
+
+// pipelineState gets the state of the pipeline, e.g. created or errored.
+func (p *ProjectV2) pipelineState(ctx context.Context, build Build) (string, error) {
+	resp, err := p.client.Get(ctx, p.pipelineURI(build.PipelineID))
+	if err != nil {
+		return "", fmt.Errorf("could not get pipeline %d of project %s: %v", build.Number, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not get pipeline %d of project %s: status %s", build.Number, p.FullName(), resp.Status)
+	}
+	var pipeline Pipeline
+	err = json.NewDecoder(resp.Body).Decode(&pipeline)
+	if err != nil {
+		return "", fmt.Errorf("could not unmarshal pipeline %d of project %s: %v", build.Number, p.FullName(), err)
+	}
+	return pipeline.State, nil
+}
+
+// pipelineURI returns the URI of the pipeline, or of a resource of it.
+func (p *ProjectV2) pipelineURI(id string, parts ...string) string {
+	return endpoint(p.client.BaseURL(), nil, append([]string{"pipeline", id}, parts...)...)
+}
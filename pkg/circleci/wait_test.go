@@ -0,0 +1,101 @@
+package circleci
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestV1BuildStatus(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"GET /project/git/test/test/42": {Status: http.StatusOK, Body: `{"build_num": 42, "status": "failed", "lifecycle": "finished", "outcome": "failed"}`},
+	})
+	defer svr.Close()
+
+	status, err := svr.project().BuildStatus(context.Background(), Build{Number: 42})
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := BuildStatus{Status: "failed", Finished: true, Success: false}
+	if status != expected {
+		t.Errorf("Expected status %+v, found %+v", expected, status)
+	}
+}
+
+func TestV2BuildStatus(t *testing.T) {
+	type test struct {
+		name      string
+		workflows string
+		expected  BuildStatus
+	}
+
+	testCases := []test{
+		{"running", `[{"name": "build", "status": "success"}, {"name": "deploy", "status": "running"}]`,
+			BuildStatus{Status: "running"}},
+		{"success", `[{"name": "build", "status": "success"}, {"name": "deploy", "status": "success"}]`,
+			BuildStatus{Status: "success", Finished: true, Success: true}},
+		{"failed", `[{"name": "build", "status": "failed"}, {"name": "deploy", "status": "success"}]`,
+			BuildStatus{Status: "failed", Finished: true}},
+		{"errored", `[]`, BuildStatus{Status: "errored", Finished: true}},
+	}
+
+	for _, tc := range testCases {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/pipeline/abc/workflow":
+				io.WriteString(w, `{"items": `+tc.workflows+`, "next_page_token": null}`)
+			case "/pipeline/abc":
+				io.WriteString(w, `{"id": "abc", "number": 7, "state": "errored"}`)
+			default:
+				t.Errorf("Unexpected request %s %s for %s", r.Method, r.URL.Path, tc.name)
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+		project := NewProjectV2WithClient("gh", "test", "test", client, client)
+		status, err := project.BuildStatus(context.Background(), Build{Number: 7, PipelineID: "abc"})
+		if err != nil {
+			t.Errorf("Expected no error for %s, found: %v", tc.name, err)
+		} else if status != tc.expected {
+			t.Errorf("Expected status %+v for %s, found %+v", tc.expected, tc.name, status)
+		}
+		svr.Close()
+	}
+}
+
+func TestWaitForBuild(t *testing.T) {
+	polls := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls < 3 {
+			io.WriteString(w, `{"status": "running", "lifecycle": "running"}`)
+			return
+		}
+		io.WriteString(w, `{"status": "success", "lifecycle": "finished", "outcome": "success"}`)
+	}))
+	defer svr.Close()
+
+	client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+	project := NewProjectV1WithClient("gh", "test", "test", client)
+	status, err := WaitForBuild(context.Background(), project, Build{Number: 42}, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if !status.Success || polls != 3 {
+		t.Errorf("Expected success after 3 polls, found %+v after %d", status, polls)
+	}
+
+	polls = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, err = WaitForBuild(ctx, project, Build{Number: 42}, time.Hour)
+	if err == nil {
+		t.Error("Expected an error once the context is done")
+	}
+}
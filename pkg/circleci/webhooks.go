@@ -0,0 +1,177 @@
+package circleci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Outbound webhook events.
+const (
+	WebhookWorkflowCompleted = "workflow-completed"
+	WebhookJobCompleted      = "job-completed"
+)
+
+// Webhook is an outbound webhook, notifying a URL of a project's events.
+type Webhook struct {
+	ID            string   `json:"id,omitempty"`
+	Name          string   `json:"name"`
+	URL           string   `json:"url"`
+	Events        []string `json:"events"`
+	VerifyTLS     bool     `json:"verify-tls"`
+	SigningSecret string   `json:"signing-secret"` // Not returned when listing webhooks
+}
+
+// validWebhookEvent checks the event can be subscribed to.
+func validWebhookEvent(event string) error {
+	if event != WebhookWorkflowCompleted && event != WebhookJobCompleted {
+		return fmt.Errorf("unknown webhook event %q, expected %s or %s",
+			event, WebhookWorkflowCompleted, WebhookJobCompleted)
+	}
+	return nil
+}
+
+// Webhooks is not available through API v1.1.
+func (p *ProjectV1) Webhooks(ctx context.Context) ([]Webhook, error) {
+	return nil, p.require(ResourceWebhook)
+}
+
+// CreateWebhook is not available through API v1.1.
+func (p *ProjectV1) CreateWebhook(ctx context.Context, webhook Webhook) (Webhook, error) {
+	return Webhook{}, p.require(ResourceWebhook)
+}
+
+// UpdateWebhook is not available through API v1.1.
+func (p *ProjectV1) UpdateWebhook(ctx context.Context, webhook Webhook) error {
+	return p.require(ResourceWebhook)
+}
+
+// DeleteWebhook is not available through API v1.1.
+func (p *ProjectV1) DeleteWebhook(ctx context.Context, id string) error {
+	return p.require(ResourceWebhook)
+}
+
+// webhookURI formats a URI of the webhook API.
+func (p *ProjectV2) webhookURI(query url.Values, parts ...string) string {
+	return endpoint(p.client.BaseURL(), query, append([]string{"webhook"}, parts...)...)
+}
+
+// Webhooks lists the project's outbound webhooks.
+func (p *ProjectV2) Webhooks(ctx context.Context) ([]Webhook, error) {
+	if err := p.require(ResourceWebhook); err != nil {
+		return nil, err
+	}
+	id, err := p.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"scope-id": {id}, "scope-type": {"project"}}
+	var webhooks []Webhook
+	err = getItems(ctx, p.client, p.webhookURI(query), func(item json.RawMessage) error {
+		var webhook Webhook
+		err := json.Unmarshal(item, &webhook)
+		webhooks = append(webhooks, webhook)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get webhooks of project %s: %v", p.FullName(), err)
+	}
+	return webhooks, nil
+}
+
+// CreateWebhook creates an outbound webhook of the project.
+func (p *ProjectV2) CreateWebhook(ctx context.Context, webhook Webhook) (Webhook, error) {
+	var created Webhook
+	if err := p.require(ResourceWebhook); err != nil {
+		return created, err
+	}
+	for _, event := range webhook.Events {
+		if err := validWebhookEvent(event); err != nil {
+			return created, err
+		}
+	}
+	id, err := p.ID(ctx)
+	if err != nil {
+		return created, err
+	}
+	type scope struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	postBody := struct {
+		Webhook
+		Scope scope `json:"scope"`
+	}{webhook, scope{id, "project"}}
+	postBody.ID = ""
+	postBodyJSON, err := json.Marshal(postBody)
+	if err != nil {
+		return created, fmt.Errorf("could not marshal webhook: %v", err)
+	}
+
+	resp, err := p.client.Post(ctx, p.webhookURI(nil), "application/json", bytes.NewReader(postBodyJSON))
+	if err != nil {
+		return created, fmt.Errorf("could not create webhook %s for project %s: %v", webhook.Name, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return created, fmt.Errorf("webhook %s not created for project %s: status %s",
+			webhook.Name, p.FullName(), resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&created)
+	if err != nil {
+		return created, fmt.Errorf("could not unmarshal webhook %s of project %s: %v", webhook.Name, p.FullName(), err)
+	}
+	return created, nil
+}
+
+// UpdateWebhook replaces the URL, events, TLS verification and signing
+// secret of the webhook with the given ID.
+func (p *ProjectV2) UpdateWebhook(ctx context.Context, webhook Webhook) error {
+	if err := p.require(ResourceWebhook); err != nil {
+		return err
+	}
+	for _, event := range webhook.Events {
+		if err := validWebhookEvent(event); err != nil {
+			return err
+		}
+	}
+	id := webhook.ID
+	webhook.ID = ""
+	putBodyJSON, err := json.Marshal(webhook)
+	if err != nil {
+		return fmt.Errorf("could not marshal webhook: %v", err)
+	}
+
+	resp, err := p.client.Put(ctx, p.webhookURI(nil, id), "application/json", bytes.NewReader(putBodyJSON))
+	if err != nil {
+		return fmt.Errorf("could not update webhook %s of project %s: %v", webhook.Name, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not update webhook %s of project %s: status %s",
+			webhook.Name, p.FullName(), resp.Status)
+	}
+	return nil
+}
+
+// DeleteWebhook deletes the webhook with the given ID.
+func (p *ProjectV2) DeleteWebhook(ctx context.Context, id string) error {
+	if err := p.require(ResourceWebhook); err != nil {
+		return err
+	}
+	resp, err := p.client.Delete(ctx, p.webhookURI(nil, id), "", nil)
+	if err != nil {
+		return fmt.Errorf("could not delete webhook %s from project %s: %v", id, p.FullName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not delete webhook %s from project %s: status %s", id, p.FullName(), resp.Status)
+	}
+	return nil
+}
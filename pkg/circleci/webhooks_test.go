@@ -0,0 +1,86 @@
+package circleci
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWebhooks(t *testing.T) {
+	var created map[string]interface{}
+	var updated, deleted []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /project/gh/test/test":
+			io.WriteString(w, `{"id": "project-id"}`)
+		case "GET /webhook":
+			if r.URL.Query().Get("scope-id") != "project-id" || r.URL.Query().Get("scope-type") != "project" {
+				t.Errorf("Unexpected query %s", r.URL.RawQuery)
+			}
+			io.WriteString(w, `{"items": [{"id": "hook-1", "name": "slack", "url": "https://example.com/hook",
+				"events": ["workflow-completed"], "verify-tls": true}], "next_page_token": null}`)
+		case "POST /webhook":
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"id": "hook-2", "name": "deploys"}`)
+		case "PUT /webhook/hook-1":
+			updated = append(updated, "hook-1")
+		case "DELETE /webhook/hook-1":
+			deleted = append(deleted, "hook-1")
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	client := &HTTPClient{baseURL: svr.URL, HTTP: svr.Client()}
+	project := NewProjectV2WithClient("gh", "test", "test", client, client)
+	ctx := context.Background()
+
+	webhooks, err := project.Webhooks(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := []Webhook{{ID: "hook-1", Name: "slack", URL: "https://example.com/hook",
+		Events: []string{WebhookWorkflowCompleted}, VerifyTLS: true}}
+	if !reflect.DeepEqual(webhooks, expected) {
+		t.Errorf("Expected %+v, found %+v", expected, webhooks)
+	}
+
+	webhook, err := project.CreateWebhook(ctx, Webhook{Name: "deploys", URL: "https://example.com/deploys",
+		Events: []string{WebhookJobCompleted}, SigningSecret: "secret"})
+	if err != nil || webhook.ID != "hook-2" {
+		t.Fatalf("Expected webhook hook-2 and no error, found %+v and %v", webhook, err)
+	}
+	scope, _ := created["scope"].(map[string]interface{})
+	if scope["id"] != "project-id" || scope["type"] != "project" || created["signing-secret"] != "secret" {
+		t.Errorf("Unexpected webhook created: %v", created)
+	}
+	if _, ok := created["id"]; ok {
+		t.Errorf("Expected no ID in the webhook created, found %v", created["id"])
+	}
+
+	_, err = project.CreateWebhook(ctx, Webhook{Name: "bad", Events: []string{"build-started"}})
+	if err == nil {
+		t.Error("Expected an error for an unknown event")
+	}
+	if err := project.UpdateWebhook(ctx, expected[0]); err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+	if err := project.DeleteWebhook(ctx, "hook-1"); err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+	if len(updated) != 1 || len(deleted) != 1 {
+		t.Errorf("Expected one update and one delete, found %v and %v", updated, deleted)
+	}
+
+	_, err = NewProjectV1WithClient("gh", "test", "test", client).Webhooks(ctx)
+	if _, ok := err.(*CapabilityError); !ok {
+		t.Errorf("Expected a capability error through API v1.1, found: %v", err)
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// Plan operations.
+const (
+	opAdd        = "add"
+	opUpdate     = "update"
+	opRemove     = "remove"
+	opFollow     = "follow"
+	opUnfollow   = "unfollow"
+	opTrigger    = "trigger"
+	opQuarantine = "quarantine"
+)
+
+// ProjectState is the live state of a project.
+type ProjectState struct {
+	Following bool
+	EnvVars   map[string]string // Values are masked by CircleCI
+	SSHKeys   []circleci.SSHKey
+
+	// Only read when planning a canonical run of a config managing them.
+	CheckoutKeys []circleci.CheckoutKey
+	Webhooks     []circleci.Webhook
+	Schedules    []circleci.Schedule
+}
+
+// Action is a change that provisioning would make to a project.
+type Action struct {
+	ID       string `json:"id" yaml:"id"` // Of the resource, as in reports and events
+	Resource string `json:"resource" yaml:"resource"`
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Op       string `json:"op" yaml:"op"`
+}
+
+// Plan is the list of actions that provisioning would take, in order.
+type Plan []Action
+
+// fetchState reads the live state of the project.
+func fetchState(ctx context.Context, project circleci.Project) (ProjectState, error) {
+	var state ProjectState
+	var err error
+
+	state.Following, err = project.IsFollowing(ctx)
+	if err != nil {
+		return state, fmt.Errorf("could not get follow status of project %s: %v", project.FullName(), err)
+	}
+	state.EnvVars, err = project.Getenvs(ctx)
+	if err != nil {
+		return state, err
+	}
+	state.SSHKeys, err = project.GetSSHKeys(ctx)
+	if err != nil {
+		return state, fmt.Errorf("could not get SSH keys of project %s: %v", project.FullName(), err)
+	}
+	return state, nil
+}
+
+// fetchPlanState reads the live state of the project that planning the
+// config needs: for a canonical run, the checkout keys, webhooks and
+// schedules it would remove are read along with the env vars and SSH keys.
+func fetchPlanState(ctx context.Context, project circleci.Project, config Config, opts provisionOptions) (ProjectState, error) {
+	state, err := fetchState(ctx, project)
+	if err != nil || !opts.canonical {
+		return state, err
+	}
+	if len(config.CheckoutKeys) > 0 {
+		state.CheckoutKeys, err = project.CheckoutKeys(ctx)
+		if err != nil {
+			return state, err
+		}
+	}
+	if len(config.Webhooks) > 0 {
+		state.Webhooks, err = project.Webhooks(ctx)
+		if err != nil {
+			return state, err
+		}
+	}
+	if len(config.Schedules) > 0 {
+		state.Schedules, err = project.Schedules(ctx)
+	}
+	return state, err
+}
+
+// computePlan works out the actions provisioning the config onto a project in
+// the given state would take.
+func computePlan(config Config, state ProjectState, opts provisionOptions) Plan {
+	slug := circleci.ProjectSlug(config.VcsType, config.Owner, config.ProjectName)
+	action := func(resource, name, op string) Action {
+		return Action{ID: resourceID(slug, resource, name), Resource: resource, Name: name, Op: op}
+	}
+	var plan Plan
+	if !state.Following {
+		plan = append(plan, action(circleci.ResourceFollow, "", opFollow))
+	}
+
+	envVars := make(map[string]string)
+	for name, value := range state.EnvVars {
+		envVars[name] = value
+	}
+	// Without a state file stale resources are removed once the configured
+	// ones are in place, so they come last.
+	var stale, removedCheckoutKeys, removedWebhooks, removedSchedules Plan
+	if opts.canonical {
+		// With a state file only managed resources are removed, keyed like
+		// the project's FullName.
+		managed := opts.managed.Project(config.Owner + "/" + config.ProjectName)
+		markers := quarantineMarkers(state.EnvVars)
+		for _, name := range sortedKeys(state.EnvVars) {
+			if _, ok := config.EnvVars[name]; ok || config.protectsEnvVar(name) {
+				continue
+			}
+			if opts.managed != nil && !containsName(managed.EnvVars, name) && markers[name] == "" {
+				continue
+			}
+			if !opts.quarantine && opts.managed == nil {
+				stale = append(stale, action(circleci.ResourceEnvVar, name, opRemove))
+			} else if !opts.quarantine {
+				plan = append(plan, action(circleci.ResourceEnvVar, name, opRemove))
+			} else if _, _, isMarker := parseQuarantineMarker(name); !isMarker && markers[name] == "" {
+				plan = append(plan, action(circleci.ResourceEnvVar, name, opQuarantine))
+			}
+		}
+		removeKey := func(key circleci.SSHKey) Action {
+			remove := action(circleci.ResourceSSHKey, key.Hostname, opRemove)
+			remove.Name += " (" + key.Fingerprint + ")"
+			return remove
+		}
+		if opts.managed == nil {
+			// Keys that cannot be read are left for provisioning to report.
+			fingerprints, _ := configuredFingerprints(config.SSHKeys)
+			overlapping, _ := overlappingFingerprints(config, time.Now())
+			for _, key := range staleSSHKeys(state.SSHKeys, config, fingerprints, overlapping) {
+				stale = append(stale, removeKey(key))
+			}
+		}
+		for _, key := range state.SSHKeys {
+			if opts.managed == nil || config.protectsSSHKey(key.Hostname) {
+				continue
+			}
+			if !containsName(managed.SSHKeys, key.Hostname) || config.SSHKeys[key.Hostname] != "" ||
+				config.generatesSSHKey(key.Hostname) {
+				continue
+			}
+			plan = append(plan, removeKey(key))
+		}
+
+		// Checkout keys, webhooks and schedules are only managed, and so
+		// removed, if the config lists some.
+		if len(config.CheckoutKeys) > 0 {
+			for _, key := range staleCheckoutKeys(state.CheckoutKeys, config.CheckoutKeys) {
+				remove := action(circleci.ResourceCheckoutKey, key.Fingerprint, opRemove)
+				remove.Name = key.Kind() + " (" + key.Fingerprint + ")"
+				removedCheckoutKeys = append(removedCheckoutKeys, remove)
+			}
+		}
+		if len(config.Webhooks) > 0 {
+			_, webhooks := matchWebhooks(state.Webhooks, config.Webhooks)
+			for _, webhook := range webhooks {
+				removedWebhooks = append(removedWebhooks, action(circleci.ResourceWebhook, webhook.Name, opRemove))
+			}
+		}
+		// Schedules are left alone if the config's are invalid.
+		if schedules, err := projectSchedules(config); err == nil && len(schedules) > 0 {
+			_, stale := matchSchedules(state.Schedules, schedules)
+			for _, schedule := range stale {
+				removedSchedules = append(removedSchedules, action(circleci.ResourceSchedule, schedule.Name, opRemove))
+			}
+		}
+	}
+
+	// Env vars are only left out when provisioning would skip them, which
+	// needs the hashes of the values last set.
+	var hashes *EnvHashes
+	if opts.history != nil {
+		hashes, _ = opts.history.plannedEnvHashes(config.Owner + "/" + config.ProjectName)
+	}
+	for _, name := range sortedKeys(config.EnvVars) {
+		if masked, ok := envVars[name]; envVarUnchanged(name, config.EnvVars[name], masked, ok, hashes) {
+			continue
+		} else if ok {
+			plan = append(plan, action(circleci.ResourceEnvVar, name, opUpdate))
+		} else {
+			plan = append(plan, action(circleci.ResourceEnvVar, name, opAdd))
+		}
+	}
+
+	hostnames := make([]string, 0, len(config.SSHKeys))
+	for hostname := range config.SSHKeys {
+		hostnames = append(hostnames, hostname)
+	}
+	// Generated keys are only added to projects without a key for their hostname.
+	for _, hostname := range config.generatedHostnames() {
+		if !hasSSHKey(state.SSHKeys, hostname) {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	sort.Strings(hostnames)
+	for _, hostname := range hostnames {
+		plan = append(plan, action(circleci.ResourceSSHKey, hostname, opAdd))
+	}
+	plan = append(plan, stale...)
+	plan = append(plan, removedCheckoutKeys...)
+
+	if len(config.buildSettingsFlags()) > 0 {
+		plan = append(plan, action(circleci.ResourceSettings, "build settings", opUpdate))
+	}
+	if config.Integrations.Jira != nil {
+		plan = append(plan, action(circleci.ResourceSettings, "jira", opUpdate))
+	}
+	plan = append(plan, removedWebhooks...)
+	plan = append(plan, removedSchedules...)
+	if config.OIDC != nil {
+		plan = append(plan, action(circleci.ResourceOIDC, "custom claims", opUpdate))
+	}
+	if config.Storage != nil {
+		plan = append(plan, action(circleci.ResourceStorage, "retention", opUpdate))
+	}
+
+	if opts.trigger {
+		plan = append(plan, action(circleci.ResourceBuild, "", opTrigger))
+	}
+	return plan
+}
+
+var opSymbols = map[string]string{
+	opAdd:        "+",
+	opUpdate:     "~",
+	opRemove:     "-",
+	opFollow:     "+",
+	opUnfollow:   "-",
+	opTrigger:    ">",
+	opQuarantine: "!",
+}
+
+// Print writes a human readable description of the plan to w.
+func (plan Plan) Print(w io.Writer, projectName string) {
+	if len(plan) == 0 {
+		fmt.Fprintf(w, "No changes for project %s\n", projectName)
+		return
+	}
+	fmt.Fprintf(w, "Plan for project %s:\n", projectName)
+	counts := make(map[string]int)
+	for _, action := range plan {
+		counts[action.Op]++
+		symbol := opSymbols[action.Op]
+		if action.Name == "" {
+			fmt.Fprintf(w, "  %s %s %s\n", paint(w, symbolColors[symbol], symbol), action.Op, action.Resource)
+		} else {
+			fmt.Fprintf(w, "  %s %s %s\n", paint(w, symbolColors[symbol], symbol), action.Resource, action.Name)
+		}
+	}
+	fmt.Fprintf(w, "%d to add, %d to update, %d to remove", counts[opAdd], counts[opUpdate], counts[opRemove])
+	if counts[opQuarantine] > 0 {
+		fmt.Fprintf(w, ", %d to quarantine", counts[opQuarantine])
+	}
+	fmt.Fprintln(w)
+}
+
+// planDocument is the plan of a project as written with -output json or
+// yaml.
+type planDocument struct {
+	Project string `json:"project" yaml:"project"`
+	ID      string `json:"id" yaml:"id"` // Slug of the project
+	Actions Plan   `json:"actions" yaml:"actions"`
+}
+
+// writePlans writes the plans of every project to w as a json or yaml
+// document.
+func writePlans(w io.Writer, format string, plans []planDocument) error {
+	var data []byte
+	var err error
+	switch format {
+	case outputJSON:
+		data, err = json.MarshalIndent(plans, "", "  ")
+		data = append(data, '\n')
+	case outputYAML:
+		data, err = yaml.Marshal(plans)
+	default:
+		return validateOutputFormat(format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// readSnapshots reads the project states written by state show to file,
+// which holds one of them or a list, keyed by project.
+func readSnapshots(file string) (map[string]ProjectState, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read snapshot %s: %v", file, err)
+	}
+	var views []stateView
+	if err := yaml.Unmarshal(data, &views); err != nil {
+		var view stateView
+		if err := yaml.Unmarshal(data, &view); err != nil {
+			return nil, fmt.Errorf("could not unmarshal snapshot %s: %v", file, err)
+		}
+		views = []stateView{view}
+	}
+	states := make(map[string]ProjectState, len(views))
+	for _, view := range views {
+		if view.Project == "" {
+			return nil, fmt.Errorf("snapshot %s has a project state without a project", file)
+		}
+		states[view.Project] = ProjectState{Following: view.Following, EnvVars: view.EnvVars, SSHKeys: view.SSHKeys}
+	}
+	return states, nil
+}
+
+func runPlan(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	canonical := fs.Bool("canonical", envBool("CIRCLECI_CANONICAL"), "Plan removing anything not described in the configs")
+	quarantine := fs.Bool("quarantine", envBool("CIRCLECI_QUARANTINE"),
+		"With -canonical, plan quarantining environment variables instead of removing them")
+	trigger := fs.Bool("trigger", envBool("CIRCLECI_TRIGGER"), "Plan triggering a build of each project")
+	stateFile := fs.String("state-file", os.Getenv("CIRCLECI_STATE_FILE"),
+		"File of the resources provisioned, so that -canonical only removes those")
+	historyDir := fs.String("history-dir", os.Getenv("CIRCLECI_HISTORY_DIR"),
+		"Directory provision keeps its history in, whose env var hashes tell which env vars are unchanged")
+	offline := fs.Bool("offline", false, "Plan against -state instead of the live API, needing no token")
+	snapshot := fs.String("state", "", "Snapshot written by state show -format json that -offline plans against")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s plan [flags] [CONFIG|DIR...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *offline != (*snapshot != "") {
+		return fmt.Errorf("-offline and -state should be given together")
+	}
+	common.offline = *offline
+
+	var snapshots map[string]ProjectState
+	if *offline {
+		var err error
+		snapshots, err = readSnapshots(*snapshot)
+		if err != nil {
+			return err
+		}
+	}
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	files, err := common.configArgs(fs.Args())
+	if err != nil {
+		fs.Usage()
+		return err
+	}
+	opts := provisionOptions{canonical: *canonical, quarantine: *quarantine, trigger: *trigger}
+	if *stateFile != "" {
+		opts.managed, err = OpenManagedState(*stateFile)
+		if err != nil {
+			return err
+		}
+	}
+	if *historyDir != "" {
+		opts.history, err = OpenHistory(*historyDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	format := *s.flags.output
+	plans := []planDocument{}
+	for _, file := range files {
+		configs, err := s.readConfigs(file)
+		if err != nil {
+			return err
+		}
+		for _, config := range configs {
+			name := config.Owner + "/" + config.ProjectName
+			state, ok := snapshots[name]
+			if !*offline {
+				scoped, err := s.forConfig(config)
+				if err != nil {
+					return err
+				}
+				project := scoped.project(config.VcsType, config.Owner, config.ProjectName)
+				state, err = fetchPlanState(scoped.ctx, project, config, opts)
+				if err != nil {
+					return err
+				}
+			} else if !ok {
+				return fmt.Errorf("no state of project %s in snapshot %s", name, *snapshot)
+			}
+			plan := computePlan(config, state, opts)
+			if format == outputTable || format == "" {
+				plan.Print(s.stdout, name)
+				continue
+			}
+			if plan == nil {
+				plan = Plan{}
+			}
+			slug := circleci.ProjectSlug(config.VcsType, config.Owner, config.ProjectName)
+			plans = append(plans, planDocument{Project: name, ID: slug, Actions: plan})
+		}
+	}
+	if format == outputTable || format == "" {
+		return nil
+	}
+	return writePlans(s.stdout, format, plans)
+}
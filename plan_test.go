@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+func TestComputePlan(t *testing.T) {
+	config := Config{
+		VcsType: "github", Owner: "test", ProjectName: "test",
+		EnvVars: map[string]string{"KEEP": "1", "NEW": "2"},
+		SSHKeys: map[string]string{"example.com": "key"},
+	}
+	state := ProjectState{
+		Following: true,
+		EnvVars:   map[string]string{"KEEP": "xxxx0", "OLD": "xxxx3"},
+		SSHKeys:   []circleci.SSHKey{{Hostname: "old.example.com", Fingerprint: "aa:bb"}},
+	}
+
+	type test struct {
+		name     string
+		state    ProjectState
+		opts     provisionOptions
+		expected Plan
+	}
+
+	testCases := []test{
+		{
+			name:  "additive",
+			state: state,
+			expected: Plan{
+				{"gh/test/test/envvar/KEEP", circleci.ResourceEnvVar, "KEEP", opUpdate},
+				{"gh/test/test/envvar/NEW", circleci.ResourceEnvVar, "NEW", opAdd},
+				{"gh/test/test/ssh-key/example.com", circleci.ResourceSSHKey, "example.com", opAdd},
+			},
+		},
+		{
+			name:  "canonical and trigger",
+			state: state,
+			opts:  provisionOptions{canonical: true, trigger: true},
+			expected: Plan{
+				{"gh/test/test/envvar/KEEP", circleci.ResourceEnvVar, "KEEP", opUpdate},
+				{"gh/test/test/envvar/NEW", circleci.ResourceEnvVar, "NEW", opAdd},
+				{"gh/test/test/ssh-key/example.com", circleci.ResourceSSHKey, "example.com", opAdd},
+				{"gh/test/test/envvar/OLD", circleci.ResourceEnvVar, "OLD", opRemove},
+				{"gh/test/test/ssh-key/old.example.com", circleci.ResourceSSHKey, "old.example.com (aa:bb)", opRemove},
+				{"gh/test/test/build", circleci.ResourceBuild, "", opTrigger},
+			},
+		},
+		{
+			name: "canonical with quarantine",
+			state: ProjectState{
+				Following: true,
+				EnvVars:   map[string]string{"KEEP": "xxxx0", "OLD": "xxxx3", "GONE": "xxxx4", "ZZ_DELETED_1563703183_GONE": "xxxxGONE"},
+			},
+			opts: provisionOptions{canonical: true, quarantine: true},
+			expected: Plan{
+				{"gh/test/test/envvar/OLD", circleci.ResourceEnvVar, "OLD", opQuarantine},
+				{"gh/test/test/envvar/KEEP", circleci.ResourceEnvVar, "KEEP", opUpdate},
+				{"gh/test/test/envvar/NEW", circleci.ResourceEnvVar, "NEW", opAdd},
+				{"gh/test/test/ssh-key/example.com", circleci.ResourceSSHKey, "example.com", opAdd},
+			},
+		},
+		{
+			name:  "not followed",
+			state: ProjectState{},
+			expected: Plan{
+				{"gh/test/test/follow", circleci.ResourceFollow, "", opFollow},
+				{"gh/test/test/envvar/KEEP", circleci.ResourceEnvVar, "KEEP", opAdd},
+				{"gh/test/test/envvar/NEW", circleci.ResourceEnvVar, "NEW", opAdd},
+				{"gh/test/test/ssh-key/example.com", circleci.ResourceSSHKey, "example.com", opAdd},
+			},
+		},
+	}
+
+	// Like provisioning, an env var whose masked value matches is only left
+	// out if the hash of the value last set does too.
+	unchanged := state
+	unchanged.EnvVars = map[string]string{"KEEP": "xxxx1"}
+	actual := computePlan(config, unchanged, provisionOptions{})
+	if len(actual) == 0 || actual[0] != (Action{"gh/test/test/envvar/KEEP", circleci.ResourceEnvVar, "KEEP", opUpdate}) {
+		t.Errorf("Expected the env var to be updated without a recorded hash, found %v", actual)
+	}
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	history, err := OpenHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual = computePlan(config, unchanged, provisionOptions{history: history})
+	if len(actual) == 0 || actual[0].Name != "KEEP" {
+		t.Errorf("Expected the env var to be updated without a hash key, found %v", actual)
+	}
+	if _, err := os.Stat(history.hashKeyPath("test/test")); !os.IsNotExist(err) {
+		t.Errorf("Expected planning not to create a hash key, found %v", err)
+	}
+	hashes, err := history.EnvHashes("test/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes.record("KEEP", "1")
+	if err := history.SaveEnvHashes("test/test", hashes); err != nil {
+		t.Fatal(err)
+	}
+	actual = computePlan(config, unchanged, provisionOptions{history: history})
+	if len(actual) == 0 || actual[0] != (Action{"gh/test/test/envvar/NEW", circleci.ResourceEnvVar, "NEW", opAdd}) {
+		t.Errorf("Expected the unchanged env var to be left out of the plan, found %v", actual)
+	}
+
+	settings := config
+	oss := true
+	settings.Settings = &circleci.BuildSettings{OSS: &oss}
+	actual = computePlan(settings, state, provisionOptions{})
+	if last := actual[len(actual)-1]; last != (Action{"gh/test/test/settings/build-settings", circleci.ResourceSettings, "build settings", opUpdate}) {
+		t.Errorf("Expected the plan to end with a build settings update, found %v", actual)
+	}
+
+	for _, tc := range testCases {
+		actual := computePlan(config, tc.state, tc.opts)
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected plan %v, found %v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestPlanPrint(t *testing.T) {
+	var out bytes.Buffer
+	Plan{{"gh/test/test/envvar/NEW", circleci.ResourceEnvVar, "NEW", opAdd}, {"gh/test/test/build", circleci.ResourceBuild, "", opTrigger}}.Print(&out, "owner/project")
+	expected := "Plan for project owner/project:\n  + envvar NEW\n  > trigger build\n1 to add, 0 to update, 0 to remove\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, found %q", expected, out.String())
+	}
+
+	out.Reset()
+	Plan{}.Print(&out, "owner/project")
+	if !strings.HasPrefix(out.String(), "No changes") {
+		t.Errorf("Expected no changes, found %q", out.String())
+	}
+}
+
+func TestReadSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	single := filepath.Join(dir, "single.json")
+	ioutil.WriteFile(single, []byte(`{"project": "test/test", "following": true, "envVars": {"A": "xxxxtext"},
+		"sshKeys": [{"hostname": "github.com", "fingerprint": "aa"}], "featureFlags": {"oss": true}}`), 0600)
+	list := filepath.Join(dir, "list.yml")
+	ioutil.WriteFile(list, []byte("- project: test/a\n  following: true\n- project: test/b\n"), 0600)
+
+	states, err := readSnapshots(single)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := map[string]ProjectState{"test/test": {Following: true, EnvVars: map[string]string{"A": "xxxxtext"},
+		SSHKeys: []circleci.SSHKey{{Hostname: "github.com", Fingerprint: "aa"}}}}
+	if !reflect.DeepEqual(states, expected) {
+		t.Errorf("Expected %+v, found %+v", expected, states)
+	}
+
+	states, err = readSnapshots(list)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if len(states) != 2 || !states["test/a"].Following || states["test/b"].Following {
+		t.Errorf("Expected test/a followed and test/b not, found %+v", states)
+	}
+
+	ioutil.WriteFile(list, []byte("- following: true\n"), 0600)
+	if _, err := readSnapshots(list); err == nil {
+		t.Error("Expected an error for a state without a project")
+	}
+}
+
+func TestRunPlanOffline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "project.yml")
+	ioutil.WriteFile(config, []byte("vcsType: github\nowner: test\nprojectName: test\nenvVars:\n  A: plaintext\n"), 0600)
+	snapshot := filepath.Join(dir, "snapshot.json")
+	ioutil.WriteFile(snapshot, []byte(`{"project": "test/other"}`), 0600)
+
+	// No token is needed offline, but the snapshot must have the project.
+	os.Unsetenv("CIRCLECI_TOKEN")
+	err = runPlan([]string{"-offline", "-state", snapshot, "-no-template", config})
+	if err == nil || !strings.Contains(err.Error(), "no state of project test/test") {
+		t.Errorf("Expected a missing project error, found: %v", err)
+	}
+	ioutil.WriteFile(snapshot, []byte(`{"project": "test/test", "following": true, "envVars": {"A": "xxxxtext"}}`), 0600)
+	if err := runPlan([]string{"-offline", "-state", snapshot, "-no-template", config}); err != nil {
+		t.Errorf("Expected no error, found: %v", err)
+	}
+	if err := runPlan([]string{"-offline", config}); err == nil {
+		t.Error("Expected an error for -offline without -state")
+	}
+}
+
+func TestWritePlans(t *testing.T) {
+	plans := []planDocument{{Project: "test/test", ID: "gh/test/test", Actions: Plan{
+		{"gh/test/test/envvar/NEW", circleci.ResourceEnvVar, "NEW", opAdd},
+	}}}
+	var out bytes.Buffer
+	if err := writePlans(&out, outputJSON, plans); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"id": "gh/test/test/envvar/NEW"`) {
+		t.Errorf("Expected the action's ID in the document, found:\n%s", out.String())
+	}
+	if err := writePlans(&out, "xml", plans); err == nil {
+		t.Error("Expected an error writing an unknown format")
+	}
+}
+
+func TestComputePlanCanonicalRemovals(t *testing.T) {
+	base := Config{VcsType: "github", Owner: "test", ProjectName: "test"}
+	following := ProjectState{Following: true}
+
+	checkoutKeys := base
+	checkoutKeys.CheckoutKeys = []string{circleci.CheckoutKeyDeploy}
+	checkoutKeysState := following
+	checkoutKeysState.CheckoutKeys = []circleci.CheckoutKey{
+		{Type: circleci.CheckoutKeyDeploy, Fingerprint: "aa"}, {Type: "github-user-key", Fingerprint: "bb"},
+	}
+
+	webhooks := base
+	webhooks.Webhooks = map[string]WebhookConfig{"slack": {URL: "https://example.com", Events: []string{"job-completed"}}}
+	webhooksState := following
+	webhooksState.Webhooks = []circleci.Webhook{{ID: "1", Name: "slack"}, {ID: "2", Name: "slack"}, {ID: "3", Name: "old"}}
+
+	schedules := base
+	schedules.Schedules = map[string]ScheduleConfig{"nightly": {Cron: "0 3 * * *", Branch: "main"}}
+	schedulesState := following
+	schedulesState.Schedules = []circleci.Schedule{{ID: "1", Name: "nightly"}, {ID: "2", Name: "weekly"}}
+
+	for _, tc := range []struct {
+		name     string
+		config   Config
+		state    ProjectState
+		expected Plan
+	}{
+		{"checkout keys", checkoutKeys, checkoutKeysState, Plan{
+			{"gh/test/test/checkout-key/bb", circleci.ResourceCheckoutKey, "user-key (bb)", opRemove},
+		}},
+		{"webhooks", webhooks, webhooksState, Plan{
+			{"gh/test/test/webhook/slack", circleci.ResourceWebhook, "slack", opRemove},
+			{"gh/test/test/webhook/old", circleci.ResourceWebhook, "old", opRemove},
+		}},
+		{"schedules", schedules, schedulesState, Plan{
+			{"gh/test/test/schedule/weekly", circleci.ResourceSchedule, "weekly", opRemove},
+		}},
+	} {
+		actual := computePlan(tc.config, tc.state, provisionOptions{canonical: true})
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected plan %v, found %v", tc.name, tc.expected, actual)
+		}
+		if actual := computePlan(tc.config, tc.state, provisionOptions{}); len(actual) != 0 {
+			t.Errorf("%s: expected nothing to be removed without -canonical, found %v", tc.name, actual)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// defaultProbeParameter is the pipeline parameter given the SSH host to probe
+// if the config names none.
+const defaultProbeParameter = "ssh-probe-host"
+
+// SSHProbe configures the pipelines that check the project's SSH keys
+// authenticate. The project's CircleCI config needs a workflow that runs
+// ssh -T against the host in the parameter when it is set.
+type SSHProbe struct {
+	Parameter string `yaml:"parameter"` // Pipeline parameter given the host, ssh-probe-host if empty
+	Branch    string `yaml:"branch"`    // Branch to run the probes on, the default branch if empty
+}
+
+// probeResult is the outcome of probing one SSH host.
+type probeResult struct {
+	host string
+	url  string
+	err  error // Why the key does not authenticate, nil if it does
+}
+
+// probeSSHKeys triggers a probe pipeline for each host and waits for it,
+// returning which keys authenticate. The probes are run parallelism at a
+// time.
+func probeSSHKeys(ctx context.Context, project circleci.Project, hosts []string, probe SSHProbe, waitOpts waitOptions, parallelism int) []probeResult {
+	parameter := probe.Parameter
+	if parameter == "" {
+		parameter = defaultProbeParameter
+	}
+	results := make([]probeResult, len(hosts))
+	runParallel(parallelism, len(hosts), func(i int) error {
+		results[i].host = hosts[i]
+		logInfof("Probing the SSH key of %s for project %s", hosts[i], project.FullName())
+		opts := circleci.TriggerOptions{Branch: probe.Branch, Parameters: map[string]interface{}{parameter: hosts[i]}}
+		build, err := project.Trigger(ctx, opts)
+		if err != nil {
+			results[i].err = fmt.Errorf("could not trigger probe: %v", err)
+			return nil
+		}
+		results[i].url = build.URL
+		results[i].err = waitForBuild(ctx, project, build, waitOpts)
+		return nil
+	})
+	return results
+}
+
+// checkProbeResults logs the outcome of each probe and returns an error
+// naming the hosts whose keys do not authenticate.
+func checkProbeResults(project circleci.Project, results []probeResult) error {
+	var failed []string
+	for _, result := range results {
+		if result.err != nil {
+			logWarnf("SSH key of %s does not authenticate for project %s: %v", result.host, project.FullName(), result.err)
+			failed = append(failed, result.host)
+			continue
+		}
+		logInfof("SSH key of %s authenticates for project %s: %s", result.host, project.FullName(), result.url)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("the SSH keys of %s do not authenticate for project %s", strings.Join(failed, ", "), project.FullName())
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+func TestProbeSSHKeys(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/project/gh/test/test/pipeline":
+			body, _ := ioutil.ReadAll(r.Body)
+			if !strings.Contains(string(body), `"parameters":{"probe-host":`) {
+				t.Errorf("Unexpected trigger %s", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			if strings.Contains(string(body), "github.com") {
+				io.WriteString(w, `{"id": "ok", "number": 1}`)
+			} else {
+				io.WriteString(w, `{"id": "bad", "number": 2}`)
+			}
+		case "/pipeline/ok/workflow":
+			io.WriteString(w, `{"items": [{"name": "ssh-probe", "status": "success"}]}`)
+		case "/pipeline/bad/workflow":
+			io.WriteString(w, `{"items": [{"name": "ssh-probe", "status": "failed"}]}`)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	client := circleci.NewHTTPClient(svr.URL, nil)
+	client.HTTP = svr.Client()
+	project := circleci.NewProjectV2WithClient("gh", "test", "test", client, client)
+	hosts := []string{"example.com", "github.com"}
+	results := probeSSHKeys(context.Background(), project, hosts, SSHProbe{Parameter: "probe-host"},
+		waitOptions{interval: time.Millisecond}, 2)
+
+	if len(results) != 2 || results[0].host != "example.com" || results[1].host != "github.com" {
+		t.Fatalf("Unexpected results %+v", results)
+	}
+	if results[0].err == nil {
+		t.Error("Expected the key of example.com not to authenticate")
+	}
+	if results[1].err != nil {
+		t.Errorf("Expected the key of github.com to authenticate, found: %v", results[1].err)
+	}
+	err := checkProbeResults(project, results)
+	if err == nil || !strings.Contains(err.Error(), "example.com") || strings.Contains(err.Error(), "github.com") {
+		t.Errorf("Expected an error naming only example.com, found: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// defaultProfile is the profile used when -profile is not given.
+const defaultProfile = "default"
+
+// Profile is a named set of credentials and the installation they are for,
+// read from the credentials file.
+type Profile struct {
+	Token        string `yaml:"token"`
+	TokenFile    string `yaml:"tokenFile"`    // File holding the token, instead of token
+	TokenCommand string `yaml:"tokenCommand"` // Shell command printing the token, instead of token
+	OrgToken     string `yaml:"orgToken"`
+	APIURL       string `yaml:"apiURL"`
+	Platform     string `yaml:"platform"`
+}
+
+// profileFlags are the flags a profile can set, with the env vars that
+// otherwise give them.
+var profileFlags = map[string]string{
+	"token":         "CIRCLECI_TOKEN",
+	"token-file":    "CIRCLECI_TOKEN_FILE",
+	"token-command": "CIRCLECI_TOKEN_COMMAND",
+	"org-token":     "CIRCLECI_ORG_TOKEN",
+	"api-url":       "CIRCLECI_API_URL",
+	"platform":      "CIRCLECI_PLATFORM",
+}
+
+// tokenFlags are the flags giving the API token, only one of which is used.
+var tokenFlags = []string{"token", "token-file", "token-command"}
+
+// flags returns the flags the profile sets, keyed by name.
+func (p Profile) flags() map[string]string {
+	flags := map[string]string{
+		"token":         p.Token,
+		"token-file":    p.TokenFile,
+		"token-command": p.TokenCommand,
+		"org-token":     p.OrgToken,
+		"api-url":       p.APIURL,
+		"platform":      p.Platform,
+	}
+	for name, value := range flags {
+		if value == "" {
+			delete(flags, name)
+		}
+	}
+	return flags
+}
+
+// credentialsFile returns the path of the credentials file,
+// $CIRCLECI_CREDENTIALS_FILE or ~/.circleci-provisioning/credentials.
+func credentialsFile(getenv func(string) string) (string, error) {
+	if file := getenv("CIRCLECI_CREDENTIALS_FILE"); file != "" {
+		return file, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".circleci-provisioning", "credentials"), nil
+}
+
+// readProfiles reads the profiles of the credentials file, keyed by name,
+// warning if other users can read it.
+func readProfiles(file string) (map[string]Profile, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	warnReadableByOthers(file, info)
+	profiles := make(map[string]Profile)
+	err = readYAML(file, &profiles)
+	if err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// applyProfile sets the flags of the named profile, or of the default
+// profile if name is empty and the credentials file has one. Flags given on
+// the command line are left alone, as are flags set by env vars unless the
+// profile was named.
+func applyProfile(fs *flag.FlagSet, name string, getenv func(string) string) error {
+	explicit := name != ""
+	if !explicit {
+		name = defaultProfile
+	}
+	file, err := credentialsFile(getenv)
+	if err != nil && !explicit {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not find the credentials file: %v", err)
+	}
+	profiles, err := readProfiles(file)
+	if os.IsNotExist(err) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read credentials file %s: %v", file, err)
+	}
+	profile, ok := profiles[name]
+	if !ok && !explicit {
+		return nil
+	}
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown profile %q in %s, expected one of %s", name, file, strings.Join(names, ", "))
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !explicit {
+		for flagName, env := range profileFlags {
+			if getenv(env) != "" {
+				set[flagName] = true
+			}
+		}
+	}
+	flags := profile.flags()
+	tokenSet, profileToken := false, false
+	for _, flagName := range tokenFlags {
+		tokenSet = tokenSet || set[flagName]
+		profileToken = profileToken || flags[flagName] != ""
+	}
+	for _, flagName := range tokenFlags {
+		switch {
+		case tokenSet:
+			// The token given is used with the profile's installation.
+			delete(flags, flagName)
+		case profileToken && flags[flagName] == "":
+			// Only the profile's source of the token is used.
+			flags[flagName] = ""
+		}
+	}
+	for _, flagName := range sortedKeys(flags) {
+		if set[flagName] || fs.Lookup(flagName) == nil {
+			continue
+		}
+		err := fs.Set(flagName, flags[flagName])
+		if err != nil {
+			return fmt.Errorf("invalid %s in profile %s: %v", flagName, name, err)
+		}
+	}
+	return nil
+}
+
+// warnReadableByOthers warns if other users can read the file, which holds
+// credentials.
+func warnReadableByOthers(file string, info os.FileInfo) {
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		logWarnf("%s can be read by other users, it should only be readable by you (chmod 600)", file)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "credentials")
+	err = ioutil.WriteFile(file, []byte(`
+default:
+  token: default-token
+acme:
+  tokenCommand: pass show acme/circleci
+  apiURL: https://circleci.acme.internal
+  platform: server-3
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		profile string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "default profile",
+			want: map[string]string{"token": "default-token", "api-url": ""}},
+		{name: "default profile after env vars", env: map[string]string{"CIRCLECI_TOKEN": "env-token"},
+			want: map[string]string{"token": "env-token"}},
+		{name: "named profile over env vars", profile: "acme", env: map[string]string{"CIRCLECI_TOKEN": "env-token"},
+			want: map[string]string{"token": "", "token-command": "pass show acme/circleci",
+				"api-url": "https://circleci.acme.internal", "platform": "server-3"}},
+		{name: "named profile after flags", profile: "acme", args: []string{"-token", "flag-token"},
+			want: map[string]string{"token": "flag-token", "token-command": "",
+				"api-url": "https://circleci.acme.internal"}},
+		{name: "unknown profile", profile: "missing", wantErr: `unknown profile "missing"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"CIRCLECI_CREDENTIALS_FILE": file}
+			for name, value := range tt.env {
+				env[name] = value
+			}
+			getenv := func(name string) string { return env[name] }
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			for name := range profileFlags {
+				fs.String(name, env[profileFlags[name]], "")
+			}
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applyProfile(fs, tt.profile, getenv)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected an error containing %q, found: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, found: %v", err)
+			}
+			for name, value := range tt.want {
+				if found := fs.Lookup(name).Value.String(); found != value {
+					t.Errorf("Expected -%s to be %q, found %q", name, value, found)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyProfileWithoutFile(t *testing.T) {
+	getenv := func(string) string { return filepath.Join(os.TempDir(), "no-such-credentials") }
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("token", "", "")
+	if err := applyProfile(fs, "", getenv); err != nil {
+		t.Errorf("Expected no error without a credentials file, found: %v", err)
+	}
+	if err := applyProfile(fs, "acme", getenv); err == nil {
+		t.Error("Expected an error naming a profile without a credentials file")
+	}
+}
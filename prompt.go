@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// prompter asks the user for values during interactive operations.
+type prompter interface {
+	Prompt(question string) (string, error)
+	PromptSecret(question string) (string, error)
+}
+
+// terminalPrompter prompts on stderr and reads answers from stdin.
+type terminalPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newTerminalPrompter() *terminalPrompter {
+	return &terminalPrompter{bufio.NewReader(os.Stdin), os.Stderr}
+}
+
+// Prompt asks the question and returns the trimmed answer.
+func (p *terminalPrompter) Prompt(question string) (string, error) {
+	fmt.Fprintf(p.out, "%s: ", question)
+	answer, err := p.in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// PromptSecret asks the question without echoing the answer when stdin is a
+// terminal.
+func (p *terminalPrompter) PromptSecret(question string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return p.Prompt(question)
+	}
+	fmt.Fprintf(p.out, "%s: ", question)
+	answer, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(p.out)
+	if err != nil {
+		return "", err
+	}
+	return string(answer), nil
+}
+
+// confirm asks a yes/no question, treating anything but yes as no.
+func confirm(prompt prompter, question string) (bool, error) {
+	answer, err := prompt.Prompt(question + " [y/N]")
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
+
+// confirmFunc asks before the removals are made to a project, failing unless
+// they are confirmed.
+type confirmFunc func(project string, removals []string) error
+
+// removalConfirmer asks before resources are removed from a project, one
+// project at a time so that projects provisioned in parallel do not
+// interleave their questions.
+type removalConfirmer struct {
+	mu     sync.Mutex
+	prompt prompter
+	out    io.Writer
+}
+
+func newRemovalConfirmer() *removalConfirmer {
+	return &removalConfirmer{prompt: newTerminalPrompter(), out: os.Stderr}
+}
+
+// confirm prints the removals and fails unless the user agrees to them.
+func (c *removalConfirmer) confirm(project string, removals []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.out, "This will make the following changes to %s:\n", project)
+	for _, removal := range removals {
+		fmt.Fprintf(c.out, "  %s\n", removal)
+	}
+	ok, err := confirm(c.prompt, "Continue?")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("changes to %s were not confirmed, pass -yes to make them without asking", project)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestProvisionConfirmsRemovals(t *testing.T) {
+	for _, answer := range []string{"n", "y"} {
+		svr := newFakeCircleCI(map[string]circlecitest.Response{
+			"POST /project/git/test/test/follow":     {Status: http.StatusCreated, Body: `{"following": true}`},
+			"GET /project/git/test/test/envvar":      {Status: http.StatusOK, Body: circlecitest.EnvVars},
+			"GET " + circlecitest.SettingsPath:       {Status: http.StatusOK, Body: circlecitest.Settings},
+			"DELETE /project/git/test/test/envvar/B": {Status: http.StatusOK, Body: `{"message": "ok"}`},
+			"POST /project/git/test/test/envvar":     {Status: http.StatusCreated, Body: `{"name": "A", "value": "xxxx1"}`},
+		})
+		var out bytes.Buffer
+		confirmer := &removalConfirmer{prompt: fakePrompter{"Continue": answer}, out: &out}
+		config := Config{EnvVars: EnvVars{"A": "1"}, ProtectedSSHKeys: []string{"github.com", "example.com"}}
+		err := provision(context.Background(), svr.project(), config,
+			provisionOptions{canonical: true, confirm: confirmer.confirm})
+		svr.Close()
+
+		if !strings.Contains(out.String(), "remove envvar B") {
+			t.Errorf("Expected the removal of B to be shown, found:\n%s", out.String())
+		}
+		removed := false
+		for _, req := range svr.Requests() {
+			removed = removed || strings.HasPrefix(req, "DELETE ")
+		}
+		if answer == "n" && (err == nil || removed) {
+			t.Errorf("Expected nothing to be removed without confirmation, found %v (%v)", svr.Requests(), err)
+		}
+		if answer == "y" && !removed {
+			t.Errorf("Expected B to be removed once confirmed, found %v (%v)", svr.Requests(), err)
+		}
+	}
+}
+
+func TestProvisionConfirmsCheckoutKeyRemovals(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"POST /project/git/test/test/follow": {Status: http.StatusCreated, Body: `{"following": true}`},
+		"GET /project/git/test/test/envvar":  {Status: http.StatusOK, Body: `[]`},
+		"GET " + circlecitest.SettingsPath:   {Status: http.StatusOK, Body: `{"ssh_keys": []}`},
+		"GET /project/git/test/test/checkout-key": {Status: http.StatusOK,
+			Body: `[{"type": "deploy-key", "fingerprint": "aa"}, {"type": "github-user-key", "fingerprint": "bb"}]`},
+		"DELETE /project/git/test/test/checkout-key/bb": {Status: http.StatusOK, Body: `{"message": "ok"}`},
+	})
+	defer svr.Close()
+	var out bytes.Buffer
+	confirmer := &removalConfirmer{prompt: fakePrompter{"Continue": "n"}, out: &out}
+	config := Config{CheckoutKeys: []string{"deploy-key"}}
+	err := provision(context.Background(), svr.project(), config,
+		provisionOptions{canonical: true, confirm: confirmer.confirm})
+
+	if !strings.Contains(out.String(), "remove checkout-key user-key (bb)") {
+		t.Errorf("Expected the removal of the user key to be shown, found:\n%s", out.String())
+	}
+	for _, req := range svr.Requests() {
+		if strings.HasPrefix(req, "DELETE ") {
+			t.Errorf("Expected nothing to be removed without confirmation, found %s (%v)", req, err)
+		}
+	}
+	if err == nil {
+		t.Error("Expected an error once the removal was declined")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// protectsEnvVar reports whether canonical mode must leave the env var in
+// place even though it is not configured, e.g. as another system sets it.
+func (c Config) protectsEnvVar(name string) bool {
+	return containsName(c.ProtectedEnvVars, name)
+}
+
+// protectsSSHKey reports whether canonical mode must leave the SSH keys for
+// hostname in place even though they are not configured.
+func (c Config) protectsSSHKey(hostname string) bool {
+	return containsName(c.ProtectedSSHKeys, hostname)
+}
+
+// keptEnvVars returns the env vars canonical mode keeps: the configured ones
+// and the protected ones, which have empty values.
+func (c Config) keptEnvVars() map[string]string {
+	kept := make(map[string]string)
+	for _, name := range c.ProtectedEnvVars {
+		kept[name] = ""
+	}
+	for name, value := range c.EnvVars {
+		kept[name] = value
+	}
+	return kept
+}
+
+// removeStaleEnvVars removes every env var of the project that the config
+// neither sets nor protects.
+func removeStaleEnvVars(ctx context.Context, project circleci.Project, config Config) error {
+	envVars, err := project.Getenvs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(envVars) {
+		if _, ok := config.EnvVars[name]; ok {
+			continue
+		}
+		if config.protectsEnvVar(name) {
+			logInfof("Keeping protected environment variable %s of project %s", name, project.FullName())
+			continue
+		}
+		logInfof("Removing environment variable %s from project %s", name, project.FullName())
+		err = project.Deleteenv(ctx, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staleSSHKeys returns the keys canonical mode removes: those of hostnames
+// the config neither sets nor protects, and those of configured hostnames
+// other than the configured key and previous keys still in their rotation
+// window. Configured hostnames without a fingerprint, such as generated
+// ones, are left alone, as there is no way to tell which of their keys is
+// wanted.
+func staleSSHKeys(keys []circleci.SSHKey, config Config, fingerprints map[string]string,
+	overlapping map[string][]string) []circleci.SSHKey {
+	var stale []circleci.SSHKey
+	kept := make(map[string]bool)
+	for _, key := range keys {
+		if config.protectsSSHKey(key.Hostname) {
+			continue
+		}
+		if _, ok := config.SSHKeys[key.Hostname]; !ok && !config.generatesSSHKey(key.Hostname) {
+			stale = append(stale, key)
+			continue
+		}
+		fingerprint, ok := fingerprints[key.Hostname]
+		switch {
+		case !ok || containsName(overlapping[key.Hostname], key.Fingerprint):
+		case key.Fingerprint == fingerprint && !kept[key.Hostname]:
+			kept[key.Hostname] = true
+		default:
+			stale = append(stale, key)
+		}
+	}
+	return stale
+}
+
+// removeStaleSSHKeys removes the stale SSH keys of the project, once the
+// configured ones have been added.
+func removeStaleSSHKeys(ctx context.Context, project circleci.Project, config Config, now time.Time) error {
+	keys, err := project.GetSSHKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get SSH keys: %v", err)
+	}
+	fingerprints, err := configuredFingerprints(config.SSHKeys)
+	if err != nil {
+		logWarnf("Keeping every SSH key of the configured hostnames of project %s: %v", project.FullName(), err)
+	}
+	overlapping, err := overlappingFingerprints(config, now)
+	if err != nil {
+		return err
+	}
+	for _, key := range staleSSHKeys(keys, config, fingerprints, overlapping) {
+		logInfof("Removing SSH key %s for %s from project %s", key.Fingerprint, key.Hostname, project.FullName())
+		err = project.DeleteSSHKey(ctx, key.Hostname, key.Fingerprint)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestCleanProjectKeepsProtected(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"GET /project/git/test/test/envvar":      {Status: http.StatusOK, Body: circlecitest.EnvVars},
+		"DELETE /project/git/test/test/envvar/B": {Status: http.StatusOK, Body: `{"message": "ok"}`},
+		"GET " + circlecitest.SettingsPath:       {Status: http.StatusOK, Body: circlecitest.Settings},
+		"DELETE /project/git/test/test/ssh-key":  {Status: http.StatusOK, Body: `{"message": "ok"}`},
+	})
+	defer svr.Close()
+
+	config := Config{ProtectedEnvVars: []string{"A"}, ProtectedSSHKeys: []string{"github.com"}}
+	err := cleanProject(context.Background(), svr.project(), config, false)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := []string{
+		"GET /project/git/test/test/envvar",
+		"DELETE /project/git/test/test/envvar/B",
+		"GET " + circlecitest.SettingsPath,
+		`DELETE /project/git/test/test/ssh-key {"hostname":"example.com","fingerprint":"cc"}`,
+	}
+	if !reflect.DeepEqual(svr.Requests(), expected) {
+		t.Errorf("Expected requests %q, found %q", expected, svr.Requests())
+	}
+}
+
+func TestComputePlanKeepsProtected(t *testing.T) {
+	config := Config{Owner: "test", ProjectName: "test",
+		ProtectedEnvVars: []string{"DEPLOY_TOKEN"}, ProtectedSSHKeys: []string{"deploy.example.com"}}
+	state := ProjectState{
+		Following: true,
+		EnvVars:   map[string]string{"DEPLOY_TOKEN": "xxxx", "STALE": "xxxx"},
+		SSHKeys: []circleci.SSHKey{
+			{Hostname: "deploy.example.com", Fingerprint: "aa:bb"},
+			{Hostname: "old.example.com", Fingerprint: "cc:dd"},
+		},
+	}
+	plan := computePlan(config, state, provisionOptions{canonical: true})
+	expected := Plan{
+		{"test/test/envvar/STALE", circleci.ResourceEnvVar, "STALE", opRemove},
+		{"test/test/ssh-key/old.example.com", circleci.ResourceSSHKey, "old.example.com (cc:dd)", opRemove},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("Expected plan %v, found %v", expected, plan)
+	}
+}
+
+func TestProvisionCanonicalRemovesStaleLast(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, test := range []struct{ setFails, managed bool }{{false, false}, {true, false}, {false, true}, {true, true}} {
+		setFails := test.setFails
+		opts := provisionOptions{canonical: true}
+		if test.managed {
+			opts.managed, err = OpenManagedState(filepath.Join(dir, fmt.Sprintf("state%d.json", i)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := opts.managed.SetProject("test/test", ManagedResources{EnvVars: []string{"B"}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		responses := map[string]circlecitest.Response{
+			"POST /project/git/test/test/follow":     {Status: http.StatusCreated, Body: `{"following": true}`},
+			"GET /project/git/test/test/envvar":      {Status: http.StatusOK, Body: circlecitest.EnvVars},
+			"GET " + circlecitest.SettingsPath:       {Status: http.StatusOK, Body: circlecitest.Settings},
+			"DELETE /project/git/test/test/envvar/B": {Status: http.StatusOK, Body: `{"message": "ok"}`},
+		}
+		if !setFails {
+			responses["POST /project/git/test/test/envvar"] = circlecitest.Response{Status: http.StatusCreated,
+				Body: `{"name": "C", "value": "xxxx1"}`}
+		}
+		svr := newFakeCircleCI(responses)
+		config := Config{EnvVars: EnvVars{"A": "1", "C": "1"}, ProtectedSSHKeys: []string{"github.com", "example.com"}}
+		err := provision(context.Background(), svr.project(), config, opts)
+		svr.Close()
+
+		set, removed := -1, -1
+		for i, req := range svr.Requests() {
+			if strings.HasPrefix(req, "POST /project/git/test/test/envvar ") && set < 0 {
+				set = i
+			} else if strings.HasPrefix(req, "DELETE /project/git/test/test/envvar/B") {
+				removed = i
+			}
+		}
+		if setFails && (err == nil || removed >= 0) {
+			t.Errorf("Expected nothing to be removed once setting an env var failed (managed %v), found %v (%v)",
+				test.managed, svr.Requests(), err)
+		}
+		if !setFails && (err != nil || set < 0 || removed < set) {
+			t.Errorf("Expected B to be removed after the env vars are set (managed %v), found %v (%v)",
+				test.managed, svr.Requests(), err)
+		}
+	}
+}
+
+func TestStaleSSHKeys(t *testing.T) {
+	keys := []circleci.SSHKey{
+		{Hostname: "github.com", Fingerprint: "aa"},
+		{Hostname: "github.com", Fingerprint: "bb"},
+		{Hostname: "github.com", Fingerprint: "cc"},
+		{Hostname: "example.com", Fingerprint: "dd"},
+		{Hostname: "old.example.com", Fingerprint: "ee"},
+		{Hostname: "deploy.example.com", Fingerprint: "ff"},
+	}
+	config := Config{
+		SSHKeys:          map[string]string{"github.com": "github", "example.com": "example"},
+		ProtectedSSHKeys: []string{"deploy.example.com"},
+	}
+	stale := staleSSHKeys(keys, config, map[string]string{"github.com": "bb"}, map[string][]string{"github.com": {"cc"}})
+	expected := []circleci.SSHKey{{Hostname: "github.com", Fingerprint: "aa"}, {Hostname: "old.example.com", Fingerprint: "ee"}}
+	if !reflect.DeepEqual(stale, expected) {
+		t.Errorf("Expected stale keys %v, found %v", expected, stale)
+	}
+}
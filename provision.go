@@ -0,0 +1,723 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// provisionOptions controls the optional steps of provisioning a project.
+type provisionOptions struct {
+	canonical   bool                    // Remove anything not described in the config
+	quarantine  bool                    // In canonical mode, quarantine env vars instead of removing them
+	trigger     bool                    // Trigger a build once provisioned
+	triggerOpts circleci.TriggerOptions // Branch or tag of the triggered build
+	insights    insightsGate            // Holds back triggering projects that are already failing
+	wait        bool                    // Wait for the triggered build to succeed
+	waitOpts    waitOptions             // How to wait for the triggered build
+	probe       bool                    // Check the SSH keys authenticate with probe pipelines
+	approval    ApprovalConfig          // Approval webhook gating canonical runs, unless the config sets one
+	confirm     confirmFunc             // Asks before canonical mode removes anything, if set
+	rollback    bool                    // Restore what canonical mode removed if provisioning fails, without asking
+	managed     *ManagedState           // Resources canonical mode may remove, everything if not set
+	report      *Report                 // Where to record the outcome of each resource, if set
+	parallelism int                     // API calls made at once, e.g. to set env vars or provision projects
+	history     *History                // Where to record the run, if set
+	webhooks    hookLister              // Checks the repository's CircleCI webhook once followed, if set
+	deployKeys  deployKeyRegistry       // Registers SSH keys as deploy keys of the GitHub repository, if set
+}
+
+// provision follows the project and brings it in line with config.
+func provision(ctx context.Context, project circleci.Project, config Config, opts provisionOptions) (err error) {
+	if opts.report.Cancelled() {
+		return errCancelled
+	}
+	opts.report.Started(project.FullName(), project.Slug())
+	defer func() { opts.report.Finished(project.FullName(), err) }()
+	if opts.history != nil {
+		defer func() {
+			entry := HistoryEntry{Time: time.Now(), Project: project.FullName(), ID: project.Slug(), Success: err == nil,
+				Failed: opts.report.FailedIDs(project.FullName())}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			if herr := opts.history.Append(entry); herr != nil {
+				logWarnf("Could not record history for project %s: %v", project.FullName(), herr)
+			}
+		}()
+	}
+
+	logInfof("Following %s", project.FullName())
+	err = project.Follow(ctx)
+	opts.report.Record(project.FullName(), circleci.ResourceFollow, "", outcomeUpdated, err)
+	if err != nil {
+		return fmt.Errorf("could not follow %s: %v", project.FullName(), err)
+	}
+
+	if opts.webhooks != nil {
+		if isGitHub(config.VcsType) {
+			logInfof("Verifying the CircleCI webhook of %s", project.FullName())
+			err = verifyWebhook(ctx, opts.webhooks, config.Owner, config.ProjectName)
+			if err != nil {
+				return err
+			}
+		} else {
+			logWarnf("Not verifying the webhook of %s, only GitHub is supported", project.FullName())
+		}
+	}
+
+	if approval := opts.approval.merge(config.Approval); opts.canonical && (approval.URL != "" || opts.confirm != nil) {
+		changes, err := canonicalChanges(ctx, project, config, opts)
+		if err == nil && opts.confirm != nil && len(changes) > 0 {
+			err = opts.confirm(project.FullName(), changes)
+		}
+		if err == nil && approval.URL != "" {
+			err = requestApproval(ctx, approval, os.Getenv, project.FullName(), "canonical", changes)
+		}
+		if err != nil {
+			return fmt.Errorf("could not get approval to make project %s canonical: %v", project.FullName(), err)
+		}
+	}
+
+	// Only a project left partly provisioned is rolled back, not one whose
+	// build failed afterwards.
+	provisioned := false
+	if opts.canonical && (opts.rollback || opts.confirm != nil) {
+		if before := snapshotForRollback(ctx, project); before != nil {
+			defer func() {
+				if err != nil && !provisioned {
+					err = rollbackOnFailure(ctx, project, config, *before, opts, err)
+				}
+			}()
+		}
+	}
+
+	// Failures from here on are collected rather than stopping the run, so
+	// that one bad resource does not leave the rest unprovisioned.
+	var errs []error
+	name := project.FullName()
+	logInfof("Setting environment variables for project %s", name)
+	existing, err := project.Getenvs(ctx)
+	if err != nil {
+		logWarnf("Could not get environment variables of project %s, setting them all: %v", name, err)
+	}
+	var hashes *EnvHashes
+	if opts.history != nil {
+		hashes, err = opts.history.EnvHashes(name)
+		if err != nil {
+			logWarnf("%v", err)
+		}
+	}
+	err = setEnvVars(ctx, project, config.EnvVars, existing, hashes, opts.parallelism, opts.report)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("could not set environment variables for project %s: %v", name, err))
+	}
+	if hashes != nil {
+		if herr := opts.history.SaveEnvHashes(name, hashes); herr != nil {
+			logWarnf("%v", herr)
+		}
+	}
+
+	logInfof("Adding ssh keys for project %s", name)
+	err = addSSHKeys(ctx, project, config.SSHKeys, opts.report)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("could not add SSH Keys for project %s: %v", name, err))
+	}
+	generated, err := generateSSHKeys(ctx, project, config, opts.report)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("could not generate SSH keys for project %s: %v", name, err))
+	}
+	if len(config.DeployKeys) > 0 {
+		err = registerDeployKeys(ctx, opts.deployKeys, project, config, generated, opts.report)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not register deploy keys for project %s: %v", name, err))
+		}
+	}
+	err = rotateSSHKeys(ctx, project, config, time.Now(), opts.report)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("could not rotate SSH keys for project %s: %v", name, err))
+	}
+
+	// Stale resources are only removed or quarantined once the config's are
+	// in place, so that builds never run without them. Managed resources stay
+	// managed until they are removed from the project.
+	managed := opts.managed.Project(name)
+	remaining := managed
+	switch {
+	case !opts.canonical || opts.report.Cancelled():
+	case len(errs) > 0:
+		logWarnf("Not removing stale resources from project %s, its configured ones could not all be set", name)
+	case opts.managed != nil:
+		logInfof("Making config canonical for project %s, removing only managed resources", name)
+		if opts.quarantine {
+			remaining.EnvVars, err = quarantineManaged(ctx, project, config, managed)
+			if err == nil {
+				var pruned ManagedResources
+				pruned, err = pruneManaged(ctx, project, config, ManagedResources{SSHKeys: managed.SSHKeys})
+				remaining.SSHKeys = pruned.SSHKeys
+			}
+		} else {
+			remaining, err = pruneManaged(ctx, project, config, managed)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not make config canonical for project %s: %v", name, err))
+		}
+	default:
+		logInfof("Making config canonical for project %s", name)
+		if opts.quarantine {
+			_, err = quarantineEnvVars(ctx, project, config.keptEnvVars(), time.Now())
+			if err != nil {
+				errs = append(errs, fmt.Errorf("could not make config canonical for project %s: %v", name, err))
+				break
+			}
+		}
+		err = cleanProject(ctx, project, config, opts.quarantine)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not make config canonical for project %s: %v", name, err))
+		}
+	}
+
+	if opts.managed != nil {
+		err = opts.managed.SetProject(name, managedResources(config, managed, remaining))
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// A cancelled run stops between steps, so the steps so far are complete.
+	if opts.report.Cancelled() {
+		return joinErrors(append(errs, errCancelled))
+	}
+
+	if len(config.CheckoutKeys) > 0 {
+		logInfof("Managing checkout keys for project %s", name)
+		err = ensureCheckoutKeys(ctx, project, config.CheckoutKeys, opts.canonical)
+		opts.report.Record(name, circleci.ResourceCheckoutKey, "", outcomeUpdated, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not manage checkout keys for project %s: %v", name, err))
+		}
+	}
+
+	if flags := config.buildSettingsFlags(); len(flags) > 0 {
+		logInfof("Updating build settings for project %s", name)
+		err = project.SetFeatureFlags(ctx, flags)
+		opts.report.Record(name, circleci.ResourceSettings, "build settings", outcomeUpdated, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not update build settings for project %s: %v", name, err))
+		}
+	}
+
+	if config.Integrations.Jira != nil {
+		logInfof("Configuring Jira integration for project %s", name)
+		err = project.SetJiraIntegration(ctx, *config.Integrations.Jira)
+		opts.report.Record(name, circleci.ResourceSettings, "jira", outcomeUpdated, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not configure Jira integration for project %s: %v", name, err))
+		}
+	}
+
+	if len(config.Webhooks) > 0 {
+		logInfof("Managing webhooks for project %s", name)
+		err = ensureWebhooks(ctx, project, config.Webhooks, opts.canonical)
+		opts.report.Record(name, circleci.ResourceWebhook, "", outcomeUpdated, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not manage webhooks for project %s: %v", name, err))
+		}
+	}
+
+	if len(config.Schedules) > 0 {
+		logInfof("Managing scheduled pipelines for project %s", name)
+		schedules, err := projectSchedules(config)
+		if err == nil {
+			err = ensureSchedules(ctx, project, schedules, opts.canonical)
+		}
+		opts.report.Record(name, circleci.ResourceSchedule, "", outcomeUpdated, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not manage scheduled pipelines for project %s: %v", name, err))
+		}
+	}
+
+	if config.OIDC != nil {
+		logInfof("Managing OIDC claims for project %s", name)
+		err = ensureOIDCClaims(ctx, project, *config.OIDC)
+		opts.report.Record(name, circleci.ResourceOIDC, "", outcomeUpdated, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not manage OIDC claims for project %s: %v", name, err))
+		}
+	}
+
+	if config.Storage != nil {
+		changed, err := ensureStoragePolicy(ctx, project, *config.Storage)
+		outcome := outcomeSkipped
+		if changed {
+			outcome = outcomeUpdated
+		}
+		opts.report.Record(name, circleci.ResourceStorage, "retention", outcome, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not manage storage retention for project %s: %v", name, err))
+		}
+	}
+
+	if opts.report.Cancelled() {
+		errs = append(errs, errCancelled)
+	}
+	if len(errs) > 0 {
+		if opts.trigger {
+			logWarnf("Not triggering a build of %s as it was not fully provisioned", name)
+			opts.report.Record(name, circleci.ResourceBuild, "", outcomeSkipped, nil)
+		}
+		return joinErrors(errs)
+	}
+	provisioned = true
+
+	if opts.trigger && !opts.insights.allowTrigger(ctx, project, opts.triggerOpts.Branch) {
+		opts.report.Record(name, circleci.ResourceBuild, "", outcomeSkipped, nil)
+	} else if opts.trigger {
+		logInfof("Triggering build of %s", name)
+		triggerOpts := opts.triggerOpts
+		triggerOpts.Parameters = config.TriggerParams
+		build, err := project.Trigger(ctx, triggerOpts)
+		opts.report.Record(name, circleci.ResourceBuild, "", outcomeCreated, err)
+		if err != nil {
+			return fmt.Errorf("could not trigger build for project %s: %v", name, err)
+		}
+		logInfof("Triggered build %d of %s: %s", build.Number, name, build.URL)
+		if opts.wait {
+			err = waitForBuild(ctx, project, build, opts.waitOpts)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.probe && len(config.SSHKeys) > 0 {
+		logInfof("Probing SSH keys for project %s", name)
+		results := probeSSHKeys(ctx, project, sortedKeys(config.SSHKeys), config.SSHProbe, opts.waitOpts, opts.parallelism)
+		err = checkProbeResults(project, results)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSSHKeys adds the SSH keys of the project, recording each one in report,
+// and returns the errors of every key that could not be added.
+func addSSHKeys(ctx context.Context, project circleci.Project, sshKeys map[string]string, report *Report) error {
+	var errs []error
+	for _, name := range sortedKeys(sshKeys) {
+		path := sshKeys[name]
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			err = fmt.Errorf("could not read SSH key at path %s: %v", path, err)
+		} else if err = project.AddSSHKey(ctx, name, string(content)); err != nil {
+			err = fmt.Errorf("could not add SSH key %s for project %s: %v", path, project.FullName(), err)
+		}
+		report.Record(project.FullName(), circleci.ResourceSSHKey, name, outcomeCreated, err)
+		errs = append(errs, err)
+	}
+	return joinErrors(errs)
+}
+
+// cleanProject removes the env vars and SSH keys of the project the config
+// neither sets nor protects. Quarantined env vars are left for purge.
+func cleanProject(ctx context.Context, project circleci.Project, config Config, quarantine bool) error {
+	if !quarantine {
+		err := removeStaleEnvVars(ctx, project, config)
+		if err != nil {
+			return fmt.Errorf("there was an error removing environment variables from project %s: %v",
+				project.FullName(), err)
+		}
+	}
+
+	err := removeStaleSSHKeys(ctx, project, config, time.Now())
+	if err != nil {
+		return fmt.Errorf("there was an error removing SSH keys from project %s: %v", project.FullName(), err)
+	}
+	return nil
+}
+
+// setEnvVars sets the env vars of the project that are new or have changed,
+// parallelism at a time, and returns the errors of every one that could not
+// be set. An env var is only left as it is if hashes is set and holds the
+// hash of its value, which is updated for each env var set. Each env var is
+// recorded in report.
+func setEnvVars(ctx context.Context, project circleci.Project, envVars, existing map[string]string, hashes *EnvHashes,
+	parallelism int, report *Report) error {
+	var mu sync.Mutex
+	names := sortedKeys(envVars)
+	errs := runParallel(parallelism, len(names), func(i int) error {
+		if report.Cancelled() {
+			return errCancelled
+		}
+		name, value := names[i], envVars[names[i]]
+		mu.Lock()
+		masked, exists := existing[name]
+		unchanged := envVarUnchanged(name, value, masked, exists, hashes)
+		mu.Unlock()
+		if unchanged {
+			logInfof("Environment variable %s for project %s is unchanged", name, project.FullName())
+			report.Record(project.FullName(), circleci.ResourceEnvVar, name, outcomeSkipped, nil)
+			return nil
+		}
+
+		logInfof("Setting environment variable %s for project %s", name, project.FullName())
+		err := project.Setenv(ctx, name, value)
+		outcome := outcomeCreated
+		if exists {
+			outcome = outcomeUpdated
+		}
+		report.Record(project.FullName(), circleci.ResourceEnvVar, name, outcome, err)
+		if err != nil {
+			return fmt.Errorf("could not set environment variable %s for project %s: %v",
+				name, project.FullName(), err)
+		}
+		if hashes != nil {
+			mu.Lock()
+			hashes.record(name, value)
+			mu.Unlock()
+		}
+		return nil
+	})
+	return joinErrors(collapseCancelled(errs))
+}
+
+// provisionFlags are the flags of provision, shared with apply.
+type provisionFlags struct {
+	canonical     *bool
+	quarantine    *bool
+	trigger       *bool
+	triggerBranch *string
+	triggerTag    *string
+	minSuccess    *float64
+	insightsGate  *string
+	wait          *bool
+	waitOpts      waitFlags
+	probe         *bool
+	parallelism   *int
+	historyDir    *string
+	stateFile     *string
+	dryRun        *bool
+	assumeYes     *bool
+	rollback      *bool
+	githubToken   *string
+
+	mu sync.Mutex // Keeps the plans and questions of configs provisioned in parallel apart
+}
+
+func addProvisionFlags(fs *flag.FlagSet) *provisionFlags {
+	return &provisionFlags{
+		canonical: fs.Bool("canonical", envBool("CIRCLECI_CANONICAL"),
+			"Project should be exactly as described in the config, removing environment variables and ssh keys "+
+				"it does not describe once confirmed"),
+		quarantine: fs.Bool("quarantine", envBool("CIRCLECI_QUARANTINE"),
+			"With -canonical, quarantine environment variables not in the config until purged instead of removing them"),
+		trigger:       fs.Bool("trigger", envBool("CIRCLECI_TRIGGER"), "Trigger a build of the project once it is setup"),
+		triggerBranch: fs.String("trigger-branch", "", "Branch to build with -trigger (default branch if empty)"),
+		triggerTag:    fs.String("trigger-tag", "", "Tag to build with -trigger instead of a branch"),
+		minSuccess: fs.Float64("trigger-min-success-rate", 0,
+			"With -trigger, hold back projects whose recent workflows succeeded less often than this (0 to 1, no check if 0)"),
+		insightsGate: fs.String("trigger-gate", insightsSkip,
+			"What to do with projects below -trigger-min-success-rate: skip them, or flag them and trigger anyway"),
+		wait:     fs.Bool("wait", false, "With -trigger, wait for the build and fail unless it succeeds"),
+		waitOpts: addWaitFlags(fs),
+		probe: fs.Bool("probe-ssh-keys", false,
+			"Trigger a probe pipeline for each SSH host and fail unless its key authenticates"),
+		parallelism: fs.Int("parallelism", 1, "API calls to make at once, e.g. env vars to set or configs to provision"),
+		historyDir: fs.String("history-dir", os.Getenv("CIRCLECI_HISTORY_DIR"),
+			"Record the outcome of each provisioned project in this directory"),
+		stateFile: fs.String("state-file", os.Getenv("CIRCLECI_STATE_FILE"),
+			"Record the resources provisioned in this file, so that -canonical only removes those"),
+		dryRun:    fs.Bool("dry-run", false, "Print the changes that would be made without making them"),
+		assumeYes: addYesFlags(fs),
+		rollback: fs.Bool("rollback-on-failure", envBool("CIRCLECI_ROLLBACK_ON_FAILURE"),
+			"With -canonical, restore the env vars and SSH keys removed if provisioning fails, without asking"),
+		githubToken: fs.String("github-token", os.Getenv("GITHUB_TOKEN"),
+			"GitHub token, used to verify the repository's CircleCI webhook once followed and register deploy keys"),
+	}
+}
+
+// options returns the provisioning options given by the flags.
+func (f *provisionFlags) options(events *EventLog) (provisionOptions, error) {
+	opts := provisionOptions{canonical: *f.canonical, quarantine: *f.quarantine, trigger: *f.trigger,
+		triggerOpts: circleci.TriggerOptions{Branch: *f.triggerBranch, Tag: *f.triggerTag},
+		insights:    insightsGate{minSuccessRate: *f.minSuccess, action: *f.insightsGate},
+		wait:        *f.wait,
+		waitOpts:    f.waitOpts.options(),
+		probe:       *f.probe,
+		report:      NewReport(events),
+		parallelism: *f.parallelism,
+		rollback:    *f.rollback,
+	}
+	if !*f.assumeYes {
+		opts.confirm = newRemovalConfirmer().confirm
+	}
+	if err := opts.insights.validate(); err != nil {
+		return opts, err
+	}
+	if *f.githubToken != "" {
+		github := NewGitHubClient(*f.githubToken)
+		opts.webhooks, opts.deployKeys = github, github
+	}
+	// -dry-run reads the history's env var hashes to plan like a run would,
+	// but records nothing.
+	if *f.historyDir != "" {
+		var err error
+		opts.history, err = OpenHistory(*f.historyDir)
+		if err != nil {
+			return opts, err
+		}
+	}
+	if *f.stateFile != "" {
+		var err error
+		opts.managed, err = OpenManagedState(*f.stateFile)
+		if err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// provisionConfig provisions the project and contexts described by the
+// config read from configFile, or prints the plan for the project with
+// -dry-run.
+func (f *provisionFlags) provisionConfig(s *session, config Config, configFile string, opts provisionOptions) error {
+	s, err := s.forConfig(config)
+	if err != nil {
+		return err
+	}
+	project := s.project(config.VcsType, config.Owner, config.ProjectName)
+	opts.approval = s.approval
+
+	if *f.dryRun {
+		state, err := fetchPlanState(s.ctx, project, config, opts)
+		if err != nil {
+			return err
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		computePlan(config, state, opts).Print(s.stdout, project.FullName())
+		return nil
+	}
+
+	// Contexts are provisioned even if the project was not, as they do not
+	// depend on it.
+	var errs []error
+	err = provision(s.ctx, project, config, opts)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(config.Contexts) > 0 && !opts.report.Cancelled() {
+		logInfof("Provisioning contexts for %s", config.Owner)
+		err = provisionContexts(s.ctx, s.contexts(config.VcsType, config.Owner), config.Contexts, opts.canonical,
+			opts.managed, config.Owner, approver(s.ctx, opts.approval.merge(config.Approval), "canonical"))
+		opts.report.Record(project.FullName(), circleci.ResourceContext, "", outcomeUpdated, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not provision contexts for %s: %v", config.Owner, err))
+		}
+	}
+
+	if len(config.AttachContexts) > 0 && len(errs) == 0 {
+		if !*f.assumeYes {
+			f.mu.Lock()
+		}
+		prompt := newTerminalPrompter()
+		confirmAttach := func(question string) (bool, error) {
+			if *f.assumeYes {
+				return true, nil
+			}
+			return confirm(prompt, question)
+		}
+		err = attachContexts(s.ctx, s.contexts(config.VcsType, config.Owner),
+			s.v2Project(config.VcsType, config.Owner, config.ProjectName),
+			config.AttachContexts, os.Stderr, confirmAttach)
+		if !*f.assumeYes {
+			f.mu.Unlock()
+		}
+		opts.report.Record(project.FullName(), circleci.ResourceContext, "attach", outcomeUpdated, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not attach contexts to %s: %v", project.FullName(), err))
+		}
+		if err == nil && opts.canonical {
+			err = detachContexts(s.ctx, s.contexts(config.VcsType, config.Owner),
+				s.v2Project(config.VcsType, config.Owner, config.ProjectName), config.AttachContexts,
+				approver(s.ctx, opts.approval.merge(config.Approval), "detach-contexts"))
+			opts.report.Record(project.FullName(), circleci.ResourceContext, "detach", outcomeUpdated, err)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("could not detach contexts from %s: %v", project.FullName(), err))
+			}
+		}
+	}
+	if len(errs) == 0 {
+		err = writePipelineValues(config, configFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not write pipeline values of %s: %v", project.FullName(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+
+	logInfof("Project %s has been successfully provisioned using %s", project.FullName(), configFile)
+	return nil
+}
+
+// provisionConfigs provisions the projects of the configs read from
+// configFile. The projects of an org config are independent, so one failing
+// does not stop the rest. They are provisioned opts.parallelism at a time,
+// each making one API call at a time.
+func (f *provisionFlags) provisionConfigs(s *session, configs []Config, configFile string, opts provisionOptions) error {
+	if len(configs) == 1 {
+		return f.provisionConfig(s, configs[0], configFile, opts)
+	}
+	projectOpts := opts
+	projectOpts.parallelism = 1
+	errs := runParallel(opts.parallelism, len(configs), func(i int) error {
+		if opts.report.Cancelled() {
+			return errCancelled
+		}
+		name := configs[i].Owner + "/" + configs[i].ProjectName
+		logInfof("Provisioning project %s of %s (%d of %d)", name, configFile, i+1, len(configs))
+		return f.provisionConfig(s, configs[i], configFile, projectOpts)
+	})
+
+	var failed []string
+	cancelled := 0
+	for i, err := range errs {
+		if err == errCancelled {
+			cancelled++
+		} else if err != nil {
+			logErrorf("%v", err)
+			failed = append(failed, configs[i].Owner+"/"+configs[i].ProjectName)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not provision %d of %d projects of %s: %s", len(failed), len(configs), configFile,
+			strings.Join(failed, ", "))
+	}
+	if cancelled > 0 {
+		return fmt.Errorf("run was cancelled before %d of %d projects of %s were provisioned", cancelled, len(configs),
+			configFile)
+	}
+	return nil
+}
+
+func runProvision(args []string) error {
+	fs := flag.NewFlagSet("provision", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	flags := addProvisionFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	files, err := common.configFiles()
+	if err != nil {
+		return err
+	}
+	opts, err := flags.options(s.events)
+	if err != nil {
+		return err
+	}
+	defer s.printReport(opts.report)
+	s.cancelOnInterrupt(opts.report)
+	if len(files) == 1 {
+		configs, err := s.readConfigs(files[0])
+		if err != nil {
+			return err
+		}
+		return flags.provisionConfigs(s, configs, files[0], opts)
+	}
+
+	// The configs of a directory are independent, so one failing does not
+	// stop the rest. They are provisioned opts.parallelism at a time, each
+	// making one API call at a time so that the calls made at once stay
+	// within opts.parallelism.
+	configOpts := opts
+	configOpts.parallelism = 1
+	errs := runParallel(opts.parallelism, len(files), func(i int) error {
+		if opts.report.Cancelled() {
+			return errCancelled
+		}
+		logInfof("Provisioning %s (%d of %d)", files[i], i+1, len(files))
+		configs, err := s.readConfigs(files[i])
+		if err != nil {
+			return err
+		}
+		return flags.provisionConfigs(s, configs, files[i], configOpts)
+	})
+
+	var failed []string
+	cancelled := 0
+	for i, err := range errs {
+		if err == errCancelled {
+			cancelled++
+		} else if err != nil {
+			logErrorf("%v", err)
+			failed = append(failed, files[i])
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not provision %d of %d configs: %s", len(failed), len(files), strings.Join(failed, ", "))
+	}
+	if cancelled > 0 {
+		return fmt.Errorf("run was cancelled before %d of %d configs were provisioned", cancelled, len(files))
+	}
+	return nil
+}
+
+func runUnfollow(args []string) error {
+	fs := flag.NewFlagSet("unfollow", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "Print the changes that would be made without making them")
+	assumeYes := addYesFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	vcsType, owner, projectName, err := s.projectSlug()
+	if err != nil {
+		return err
+	}
+	project := s.project(vcsType, owner, projectName)
+
+	if *dryRun {
+		unfollow := Action{ID: resourceID(project.Slug(), circleci.ResourceFollow, ""), Resource: circleci.ResourceFollow,
+			Op: opUnfollow}
+		Plan{unfollow}.Print(s.stdout, project.FullName())
+		return nil
+	}
+	if !*assumeYes {
+		err = newRemovalConfirmer().confirm(project.FullName(), []string{"unfollow, stopping its builds"})
+		if err != nil {
+			return err
+		}
+	}
+	err = requestApproval(s.ctx, s.approval, os.Getenv, project.FullName(), "unfollow", nil)
+	if err != nil {
+		return err
+	}
+	logInfof("Unfollowing %s", project.FullName())
+	err = project.Unfollow(s.ctx)
+	if err != nil {
+		return fmt.Errorf("could not unfollow %s: %v", project.FullName(), err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// quarantinePrefix starts the names of the markers recording that an env var
+// was quarantined, as ZZ_DELETED_<unix time>_<name>.
+const quarantinePrefix = "ZZ_DELETED_"
+
+// defaultRetention is how long quarantined env vars are kept before purge
+// removes them.
+const defaultRetention = 7 * 24 * time.Hour
+
+// envVarStore is what quarantining and purging env vars needs of a project.
+type envVarStore interface {
+	FullName() string
+	Getenvs(ctx context.Context) (map[string]string, error)
+	Setenv(ctx context.Context, name, value string) error
+	Deleteenv(ctx context.Context, name string) error
+}
+
+// quarantineMarker returns the name of the marker recording that the env var
+// was quarantined at t.
+func quarantineMarker(name string, t time.Time) string {
+	return fmt.Sprintf("%s%d_%s", quarantinePrefix, t.Unix(), name)
+}
+
+// parseQuarantineMarker returns the env var a marker quarantines and when it
+// was quarantined. ok is false if name is not a marker.
+func parseQuarantineMarker(marker string) (name string, at time.Time, ok bool) {
+	if !strings.HasPrefix(marker, quarantinePrefix) {
+		return "", at, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(marker, quarantinePrefix), "_", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", at, false
+	}
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return "", at, false
+	}
+	return parts[1], time.Unix(seconds, 0), true
+}
+
+// quarantineMarkers returns the quarantine markers among the env vars, keyed
+// by the env var they quarantine.
+func quarantineMarkers(envVars map[string]string) map[string]string {
+	markers := make(map[string]string)
+	for _, marker := range sortedKeys(envVars) {
+		if name, _, ok := parseQuarantineMarker(marker); ok {
+			markers[name] = marker
+		}
+	}
+	return markers
+}
+
+// quarantineEnvVars quarantines the project's env vars that are not in
+// envVars instead of removing them. CircleCI never returns env var values, so
+// they cannot be moved to another name: the env var is left in place and a
+// marker records when it was quarantined, for purge to remove both once the
+// retention window has passed. Env vars back in envVars are released from
+// quarantine. The quarantined env vars are returned.
+func quarantineEnvVars(ctx context.Context, project envVarStore, envVars map[string]string, now time.Time) ([]string, error) {
+	current, err := project.Getenvs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	markers := quarantineMarkers(current)
+
+	var quarantined []string
+	for _, name := range sortedKeys(current) {
+		if _, _, ok := parseQuarantineMarker(name); ok {
+			continue
+		}
+		marker, isQuarantined := markers[name]
+		_, configured := envVars[name]
+		switch {
+		case configured && isQuarantined:
+			logInfof("Releasing environment variable %s of project %s from quarantine", name, project.FullName())
+			err = project.Deleteenv(ctx, marker)
+		case !configured && !isQuarantined:
+			logInfof("Quarantining environment variable %s of project %s", name, project.FullName())
+			err = project.Setenv(ctx, quarantineMarker(name, now), name)
+			quarantined = append(quarantined, name)
+		}
+		if err != nil {
+			return quarantined, fmt.Errorf("could not quarantine environment variable %s: %v", name, err)
+		}
+	}
+	return quarantined, nil
+}
+
+// purgeEnvVars removes the env vars that were quarantined before cutoff,
+// along with their markers. Env vars that are in envVars again only lose
+// their marker. Nothing is removed if dryRun is set. The purged env vars are
+// returned.
+func purgeEnvVars(ctx context.Context, project envVarStore, envVars map[string]string, cutoff time.Time, dryRun bool) ([]string, error) {
+	current, err := project.Getenvs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []string
+	for _, marker := range sortedKeys(current) {
+		name, at, ok := parseQuarantineMarker(marker)
+		if !ok || !at.Before(cutoff) {
+			continue
+		}
+		_, configured := envVars[name]
+		if _, exists := current[name]; exists && !configured {
+			if dryRun {
+				logInfof("Would purge environment variable %s, quarantined at %s", name, at.Format(time.RFC3339))
+				purged = append(purged, name)
+				continue
+			}
+			logInfof("Purging environment variable %s, quarantined at %s", name, at.Format(time.RFC3339))
+			err = project.Deleteenv(ctx, name)
+			if err != nil {
+				return purged, err
+			}
+			purged = append(purged, name)
+		}
+		if !dryRun {
+			err = project.Deleteenv(ctx, marker)
+			if err != nil {
+				return purged, err
+			}
+		}
+	}
+	return purged, nil
+}
+
+func runPurge(args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	retention := fs.Duration("retention", defaultRetention, "Remove env vars quarantined longer ago than this")
+	dryRun := fs.Bool("dry-run", false, "Print the env vars that would be purged without removing them")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	config, err := s.config()
+	if err != nil {
+		return err
+	}
+	s, err = s.forConfig(config)
+	if err != nil {
+		return err
+	}
+	project := s.project(config.VcsType, config.Owner, config.ProjectName)
+	cutoff := time.Now().Add(-*retention)
+	if approval := s.approval.merge(config.Approval); !*dryRun && approval.URL != "" {
+		changes, err := purgeEnvVars(s.ctx, project, config.keptEnvVars(), cutoff, true)
+		if err == nil {
+			err = requestApproval(s.ctx, approval, os.Getenv, project.FullName(), "purge", changes)
+		}
+		if err != nil {
+			return fmt.Errorf("could not get approval to purge project %s: %v", project.FullName(), err)
+		}
+	}
+	purged, err := purgeEnvVars(s.ctx, project, config.keptEnvVars(), cutoff, *dryRun)
+	if err != nil {
+		return fmt.Errorf("could not purge environment variables of project %s: %v", project.FullName(), err)
+	}
+	logInfof("Purged %d quarantined environment variables from project %s", len(purged), project.FullName())
+	return nil
+}
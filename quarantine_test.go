@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeEnvVarStore is a project holding env vars in memory.
+type fakeEnvVarStore map[string]string
+
+func (p fakeEnvVarStore) FullName() string { return "owner/project" }
+
+func (p fakeEnvVarStore) Getenvs(ctx context.Context) (map[string]string, error) {
+	envVars := make(map[string]string, len(p))
+	for name, value := range p {
+		envVars[name] = value
+	}
+	return envVars, nil
+}
+
+func (p fakeEnvVarStore) Setenv(ctx context.Context, name, value string) error {
+	p[name] = value
+	return nil
+}
+
+func (p fakeEnvVarStore) Deleteenv(ctx context.Context, name string) error {
+	delete(p, name)
+	return nil
+}
+
+func TestParseQuarantineMarker(t *testing.T) {
+	at := time.Unix(1563703183, 0)
+	name, parsed, ok := parseQuarantineMarker(quarantineMarker("API_TOKEN", at))
+	if !ok || name != "API_TOKEN" || !parsed.Equal(at) {
+		t.Errorf("Expected API_TOKEN quarantined at %v, found %q at %v (%v)", at, name, parsed, ok)
+	}
+	for _, marker := range []string{"API_TOKEN", "ZZ_DELETED_API_TOKEN", "ZZ_DELETED_1563703183_", "ZZ_DELETED_soon_A"} {
+		if _, _, ok := parseQuarantineMarker(marker); ok {
+			t.Errorf("Expected %s not to be a quarantine marker", marker)
+		}
+	}
+}
+
+func TestQuarantineEnvVars(t *testing.T) {
+	project := fakeEnvVarStore{
+		"KEEP":                       "xxxx1",
+		"OLD":                        "xxxx2",
+		"BACK":                       "xxxx3",
+		"ZZ_DELETED_1563000000_BACK": "xxxxBACK",
+	}
+	now := time.Unix(1563703183, 0)
+	quarantined, err := quarantineEnvVars(context.Background(), project, map[string]string{"KEEP": "1", "BACK": "3"}, now)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if !reflect.DeepEqual(quarantined, []string{"OLD"}) {
+		t.Errorf("Expected OLD to be quarantined, found %v", quarantined)
+	}
+	expected := fakeEnvVarStore{
+		"KEEP":                      "xxxx1",
+		"OLD":                       "xxxx2",
+		"BACK":                      "xxxx3",
+		"ZZ_DELETED_1563703183_OLD": "OLD",
+	}
+	if !reflect.DeepEqual(project, expected) {
+		t.Errorf("Expected env vars %v, found %v", expected, project)
+	}
+
+	// Quarantining again leaves the existing marker alone.
+	_, err = quarantineEnvVars(context.Background(), project, map[string]string{"KEEP": "1", "BACK": "3"}, now.Add(time.Hour))
+	if err != nil || !reflect.DeepEqual(project, expected) {
+		t.Errorf("Expected env vars %v to be unchanged, found %v (%v)", expected, project, err)
+	}
+}
+
+func TestPurgeEnvVars(t *testing.T) {
+	newProject := func() fakeEnvVarStore {
+		return fakeEnvVarStore{
+			"OLD":                          "xxxx1",
+			"ZZ_DELETED_1563000000_OLD":    "xxxxOLD",
+			"RECENT":                       "xxxx2",
+			"ZZ_DELETED_1563700000_RECENT": "xxxxCENT",
+			"BACK":                         "xxxx3",
+			"ZZ_DELETED_1563000000_BACK":   "xxxxBACK",
+		}
+	}
+	cutoff := time.Unix(1563600000, 0)
+
+	project := newProject()
+	purged, err := purgeEnvVars(context.Background(), project, map[string]string{"BACK": "3"}, cutoff, true)
+	if err != nil || !reflect.DeepEqual(purged, []string{"OLD"}) || !reflect.DeepEqual(project, newProject()) {
+		t.Errorf("Expected a dry run to report OLD and change nothing, found %v, %v (%v)", purged, project, err)
+	}
+
+	purged, err = purgeEnvVars(context.Background(), project, map[string]string{"BACK": "3"}, cutoff, false)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if !reflect.DeepEqual(purged, []string{"OLD"}) {
+		t.Errorf("Expected OLD to be purged, found %v", purged)
+	}
+	expected := fakeEnvVarStore{
+		"RECENT":                       "xxxx2",
+		"ZZ_DELETED_1563700000_RECENT": "xxxxCENT",
+		"BACK":                         "xxxx3",
+	}
+	if !reflect.DeepEqual(project, expected) {
+		t.Errorf("Expected env vars %v, found %v", expected, project)
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Outcomes of provisioning a resource.
+const (
+	outcomeCreated = "created"
+	outcomeUpdated = "updated"
+	outcomeSkipped = "skipped"
+	outcomeFailed  = "failed"
+
+	outcomeCancelled = "cancelled" // Of a project whose run was cancelled before it finished
+)
+
+// Formats the report can be written in with -output.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// validateOutputFormat checks the -output format is one the report can be
+// written in.
+func validateOutputFormat(format string) error {
+	if format != outputTable && format != outputJSON && format != outputYAML {
+		return fmt.Errorf("invalid -output %q, expected %s, %s or %s", format, outputTable, outputJSON, outputYAML)
+	}
+	return nil
+}
+
+// reportOutcomes are the outcomes in the order they are printed.
+var reportOutcomes = []string{outcomeCreated, outcomeUpdated, outcomeSkipped, outcomeFailed}
+
+// resourceID returns the stable ID of a resource of the project with the
+// slug, e.g. gh/owner/project/envvar/API_KEY, which identifies it the same
+// way in plans, reports, history and events across runs. Resources a project
+// has only one of, such as its build, have no name.
+func resourceID(slug, resource, name string) string {
+	id := slug + "/" + resource
+	if name != "" {
+		id += "/" + strings.Replace(name, " ", "-", -1)
+	}
+	return id
+}
+
+// reportEntry is the outcome of provisioning one resource of a project.
+type reportEntry struct {
+	id       string
+	project  string
+	resource string
+	name     string // Name of the resource, e.g. the env var, empty for a whole step
+	action   string // What was done to the resource, which may have failed
+	outcome  string
+	err      error // Why the resource failed
+}
+
+// reportDocument is the report as written with -output json or yaml, for
+// CI pipelines and wrappers to parse.
+type reportDocument struct {
+	Actions   []reportAction `json:"actions" yaml:"actions"`
+	Failed    int            `json:"failed" yaml:"failed"`
+	Cancelled bool           `json:"cancelled" yaml:"cancelled"` // Resources not listed were not provisioned
+}
+
+// reportAction is a resource of a report document.
+type reportAction struct {
+	ID       string `json:"id" yaml:"id"`
+	Project  string `json:"project" yaml:"project"`
+	Resource string `json:"resource" yaml:"resource"`
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Action   string `json:"action" yaml:"action"` // created, updated or skipped
+	Status   string `json:"status" yaml:"status"` // ok or failed
+	Error    string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Report collects the outcome of every resource provisioned in a run, so
+// that a partial failure can be summarised once the run is over.
+type Report struct {
+	mu        sync.Mutex
+	entries   []reportEntry
+	events    *EventLog // Where each outcome is streamed as it is recorded, if set
+	cancelled bool      // The run was cancelled, so no new operations should start
+	slugs     map[string]string
+}
+
+// NewReport starts an empty report, streaming outcomes to events if set.
+func NewReport(events *EventLog) *Report {
+	return &Report{events: events}
+}
+
+// Identify sets the slug identifying the project's resources, which are
+// otherwise identified by the project's name.
+func (r *Report) Identify(project, slug string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.slugs == nil {
+		r.slugs = make(map[string]string)
+	}
+	r.slugs[project] = slug
+}
+
+// slug returns the slug identifying the project's resources.
+func (r *Report) slug(project string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if slug, ok := r.slugs[project]; ok {
+		return slug
+	}
+	return project
+}
+
+// Started streams that provisioning the project, identified by the slug,
+// has started.
+func (r *Report) Started(project, slug string) {
+	if r == nil {
+		return
+	}
+	r.Identify(project, slug)
+	r.events.Emit(Event{Type: eventProjectStarted, Project: project, ID: slug})
+}
+
+// Finished streams that provisioning the project has finished, failing with
+// err if set.
+func (r *Report) Finished(project string, err error) {
+	if r == nil {
+		return
+	}
+	event := Event{Type: eventProjectFinished, Project: project, Outcome: outcomeUpdated, ID: r.slug(project)}
+	if err != nil {
+		event.Outcome, event.Error = outcomeFailed, err.Error()
+		if r.Cancelled() {
+			event.Outcome = outcomeCancelled
+		}
+	}
+	r.events.Emit(event)
+}
+
+// Cancel marks the run as cancelled, so that no new operations are started.
+func (r *Report) Cancel() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	cancelled := r.cancelled
+	r.cancelled = true
+	r.mu.Unlock()
+	if !cancelled {
+		r.events.Emit(Event{Type: eventRunCancelled})
+	}
+}
+
+// Cancelled reports whether the run has been cancelled.
+func (r *Report) Cancelled() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cancelled
+}
+
+// Record records the outcome of a resource of the project. The outcome is
+// failed whenever err is set.
+func (r *Report) Record(project, resource, name, outcome string, err error) {
+	if r == nil {
+		return
+	}
+	id := resourceID(r.slug(project), resource, name)
+	event := Event{Type: eventResource, Project: project, Resource: resource, Name: name, Outcome: outcome, ID: id}
+	action := outcome
+	if err != nil {
+		outcome = outcomeFailed
+		event.Outcome, event.Error = outcome, err.Error()
+	}
+	r.events.Emit(event)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, reportEntry{id, project, resource, name, action, outcome, err})
+}
+
+// Failed returns how many resources failed.
+func (r *Report) Failed() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	failed := 0
+	for _, entry := range r.entries {
+		if entry.outcome == outcomeFailed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// FailedIDs returns the IDs of the project's resources that failed.
+func (r *Report) FailedIDs(project string) []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []string
+	for _, entry := range r.entries {
+		if entry.project == project && entry.outcome == outcomeFailed {
+			ids = append(ids, entry.id)
+		}
+	}
+	return ids
+}
+
+// Write writes the report to w in the format, a table or a json or yaml
+// document.
+func (r *Report) Write(w io.Writer, format string) error {
+	if format == outputTable || format == "" {
+		r.Print(w)
+		return nil
+	}
+	doc := r.document()
+	var data []byte
+	var err error
+	switch format {
+	case outputJSON:
+		data, err = json.MarshalIndent(doc, "", "  ")
+		data = append(data, '\n')
+	case outputYAML:
+		data, err = yaml.Marshal(doc)
+	default:
+		return validateOutputFormat(format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// document returns the report as a document describing each resource.
+func (r *Report) document() reportDocument {
+	doc := reportDocument{Actions: []reportAction{}}
+	if r == nil {
+		return doc
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, entry := range r.entries {
+		action := reportAction{ID: entry.id, Project: entry.project, Resource: entry.resource, Name: entry.name,
+			Action: entry.action, Status: "ok"}
+		if entry.err != nil {
+			action.Status, action.Error = outcomeFailed, entry.err.Error()
+			doc.Failed++
+		}
+		doc.Actions = append(doc.Actions, action)
+	}
+	doc.Cancelled = r.cancelled
+	return doc
+}
+
+// Print writes a table of the outcomes of each resource of every project to
+// w, followed by the failures and whether the run was cancelled.
+func (r *Report) Print(w io.Writer) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancelled {
+		defer fmt.Fprintf(w, "%s: resources not listed above were not provisioned\n", paint(w, colorYellow, "cancelled"))
+	}
+	if len(r.entries) == 0 {
+		return
+	}
+
+	type row struct{ project, resource string }
+	counts := make(map[row]map[string]int)
+	var rows []row
+	var failures []reportEntry
+	for _, entry := range r.entries {
+		key := row{entry.project, entry.resource}
+		if counts[key] == nil {
+			counts[key] = make(map[string]int)
+			rows = append(rows, key)
+		}
+		counts[key][entry.outcome]++
+		if entry.outcome == outcomeFailed {
+			failures = append(failures, entry)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].project < rows[j].project })
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tRESOURCE\tCREATED\tUPDATED\tSKIPPED\tFAILED")
+	for _, key := range rows {
+		fmt.Fprintf(tw, "%s\t%s", key.project, key.resource)
+		for _, outcome := range reportOutcomes {
+			count := fmt.Sprint(counts[key][outcome])
+			if outcome == outcomeFailed && counts[key][outcome] > 0 {
+				count = paint(w, colorRed, count)
+			}
+			fmt.Fprintf(tw, "\t%s", count)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+	for _, failure := range failures {
+		fmt.Fprintf(w, "%s %s: %v\n", paint(w, colorRed, "failed"), failure.id, failure.err)
+	}
+}
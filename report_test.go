@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestReportPrint(t *testing.T) {
+	report := NewReport(nil)
+	report.Record("git/test/a", circleci.ResourceEnvVar, "A", outcomeCreated, nil)
+	report.Record("git/test/a", circleci.ResourceEnvVar, "B", outcomeUpdated, nil)
+	report.Record("git/test/a", circleci.ResourceEnvVar, "C", outcomeCreated, fmt.Errorf("bad request"))
+	report.Record("git/test/a", circleci.ResourceBuild, "", outcomeSkipped, nil)
+
+	var out bytes.Buffer
+	report.Print(&out)
+	expected := `PROJECT     RESOURCE  CREATED  UPDATED  SKIPPED  FAILED
+git/test/a  envvar    1        1        0        1
+git/test/a  build     0        0        1        0
+failed git/test/a/envvar/C: bad request
+`
+	if out.String() != expected {
+		t.Errorf("Expected report:\n%s\nfound:\n%s", expected, out.String())
+	}
+	if report.Failed() != 1 {
+		t.Errorf("Expected 1 failure, found %d", report.Failed())
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	report := NewReport(nil)
+	report.Record("git/test/a", circleci.ResourceEnvVar, "A", outcomeCreated, nil)
+	report.Record("git/test/a", circleci.ResourceEnvVar, "C", outcomeUpdated, fmt.Errorf("bad request"))
+
+	var out bytes.Buffer
+	if err := report.Write(&out, outputJSON); err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	var doc reportDocument
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected a JSON document, found %q: %v", out.String(), err)
+	}
+	expected := reportDocument{Failed: 1, Actions: []reportAction{
+		{ID: "git/test/a/envvar/A", Project: "git/test/a", Resource: "envvar", Name: "A", Action: "created", Status: "ok"},
+		{ID: "git/test/a/envvar/C", Project: "git/test/a", Resource: "envvar", Name: "C", Action: "updated", Status: "failed", Error: "bad request"},
+	}}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("Expected document %+v, found %+v", expected, doc)
+	}
+
+	out.Reset()
+	if err := report.Write(&out, outputYAML); err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if !strings.Contains(out.String(), "  status: failed\n  error: bad request\n") {
+		t.Errorf("Expected the failure in the YAML document, found:\n%s", out.String())
+	}
+	if err := report.Write(&out, "xml"); err == nil {
+		t.Error("Expected an error writing an unknown format")
+	}
+}
+
+func TestProvisionCollectsFailures(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"POST /project/git/test/test/follow": {Status: http.StatusCreated, Body: `{"following": true}`},
+		"GET /project/git/test/test/envvar":  {Status: http.StatusOK, Body: `[{"name": "A", "value": "xxxxa"}]`},
+		"POST /project/git/test/test/envvar": {Status: http.StatusCreated, Body: `{}`},
+	})
+	defer svr.Close()
+
+	config := Config{
+		EnvVars: EnvVars{"A": "1", "B": "2"},
+		SSHKeys: map[string]string{"example.com": "testdata/missing.key"},
+	}
+	report := NewReport(nil)
+	err := provision(context.Background(), svr.project(), config, provisionOptions{trigger: true, report: report})
+	if err == nil || !strings.Contains(err.Error(), "missing.key") {
+		t.Errorf("Expected the SSH key to fail, found: %v", err)
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	for _, row := range []string{
+		"test/test  envvar    1        1        0        0",
+		"test/test  ssh-key   0        0        0        1",
+		"test/test  build     0        0        1        0",
+	} {
+		if !strings.Contains(out.String(), row) {
+			t.Errorf("Expected report row %q, found:\n%s", row, out.String())
+		}
+	}
+	for _, request := range svr.Requests() {
+		if strings.HasPrefix(request, "POST /project/git/test/test/build") {
+			t.Error("Expected no build to be triggered for a partly provisioned project")
+		}
+	}
+}
+
+func TestReportResourceIDs(t *testing.T) {
+	report := NewReport(nil)
+	report.Identify("test/a", "gh/test/a")
+	report.Record("test/a", circleci.ResourceEnvVar, "A", outcomeCreated, nil)
+	report.Record("test/a", circleci.ResourceSettings, "build settings", outcomeUpdated, fmt.Errorf("bad request"))
+	report.Record("test/b", circleci.ResourceBuild, "", outcomeCreated, fmt.Errorf("bad request"))
+
+	expected := []string{"gh/test/a/settings/build-settings"}
+	if ids := report.FailedIDs("test/a"); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Expected failed resources %v, found %v", expected, ids)
+	}
+	if ids := report.FailedIDs("test/b"); !reflect.DeepEqual(ids, []string{"test/b/build"}) {
+		t.Errorf("Expected a project without a slug to be identified by its name, found %v", ids)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policies for env vars named like the ones CircleCI sets in every job.
+const (
+	reservedWarn   = "warn"   // Provision them as they are, warning about each one
+	reservedFail   = "fail"   // Reject the config
+	reservedPrefix = "prefix" // Provision them under a prefix instead
+)
+
+// defaultReservedPrefix is the prefix given to reserved env vars by the
+// prefix policy if the config sets none.
+const defaultReservedPrefix = "APP_"
+
+// reservedNames are the env vars CircleCI sets in every job, besides the
+// CIRCLE_ ones. Project and context env vars with these names override
+// them, which breaks jobs in confusing ways.
+var reservedNames = map[string]bool{"CI": true, "CIRCLECI": true, "BASH_ENV": true}
+
+// ReservedEnvVars sets what is done with configured env vars that collide
+// with the ones CircleCI sets.
+type ReservedEnvVars struct {
+	Policy string `yaml:"policy"` // warn, fail or prefix, warn if empty
+	Prefix string `yaml:"prefix"` // Prefix used by the prefix policy, APP_ if empty
+}
+
+// isReservedEnvVar reports whether CircleCI sets an env var with this name.
+func isReservedEnvVar(name string) bool {
+	return reservedNames[name] || strings.HasPrefix(name, "CIRCLE_")
+}
+
+// checkReservedEnvVars applies the config's policy to the env vars of the
+// config and its contexts that collide with CircleCI's own.
+func checkReservedEnvVars(config *Config) error {
+	policy, prefix := config.Reserved.Policy, config.Reserved.Prefix
+	switch policy {
+	case "":
+		policy = reservedWarn
+	case reservedWarn, reservedFail, reservedPrefix:
+	default:
+		return fmt.Errorf("invalid reservedEnvVars policy %q, expected warn, fail or prefix", policy)
+	}
+	if prefix == "" {
+		prefix = defaultReservedPrefix
+	}
+
+	err := applyReservedPolicy(config.EnvVars, policy, prefix, "", config.renameEnvVar)
+	if err != nil {
+		return err
+	}
+	for _, context := range config.Contexts {
+		err = applyReservedPolicy(context.EnvVars, policy, prefix, "context "+context.Name, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyReservedPolicy applies the policy to envVars, calling renamed (if set)
+// for each env var that is prefixed.
+func applyReservedPolicy(envVars map[string]string, policy, prefix, owner string, renamed func(name, mapped string)) error {
+	where := ""
+	if owner != "" {
+		where = " of " + owner
+	}
+	for _, name := range sortedKeys(envVars) {
+		if !isReservedEnvVar(name) {
+			continue
+		}
+		switch policy {
+		case reservedFail:
+			return fmt.Errorf("environment variable %s%s collides with one CircleCI sets", name, where)
+		case reservedWarn:
+			logWarnf("Environment variable %s%s collides with one CircleCI sets and will override it in jobs", name, where)
+		case reservedPrefix:
+			mapped := prefix + name
+			if _, ok := envVars[mapped]; ok {
+				return fmt.Errorf("environment variable %s%s cannot be prefixed as %s is already set", name, where, mapped)
+			}
+			logWarnf("Environment variable %s%s collides with one CircleCI sets, provisioning it as %s", name, where, mapped)
+			envVars[mapped] = envVars[name]
+			delete(envVars, name)
+			if renamed != nil {
+				renamed(name, mapped)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckReservedEnvVars(t *testing.T) {
+	type test struct {
+		reserved ReservedEnvVars
+		expected EnvVars
+		context  map[string]string
+		fails    bool
+	}
+
+	testCases := []test{
+		{ReservedEnvVars{}, EnvVars{"CI": "1", "CIRCLE_BRANCH": "x", "DB_URL": "db"},
+			map[string]string{"CIRCLECI": "1"}, false},
+		{ReservedEnvVars{Policy: "fail"}, nil, nil, true},
+		{ReservedEnvVars{Policy: "prefix"}, EnvVars{"APP_CI": "1", "APP_CIRCLE_BRANCH": "x", "DB_URL": "db"},
+			map[string]string{"APP_CIRCLECI": "1"}, false},
+		{ReservedEnvVars{Policy: "prefix", Prefix: "MY_"}, EnvVars{"MY_CI": "1", "MY_CIRCLE_BRANCH": "x", "DB_URL": "db"},
+			map[string]string{"MY_CIRCLECI": "1"}, false},
+		{ReservedEnvVars{Policy: "rename"}, nil, nil, true},
+	}
+
+	for _, tc := range testCases {
+		expiresAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		config := Config{
+			EnvVars:  EnvVars{"CI": "1", "CIRCLE_BRANCH": "x", "DB_URL": "db"},
+			Contexts: []ContextConfig{{Name: "shared", EnvVars: map[string]string{"CIRCLECI": "1"}}},
+			Reserved: tc.reserved,
+			Expiry:   map[string]time.Time{"CI": expiresAt},
+		}
+		err := checkReservedEnvVars(&config)
+		if tc.fails {
+			if err == nil {
+				t.Errorf("Expected an error for %+v", tc.reserved)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected no error for %+v, found: %v", tc.reserved, err)
+			continue
+		}
+		if !reflect.DeepEqual(config.EnvVars, tc.expected) {
+			t.Errorf("Expected %v for %+v, found %v", tc.expected, tc.reserved, config.EnvVars)
+		}
+		if !reflect.DeepEqual(config.Contexts[0].EnvVars, tc.context) {
+			t.Errorf("Expected context %v for %+v, found %v", tc.context, tc.reserved, config.Contexts[0].EnvVars)
+		}
+		_, kept := tc.expected["CI"]
+		_, stale := config.Expiry["CI"]
+		if !kept && (stale || len(config.Expiry) != 1) {
+			t.Errorf("Expected the expiry of CI to follow it for %+v, found %v", tc.reserved, config.Expiry)
+		}
+	}
+}
+
+func TestCheckReservedEnvVarsPrefixCollision(t *testing.T) {
+	config := Config{
+		EnvVars:  EnvVars{"CI": "1", "APP_CI": "2"},
+		Reserved: ReservedEnvVars{Policy: "prefix"},
+	}
+	err := checkReservedEnvVars(&config)
+	if err == nil {
+		t.Error("Expected an error when the prefixed name is already set")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// secretRecorder is a SecretStore standing in for a real one while a config
+// is read, recording the references made to it instead of looking them up.
+type secretRecorder struct {
+	scheme string
+	refs   *secretRefs
+}
+
+// Secret records the reference and returns it as the secret.
+func (r secretRecorder) Secret(path, key string) (string, error) {
+	ref := r.scheme + ":" + path
+	if key != "" {
+		ref += "#" + key
+	}
+	r.refs.add(ref)
+	return ref, nil
+}
+
+// secretRefs is the set of secret references made by the configs read.
+type secretRefs struct {
+	refs map[string]bool
+}
+
+func (r *secretRefs) add(ref string) {
+	if r.refs == nil {
+		r.refs = make(map[string]bool)
+	}
+	r.refs[ref] = true
+}
+
+// recorders returns stores recording the references made to each of stores.
+func (r *secretRefs) recorders(stores map[string]SecretStore) map[string]SecretStore {
+	recorders := make(map[string]SecretStore, len(stores))
+	for scheme := range stores {
+		recorders[scheme] = secretRecorder{scheme, r}
+	}
+	return recorders
+}
+
+// addSources records the secret references of every source of the config's
+// fallback chains, including those a source before them would have shadowed.
+func (r *secretRefs) addSources(config Config, stores map[string]SecretStore) {
+	for _, sources := range config.Sources {
+		for _, source := range sources {
+			if _, _, _, ok := secretReference(source, stores); ok {
+				r.add(source)
+			}
+		}
+	}
+}
+
+// secretCheck is the outcome of resolving a secret reference.
+type secretCheck struct {
+	ref    string
+	reason string // Why it could not be resolved, empty if it was
+	err    error
+}
+
+// check resolves every reference against stores, in order.
+func (r *secretRefs) check(stores map[string]SecretStore) []secretCheck {
+	refs := make([]string, 0, len(r.refs))
+	for ref := range r.refs {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	checks := make([]secretCheck, len(refs))
+	for i, ref := range refs {
+		checks[i].ref = ref
+		store, path, key, _ := secretReference(ref, stores)
+		if _, err := store.Secret(path, key); err != nil {
+			checks[i].reason, checks[i].err = secretFailure(err), err
+		}
+	}
+	return checks
+}
+
+// printSecretChecks writes a table of the outcome of each reference to w,
+// returning how many could not be resolved.
+func printSecretChecks(w io.Writer, checks []secretCheck) int {
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REFERENCE\tSTATUS\tERROR")
+	for _, check := range checks {
+		if check.err == nil {
+			fmt.Fprintf(tw, "%s\t%s\t\n", check.ref, paint(w, colorGreen, "ok"))
+			continue
+		}
+		failed++
+		fmt.Fprintf(tw, "%s\t%s\t%v\n", check.ref, paint(w, colorRed, check.reason), check.err)
+	}
+	tw.Flush()
+	return failed
+}
+
+func runResolveCheck(args []string) error {
+	fs := flag.NewFlagSet("resolve-check", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s resolve-check [flags] [CONFIG ...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	common := addCommonFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	common.offline = true
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	files, err := common.configArgs(fs.Args())
+	if err != nil {
+		return err
+	}
+	stores := s.configOpts.secrets
+	refs := &secretRefs{}
+	opts := s.configOpts
+	opts.secrets = refs.recorders(stores)
+	for _, file := range files {
+		configs, err := readConfigs(file, opts)
+		if err != nil {
+			return usageError(fmt.Errorf("could not read config file %s: %v", file, err))
+		}
+		for _, config := range configs {
+			refs.addSources(config, stores)
+		}
+	}
+	if len(refs.refs) == 0 {
+		logInfof("No secret references in %s", strings.Join(files, ", "))
+		return nil
+	}
+
+	checks := refs.check(stores)
+	if failed := printSecretChecks(s.stdout, checks); failed > 0 {
+		return fmt.Errorf("%d of %d secret references could not be resolved", failed, len(checks))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSecretRefsCheck(t *testing.T) {
+	stores := map[string]SecretStore{
+		"vault": fakeSecretStore{"secret/app#token": "s3cret"},
+		"ssm":   fakeSecretStore{},
+	}
+	refs := &secretRefs{}
+	config := Config{
+		EnvVars:  EnvVars{"TOKEN": "vault:secret/app#token", "URL": "https://example.com"},
+		Contexts: []ContextConfig{{Name: "deploy", EnvVars: map[string]string{"KEY": "vault:secret/deploy#key"}}},
+		Sources:  map[string][]string{"DB": {"env:DB_PASSWORD", "ssm:/db/password", "literal:dev"}},
+	}
+	if err := resolveSecrets(&config, refs.recorders(stores)); err != nil {
+		t.Fatalf("Expected no error recording references, found: %v", err)
+	}
+	if config.EnvVars["TOKEN"] != "vault:secret/app#token" {
+		t.Errorf("Expected references not to be resolved, found %s", config.EnvVars["TOKEN"])
+	}
+	refs.addSources(config, stores)
+
+	checks := refs.check(stores)
+	var found []string
+	for _, check := range checks {
+		found = append(found, check.ref+" "+check.reason)
+	}
+	expected := []string{"ssm:/db/password failed", "vault:secret/app#token ", "vault:secret/deploy#key failed"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected checks %v, found %v", expected, found)
+	}
+
+	var out bytes.Buffer
+	if failed := printSecretChecks(&out, checks); failed != 2 {
+		t.Errorf("Expected 2 failures, found %d", failed)
+	}
+	if strings.Contains(out.String(), "s3cret") {
+		t.Errorf("Expected secrets not to be printed, found:\n%s", out.String())
+	}
+}
+
+func TestSecretFailure(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/secret/denied":
+			w.WriteHeader(http.StatusForbidden)
+		case "/v1/secret/app":
+			fmt.Fprint(w, `{"data": {"token": "s3cret"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+	vault := NewVaultClient(svr.URL, "token", "")
+
+	for _, tt := range []struct {
+		store     SecretStore
+		path, key string
+		want      string
+	}{
+		{vault, "secret/denied", "token", secretDenied},
+		{vault, "secret/missing", "token", secretMissing},
+		{vault, "secret/app", "other", secretMissing},
+		{NewVaultClient("", "token", ""), "secret/app", "token", secretUnreachable},
+		{NewAWSParameterStore("", awsCredentials{}), "/db/password", "", secretUnreachable},
+	} {
+		_, err := tt.store.Secret(tt.path, tt.key)
+		if reason := secretFailure(err); err == nil || reason != tt.want {
+			t.Errorf("Expected %s#%s to fail as %s, found %s (%v)", tt.path, tt.key, tt.want, reason, err)
+		}
+	}
+	if reason := awsFailure(http.StatusBadRequest, "ParameterNotFound"); reason != secretMissing {
+		t.Errorf("Expected a missing parameter, found %s", reason)
+	}
+	if reason := awsFailure(http.StatusBadRequest, "com.amazonaws#AccessDeniedException"); reason != secretDenied {
+		t.Errorf("Expected access to be denied, found %s", reason)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// envVarInput describes an environment variable given as JSON on stdin.
+type envVarInput struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// sshKeyInput describes an SSH key given as JSON on stdin.
+type sshKeyInput struct {
+	Hostname   string `json:"hostname"`
+	PrivateKey string `json:"privateKey"`
+}
+
+// decodeInput decodes a single JSON document describing a resource.
+func decodeInput(r io.Reader, out interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(out)
+	if err != nil {
+		return fmt.Errorf("could not decode JSON input: %v", err)
+	}
+	return nil
+}
+
+// subcommand returns the action of a resource command and its arguments.
+func subcommand(name string, args []string, actions ...string) (string, []string, error) {
+	if len(args) > 0 {
+		for _, action := range actions {
+			if args[0] == action {
+				return action, args[1:], nil
+			}
+		}
+	}
+	return "", nil, fmt.Errorf("usage: %s %s [flags]", name, actions)
+}
+
+func runEnv(args []string) error {
+	_, args, err := subcommand("env", args, "set")
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("env set", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	fromJSON := fs.Bool("json", false, `Read {"name": ..., "value": ...} from stdin instead of arguments`)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s env set [flags] NAME VALUE\n       %[1]s env set -json [flags] < envvar.json\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var input envVarInput
+	if *fromJSON {
+		err = decodeInput(os.Stdin, &input)
+		if err != nil {
+			return err
+		}
+	} else if fs.NArg() == 2 {
+		input = envVarInput{fs.Arg(0), fs.Arg(1)}
+	} else {
+		fs.Usage()
+		return fmt.Errorf("env set takes NAME and VALUE or -json")
+	}
+	if input.Name == "" {
+		return fmt.Errorf("environment variable name is required")
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	vcsType, owner, projectName, err := s.projectSlug()
+	if err != nil {
+		return err
+	}
+	project := s.project(vcsType, owner, projectName)
+	err = project.Setenv(s.ctx, input.Name, input.Value)
+	if err != nil {
+		return fmt.Errorf("could not set environment variable %s for project %s: %v",
+			input.Name, project.FullName(), err)
+	}
+	logInfof("Set environment variable %s for project %s", input.Name, project.FullName())
+	return nil
+}
+
+func runSSHKey(args []string) error {
+	_, args, err := subcommand("sshkey", args, "add")
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("sshkey add", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	fromJSON := fs.Bool("json", false, `Read {"hostname": ..., "privateKey": ...} from stdin instead of arguments`)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s sshkey add [flags] HOSTNAME KEYFILE\n       %[1]s sshkey add -json [flags] < sshkey.json\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var input sshKeyInput
+	if *fromJSON {
+		err = decodeInput(os.Stdin, &input)
+		if err != nil {
+			return err
+		}
+	} else if fs.NArg() == 2 {
+		content, err := ioutil.ReadFile(fs.Arg(1))
+		if err != nil {
+			return fmt.Errorf("could not read SSH key at path %s: %v", fs.Arg(1), err)
+		}
+		input = sshKeyInput{fs.Arg(0), string(content)}
+	} else {
+		fs.Usage()
+		return fmt.Errorf("sshkey add takes HOSTNAME and KEYFILE or -json")
+	}
+	if input.Hostname == "" || input.PrivateKey == "" {
+		return fmt.Errorf("hostname and private key are required")
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	vcsType, owner, projectName, err := s.projectSlug()
+	if err != nil {
+		return err
+	}
+	project := s.project(vcsType, owner, projectName)
+	err = project.AddSSHKey(s.ctx, input.Hostname, input.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("could not add SSH key for %s to project %s: %v", input.Hostname, project.FullName(), err)
+	}
+	logInfof("Added SSH key for %s to project %s", input.Hostname, project.FullName())
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// snapshotForRollback reads the restorable state of the project before a
+// canonical run removes anything from it, or returns nil if it cannot, in
+// which case the run cannot be rolled back.
+func snapshotForRollback(ctx context.Context, project circleci.Project) *backupState {
+	state, err := fetchBackupState(ctx, project)
+	if err != nil {
+		logWarnf("Could not record the state of %s, it cannot be rolled back if provisioning fails: %v",
+			project.FullName(), err)
+		return nil
+	}
+	return &state
+}
+
+// rollbackOnFailure restores the project to its state before the run, which
+// failed with runErr, if -rollback-on-failure is set or the user agrees to
+// it. It returns runErr, along with why the rollback failed if it did.
+func rollbackOnFailure(ctx context.Context, project circleci.Project, config Config, before backupState,
+	opts provisionOptions, runErr error) error {
+	name := project.FullName()
+	if opts.report.Cancelled() {
+		return runErr
+	}
+	if !opts.rollback {
+		if opts.confirm == nil {
+			logWarnf("Project %s was left partly provisioned, pass -rollback-on-failure to restore what the run removed",
+				name)
+			return runErr
+		}
+		logErrorf("%v", runErr)
+		if err := opts.confirm(name, []string{"restore the env vars and SSH keys this run removed"}); err != nil {
+			return runErr
+		}
+	}
+
+	logWarnf("Rolling back project %s", name)
+	err := rollback(ctx, project, config, before)
+	opts.report.Record(name, circleci.ResourceSettings, "rollback", outcomeUpdated, err)
+	if err != nil {
+		return fmt.Errorf("%v (rollback failed too: %v)", runErr, err)
+	}
+	logInfof("Rolled back project %s", name)
+	return runErr
+}
+
+// rollback restores the env vars and SSH keys the project had before that
+// are now gone, and its build settings. CircleCI never returns env var
+// values or private keys, so only those the config holds can be restored.
+func rollback(ctx context.Context, project circleci.Project, config Config, before backupState) error {
+	now, err := fetchState(ctx, project)
+	if err != nil {
+		return err
+	}
+	missing := backupState{Following: before.Following, FeatureFlags: before.FeatureFlags}
+	for _, name := range before.EnvVarNames {
+		if _, ok := now.EnvVars[name]; ok {
+			continue
+		}
+		if _, ok := config.EnvVars[name]; !ok {
+			logWarnf("Cannot restore environment variable %s of project %s, its value is not in the config",
+				name, project.FullName())
+			continue
+		}
+		missing.EnvVarNames = append(missing.EnvVarNames, name)
+	}
+	present := make(map[string]bool)
+	for _, key := range now.SSHKeys {
+		present[key.Hostname] = true
+	}
+	for _, key := range before.SSHKeys {
+		if present[key.Hostname] {
+			continue
+		}
+		if _, ok := config.SSHKeys[key.Hostname]; !ok {
+			logWarnf("Cannot restore SSH key %s of project %s, its private key is not in the config",
+				key.Hostname, project.FullName())
+			continue
+		}
+		missing.SSHKeys = append(missing.SSHKeys, key)
+	}
+	// Every value restored is in the config, so there is nothing to prompt for.
+	return applyBackupState(ctx, project, missing, secretSources{envVars: config.EnvVars, sshKeys: config.SSHKeys}, nil)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestRollbackRestoresKnownEnvVars(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"GET /project/git/test/test/envvar":  {Status: http.StatusOK, Body: `[{"name": "C", "value": "xxxxc"}]`},
+		"GET " + circlecitest.SettingsPath:   {Status: http.StatusOK, Body: circlecitest.Settings},
+		"POST /project/git/test/test/envvar": {Status: http.StatusCreated, Body: `{}`},
+		"POST /project/git/test/test/follow": {Status: http.StatusCreated, Body: `{"following": true}`},
+		"PUT " + circlecitest.SettingsPath:   {Status: http.StatusOK, Body: `{}`},
+	})
+	defer svr.Close()
+
+	before := backupState{Following: true, EnvVarNames: []string{"A", "B", "C"}}
+	config := Config{EnvVars: EnvVars{"A": "1", "C": "3"}}
+	if err := rollback(context.Background(), svr.project(), config, before); err != nil {
+		t.Fatal(err)
+	}
+
+	var restored []string
+	for _, req := range svr.Requests() {
+		if strings.HasPrefix(req, "POST /project/git/test/test/envvar ") {
+			restored = append(restored, req)
+		}
+	}
+	if len(restored) != 1 || !strings.Contains(restored[0], `"A"`) {
+		t.Errorf("Expected only A to be restored, found %v", restored)
+	}
+}
+
+func TestProvisionRollsBackOnFailure(t *testing.T) {
+	for _, auto := range []bool{false, true} {
+		svr := newFakeCircleCI(map[string]circlecitest.Response{
+			"POST /project/git/test/test/follow":      {Status: http.StatusCreated, Body: `{"following": true}`},
+			"GET /project/git/test/test/envvar":       {Status: http.StatusOK, Body: circlecitest.EnvVars},
+			"GET " + circlecitest.SettingsPath:        {Status: http.StatusOK, Body: circlecitest.Settings},
+			"PUT " + circlecitest.SettingsPath:        {Status: http.StatusOK, Body: `{}`},
+			"GET /project/git/test/test/checkout-key": {Status: http.StatusOK, Body: `[]`},
+			"DELETE /project/git/test/test/envvar/B":  {Status: http.StatusOK, Body: `{"message": "ok"}`},
+		})
+		config := Config{EnvVars: EnvVars{"A": "1"}, ProtectedSSHKeys: []string{"github.com", "example.com"}}
+		err := provision(context.Background(), svr.project(), config,
+			provisionOptions{canonical: true, rollback: auto, report: NewReport(nil)})
+		svr.Close()
+
+		if err == nil {
+			t.Fatal("Expected setting A to fail")
+		}
+		rolledBack := false
+		for _, req := range svr.Requests() {
+			rolledBack = rolledBack || strings.HasPrefix(req, "PUT "+circlecitest.SettingsPath)
+		}
+		if rolledBack != auto {
+			t.Errorf("Expected rollback %v with -rollback-on-failure=%v, found %v", auto, auto, svr.Requests())
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// UserConfig is the user's config file, holding settings for every run
+// rather than for a project.
+type UserConfig struct {
+	Profiles map[string]RunProfile `yaml:"profiles"`
+}
+
+// RunProfile is a command and its flags bundled under a name, so that cron
+// entries and CI jobs can run it with "run NAME" instead of a long flag
+// string.
+type RunProfile struct {
+	Command string            `yaml:"command"`
+	Flags   map[string]string `yaml:"flags"` // Values of the command's flags, keyed by name without the dash
+}
+
+// userConfigFile returns the path of the user config file,
+// $CIRCLECI_USER_CONFIG or ~/.circleci-provisioning/config.
+func userConfigFile(getenv func(string) string) (string, error) {
+	if file := getenv("CIRCLECI_USER_CONFIG"); file != "" {
+		return file, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".circleci-provisioning", "config"), nil
+}
+
+// readUserConfig reads the user config file, which is empty if the file
+// does not exist.
+func readUserConfig(file string) (UserConfig, error) {
+	var config UserConfig
+	err := readYAML(file, &config)
+	if os.IsNotExist(err) {
+		return UserConfig{}, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("could not read user config %s: %v", file, err)
+	}
+	return config, nil
+}
+
+// args returns the arguments running the profile's command with its flags,
+// followed by extra, so that they take precedence.
+func (p RunProfile) args(extra []string) []string {
+	names := make([]string, 0, len(p.Flags))
+	for name := range p.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := make([]string, 0, len(names)+len(extra))
+	for _, name := range names {
+		args = append(args, "-"+name+"="+p.Flags[name])
+	}
+	return append(args, extra...)
+}
+
+// expandRunProfile returns the command and arguments running the run
+// profile name with extra flags, which take precedence over the profile's.
+func expandRunProfile(name string, extra []string, getenv func(string) string) (string, []string, error) {
+	file, err := userConfigFile(getenv)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not find the user config: %v", err)
+	}
+	config, err := readUserConfig(file)
+	if err != nil {
+		return "", nil, err
+	}
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return "", nil, fmt.Errorf("%s has no run profile %s", file, name)
+	}
+	if _, ok := commands[profile.Command]; !ok || profile.Command == "run" {
+		return "", nil, fmt.Errorf("run profile %s in %s has unknown command %q", name, file, profile.Command)
+	}
+	return profile.Command, profile.args(extra), nil
+}
+
+// The run command is registered here, as it looks up the command it runs in
+// commands.
+func init() {
+	commands["run"] = command{"Run a profile of the user config, with extra flags", runRunProfile}
+}
+
+func runRunProfile(args []string) error {
+	usage := func(w io.Writer) {
+		fmt.Fprintf(w, "Usage: %s run NAME [flags]\n\nRuns the command of the run profile NAME of the user "+
+			"config with its flags, followed by flags.\n", os.Args[0])
+	}
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		usage(os.Stdout)
+		return nil
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		usage(os.Stderr)
+		return usageError(fmt.Errorf("run takes the name of a run profile"))
+	}
+	name, args, err := expandRunProfile(args[0], args[1:], os.Getenv)
+	if err != nil {
+		return usageError(err)
+	}
+	return commands[name].run(args)
+}
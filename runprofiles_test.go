@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandRunProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runprofiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config")
+	err = ioutil.WriteFile(file, []byte(`
+profiles:
+  nightly-audit:
+    command: diff
+    flags:
+      config: configs/payments.yml
+      profile: acme
+  broken:
+    command: nightly
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getenv := func(name string) string { return map[string]string{"CIRCLECI_USER_CONFIG": file}[name] }
+
+	for _, tt := range []struct {
+		name        string
+		extra       []string
+		wantCommand string
+		wantArgs    []string
+		wantErr     bool
+	}{
+		{name: "nightly-audit", extra: []string{"-verbose"}, wantCommand: "diff",
+			wantArgs: []string{"-config=configs/payments.yml", "-profile=acme", "-verbose"}},
+		{name: "nightly-audit", wantCommand: "diff",
+			wantArgs: []string{"-config=configs/payments.yml", "-profile=acme"}},
+		{name: "acme", wantErr: true},
+		{name: "broken", wantErr: true},
+	} {
+		command, args, err := expandRunProfile(tt.name, tt.extra, getenv)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Expected an error for %s to be %v, found: %v", tt.name, tt.wantErr, err)
+		}
+		if command != tt.wantCommand || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("Expected %s %v to run %q %v, found %q %v", tt.name, tt.extra, tt.wantCommand, tt.wantArgs,
+				command, args)
+		}
+	}
+}
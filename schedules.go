@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// Names of the days and months of a timetable, in cron's order.
+var (
+	cronDays   = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+	cronMonths = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+)
+
+// ScheduleConfig is a scheduled pipeline of the project, running either on
+// a cron expression or a timetable.
+type ScheduleConfig struct {
+	Description      string                 `yaml:"description"`
+	Cron             string                 `yaml:"cron"`             // e.g. "0 3 * * 1-5", in UTC
+	Timetable        *circleci.Timetable    `yaml:"timetable"`        // Instead of cron
+	Branch           string                 `yaml:"branch"`           // Branch to build, unless parameters set a tag
+	Parameters       map[string]interface{} `yaml:"parameters"`       // Pipeline parameters
+	AttributionActor string                 `yaml:"attributionActor"` // current (the default) or system
+}
+
+// schedule returns the schedule to create or update.
+func (c ScheduleConfig) schedule(name string) (circleci.Schedule, error) {
+	schedule := circleci.Schedule{Name: name, Description: c.Description, AttributionActor: c.AttributionActor}
+	if schedule.AttributionActor == "" {
+		schedule.AttributionActor = circleci.ActorCurrent
+	}
+	if schedule.AttributionActor != circleci.ActorCurrent && schedule.AttributionActor != circleci.ActorSystem {
+		return schedule, fmt.Errorf("invalid attributionActor %q, expected %s or %s",
+			schedule.AttributionActor, circleci.ActorCurrent, circleci.ActorSystem)
+	}
+
+	switch {
+	case c.Cron != "" && c.Timetable != nil:
+		return schedule, fmt.Errorf("only one of cron and timetable can be set")
+	case c.Cron != "":
+		timetable, err := parseCron(c.Cron)
+		if err != nil {
+			return schedule, fmt.Errorf("invalid cron %q: %v", c.Cron, err)
+		}
+		schedule.Timetable = timetable
+	case c.Timetable != nil:
+		schedule.Timetable = normalizeTimetable(*c.Timetable)
+		if err := checkTimetable(schedule.Timetable); err != nil {
+			return schedule, fmt.Errorf("invalid timetable: %v", err)
+		}
+	default:
+		return schedule, fmt.Errorf("cron or timetable is required")
+	}
+
+	parameters := make(map[string]interface{}, len(c.Parameters)+1)
+	for name, value := range c.Parameters {
+		parameters[name] = value
+	}
+	if c.Branch != "" {
+		parameters["branch"] = c.Branch
+	}
+	if parameters["branch"] == nil && parameters["tag"] == nil {
+		return schedule, fmt.Errorf("branch or a tag parameter is required")
+	}
+	// Round trip the parameters so that they compare equal to those the API
+	// returns.
+	data, err := json.Marshal(parameters)
+	if err == nil {
+		err = json.Unmarshal(data, &schedule.Parameters)
+	}
+	if err != nil {
+		return schedule, fmt.Errorf("invalid parameters, expected strings, numbers or booleans: %v", err)
+	}
+	return schedule, nil
+}
+
+// parseCron converts a five field cron expression to a timetable. Timetables
+// run a number of times an hour rather than at given minutes, so the minute
+// field only sets how many times.
+func parseCron(expr string) (circleci.Timetable, error) {
+	var timetable circleci.Timetable
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return timetable, fmt.Errorf("expected 5 fields, found %d", len(fields))
+	}
+	minutes, err := expandCronField(fields[0], 0, 59, nil)
+	if err != nil {
+		return timetable, fmt.Errorf("minute: %v", err)
+	}
+	timetable.PerHour = len(minutes)
+	timetable.HoursOfDay, err = expandCronField(fields[1], 0, 23, nil)
+	if err != nil {
+		return timetable, fmt.Errorf("hour: %v", err)
+	}
+	if fields[2] != "*" {
+		timetable.DaysOfMonth, err = expandCronField(fields[2], 1, 31, nil)
+		if err != nil {
+			return timetable, fmt.Errorf("day of month: %v", err)
+		}
+	}
+	if fields[3] != "*" {
+		months, err := expandCronField(fields[3], 1, 12, cronMonths)
+		if err != nil {
+			return timetable, fmt.Errorf("month: %v", err)
+		}
+		for _, month := range months {
+			timetable.Months = append(timetable.Months, cronMonths[month-1])
+		}
+	}
+	if fields[4] != "*" {
+		days, err := expandCronField(fields[4], 0, 7, cronDays)
+		if err != nil {
+			return timetable, fmt.Errorf("day of week: %v", err)
+		}
+		for _, day := range days {
+			timetable.DaysOfWeek = append(timetable.DaysOfWeek, cronDays[day%7])
+		}
+	}
+	return normalizeTimetable(timetable), nil
+}
+
+// expandCronField returns the values a cron field matches, in order. The
+// field is a list of *, values or ranges, each optionally with a /step.
+// Values may be given by name, names[0] being min.
+func expandCronField(field string, min, max int, names []string) ([]int, error) {
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(s, name) {
+				return min + i, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("invalid value %q, expected %d to %d", s, min, max)
+		}
+		return n, nil
+	}
+
+	matched := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			step, part = n, part[:i]
+		}
+		first, last := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			first, err = value(bounds[0])
+			if err != nil {
+				return nil, err
+			}
+			last = first
+			if len(bounds) == 2 {
+				last, err = value(bounds[1])
+				if err != nil {
+					return nil, err
+				}
+			}
+			if last < first {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		for n := first; n <= last; n += step {
+			matched[n] = true
+		}
+	}
+	values := make([]int, 0, len(matched))
+	for n := range matched {
+		values = append(values, n)
+	}
+	sort.Ints(values)
+	return values, nil
+}
+
+// normalizeTimetable sorts and upper cases the timetable's days and months,
+// so that it compares equal to the same timetable returned by the API.
+func normalizeTimetable(timetable circleci.Timetable) circleci.Timetable {
+	order := func(values []string, names []string) []string {
+		if len(values) == 0 {
+			return nil
+		}
+		index := make(map[string]int, len(names))
+		for i, name := range names {
+			index[name] = i
+		}
+		sorted := make([]string, len(values))
+		for i, value := range values {
+			sorted[i] = strings.ToUpper(value)
+		}
+		sort.SliceStable(sorted, func(i, j int) bool { return index[sorted[i]] < index[sorted[j]] })
+		return sorted
+	}
+	ints := func(values []int) []int {
+		if len(values) == 0 {
+			return nil
+		}
+		sorted := append([]int(nil), values...)
+		sort.Ints(sorted)
+		return sorted
+	}
+	return circleci.Timetable{
+		PerHour:     timetable.PerHour,
+		HoursOfDay:  ints(timetable.HoursOfDay),
+		DaysOfWeek:  order(timetable.DaysOfWeek, cronDays),
+		DaysOfMonth: ints(timetable.DaysOfMonth),
+		Months:      order(timetable.Months, cronMonths),
+	}
+}
+
+// checkTimetable checks the values of a timetable.
+func checkTimetable(timetable circleci.Timetable) error {
+	if timetable.PerHour < 1 || timetable.PerHour > 60 {
+		return fmt.Errorf("invalid perHour %d, expected 1 to 60", timetable.PerHour)
+	}
+	if len(timetable.HoursOfDay) == 0 {
+		return fmt.Errorf("hoursOfDay is required")
+	}
+	for _, hour := range timetable.HoursOfDay {
+		if hour < 0 || hour > 23 {
+			return fmt.Errorf("invalid hour %d, expected 0 to 23", hour)
+		}
+	}
+	for _, day := range timetable.DaysOfMonth {
+		if day < 1 || day > 31 {
+			return fmt.Errorf("invalid day of month %d, expected 1 to 31", day)
+		}
+	}
+	for _, day := range timetable.DaysOfWeek {
+		if !containsName(cronDays, day) {
+			return fmt.Errorf("invalid day of week %q, expected one of %s", day, strings.Join(cronDays, ", "))
+		}
+	}
+	for _, month := range timetable.Months {
+		if !containsName(cronMonths, month) {
+			return fmt.Errorf("invalid month %q, expected one of %s", month, strings.Join(cronMonths, ", "))
+		}
+	}
+	return nil
+}
+
+// projectSchedules returns the config's schedules, keyed by name.
+func projectSchedules(config Config) (map[string]circleci.Schedule, error) {
+	schedules := make(map[string]circleci.Schedule, len(config.Schedules))
+	for name, c := range config.Schedules {
+		schedule, err := c.schedule(name)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %v", name, err)
+		}
+		schedules[name] = schedule
+	}
+	return schedules, nil
+}
+
+// scheduleManager is what managing scheduled pipelines needs of a project.
+type scheduleManager interface {
+	FullName() string
+	Schedules(ctx context.Context) ([]circleci.Schedule, error)
+	CreateSchedule(ctx context.Context, schedule circleci.Schedule) (circleci.Schedule, error)
+	UpdateSchedule(ctx context.Context, schedule circleci.Schedule) error
+	DeleteSchedule(ctx context.Context, id string) error
+}
+
+// matchSchedules returns the existing schedules of the config keyed by
+// name, and the others, which canonical mode deletes. Only the first
+// schedule of a name is matched, later ones are duplicates.
+func matchSchedules(existing []circleci.Schedule, schedules map[string]circleci.Schedule) (map[string]circleci.Schedule, []circleci.Schedule) {
+	byName := make(map[string]circleci.Schedule, len(existing))
+	var stale []circleci.Schedule
+	for _, schedule := range existing {
+		if _, ok := schedules[schedule.Name]; ok {
+			if _, seen := byName[schedule.Name]; !seen {
+				byName[schedule.Name] = schedule
+				continue
+			}
+		}
+		stale = append(stale, schedule)
+	}
+	return byName, stale
+}
+
+// ensureSchedules creates the schedules the project is missing and updates
+// those whose description, timetable or parameters differ from the config,
+// matching them by name. If canonical is set, schedules not in the config
+// are deleted.
+func ensureSchedules(ctx context.Context, project scheduleManager, schedules map[string]circleci.Schedule, canonical bool) error {
+	existing, err := project.Schedules(ctx)
+	if err != nil {
+		return err
+	}
+	byName, stale := matchSchedules(existing, schedules)
+	if canonical {
+		for _, schedule := range stale {
+			logInfof("Deleting schedule %s from project %s", schedule.Name, project.FullName())
+			err = project.DeleteSchedule(ctx, schedule.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	names := make([]string, 0, len(schedules))
+	for name := range schedules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		wanted := schedules[name]
+		current, ok := byName[name]
+		if !ok {
+			logInfof("Creating schedule %s for project %s", name, project.FullName())
+			if _, err = project.CreateSchedule(ctx, wanted); err != nil {
+				return err
+			}
+			continue
+		}
+		if current.Description == wanted.Description &&
+			reflect.DeepEqual(normalizeTimetable(current.Timetable), wanted.Timetable) &&
+			reflect.DeepEqual(current.Parameters, wanted.Parameters) {
+			continue
+		}
+		logInfof("Updating schedule %s of project %s", name, project.FullName())
+		wanted.ID = current.ID
+		if err = project.UpdateSchedule(ctx, wanted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+func TestParseCron(t *testing.T) {
+	testCases := []struct {
+		expr     string
+		expected circleci.Timetable
+	}{
+		{"0 3 * * *", circleci.Timetable{PerHour: 1, HoursOfDay: []int{3}}},
+		{"*/15 9-17/4 * * mon-fri", circleci.Timetable{PerHour: 4, HoursOfDay: []int{9, 13, 17},
+			DaysOfWeek: []string{"MON", "TUE", "WED", "THU", "FRI"}}},
+		{"0,30 0 1,15 jan,7 0,6", circleci.Timetable{PerHour: 2, HoursOfDay: []int{0}, DaysOfMonth: []int{1, 15},
+			Months: []string{"JAN", "JUL"}, DaysOfWeek: []string{"SUN", "SAT"}}},
+		{"0 12 * * 7", circleci.Timetable{PerHour: 1, HoursOfDay: []int{12}, DaysOfWeek: []string{"SUN"}}},
+	}
+	for _, tc := range testCases {
+		actual, err := parseCron(tc.expr)
+		if err != nil {
+			t.Errorf("%s: expected no error, found: %v", tc.expr, err)
+		}
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected %+v, found %+v", tc.expr, tc.expected, actual)
+		}
+	}
+
+	for _, expr := range []string{"0 3 * *", "0 24 * * *", "0 5-3 * * *", "0 3 * * funday", "*/0 3 * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+}
+
+func TestScheduleConfig(t *testing.T) {
+	c := ScheduleConfig{Cron: "0 3 * * *", Branch: "main", Parameters: map[string]interface{}{"deploy": true, "shards": 4}}
+	schedule, err := c.schedule("nightly")
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := circleci.Schedule{Name: "nightly", AttributionActor: circleci.ActorCurrent,
+		Timetable:  circleci.Timetable{PerHour: 1, HoursOfDay: []int{3}},
+		Parameters: map[string]interface{}{"branch": "main", "deploy": true, "shards": 4.0}}
+	if !reflect.DeepEqual(schedule, expected) {
+		t.Errorf("Expected %+v, found %+v", expected, schedule)
+	}
+
+	invalid := []ScheduleConfig{
+		{Branch: "main"},
+		{Cron: "0 3 * * *"},
+		{Cron: "0 3 * * *", Timetable: &circleci.Timetable{PerHour: 1, HoursOfDay: []int{3}}, Branch: "main"},
+		{Timetable: &circleci.Timetable{PerHour: 1, DaysOfWeek: []string{"MON"}}, Branch: "main"},
+		{Cron: "0 3 * * *", Branch: "main", AttributionActor: "bot"},
+	}
+	for _, c := range invalid {
+		if _, err := c.schedule("bad"); err == nil {
+			t.Errorf("Expected an error for %+v", c)
+		}
+	}
+}
+
+type fakeScheduleManager struct {
+	schedules []circleci.Schedule
+	created   []string
+	updated   []string
+	deleted   []string
+}
+
+func (p *fakeScheduleManager) FullName() string { return "owner/project" }
+
+func (p *fakeScheduleManager) Schedules(ctx context.Context) ([]circleci.Schedule, error) {
+	return p.schedules, nil
+}
+
+func (p *fakeScheduleManager) CreateSchedule(ctx context.Context, schedule circleci.Schedule) (circleci.Schedule, error) {
+	p.created = append(p.created, schedule.Name)
+	return schedule, nil
+}
+
+func (p *fakeScheduleManager) UpdateSchedule(ctx context.Context, schedule circleci.Schedule) error {
+	p.updated = append(p.updated, schedule.ID)
+	return nil
+}
+
+func (p *fakeScheduleManager) DeleteSchedule(ctx context.Context, id string) error {
+	p.deleted = append(p.deleted, id)
+	return nil
+}
+
+func TestEnsureSchedules(t *testing.T) {
+	timetable := circleci.Timetable{PerHour: 1, HoursOfDay: []int{3}, DaysOfWeek: []string{"MON", "TUE"}}
+	branch := map[string]interface{}{"branch": "main"}
+	existing := []circleci.Schedule{
+		{ID: "1", Name: "nightly", Timetable: circleci.Timetable{PerHour: 1, HoursOfDay: []int{3},
+			DaysOfWeek: []string{"TUE", "MON"}}, Parameters: branch},
+		{ID: "2", Name: "weekly", Timetable: timetable, Parameters: map[string]interface{}{"branch": "old"}},
+		{ID: "3", Name: "manual", Timetable: timetable, Parameters: branch},
+	}
+	schedules := map[string]circleci.Schedule{
+		"nightly": {Name: "nightly", Timetable: timetable, Parameters: branch},
+		"weekly":  {Name: "weekly", Timetable: timetable, Parameters: branch},
+		"hourly":  {Name: "hourly", Timetable: circleci.Timetable{PerHour: 1, HoursOfDay: []int{0}}, Parameters: branch},
+	}
+	testCases := []struct {
+		name      string
+		canonical bool
+		deleted   []string
+	}{
+		{"additive", false, nil},
+		{"canonical", true, []string{"3"}},
+	}
+
+	for _, tc := range testCases {
+		project := &fakeScheduleManager{schedules: existing}
+		err := ensureSchedules(context.Background(), project, schedules, tc.canonical)
+		if err != nil {
+			t.Errorf("%s: expected no error, found: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(project.created, []string{"hourly"}) || !reflect.DeepEqual(project.updated, []string{"2"}) ||
+			!reflect.DeepEqual(project.deleted, tc.deleted) {
+			t.Errorf("%s: expected hourly created, 2 updated and %v deleted, found %v, %v and %v",
+				tc.name, tc.deleted, project.created, project.updated, project.deleted)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Defaults of the secret cache shared by a run.
+const (
+	defaultSecretCacheTTL  = 10 * time.Minute
+	defaultSecretCacheSize = 1000
+)
+
+// secretEntry is a cached secret, or one being resolved until ready is
+// closed.
+type secretEntry struct {
+	value   string
+	err     error
+	expires time.Time
+	ready   chan struct{}
+}
+
+// secretCache is a SecretStore resolving each reference once across the
+// projects of a run, however many of them refer to it at once. Secrets are
+// reused until they are ttl old, and once maxEntries are cached the closest
+// to expiring is dropped. Failed lookups are not cached.
+type secretCache struct {
+	store      SecretStore
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*secretEntry // Keyed by path#key
+}
+
+// newSecretCache caches the secrets of store. With a ttl of 0 or no entries
+// the store is returned as it is.
+func newSecretCache(store SecretStore, ttl time.Duration, maxEntries int) SecretStore {
+	if ttl <= 0 || maxEntries <= 0 {
+		return store
+	}
+	return &secretCache{store: store, ttl: ttl, maxEntries: maxEntries, now: time.Now,
+		entries: make(map[string]*secretEntry)}
+}
+
+// Secret gets the key of the secret at path, from the cache if it is there.
+func (c *secretCache) Secret(path, key string) (string, error) {
+	ref := path + "#" + key
+	c.mu.Lock()
+	entry, ok := c.entries[ref]
+	if ok {
+		select {
+		case <-entry.ready:
+			ok = c.now().Before(entry.expires)
+		default:
+			// Another project is resolving it.
+		}
+	}
+	if ok {
+		c.mu.Unlock()
+		<-entry.ready
+		return entry.value, entry.err
+	}
+	entry = &secretEntry{ready: make(chan struct{})}
+	c.evict()
+	c.entries[ref] = entry
+	c.mu.Unlock()
+
+	entry.value, entry.err = c.store.Secret(path, key)
+
+	c.mu.Lock()
+	entry.expires = c.now().Add(c.ttl)
+	if entry.err != nil && c.entries[ref] == entry {
+		delete(c.entries, ref)
+	}
+	close(entry.ready)
+	c.mu.Unlock()
+	return entry.value, entry.err
+}
+
+// evict drops expired secrets and, if the cache is still full, the one
+// closest to expiring. c.mu must be held.
+func (c *secretCache) evict() {
+	now := c.now()
+	var oldest string
+	for ref, entry := range c.entries {
+		select {
+		case <-entry.ready:
+		default:
+			continue
+		}
+		if !now.Before(entry.expires) {
+			delete(c.entries, ref)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(c.entries[oldest].expires) {
+			oldest = ref
+		}
+	}
+	if len(c.entries) >= c.maxEntries && oldest != "" {
+		delete(c.entries, oldest)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingSecretStore counts the lookups of each reference, blocking them
+// until release is closed.
+type countingSecretStore struct {
+	release chan struct{}
+
+	mu      sync.Mutex
+	lookups map[string]int
+}
+
+func (s *countingSecretStore) Secret(path, key string) (string, error) {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups[path+"#"+key]++
+	if path == "missing" {
+		return "", fmt.Errorf("no secret %s", path)
+	}
+	return path + "-value", nil
+}
+
+func newCountingSecretStore() *countingSecretStore {
+	release := make(chan struct{})
+	close(release)
+	return &countingSecretStore{release: release, lookups: make(map[string]int)}
+}
+
+func TestSecretCacheConcurrent(t *testing.T) {
+	store := &countingSecretStore{release: make(chan struct{}), lookups: make(map[string]int)}
+	cache := newSecretCache(store, time.Minute, 10)
+
+	var wg sync.WaitGroup
+	values := make([]string, 20)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], _ = cache.Secret("secret/app", "token")
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(store.release)
+	wg.Wait()
+
+	if store.lookups["secret/app#token"] != 1 {
+		t.Errorf("Expected the secret to be looked up once, found %d", store.lookups["secret/app#token"])
+	}
+	for i, value := range values {
+		if value != "secret/app-value" {
+			t.Errorf("Expected lookup %d to return secret/app-value, found %q", i, value)
+		}
+	}
+}
+
+func TestSecretCacheExpiry(t *testing.T) {
+	store := newCountingSecretStore()
+	cache := newSecretCache(store, time.Minute, 2).(*secretCache)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Secret("a", "")
+	cache.Secret("a", "")
+	if store.lookups["a#"] != 1 {
+		t.Errorf("Expected a to be looked up once, found %d", store.lookups["a#"])
+	}
+	now = now.Add(2 * time.Minute)
+	cache.Secret("a", "")
+	if store.lookups["a#"] != 2 {
+		t.Errorf("Expected an expired a to be looked up again, found %d lookups", store.lookups["a#"])
+	}
+
+	// The cache is full once b is looked up, so the secret closest to
+	// expiring is dropped for each of c and d: a, then b.
+	for _, path := range []string{"b", "c"} {
+		now = now.Add(10 * time.Second)
+		cache.Secret(path, "")
+	}
+	now = now.Add(10 * time.Second)
+	cache.Secret("d", "")
+	if len(cache.entries) != 2 {
+		t.Errorf("Expected 2 cached secrets, found %d", len(cache.entries))
+	}
+	if _, ok := cache.entries["c#"]; !ok {
+		t.Errorf("Expected c to stay cached, found %v", cache.entries)
+	}
+	if _, ok := cache.entries["d#"]; !ok {
+		t.Errorf("Expected d to be cached, found %v", cache.entries)
+	}
+}
+
+func TestSecretCacheErrors(t *testing.T) {
+	store := newCountingSecretStore()
+	cache := newSecretCache(store, time.Minute, 10)
+	for i := 0; i < 2; i++ {
+		_, err := cache.Secret("missing", "key")
+		if err == nil {
+			t.Fatalf("Expected an error")
+		}
+	}
+	if store.lookups["missing#key"] != 2 {
+		t.Errorf("Expected failed lookups not to be cached, found %d lookups", store.lookups["missing#key"])
+	}
+}
+
+func TestNewSecretCacheDisabled(t *testing.T) {
+	store := newCountingSecretStore()
+	if cache := newSecretCache(store, 0, 10); cache != SecretStore(store) {
+		t.Errorf("Expected no cache with a ttl of 0, found %T", cache)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SecretStore looks up secrets referenced by config values.
+type SecretStore interface {
+	Secret(path, key string) (string, error)
+}
+
+// Why a secret could not be resolved, as resolve-check reports it.
+const (
+	secretUnreachable = "unreachable" // The store is not configured or could not be reached
+	secretDenied      = "denied"      // The store refused access to the secret
+	secretMissing     = "missing"     // The path or key does not exist
+	secretFailed      = "failed"      // Anything else
+)
+
+// secretError is an error looking up a secret, recording why it failed.
+type secretError struct {
+	reason string
+	err    error
+}
+
+func (e *secretError) Error() string {
+	return e.err.Error()
+}
+
+// secretErrorf formats an error looking up a secret that failed for reason.
+func secretErrorf(reason, format string, args ...interface{}) error {
+	return &secretError{reason, fmt.Errorf(format, args...)}
+}
+
+// secretFailure returns why err failed a secret lookup.
+func secretFailure(err error) string {
+	if e, ok := err.(*secretError); ok {
+		return e.reason
+	}
+	return secretFailed
+}
+
+// secretReference splits a scheme:path#key value into its parts, reporting
+// whether value refers to one of the stores. The key is optional.
+func secretReference(value string, stores map[string]SecretStore) (store SecretStore, path, key string, ok bool) {
+	i := strings.Index(value, ":")
+	if i < 0 {
+		return nil, "", "", false
+	}
+	store, ok = stores[value[:i]]
+	if !ok {
+		return nil, "", "", false
+	}
+	path = value[i+1:]
+	if j := strings.LastIndex(path, "#"); j >= 0 {
+		path, key = path[:j], path[j+1:]
+	}
+	return store, path, key, true
+}
+
+// resolveSecrets replaces the values of the config's project and context env
+// vars that refer to a secret store (e.g. vault:secret/app#token) with the
+// secret.
+func resolveSecrets(config *Config, stores map[string]SecretStore) error {
+	err := resolveSecretMap(config.EnvVars, stores)
+	if err != nil {
+		return err
+	}
+	for _, context := range config.Contexts {
+		err = resolveSecretMap(context.EnvVars, stores)
+		if err != nil {
+			return fmt.Errorf("context %s: %v", context.Name, err)
+		}
+	}
+	return nil
+}
+
+func resolveSecretMap(envVars map[string]string, stores map[string]SecretStore) error {
+	for _, name := range sortedKeys(envVars) {
+		store, path, key, ok := secretReference(envVars[name], stores)
+		if !ok {
+			continue
+		}
+		secret, err := store.Secret(path, key)
+		if err != nil {
+			return fmt.Errorf("%s: could not resolve %s: %v", name, envVars[name], err)
+		}
+		envVars[name] = secret
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type fakeSecretStore map[string]string
+
+func (s fakeSecretStore) Secret(path, key string) (string, error) {
+	value, ok := s[path+"#"+key]
+	if !ok {
+		return "", fmt.Errorf("no secret %s#%s", path, key)
+	}
+	return value, nil
+}
+
+func TestResolveSecrets(t *testing.T) {
+	stores := map[string]SecretStore{"vault": fakeSecretStore{"secret/app#token": "s3cret"}}
+	config := Config{
+		EnvVars:  EnvVars{"TOKEN": "vault:secret/app#token", "URL": "https://example.com"},
+		Contexts: []ContextConfig{{Name: "deploy", EnvVars: map[string]string{"KEY": "vault:secret/deploy#key"}}},
+	}
+
+	err := resolveSecrets(&config, stores)
+	expected := "context deploy: KEY: could not resolve vault:secret/deploy#key: no secret secret/deploy#key"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, found: %v", expected, err)
+	}
+	expectedEnvVars := EnvVars{"TOKEN": "s3cret", "URL": "https://example.com"}
+	if !reflect.DeepEqual(config.EnvVars, expectedEnvVars) {
+		t.Errorf("Expected env vars %v, found %v", expectedEnvVars, config.EnvVars)
+	}
+}
+
+func TestVaultClientSecret(t *testing.T) {
+	requests := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/app":
+			io.WriteString(w, `{"data": {"token": "v1-secret"}}`)
+		case "/v1/secret/data/app":
+			io.WriteString(w, `{"data": {"data": {"token": "v2-secret"}, "metadata": {"version": 3}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	vault := NewVaultClient(svr.URL, "token", "")
+	for path, expected := range map[string]string{"kv/app": "v1-secret", "secret/data/app": "v2-secret"} {
+		actual, err := vault.Secret(path, "token")
+		if err != nil {
+			t.Errorf("Expected no error reading %s, found: %v", path, err)
+		} else if actual != expected {
+			t.Errorf("Expected %s#token to be %q, found %q", path, expected, actual)
+		}
+	}
+	vault.Secret("kv/app", "token")
+	if requests != 2 {
+		t.Errorf("Expected each secret to be read once, found %d requests", requests)
+	}
+
+	_, err := vault.Secret("kv/app", "missing")
+	if err == nil {
+		t.Error("Expected a missing key to be an error")
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Set at build time with -ldflags "-X main.buildVersion=v1.2.3 -X
+// main.releasePublicKey=BASE64".
+var (
+	buildVersion     = "dev"
+	releasePublicKey = "" // Base64 ed25519 key signing the release checksums
+)
+
+// Release assets, besides the binary of each platform.
+const (
+	releaseOwner     = "nick96"
+	releaseRepo      = "circleci-provisioning"
+	checksumsAsset   = "checksums.txt"     // sha256sum output of the binaries
+	signatureAsset   = "checksums.txt.sig" // Base64 ed25519 signature of the tag and checksums.txt
+	releaseAssetName = "circleci-provision_%s_%s"
+)
+
+// updater replaces the running executable with a release binary.
+type updater struct {
+	github    *GitHubClient
+	owner     string
+	repo      string
+	publicKey ed25519.PublicKey
+	goos      string
+	goarch    string
+}
+
+// assetName returns the name of the release binary of the updater's
+// platform.
+func (u updater) assetName() string {
+	name := fmt.Sprintf(releaseAssetName, u.goos, u.goarch)
+	if u.goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// download gets the named asset of the release.
+func (u updater) download(ctx context.Context, release GitHubRelease, name string) ([]byte, error) {
+	for _, asset := range release.Assets {
+		if asset.Name != name {
+			continue
+		}
+		req, err := http.NewRequest(http.MethodGet, asset.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := u.github.client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("could not download %s: %v", name, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("could not download %s: status %s", name, resp.Status)
+		}
+		content, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("could not download %s: %v", name, err)
+		}
+		return content, nil
+	}
+	return nil, fmt.Errorf("release %s has no %s", release.TagName, name)
+}
+
+// signedPayload returns what the signature of a release covers: its tag on
+// a line of its own, followed by its checksums. Signing the tag stops the
+// checksums of an older release from being passed off as a newer one.
+func signedPayload(tag string, checksums []byte) []byte {
+	return append([]byte(tag+"\n"), checksums...)
+}
+
+// verifiedChecksum returns the checksum of the named asset, once the
+// checksums are verified to be signed with the updater's key for the
+// release tag.
+func (u updater) verifiedChecksum(tag string, checksums, signature []byte, name string) (string, error) {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || !ed25519.Verify(u.publicKey, signedPayload(tag, checksums), sig) {
+		return "", fmt.Errorf("the signature of %s for %s is invalid", checksumsAsset, tag)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum of %s", checksumsAsset, name)
+}
+
+// update replaces exe with the release's binary, once its checksum is
+// verified. The binary is written next to exe then renamed over it, so that
+// exe is never left half written.
+func (u updater) update(ctx context.Context, release GitHubRelease, exe string) error {
+	checksums, err := u.download(ctx, release, checksumsAsset)
+	if err != nil {
+		return err
+	}
+	signature, err := u.download(ctx, release, signatureAsset)
+	if err != nil {
+		return err
+	}
+	name := u.assetName()
+	checksum, err := u.verifiedChecksum(release.TagName, checksums, signature, name)
+	if err != nil {
+		return err
+	}
+	binary, err := u.download(ctx, release, name)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(binary)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return fmt.Errorf("the checksum of %s does not match %s", name, checksumsAsset)
+	}
+
+	fh, err := ioutil.TempFile(filepath.Dir(exe), "."+filepath.Base(exe)+".new")
+	if err != nil {
+		return fmt.Errorf("could not write the new binary: %v", err)
+	}
+	tmp := fh.Name()
+	defer os.Remove(tmp)
+	_, err = fh.Write(binary)
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0755)
+	}
+	if err != nil {
+		return fmt.Errorf("could not write the new binary: %v", err)
+	}
+	if u.goos == "windows" {
+		// A running executable cannot be replaced on Windows, but it can be
+		// moved out of the way.
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return fmt.Errorf("could not move %s out of the way: %v", exe, err)
+		}
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		return fmt.Errorf("could not replace %s: %v", exe, err)
+	}
+	return nil
+}
+
+func runSelfUpdate(args []string) error {
+	fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	version := fs.String("version", "", "Release to install (default the latest)")
+	check := fs.Bool("check", false, "Only report whether an update is available")
+	githubToken := fs.String("github-token", os.Getenv("GITHUB_TOKEN"), "GitHub token, to avoid rate limits")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s self-update [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(releasePublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("this build has no release key to verify updates with, download a release instead")
+	}
+	u := updater{github: NewGitHubClient(*githubToken), owner: releaseOwner, repo: releaseRepo,
+		publicKey: ed25519.PublicKey(key), goos: runtime.GOOS, goarch: runtime.GOARCH}
+
+	ctx := context.Background()
+	release, err := u.github.Release(ctx, u.owner, u.repo, *version)
+	if err != nil {
+		return err
+	}
+	if release.TagName == buildVersion {
+		logInfof("circleci-provision %s is up to date", buildVersion)
+		return nil
+	}
+	if *version == "" && olderVersion(release.TagName, buildVersion) {
+		return fmt.Errorf("the latest release %s is older than %s, pass -version to downgrade", release.TagName,
+			buildVersion)
+	}
+	if *check {
+		fmt.Printf("circleci-provision %s is available, this is %s\n", release.TagName, buildVersion)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		return fmt.Errorf("could not find the running executable: %v", err)
+	}
+	logInfof("Updating circleci-provision from %s to %s", buildVersion, release.TagName)
+	err = u.update(ctx, release, exe)
+	if err != nil {
+		return fmt.Errorf("could not update to %s: %v", release.TagName, err)
+	}
+	logInfof("Updated %s to %s", exe, release.TagName)
+	return nil
+}
+
+// olderVersion reports whether the release tag a is older than b. Tags that
+// are not vMAJOR.MINOR.PATCH, such as dev builds, are never older.
+func olderVersion(a, b string) bool {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	if !okA || !okB {
+		return false
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			return va[i] < vb[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses a vMAJOR.MINOR.PATCH tag.
+func parseVersion(tag string) ([3]int, bool) {
+	var v [3]int
+	parts := strings.Split(strings.TrimPrefix(tag, "v"), ".")
+	if len(parts) != len(v) || !strings.HasPrefix(tag, "v") {
+		return v, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// releaseServer serves the assets of a release, returning the release.
+func releaseServer(t *testing.T, assets map[string]string) (*httptest.Server, GitHubRelease) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := assets[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	release := GitHubRelease{TagName: "v1.0.0"}
+	for name := range assets {
+		release.Assets = append(release.Assets, struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		}{name, svr.URL + "/" + name})
+	}
+	return svr, release
+}
+
+func TestUpdate(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := "new binary"
+	sum := sha256.Sum256([]byte(binary))
+	checksums := fmt.Sprintf("%s  circleci-provision_linux_amd64\n%s  circleci-provision_darwin_amd64\n",
+		hex.EncodeToString(sum[:]), strings.Repeat("0", 64))
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, signedPayload("v1.0.0", []byte(checksums))))
+	// The signature of the same checksums released as an older tag.
+	oldSignature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, signedPayload("v0.9.0", []byte(checksums))))
+
+	tests := []struct {
+		name    string
+		assets  map[string]string
+		goarch  string
+		wantErr string
+	}{
+		{"verified", map[string]string{
+			checksumsAsset: checksums, signatureAsset: signature, "circleci-provision_linux_amd64": binary,
+		}, "amd64", ""},
+		{"bad signature", map[string]string{
+			checksumsAsset: checksums + "tampered", signatureAsset: signature, "circleci-provision_linux_amd64": binary,
+		}, "amd64", "signature"},
+		{"signature of another tag", map[string]string{
+			checksumsAsset: checksums, signatureAsset: oldSignature, "circleci-provision_linux_amd64": binary,
+		}, "amd64", "signature"},
+		{"bad checksum", map[string]string{
+			checksumsAsset: checksums, signatureAsset: signature, "circleci-provision_linux_amd64": "tampered",
+		}, "amd64", "checksum of circleci-provision_linux_amd64 does not match"},
+		{"no checksum", map[string]string{
+			checksumsAsset: checksums, signatureAsset: signature, "circleci-provision_linux_arm64": binary,
+		}, "arm64", "no checksum"},
+		{"no signature", map[string]string{
+			checksumsAsset: checksums, "circleci-provision_linux_amd64": binary,
+		}, "amd64", "has no checksums.txt.sig"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr, release := releaseServer(t, tt.assets)
+			defer svr.Close()
+			dir, err := ioutil.TempDir("", "selfupdate")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			exe := filepath.Join(dir, "circleci-provision")
+			err = ioutil.WriteFile(exe, []byte("old binary"), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			u := updater{github: &GitHubClient{svr.URL, "", svr.Client()}, publicKey: publicKey,
+				goos: "linux", goarch: tt.goarch}
+			err = u.update(context.Background(), release, exe)
+			content, readErr := ioutil.ReadFile(exe)
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				if string(content) != "old binary" {
+					t.Errorf("expected the executable to be left alone, got %q", content)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != binary {
+				t.Errorf("expected the executable to be replaced, got %q", content)
+			}
+			files, _ := ioutil.ReadDir(dir)
+			if len(files) != 1 {
+				t.Errorf("expected the temporary binary to be removed, found %d files", len(files))
+			}
+		})
+	}
+}
+
+func TestOlderVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"v1.2.3", "v1.2.4", true},
+		{"v1.9.0", "v1.10.0", true},
+		{"v2.0.0", "v1.10.0", false},
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.3", "dev", false},
+		{"v1.2", "v1.2.3", false},
+	}
+	for _, tt := range tests {
+		if got := olderVersion(tt.a, tt.b); got != tt.want {
+			t.Errorf("olderVersion(%q, %q) = %v, expected %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
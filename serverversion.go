@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// serverVersion returns the version of the CircleCI Server at apiURL,
+// detecting it once per installation and warning about the resources it does
+// not support, which then fail up front rather than with a 404. It is empty
+// if the version cannot be detected, so that nothing is held back.
+func (i *installations) serverVersion(apiURL string, v2Client circleci.Client) string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if version, ok := i.versions[apiURL]; ok {
+		return version
+	}
+	if i.versions == nil {
+		i.versions = make(map[string]string)
+	}
+
+	version, err := circleci.DetectServerVersion(context.Background(), v2Client)
+	i.versions[apiURL] = version
+	if err != nil {
+		logWarnf("Could not detect the CircleCI Server version of %s, pass -server-version to check which "+
+			"resources it supports: %v", apiURL, err)
+		return ""
+	}
+	logInfof("Detected CircleCI Server %s at %s", version, apiURL)
+	warnUnsupported(version)
+	return version
+}
+
+// warnUnsupported warns about each resource the Server version does not
+// support.
+func warnUnsupported(version string) {
+	for _, unsupported := range circleci.UnsupportedResources(version) {
+		logWarnf("Server %s does not support %s, which needs Server %s: configs managing it will fail",
+			version, unsupported.Resource, unsupported.Required)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// shadowReader is the set of read operations compared in shadow mode.
+type shadowReader interface {
+	Getenvs(ctx context.Context) (map[string]string, error)
+	CheckoutKeys(ctx context.Context) ([]circleci.CheckoutKey, error)
+}
+
+// shadowCompare reads the project through both API versions and returns a
+// description of every discrepancy between them. Nothing is mutated.
+func shadowCompare(ctx context.Context, v1, v2 shadowReader) ([]string, error) {
+	var discrepancies []string
+
+	v1Vars, err := v1.Getenvs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read environment variables via API v1.1: %v", err)
+	}
+	v2Vars, err := v2.Getenvs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read environment variables via API v2: %v", err)
+	}
+	for _, name := range sortedKeys(v1Vars) {
+		v2Value, ok := v2Vars[name]
+		if !ok {
+			discrepancies = append(discrepancies, fmt.Sprintf("environment variable %s missing from API v2", name))
+		} else if v2Value != v1Vars[name] {
+			discrepancies = append(discrepancies,
+				fmt.Sprintf("environment variable %s differs: v1.1 %q, v2 %q", name, v1Vars[name], v2Value))
+		}
+	}
+	for _, name := range sortedKeys(v2Vars) {
+		if _, ok := v1Vars[name]; !ok {
+			discrepancies = append(discrepancies, fmt.Sprintf("environment variable %s missing from API v1.1", name))
+		}
+	}
+
+	v1Keys, err := v1.CheckoutKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read checkout keys via API v1.1: %v", err)
+	}
+	v2Keys, err := v2.CheckoutKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read checkout keys via API v2: %v", err)
+	}
+	v1Fingerprints := checkoutKeyFingerprints(v1Keys)
+	v2Fingerprints := checkoutKeyFingerprints(v2Keys)
+	for _, fingerprint := range sortedKeys(v1Fingerprints) {
+		if _, ok := v2Fingerprints[fingerprint]; !ok {
+			discrepancies = append(discrepancies,
+				fmt.Sprintf("checkout key %s missing from API v2", fingerprint))
+		}
+	}
+	for _, fingerprint := range sortedKeys(v2Fingerprints) {
+		if _, ok := v1Fingerprints[fingerprint]; !ok {
+			discrepancies = append(discrepancies,
+				fmt.Sprintf("checkout key %s missing from API v1.1", fingerprint))
+		}
+	}
+
+	return discrepancies, nil
+}
+
+func checkoutKeyFingerprints(keys []circleci.CheckoutKey) map[string]string {
+	fingerprints := make(map[string]string)
+	for _, key := range keys {
+		fingerprints[key.Fingerprint] = key.Type
+	}
+	return fingerprints
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func runShadow(args []string) error {
+	fs := flag.NewFlagSet("shadow", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	vcsType, owner, projectName, err := s.projectSlug()
+	if err != nil {
+		return err
+	}
+	v1 := s.v1Project(vcsType, owner, projectName)
+	discrepancies, err := shadowCompare(s.ctx, v1, s.v2Project(vcsType, owner, projectName))
+	if err != nil {
+		return err
+	}
+	for _, discrepancy := range discrepancies {
+		logWarnf("Discrepancy for project %s: %s", v1.FullName(), discrepancy)
+	}
+	logInfof("Found %d discrepancies between API v1.1 and v2 for project %s", len(discrepancies), v1.FullName())
+	return nil
+}
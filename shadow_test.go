@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+type fakeShadowReader struct {
+	envVars map[string]string
+	keys    []circleci.CheckoutKey
+}
+
+func (r fakeShadowReader) Getenvs(ctx context.Context) (map[string]string, error) {
+	return r.envVars, nil
+}
+
+func (r fakeShadowReader) CheckoutKeys(ctx context.Context) ([]circleci.CheckoutKey, error) {
+	return r.keys, nil
+}
+
+func TestShadowCompare(t *testing.T) {
+	v1 := fakeShadowReader{
+		envVars: map[string]string{"A": "xxxx1", "B": "xxxx2", "C": "xxxx3"},
+		keys:    []circleci.CheckoutKey{{Fingerprint: "aa", Type: "deploy-key"}},
+	}
+	v2 := fakeShadowReader{
+		envVars: map[string]string{"A": "xxxx1", "B": "xxxx9", "D": "xxxx4"},
+		keys:    []circleci.CheckoutKey{{Fingerprint: "aa", Type: "deploy-key"}, {Fingerprint: "bb", Type: "github-user-key"}},
+	}
+
+	discrepancies, err := shadowCompare(context.Background(), v1, v2)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := []string{
+		`environment variable B differs: v1.1 "xxxx2", v2 "xxxx9"`,
+		"environment variable C missing from API v2",
+		"environment variable D missing from API v1.1",
+		"checkout key bb missing from API v1.1",
+	}
+	if !reflect.DeepEqual(discrepancies, expected) {
+		t.Errorf("Expected discrepancies %q, found %q", expected, discrepancies)
+	}
+
+	discrepancies, err = shadowCompare(context.Background(), v1, v1)
+	if err != nil || len(discrepancies) != 0 {
+		t.Errorf("Expected no discrepancies, found %q, %v", discrepancies, err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// sopsCommand is the sops binary used to decrypt configs. The sops Go
+// library (go.mozilla.org/sops/v3/decrypt) needs a newer Go than this module
+// targets, along with the SDKs of every key service, so the CLI is run
+// instead and must be installed to read encrypted configs.
+var sopsCommand = "sops"
+
+// isSOPSEncrypted reports whether data is a YAML document encrypted by
+// sops, which records its metadata under a top level sops key.
+func isSOPSEncrypted(data []byte) bool {
+	var doc struct {
+		Sops struct {
+			Mac     string `yaml:"mac"`
+			Version string `yaml:"version"`
+		} `yaml:"sops"`
+	}
+	// Configs that are templates may not be valid YAML, and are not
+	// encrypted.
+	if yaml.Unmarshal(data, &doc) != nil {
+		return false
+	}
+	return doc.Sops.Mac != "" && doc.Sops.Version != ""
+}
+
+// decryptSOPS decrypts the sops encrypted config file.
+func decryptSOPS(configFile string) ([]byte, error) {
+	command, err := exec.LookPath(sopsCommand)
+	if err != nil {
+		return nil, fmt.Errorf("it is encrypted with sops, but %s was not found on the PATH: "+
+			"install sops from https://github.com/mozilla/sops to read encrypted configs", sopsCommand)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(command, "--decrypt", "--input-type", "yaml", "--output-type", "yaml", configFile)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("could not decrypt with sops: %v: %s", err, msg)
+		}
+		return nil, fmt.Errorf("could not decrypt with sops: %v", err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const sopsEncryptedConfig = `projectName: ENC[AES256_GCM,data:abc,iv:def,tag:ghi,type:str]
+sops:
+    mac: ENC[AES256_GCM,data:jkl,iv:mno,tag:pqr,type:str]
+    version: 3.5.0
+`
+
+func TestIsSOPSEncrypted(t *testing.T) {
+	testCases := []struct {
+		data     string
+		expected bool
+	}{
+		{sopsEncryptedConfig, true},
+		{"projectName: test\n", false},
+		{"sops: {}\n", false},
+		{"envVars:\n  A: {{ .Missing }\n", false},
+	}
+	for _, tc := range testCases {
+		if actual := isSOPSEncrypted([]byte(tc.data)); actual != tc.expected {
+			t.Errorf("Expected %v for %q, found %v", tc.expected, tc.data, actual)
+		}
+	}
+}
+
+func TestReadConfigSOPS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake sops is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "sops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fakeSOPS := filepath.Join(dir, "sops")
+	err = ioutil.WriteFile(fakeSOPS, []byte("#!/bin/sh\nprintf 'projectName: decrypted\\n'\n"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "config.yml")
+	err = ioutil.WriteFile(configFile, []byte(sopsEncryptedConfig), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(command string) { sopsCommand = command }(sopsCommand)
+	sopsCommand = fakeSOPS
+	config, err := readConfig(configFile, configOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if config.ProjectName != "decrypted" {
+		t.Errorf("Expected the decrypted config to be read, found project %q", config.ProjectName)
+	}
+
+	sopsCommand = filepath.Join(dir, "missing")
+	_, err = readConfig(configFile, configOptions{})
+	if err == nil || !strings.Contains(err.Error(), "not found on the PATH") {
+		t.Errorf("Expected an error saying sops is not installed, found: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sources of env var values besides the secret stores.
+const (
+	sourceEnv     = "env"     // env:NAME, the environment variable NAME if it is set and not empty
+	sourceLiteral = "literal" // literal:VALUE, VALUE itself
+)
+
+// collectSources records the sources of specs declared as fallback chains,
+// prefixing their names with prefix.
+func (c *Config) collectSources(prefix string, specs map[string]envVarSpec) error {
+	for name, spec := range specs {
+		if len(spec.Sources) == 0 {
+			continue
+		}
+		if spec.Value != "" {
+			return fmt.Errorf("%s sets both a value and sources", name)
+		}
+		for _, source := range spec.Sources {
+			if !strings.Contains(source, ":") {
+				return fmt.Errorf("invalid source %q of %s, expected scheme:reference", source, name)
+			}
+		}
+		if c.Sources == nil {
+			c.Sources = make(map[string][]string)
+		}
+		c.Sources[prefix+name] = spec.Sources
+	}
+	return nil
+}
+
+// renameEnvVar moves what the config records about the env var, such as
+// its expiry and sources, to its new name.
+func (c *Config) renameEnvVar(name, mapped string) {
+	if expiresAt, ok := c.Expiry[name]; ok {
+		c.Expiry[mapped] = expiresAt
+		delete(c.Expiry, name)
+	}
+	if sources, ok := c.Sources[name]; ok {
+		c.Sources[mapped] = sources
+		delete(c.Sources, name)
+	}
+	if file, ok := c.Files[name]; ok {
+		c.Files[mapped] = file
+		delete(c.Files, name)
+	}
+}
+
+// resolveSource returns the value of a single source, or why it could not be
+// resolved.
+func resolveSource(source string, stores map[string]SecretStore, lookup func(string) (string, bool)) (string, error) {
+	i := strings.Index(source, ":")
+	scheme, reference := source[:i], source[i+1:]
+	switch scheme {
+	case sourceLiteral:
+		return reference, nil
+	case sourceEnv:
+		value, ok := lookup(reference)
+		if !ok || value == "" {
+			return "", fmt.Errorf("environment variable %s is not set", reference)
+		}
+		return value, nil
+	}
+	store, path, key, ok := secretReference(source, stores)
+	if !ok {
+		return "", fmt.Errorf("unknown source %s", scheme)
+	}
+	return store.Secret(path, key)
+}
+
+// resolveSources sets the env vars declared as fallback chains to the value
+// of the first of their sources that resolves, recording which one in the
+// config's provenance. It fails if none of an env var's sources resolve.
+func resolveSources(config *Config, stores map[string]SecretStore, lookup func(string) (string, bool)) error {
+	for _, name := range sortedKeys(config.EnvVars) {
+		sources, ok := config.Sources[name]
+		if !ok {
+			continue
+		}
+		var failures []string
+		resolved := false
+		for _, source := range sources {
+			value, err := resolveSource(source, stores, lookup)
+			if err != nil {
+				logDebugf("Could not resolve %s from %s: %v", name, source, err)
+				failures = append(failures, fmt.Sprintf("%s: %v", source, err))
+				continue
+			}
+			provenance := source
+			if strings.HasPrefix(source, sourceLiteral+":") {
+				provenance = sourceLiteral // The literal may be a secret
+			}
+			logInfof("Resolved environment variable %s from %s", name, provenance)
+			config.EnvVars[name] = value
+			if config.Provenance == nil {
+				config.Provenance = make(map[string]string)
+			}
+			config.Provenance[name] = provenance
+			resolved = true
+			break
+		}
+		if !resolved {
+			return fmt.Errorf("%s: no source could be resolved (%s)", name, strings.Join(failures, "; "))
+		}
+	}
+	return nil
+}
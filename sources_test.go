@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestUnmarshalSources(t *testing.T) {
+	data := []byte(`
+envVars:
+  PLAIN: value
+  TOKEN: [vault:secret/app#token, env:TOKEN, literal:default]
+  KEY:
+    sources: [env:KEY]
+    expiresAt: 2030-01-01
+namespaces:
+  svc-a:
+    envVars:
+      URL: [env:SVC_A_URL]
+`)
+	var config Config
+	err := yaml.Unmarshal(data, &config)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := map[string][]string{
+		"TOKEN":      {"vault:secret/app#token", "env:TOKEN", "literal:default"},
+		"KEY":        {"env:KEY"},
+		"SVC_A__URL": {"env:SVC_A_URL"},
+	}
+	if !reflect.DeepEqual(config.Sources, expected) {
+		t.Errorf("Expected sources %v, found %v", expected, config.Sources)
+	}
+	if _, ok := config.Expiry["KEY"]; !ok || config.EnvVars["PLAIN"] != "value" {
+		t.Errorf("Expected plain values and expiry to be read as before, found %+v", config)
+	}
+
+	err = yaml.Unmarshal([]byte("envVars:\n  A: [default]\n"), &Config{})
+	if err == nil {
+		t.Error("Expected an error for a source without a scheme")
+	}
+}
+
+func TestResolveSources(t *testing.T) {
+	stores := map[string]SecretStore{"vault": fakeSecretStore{"secret/app#token": "s3cret"}}
+	lookup := func(name string) (string, bool) {
+		value, ok := map[string]string{"FALLBACK": "from-env", "EMPTY": ""}[name]
+		return value, ok
+	}
+	config := Config{
+		EnvVars: EnvVars{"VAULT": "", "ENV": "", "LITERAL": ""},
+		Sources: map[string][]string{
+			"VAULT":   {"vault:secret/app#token", "literal:default"},
+			"ENV":     {"vault:secret/missing#token", "env:EMPTY", "env:FALLBACK"},
+			"LITERAL": {"env:UNSET", "literal:default"},
+		},
+	}
+
+	err := resolveSources(&config, stores, lookup)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := EnvVars{"VAULT": "s3cret", "ENV": "from-env", "LITERAL": "default"}
+	if !reflect.DeepEqual(config.EnvVars, expected) {
+		t.Errorf("Expected env vars %v, found %v", expected, config.EnvVars)
+	}
+	expectedProvenance := map[string]string{"VAULT": "vault:secret/app#token", "ENV": "env:FALLBACK", "LITERAL": "literal"}
+	if !reflect.DeepEqual(config.Provenance, expectedProvenance) {
+		t.Errorf("Expected provenance %v, found %v", expectedProvenance, config.Provenance)
+	}
+
+	config = Config{EnvVars: EnvVars{"A": ""}, Sources: map[string][]string{"A": {"env:UNSET", "aws-sm:token"}}}
+	err = resolveSources(&config, stores, lookup)
+	expectedErr := "A: no source could be resolved (env:UNSET: environment variable UNSET is not set; " +
+		"aws-sm:token: unknown source aws-sm)"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected error %q, found: %v", expectedErr, err)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// Types of SSH keys that can be generated.
+const (
+	sshKeyED25519 = "ed25519"
+	sshKeyRSA     = "rsa"
+)
+
+// rsaKeyBits is the size of the RSA keys generated.
+const rsaKeyBits = 4096
+
+// GeneratedSSHKey is the key of a hostname generated the first time the
+// project is provisioned, rather than read from a file.
+type GeneratedSSHKey struct {
+	Type      string // ed25519 or rsa
+	PublicKey string // Where to write the public key, empty to log it
+}
+
+// generated returns the spec of the key to generate, if the hostname
+// declares one.
+func (s sshKeySpecs) generated() (sshKeySpec, bool) {
+	for _, spec := range s {
+		if spec.Generate != "" {
+			return spec, true
+		}
+	}
+	return sshKeySpec{}, false
+}
+
+// collectGeneratedSSHKeys parses the keys of specs to generate into the config.
+func (c *Config) collectGeneratedSSHKeys(specs map[string]sshKeySpecs) error {
+	for hostname, keys := range specs {
+		spec, ok := keys.generated()
+		if !ok {
+			continue
+		}
+		switch {
+		case len(keys) > 1:
+			return fmt.Errorf("invalid SSH keys of %s: a generated key cannot be rotated", hostname)
+		case spec.Path != "":
+			return fmt.Errorf("invalid SSH keys of %s: path and generate are exclusive", hostname)
+		case spec.Generate != sshKeyED25519 && spec.Generate != sshKeyRSA:
+			return fmt.Errorf("invalid SSH keys of %s: cannot generate %s keys, only %s and %s",
+				hostname, spec.Generate, sshKeyED25519, sshKeyRSA)
+		}
+		if c.GeneratedSSHKeys == nil {
+			c.GeneratedSSHKeys = make(map[string]GeneratedSSHKey)
+		}
+		c.GeneratedSSHKeys[hostname] = GeneratedSSHKey{spec.Generate, spec.PublicKey}
+	}
+	return nil
+}
+
+// generatesSSHKey reports whether the key of hostname is generated.
+func (c Config) generatesSSHKey(hostname string) bool {
+	_, ok := c.GeneratedSSHKeys[hostname]
+	return ok
+}
+
+// generatedHostnames returns the hostnames whose keys are generated, in order.
+func (c Config) generatedHostnames() []string {
+	hostnames := make([]string, 0, len(c.GeneratedSSHKeys))
+	for hostname := range c.GeneratedSSHKeys {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+	return hostnames
+}
+
+// hasSSHKey reports whether keys include one for hostname.
+func hasSSHKey(keys []circleci.SSHKey, hostname string) bool {
+	for _, key := range keys {
+		if key.Hostname == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// generateSSHKey returns a new PEM encoded private key of keyType, and its
+// public key in authorized_keys format.
+func generateSSHKey(keyType, comment string) (privateKey, publicKey []byte, err error) {
+	var signer interface{}
+	switch keyType {
+	case sshKeyED25519:
+		var key ed25519.PrivateKey
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+		if err == nil {
+			privateKey, err = marshalED25519PrivateKey(key, comment)
+		}
+		signer = key
+	case sshKeyRSA:
+		var key *rsa.PrivateKey
+		key, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err == nil {
+			privateKey = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		}
+		signer = key
+	default:
+		return nil, nil, fmt.Errorf("cannot generate %s keys", keyType)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	sshSigner, err := ssh.NewSignerFromKey(signer)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicKey = ssh.MarshalAuthorizedKey(sshSigner.PublicKey())
+	publicKey = append(publicKey[:len(publicKey)-1], " "+comment+"\n"...)
+	return privateKey, publicKey, nil
+}
+
+// marshalED25519PrivateKey encodes key in the unencrypted OpenSSH format,
+// the only one ed25519 keys have.
+func marshalED25519PrivateKey(key ed25519.PrivateKey, comment string) ([]byte, error) {
+	var check [4]byte
+	if _, err := rand.Read(check[:]); err != nil {
+		return nil, err
+	}
+	public := key.Public().(ed25519.PublicKey)
+	private := struct {
+		Check1, Check2 uint32
+		Type           string
+		Public         []byte
+		Private        []byte
+		Comment        string
+		Pad            []byte `ssh:"rest"`
+	}{
+		Check1:  binary.BigEndian.Uint32(check[:]),
+		Check2:  binary.BigEndian.Uint32(check[:]),
+		Type:    ssh.KeyAlgoED25519,
+		Public:  public,
+		Private: key,
+		Comment: comment,
+	}
+	// The private section is padded with 1, 2, 3, ... to the cipher's block
+	// size, which is 8 without a cipher.
+	for i := 1; len(ssh.Marshal(private))%8 != 0; i++ {
+		private.Pad = append(private.Pad, byte(i))
+	}
+	publicBlob := ssh.Marshal(struct {
+		Type   string
+		Public []byte
+	}{ssh.KeyAlgoED25519, public})
+	envelope := struct {
+		Cipher     string
+		KDF        string
+		KDFOptions string
+		Keys       uint32
+		Public     []byte
+		Private    []byte
+	}{"none", "none", "", 1, publicBlob, ssh.Marshal(private)}
+	data := append([]byte("openssh-key-v1\x00"), ssh.Marshal(envelope)...)
+	return pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: data}), nil
+}
+
+// generateSSHKeys generates and adds the key of each hostname of the config
+// generating one that the project has no key for yet, writing or logging its
+// public key, and returns the public keys generated keyed by hostname.
+// Hostnames that already have one are left as they are, as CircleCI never
+// returns private keys. Each key is recorded in report.
+func generateSSHKeys(ctx context.Context, project circleci.Project, config Config, report *Report) (map[string][]byte, error) {
+	if len(config.GeneratedSSHKeys) == 0 {
+		return nil, nil
+	}
+	keys, err := project.GetSSHKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get SSH keys: %v", err)
+	}
+	generated := make(map[string][]byte)
+
+	var errs []error
+	for _, hostname := range config.generatedHostnames() {
+		if hasSSHKey(keys, hostname) {
+			logInfof("Project %s already has an SSH key for %s, not generating one", project.FullName(), hostname)
+			report.Record(project.FullName(), circleci.ResourceSSHKey, hostname, outcomeSkipped, nil)
+			continue
+		}
+		publicKey, err := generateSSHKeyFor(ctx, project, hostname, config.GeneratedSSHKeys[hostname])
+		report.Record(project.FullName(), circleci.ResourceSSHKey, hostname, outcomeCreated, err)
+		if publicKey != nil {
+			generated[hostname] = publicKey
+		}
+		errs = append(errs, err)
+	}
+	return generated, joinErrors(errs)
+}
+
+// generateSSHKeyFor generates and adds the key of hostname, then publishes
+// its public key, which is returned once the key has been added.
+func generateSSHKeyFor(ctx context.Context, project circleci.Project, hostname string, key GeneratedSSHKey) ([]byte, error) {
+	logInfof("Generating %s SSH key %s for project %s", key.Type, hostname, project.FullName())
+	privateKey, publicKey, err := generateSSHKey(key.Type, project.FullName())
+	if err != nil {
+		return nil, fmt.Errorf("could not generate SSH key %s: %v", hostname, err)
+	}
+	err = project.AddSSHKey(ctx, hostname, string(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("could not add SSH key %s for project %s: %v", hostname, project.FullName(), err)
+	}
+	if key.PublicKey == "" {
+		logInfof("Public key of SSH key %s for project %s: %s", hostname, project.FullName(), bytes.TrimSpace(publicKey))
+		return publicKey, nil
+	}
+	err = ioutil.WriteFile(key.PublicKey, publicKey, 0644)
+	if err != nil {
+		return publicKey, fmt.Errorf("could not write public key of SSH key %s to %s: %v", hostname, key.PublicKey, err)
+	}
+	logInfof("Wrote public key of SSH key %s for project %s to %s", hostname, project.FullName(), key.PublicKey)
+	return publicKey, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+	"golang.org/x/crypto/ssh"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestReadGeneratedSSHKeys(t *testing.T) {
+	data := `
+sshKeys:
+  example.com: example.com.key
+  github.com:
+    generate: ed25519
+    publicKey: github.pub
+`
+	var config Config
+	err := yaml.Unmarshal([]byte(data), &config)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if expected := (SSHKeys{"example.com": "example.com.key"}); !reflect.DeepEqual(config.SSHKeys, expected) {
+		t.Errorf("Expected SSH keys %v, found %v", expected, config.SSHKeys)
+	}
+	expected := map[string]GeneratedSSHKey{"github.com": {sshKeyED25519, "github.pub"}}
+	if !reflect.DeepEqual(config.GeneratedSSHKeys, expected) {
+		t.Errorf("Expected generated SSH keys %v, found %v", expected, config.GeneratedSSHKeys)
+	}
+
+	invalid := []string{
+		"sshKeys:\n  github.com:\n    generate: dsa\n",
+		"sshKeys:\n  github.com:\n    generate: ed25519\n    path: a.key\n",
+		"sshKeys:\n  github.com:\n    - generate: ed25519\n    - path: b.key\n      activeUntil: 2026-11-01\n",
+	}
+	for _, data := range invalid {
+		if err := yaml.Unmarshal([]byte(data), &Config{}); err == nil {
+			t.Errorf("Expected an error reading %q", data)
+		}
+	}
+}
+
+func TestGenerateSSHKey(t *testing.T) {
+	privateKey, publicKey, err := generateSSHKey(sshKeyED25519, "git/test/test")
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("Expected the private key to parse, found: %v", err)
+	}
+	public, comment, _, _, err := ssh.ParseAuthorizedKey(publicKey)
+	if err != nil {
+		t.Fatalf("Expected the public key to parse, found: %v", err)
+	}
+	if string(public.Marshal()) != string(signer.PublicKey().Marshal()) {
+		t.Errorf("Expected the public key to match the private key, found %s", publicKey)
+	}
+	if public.Type() != ssh.KeyAlgoED25519 || comment != "git/test/test" {
+		t.Errorf("Expected an ed25519 key commented git/test/test, found %s", publicKey)
+	}
+
+	if _, _, err := generateSSHKey("dsa", ""); err == nil {
+		t.Error("Expected an error generating a dsa key")
+	}
+}
+
+func TestGenerateSSHKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"GET " + circlecitest.SettingsPath:    {Status: http.StatusOK, Body: circlecitest.Settings},
+		"POST /project/git/test/test/ssh-key": {Status: http.StatusCreated, Body: ``},
+	})
+	defer svr.Close()
+
+	publicKeyPath := filepath.Join(dir, "deploy.pub")
+	config := Config{GeneratedSSHKeys: map[string]GeneratedSSHKey{
+		"github.com":  {sshKeyED25519, ""},
+		"deploy.com":  {sshKeyED25519, publicKeyPath},
+		"example.com": {sshKeyED25519, ""},
+	}}
+	report := NewReport(nil)
+	generated, err := generateSSHKeys(context.Background(), svr.project(), config, report)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if len(generated) != 1 || generated["deploy.com"] == nil {
+		t.Fatalf("Expected the public key of deploy.com to be returned, found %q", generated)
+	}
+	if len(svr.Requests()) != 2 || !strings.HasPrefix(svr.Requests()[1], "POST /project/git/test/test/ssh-key ") {
+		t.Fatalf("Expected only the key of deploy.com to be added, found %q", svr.Requests())
+	}
+	var added struct {
+		Hostname   string `json:"hostname"`
+		PrivateKey string `json:"private_key"`
+	}
+	err = json.Unmarshal([]byte(strings.TrimPrefix(svr.Requests()[1], "POST /project/git/test/test/ssh-key ")), &added)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Hostname != "deploy.com" {
+		t.Errorf("Expected the key of deploy.com to be added, found %s", added.Hostname)
+	}
+	fingerprint, err := sshKeyFingerprint([]byte(added.PrivateKey))
+	if err != nil {
+		t.Fatalf("Expected the added key to parse, found: %v", err)
+	}
+	content, err := ioutil.ReadFile(publicKeyPath)
+	if err != nil {
+		t.Fatalf("Expected the public key to be written, found: %v", err)
+	}
+	public, _, _, _, err := ssh.ParseAuthorizedKey(content)
+	if err != nil {
+		t.Fatalf("Expected the public key to parse, found: %v", err)
+	}
+	if string(content) != string(generated["deploy.com"]) {
+		t.Errorf("Expected the public key returned to be the one written, found %s", generated["deploy.com"])
+	}
+	if ssh.FingerprintLegacyMD5(public) != fingerprint {
+		t.Errorf("Expected the public key written to match the key added, found %s", content)
+	}
+}
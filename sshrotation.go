@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// SSHKeys are the SSH keys to add, keyed by hostname. Each is either the path
+// of the key, or a list of keys: the current one, and previous ones kept
+// until their activeUntil while the hostname's key is rotated.
+type SSHKeys map[string]string
+
+// RetiringSSHKey is a previous key of a hostname, kept on the project
+// alongside the current one until ActiveUntil and removed afterwards.
+type RetiringSSHKey struct {
+	Path        string
+	ActiveUntil time.Time
+}
+
+// sshKeySpec is one key of a hostname.
+type sshKeySpec struct {
+	Path        string `yaml:"path"`
+	ActiveUntil string `yaml:"activeUntil"` // Set on previous keys being rotated out
+	Generate    string `yaml:"generate"`    // Type of key to generate instead of reading one from path
+	PublicKey   string `yaml:"publicKey"`   // Where to write the public key of a generated key
+	DeployKey   string `yaml:"deployKey"`   // Access to give the public key as a GitHub deploy key, if any
+}
+
+// sshKeySpecs are the keys of a hostname.
+type sshKeySpecs []sshKeySpec
+
+// UnmarshalYAML reads a path, a key or a list of keys.
+func (s *sshKeySpecs) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var path string
+	if err := unmarshal(&path); err == nil {
+		*s = sshKeySpecs{{Path: path}}
+		return nil
+	}
+	var spec sshKeySpec
+	if err := unmarshal(&spec); err == nil {
+		*s = sshKeySpecs{spec}
+		return nil
+	}
+	type plain sshKeySpecs
+	return unmarshal((*plain)(s))
+}
+
+// current returns the path of the key without an activeUntil.
+func (s sshKeySpecs) current() (string, error) {
+	path := ""
+	for _, spec := range s {
+		if spec.ActiveUntil != "" {
+			continue
+		}
+		if path != "" {
+			return "", fmt.Errorf("more than one key without activeUntil")
+		}
+		path = spec.Path
+	}
+	if path == "" {
+		return "", fmt.Errorf("no current key, every key has an activeUntil")
+	}
+	return path, nil
+}
+
+// UnmarshalYAML reads the current key of each hostname, ignoring previous
+// ones and generated ones which are collected by Config.
+func (k *SSHKeys) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var specs map[string]sshKeySpecs
+	err := unmarshal(&specs)
+	if err != nil {
+		return err
+	}
+	*k = make(SSHKeys, len(specs))
+	for hostname, keys := range specs {
+		if _, ok := keys.generated(); ok {
+			continue
+		}
+		(*k)[hostname], err = keys.current()
+		if err != nil {
+			return fmt.Errorf("invalid SSH keys of %s: %v", hostname, err)
+		}
+	}
+	return nil
+}
+
+// collectRetiringSSHKeys parses the previous keys of specs into the config.
+func (c *Config) collectRetiringSSHKeys(specs map[string]sshKeySpecs) error {
+	for hostname, keys := range specs {
+		for _, spec := range keys {
+			if spec.ActiveUntil == "" {
+				continue
+			}
+			activeUntil, err := parseExpiry(spec.ActiveUntil)
+			if err != nil {
+				return fmt.Errorf("invalid activeUntil of SSH key %s for %s: %v", spec.Path, hostname, err)
+			}
+			if c.RetiringSSHKeys == nil {
+				c.RetiringSSHKeys = make(map[string][]RetiringSSHKey)
+			}
+			c.RetiringSSHKeys[hostname] = append(c.RetiringSSHKeys[hostname], RetiringSSHKey{spec.Path, activeUntil})
+		}
+	}
+	return nil
+}
+
+// overlappingFingerprints returns the fingerprints of the previous keys of
+// each hostname that are still active at now.
+func overlappingFingerprints(config Config, now time.Time) (map[string][]string, error) {
+	overlapping := make(map[string][]string)
+	for hostname, keys := range config.RetiringSSHKeys {
+		for _, key := range keys {
+			if !now.Before(key.ActiveUntil) {
+				continue
+			}
+			content, err := ioutil.ReadFile(key.Path)
+			if err != nil {
+				return nil, fmt.Errorf("could not read SSH key at path %s: %v", key.Path, err)
+			}
+			fingerprint, err := sshKeyFingerprint(content)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse SSH key at path %s: %v", key.Path, err)
+			}
+			overlapping[hostname] = append(overlapping[hostname], fingerprint)
+		}
+	}
+	return overlapping, nil
+}
+
+// rotateSSHKeys keeps the previous keys of hostnames being rotated on the
+// project until their activeUntil, adding any that are missing, and removes
+// them once it has passed. Each key is recorded in report.
+func rotateSSHKeys(ctx context.Context, project circleci.Project, config Config, now time.Time, report *Report) error {
+	if len(config.RetiringSSHKeys) == 0 {
+		return nil
+	}
+	keys, err := project.GetSSHKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get SSH keys: %v", err)
+	}
+	live := make(map[string]bool)
+	for _, key := range keys {
+		live[key.Hostname+" "+key.Fingerprint] = true
+	}
+
+	hostnames := make([]string, 0, len(config.RetiringSSHKeys))
+	for hostname := range config.RetiringSSHKeys {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+	var errs []error
+	for _, hostname := range hostnames {
+		for _, key := range config.RetiringSSHKeys[hostname] {
+			content, err := ioutil.ReadFile(key.Path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("could not read SSH key at path %s: %v", key.Path, err))
+				continue
+			}
+			fingerprint, err := sshKeyFingerprint(content)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("could not parse SSH key at path %s: %v", key.Path, err))
+				continue
+			}
+			present := live[hostname+" "+fingerprint]
+			name := fmt.Sprintf("%s (until %s)", hostname, key.ActiveUntil.Format("2006-01-02"))
+			if now.Before(key.ActiveUntil) && !present {
+				logInfof("Keeping previous SSH key %s for %s of project %s until %s", fingerprint, hostname,
+					project.FullName(), key.ActiveUntil.Format(time.RFC3339))
+				err = project.AddSSHKey(ctx, hostname, string(content))
+				report.Record(project.FullName(), circleci.ResourceSSHKey, name, outcomeCreated, err)
+			} else if !now.Before(key.ActiveUntil) && present {
+				logInfof("Removing previous SSH key %s for %s of project %s, it was active until %s", fingerprint,
+					hostname, project.FullName(), key.ActiveUntil.Format(time.RFC3339))
+				err = project.DeleteSSHKey(ctx, hostname, fingerprint)
+				report.Record(project.FullName(), circleci.ResourceSSHKey, name, outcomeUpdated, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return joinErrors(errs)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// writeTestSSHKey writes a new private key to a temporary file, returning its
+// path and fingerprint.
+func writeTestSSHKey(t *testing.T) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	fh, err := ioutil.TempFile("", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh.Write(keyPEM)
+	fh.Close()
+	fingerprint, err := sshKeyFingerprint(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fh.Name(), fingerprint
+}
+
+func TestReadRotatingSSHKeys(t *testing.T) {
+	data := `
+sshKeys:
+  example.com: example.com.key
+  github.com:
+    - path: new.key
+    - path: old.key
+      activeUntil: 2026-11-01
+`
+	var config Config
+	err := yaml.Unmarshal([]byte(data), &config)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := SSHKeys{"example.com": "example.com.key", "github.com": "new.key"}
+	if !reflect.DeepEqual(config.SSHKeys, expected) {
+		t.Errorf("Expected SSH keys %v, found %v", expected, config.SSHKeys)
+	}
+	retiring := map[string][]RetiringSSHKey{
+		"github.com": {{"old.key", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)}},
+	}
+	if !reflect.DeepEqual(config.RetiringSSHKeys, retiring) {
+		t.Errorf("Expected retiring SSH keys %v, found %v", retiring, config.RetiringSSHKeys)
+	}
+
+	invalid := []string{
+		"sshKeys:\n  github.com:\n    - path: a.key\n    - path: b.key\n",
+		"sshKeys:\n  github.com:\n    - path: a.key\n      activeUntil: 2026-11-01\n",
+		"sshKeys:\n  github.com:\n    - path: a.key\n    - path: b.key\n      activeUntil: soon\n",
+	}
+	for _, data := range invalid {
+		if err := yaml.Unmarshal([]byte(data), &Config{}); err == nil {
+			t.Errorf("Expected an error reading %q", data)
+		}
+	}
+}
+
+func TestRotateSSHKeys(t *testing.T) {
+	missing, _ := writeTestSSHKey(t)
+	defer os.Remove(missing)
+	expired, expiredFingerprint := writeTestSSHKey(t)
+	defer os.Remove(expired)
+	kept, keptFingerprint := writeTestSSHKey(t)
+	defer os.Remove(kept)
+
+	settings := fmt.Sprintf(`{"ssh_keys": [{"hostname": "github.com", "fingerprint": %q},
+		{"hostname": "github.com", "fingerprint": %q}]}`, expiredFingerprint, keptFingerprint)
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"GET " + circlecitest.SettingsPath:      {Status: http.StatusOK, Body: settings},
+		"POST /project/git/test/test/ssh-key":   {Status: http.StatusCreated, Body: ``},
+		"DELETE /project/git/test/test/ssh-key": {Status: http.StatusOK, Body: `{"message": "ok"}`},
+	})
+	defer svr.Close()
+
+	now := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	config := Config{RetiringSSHKeys: map[string][]RetiringSSHKey{
+		"example.com": {{missing, now.AddDate(0, 0, 7)}},
+		"github.com":  {{expired, now.AddDate(0, 0, -1)}, {kept, now.AddDate(0, 0, 1)}},
+	}}
+	report := NewReport(nil)
+	err := rotateSSHKeys(context.Background(), svr.project(), config, now, report)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if len(svr.Requests()) != 3 || svr.Requests()[0] != "GET "+circlecitest.SettingsPath {
+		t.Fatalf("Expected the keys to be fetched, one added and one removed, found %q", svr.Requests())
+	}
+	expected := fmt.Sprintf(`DELETE /project/git/test/test/ssh-key {"hostname":"github.com","fingerprint":%q}`, expiredFingerprint)
+	if svr.Requests()[2] != expected {
+		t.Errorf("Expected the expired key to be removed, found %q", svr.Requests()[2])
+	}
+
+	overlapping, err := overlappingFingerprints(config, now)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	keys := []circleci.SSHKey{
+		{Hostname: "github.com", Fingerprint: "current"},
+		{Hostname: "github.com", Fingerprint: keptFingerprint},
+		{Hostname: "github.com", Fingerprint: expiredFingerprint},
+	}
+	duplicates := duplicateSSHKeys(keys, map[string]string{"github.com": "current"}, overlapping)
+	if len(duplicates) != 1 || duplicates[0].Fingerprint != expiredFingerprint {
+		t.Errorf("Expected only the expired key to be a duplicate, found %v", duplicates)
+	}
+}
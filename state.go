@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// stateView is the live state of a project as printed by state show.
+type stateView struct {
+	Project      string                 `json:"project" yaml:"project"`
+	Following    bool                   `json:"following" yaml:"following"`
+	EnvVars      map[string]string      `json:"envVars" yaml:"envVars"` // Masked values, keyed by name
+	SSHKeys      []circleci.SSHKey      `json:"sshKeys" yaml:"sshKeys"`
+	FeatureFlags map[string]interface{} `json:"featureFlags" yaml:"featureFlags"`
+}
+
+// maskValue hides all but the last four characters of an env var value, the
+// way CircleCI does. Values CircleCI has already masked are left as they are.
+func maskValue(value string) string {
+	if len(value) <= 4 {
+		return "xxxx"
+	}
+	return "xxxx" + value[len(value)-4:]
+}
+
+// fetchStateView reads the live state of the project, masking its env vars.
+func fetchStateView(ctx context.Context, project circleci.Project) (stateView, error) {
+	view := stateView{Project: project.FullName()}
+	state, err := fetchState(ctx, project)
+	if err != nil {
+		return view, err
+	}
+	view.Following = state.Following
+	view.SSHKeys = state.SSHKeys
+	view.EnvVars = make(map[string]string, len(state.EnvVars))
+	for name, value := range state.EnvVars {
+		view.EnvVars[name] = maskValue(value)
+	}
+	view.FeatureFlags, err = project.FeatureFlags(ctx)
+	if err != nil {
+		return view, fmt.Errorf("could not get feature flags of project %s: %v", project.FullName(), err)
+	}
+	return view, nil
+}
+
+// writeStateView writes the view to w in the given format, yaml or json.
+func writeStateView(w io.Writer, view stateView, format string) error {
+	switch format {
+	case "yaml":
+		content, err := yaml.Marshal(view)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(view)
+	default:
+		return fmt.Errorf("unknown format %q, expected yaml or json", format)
+	}
+}
+
+func runState(args []string) error {
+	_, args, err := subcommand("state", args, "show")
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("state show", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	format := fs.String("format", "yaml", "Output format (yaml or json)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s state show [flags] [vcs/owner/name]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("state show takes at most one project")
+	}
+	if *format != "yaml" && *format != "json" {
+		return fmt.Errorf("invalid -format %q, expected yaml or json", *format)
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	var vcsType, owner, projectName string
+	if fs.NArg() == 1 {
+		vcsType, owner, projectName, err = parseProjectSlug(fs.Arg(0))
+	} else {
+		vcsType, owner, projectName, err = s.projectSlug()
+	}
+	if err != nil {
+		return err
+	}
+	view, err := fetchStateView(s.ctx, s.project(vcsType, owner, projectName))
+	if err != nil {
+		return err
+	}
+	return writeStateView(os.Stdout, view, *format)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+	"github.com/nick96/circleci-provision/pkg/circleci/circlecitest"
+)
+
+func TestMaskValue(t *testing.T) {
+	testCases := map[string]string{
+		"":             "xxxx",
+		"abc":          "xxxx",
+		"xxxxabcd":     "xxxxabcd",
+		"supersecret1": "xxxxret1",
+	}
+	for value, expected := range testCases {
+		if actual := maskValue(value); actual != expected {
+			t.Errorf("Expected %q to be masked as %q, found %q", value, expected, actual)
+		}
+	}
+}
+
+func TestFetchStateView(t *testing.T) {
+	svr := newFakeCircleCI(map[string]circlecitest.Response{
+		"GET " + circlecitest.SettingsPath:  {Status: http.StatusOK, Body: circlecitest.Settings},
+		"GET /project/git/test/test/envvar": {Status: http.StatusOK, Body: `[{"name": "A", "value": "plaintext"}]`},
+	})
+	defer svr.Close()
+
+	view, err := fetchStateView(context.Background(), svr.project())
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := stateView{
+		Project:   "test/test",
+		Following: true,
+		EnvVars:   map[string]string{"A": "xxxxtext"},
+		SSHKeys: []circleci.SSHKey{
+			{Hostname: "github.com", Fingerprint: "aa"},
+			{Hostname: "github.com", Fingerprint: "bb"},
+			{Hostname: "example.com", Fingerprint: "cc"},
+		},
+		FeatureFlags: map[string]interface{}{"oss": true},
+	}
+	if !reflect.DeepEqual(view, expected) {
+		t.Errorf("Expected state %+v, found %+v", expected, view)
+	}
+}
+
+func TestWriteStateView(t *testing.T) {
+	view := stateView{
+		Project:      "test/test",
+		Following:    true,
+		EnvVars:      map[string]string{"A": "xxxxa"},
+		SSHKeys:      []circleci.SSHKey{{Hostname: "github.com", Fingerprint: "aa"}},
+		FeatureFlags: map[string]interface{}{"oss": true},
+	}
+
+	var buf bytes.Buffer
+	err := writeStateView(&buf, view, "yaml")
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	var fromYAML stateView
+	err = yaml.Unmarshal(buf.Bytes(), &fromYAML)
+	if err != nil || !reflect.DeepEqual(fromYAML, view) {
+		t.Errorf("Expected YAML for %+v, found %s (%v)", view, buf.String(), err)
+	}
+
+	buf.Reset()
+	err = writeStateView(&buf, view, "json")
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	var fromJSON stateView
+	err = json.Unmarshal(buf.Bytes(), &fromJSON)
+	if err != nil || !reflect.DeepEqual(fromJSON, view) {
+		t.Errorf("Expected JSON for %+v, found %s (%v)", view, buf.String(), err)
+	}
+
+	if err = writeStateView(&buf, view, "toml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
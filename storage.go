@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// Longest CircleCI keeps each kind of storage, in days.
+const (
+	maxArtifactsDays  = 30
+	maxWorkspacesDays = 15
+	maxCachesDays     = 15
+)
+
+// StorageConfig is how long CircleCI keeps what the project's jobs store, so
+// that storage cost policies can be enforced across every managed project.
+// Retentions left out are not changed.
+type StorageConfig struct {
+	ArtifactsDays  int `yaml:"artifactsDays"`  // Artifacts stored with store_artifacts, up to 30
+	WorkspacesDays int `yaml:"workspacesDays"` // Workspaces persisted between jobs, up to 15
+	CachesDays     int `yaml:"cachesDays"`     // Dependency caches saved with save_cache, up to 15
+}
+
+// validate checks the retentions are within what CircleCI allows.
+func (c StorageConfig) validate() error {
+	for _, retention := range []struct {
+		name      string
+		days, max int
+	}{
+		{"artifactsDays", c.ArtifactsDays, maxArtifactsDays},
+		{"workspacesDays", c.WorkspacesDays, maxWorkspacesDays},
+		{"cachesDays", c.CachesDays, maxCachesDays},
+	} {
+		if retention.days < 0 || retention.days > retention.max {
+			return fmt.Errorf("invalid %s %d, expected between 1 and %d", retention.name, retention.days, retention.max)
+		}
+	}
+	if c == (StorageConfig{}) {
+		return fmt.Errorf("artifactsDays, workspacesDays or cachesDays is required")
+	}
+	return nil
+}
+
+// storageManager is what managing the storage policy needs of a project.
+type storageManager interface {
+	FullName() string
+	StoragePolicy(ctx context.Context) (circleci.StoragePolicy, error)
+	SetStoragePolicy(ctx context.Context, policy circleci.StoragePolicy) error
+}
+
+// ensureStoragePolicy sets the retentions of the project that differ from the
+// config's, reporting whether it changed any.
+func ensureStoragePolicy(ctx context.Context, project storageManager, config StorageConfig) (bool, error) {
+	current, err := project.StoragePolicy(ctx)
+	if err != nil {
+		return false, err
+	}
+	var changes circleci.StoragePolicy
+	if config.ArtifactsDays != 0 && config.ArtifactsDays != current.ArtifactsDays {
+		changes.ArtifactsDays = config.ArtifactsDays
+	}
+	if config.WorkspacesDays != 0 && config.WorkspacesDays != current.WorkspacesDays {
+		changes.WorkspacesDays = config.WorkspacesDays
+	}
+	if config.CachesDays != 0 && config.CachesDays != current.CachesDays {
+		changes.CachesDays = config.CachesDays
+	}
+	if changes == (circleci.StoragePolicy{}) {
+		return false, nil
+	}
+	logInfof("Setting storage retention of project %s", project.FullName())
+	return true, project.SetStoragePolicy(ctx, changes)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+type fakeStorageManager struct {
+	policy circleci.StoragePolicy
+	set    []circleci.StoragePolicy
+}
+
+func (p *fakeStorageManager) FullName() string { return "owner/project" }
+
+func (p *fakeStorageManager) StoragePolicy(ctx context.Context) (circleci.StoragePolicy, error) {
+	return p.policy, nil
+}
+
+func (p *fakeStorageManager) SetStoragePolicy(ctx context.Context, policy circleci.StoragePolicy) error {
+	p.set = append(p.set, policy)
+	return nil
+}
+
+func TestEnsureStoragePolicy(t *testing.T) {
+	current := circleci.StoragePolicy{ArtifactsDays: 30, WorkspacesDays: 15, CachesDays: 15}
+	for _, tt := range []struct {
+		name   string
+		config StorageConfig
+		want   *circleci.StoragePolicy
+	}{
+		{"unchanged", StorageConfig{ArtifactsDays: 30, CachesDays: 15}, nil},
+		{"changed", StorageConfig{ArtifactsDays: 7, CachesDays: 15}, &circleci.StoragePolicy{ArtifactsDays: 7}},
+		{"all changed", StorageConfig{ArtifactsDays: 1, WorkspacesDays: 1, CachesDays: 1},
+			&circleci.StoragePolicy{ArtifactsDays: 1, WorkspacesDays: 1, CachesDays: 1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			project := &fakeStorageManager{policy: current}
+			changed, err := ensureStoragePolicy(context.Background(), project, tt.config)
+			if err != nil {
+				t.Fatalf("Expected no error, found: %v", err)
+			}
+			if changed != (tt.want != nil) {
+				t.Errorf("Expected changed to be %v, found %v", tt.want != nil, changed)
+			}
+			if tt.want == nil && len(project.set) > 0 || tt.want != nil && (len(project.set) != 1 || project.set[0] != *tt.want) {
+				t.Errorf("Expected %+v to be set, found %+v", tt.want, project.set)
+			}
+		})
+	}
+}
+
+func TestStorageConfigValidate(t *testing.T) {
+	for _, tt := range []struct {
+		config  StorageConfig
+		wantErr bool
+	}{
+		{StorageConfig{ArtifactsDays: 30, WorkspacesDays: 15, CachesDays: 15}, false},
+		{StorageConfig{CachesDays: 7}, false},
+		{StorageConfig{}, true},
+		{StorageConfig{ArtifactsDays: 31}, true},
+		{StorageConfig{WorkspacesDays: 16}, true},
+		{StorageConfig{CachesDays: -1}, true},
+	} {
+		if err := tt.config.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Expected an error for %+v to be %v, found: %v", tt.config, tt.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// SyncConfig describes how the repositories of an organisation map to
+// provisioning profiles.
+type SyncConfig struct {
+	VcsType  string        `yaml:"vcsType"`  // Type of VCS used (only GitHub is supported)
+	Owner    string        `yaml:"owner"`    // Organisation whose repos are synced
+	Profiles []SyncProfile `yaml:"profiles"` // Profiles in order of precedence
+}
+
+// SyncProfile is a provisioning config applied to every repo carrying one of
+// its topics.
+type SyncProfile struct {
+	Name   string   `yaml:"name"`   // Name of the profile, for logging
+	Topics []string `yaml:"topics"` // Repos tagged with any of these use the profile. Empty, with no names, matches every repo.
+	Names  []string `yaml:"names"`  // Repos whose name matches any of these globs (e.g. svc-*) use the profile
+	Config string   `yaml:"config"` // Path to the provisioning config, relative to the sync config
+}
+
+// projectFactory creates the Project for a repository.
+type projectFactory func(vcsType, owner, projectName string) circleci.Project
+
+// selectProfile returns the first profile matching one of the repo's topics
+// or its name.
+func selectProfile(profiles []SyncProfile, repo GitHubRepo) (SyncProfile, bool) {
+	for _, profile := range profiles {
+		if len(profile.Topics) == 0 && len(profile.Names) == 0 {
+			return profile, true
+		}
+		for _, want := range profile.Topics {
+			for _, topic := range repo.Topics {
+				if strings.EqualFold(want, topic) {
+					return profile, true
+				}
+			}
+		}
+		for _, pattern := range profile.Names {
+			if ok, _ := filepath.Match(pattern, repo.Name); ok {
+				return profile, true
+			}
+		}
+	}
+	return SyncProfile{}, false
+}
+
+// repoConfigOptions renders a profile's config for the repo, with the repo
+// available to its template as .repo (name, owner and topics).
+func repoConfigOptions(opts configOptions, owner string, repo GitHubRepo) configOptions {
+	values := make(map[string]interface{}, len(opts.values)+1)
+	for key, value := range opts.values {
+		values[key] = value
+	}
+	values["repo"] = map[string]interface{}{"name": repo.Name, "owner": owner, "topics": repo.Topics}
+	opts.values = values
+	return opts
+}
+
+func readSyncConfig(syncFile string) (SyncConfig, error) {
+	config := SyncConfig{}
+	err := readYAML(syncFile, &config)
+	if err != nil {
+		return config, err
+	}
+	if config.VcsType != "gh" && config.VcsType != "github" {
+		return config, fmt.Errorf("sync is only supported for GitHub, found vcsType %q", config.VcsType)
+	}
+	for i, profile := range config.Profiles {
+		if profile.Config == "" {
+			return config, fmt.Errorf("profile %s has no config", profile.Name)
+		}
+		if !filepath.IsAbs(profile.Config) {
+			config.Profiles[i].Config = filepath.Join(filepath.Dir(syncFile), profile.Config)
+		}
+	}
+	for _, profile := range config.Profiles {
+		for _, pattern := range profile.Names {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return config, fmt.Errorf("profile %s has an invalid name pattern %q: %v", profile.Name, pattern, err)
+			}
+		}
+	}
+	return config, nil
+}
+
+// syncRepos provisions every repo in the organisation using the profile
+// selected by its name or topics. Repos that match no profile are skipped.
+func syncRepos(ctx context.Context, syncConfig SyncConfig, configOpts configOptions, github *GitHubClient, newProject projectFactory, opts provisionOptions) error {
+	repos, err := github.OrgRepos(ctx, syncConfig.Owner)
+	if err != nil {
+		return err
+	}
+
+	var selected []GitHubRepo
+	var profiles []SyncProfile
+	for _, repo := range repos {
+		if repo.Archived {
+			continue
+		}
+		profile, ok := selectProfile(syncConfig.Profiles, repo)
+		if !ok {
+			logWarnf("Skipping %s/%s: no profile matches its name or topics %v", syncConfig.Owner, repo.Name, repo.Topics)
+			continue
+		}
+		selected = append(selected, repo)
+		profiles = append(profiles, profile)
+	}
+
+	// Projects are provisioned opts.parallelism at a time, each setting its
+	// env vars one at a time so that the API calls made at once stay within
+	// opts.parallelism.
+	projectOpts := opts
+	projectOpts.parallelism = 1
+	errs := runParallel(opts.parallelism, len(selected), func(i int) error {
+		if opts.report.Cancelled() {
+			return errCancelled
+		}
+		profile := profiles[i]
+		config, err := readConfig(profile.Config, repoConfigOptions(configOpts, syncConfig.Owner, selected[i]))
+		if err != nil {
+			name := syncConfig.Owner + "/" + selected[i].Name
+			opts.report.Identify(name, circleci.ProjectSlug(syncConfig.VcsType, syncConfig.Owner, selected[i].Name))
+			opts.report.Record(name, "config", profile.Config, outcomeFailed, err)
+			return fmt.Errorf("could not read config %s for profile %s: %v", profile.Config, profile.Name, err)
+		}
+		config.VcsType = syncConfig.VcsType
+		config.Owner = syncConfig.Owner
+		config.ProjectName = selected[i].Name
+
+		project := newProject(config.VcsType, config.Owner, config.ProjectName)
+		logInfof("Provisioning %s with profile %s", project.FullName(), profile.Name)
+		return provision(ctx, project, config, projectOpts)
+	})
+
+	var failed []string
+	cancelled := 0
+	for i, err := range errs {
+		if err == errCancelled {
+			cancelled++
+		} else if err != nil {
+			logErrorf("%v", err)
+			failed = append(failed, syncConfig.Owner+"/"+selected[i].Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not provision %d project(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	if cancelled > 0 {
+		return fmt.Errorf("run was cancelled before %d of %d project(s) were provisioned", cancelled, len(selected))
+	}
+	return nil
+}
+
+// syncFlags are the flags of sync, shared with discover.
+type syncFlags struct {
+	githubToken   *string
+	canonical     *bool
+	trigger       *bool
+	triggerBranch *string
+	triggerTag    *string
+	minSuccess    *float64
+	gate          *string
+	quarantine    *bool
+	historyDir    *string
+	stateFile     *string
+	parallelism   *int
+	assumeYes     *bool
+	rollback      *bool
+}
+
+func addSyncFlags(fs *flag.FlagSet) *syncFlags {
+	return &syncFlags{
+		githubToken: fs.String("github-token", os.Getenv("GITHUB_TOKEN"),
+			"GitHub token, used to list the org's repos and register deploy keys"),
+		canonical: fs.Bool("canonical", envBool("CIRCLECI_CANONICAL"),
+			"Projects should be exactly as described in their config, removing environment variables and ssh keys "+
+				"it does not describe once confirmed"),
+		trigger:       fs.Bool("trigger", envBool("CIRCLECI_TRIGGER"), "Trigger a build of each project once it is setup"),
+		triggerBranch: fs.String("trigger-branch", "", "Branch to build with -trigger (default branch if empty)"),
+		triggerTag:    fs.String("trigger-tag", "", "Tag to build with -trigger instead of a branch"),
+		minSuccess: fs.Float64("trigger-min-success-rate", 0,
+			"With -trigger, hold back projects whose recent workflows succeeded less often than this (0 to 1, no check if 0)"),
+		gate: fs.String("trigger-gate", insightsSkip,
+			"What to do with projects below -trigger-min-success-rate: skip them, or flag them and trigger anyway"),
+		quarantine: fs.Bool("quarantine", envBool("CIRCLECI_QUARANTINE"),
+			"With -canonical, quarantine environment variables not in the config until purged instead of removing them"),
+		historyDir: fs.String("history-dir", os.Getenv("CIRCLECI_HISTORY_DIR"),
+			"Record the outcome of each provisioned project in this directory"),
+		stateFile: fs.String("state-file", os.Getenv("CIRCLECI_STATE_FILE"),
+			"Record the resources provisioned in this file, so that -canonical only removes those"),
+		parallelism: fs.Int("parallelism", 1, "Projects to provision at once"),
+		assumeYes:   addYesFlags(fs),
+		rollback: fs.Bool("rollback-on-failure", envBool("CIRCLECI_ROLLBACK_ON_FAILURE"),
+			"With -canonical, restore the env vars and SSH keys removed from a project if it fails, without asking"),
+	}
+}
+
+// sync provisions the repos of the sync config with their profiles.
+func (f *syncFlags) sync(s *session, syncConfig SyncConfig) error {
+	opts := provisionOptions{canonical: *f.canonical, quarantine: *f.quarantine, trigger: *f.trigger,
+		triggerOpts: circleci.TriggerOptions{Branch: *f.triggerBranch, Tag: *f.triggerTag}, parallelism: *f.parallelism,
+		report: NewReport(s.events), approval: s.approval, rollback: *f.rollback,
+		insights: insightsGate{minSuccessRate: *f.minSuccess, action: *f.gate}}
+	if !*f.assumeYes {
+		opts.confirm = newRemovalConfirmer().confirm
+	}
+	err := opts.insights.validate()
+	if err != nil {
+		return err
+	}
+	if *f.historyDir != "" {
+		opts.history, err = OpenHistory(*f.historyDir)
+		if err != nil {
+			return err
+		}
+	}
+	if *f.stateFile != "" {
+		opts.managed, err = OpenManagedState(*f.stateFile)
+		if err != nil {
+			return err
+		}
+	}
+	github := NewGitHubClient(*f.githubToken)
+	if *f.githubToken != "" {
+		opts.deployKeys = github
+	}
+	s.cancelOnInterrupt(opts.report)
+	err = syncRepos(s.ctx, syncConfig, s.configOpts, github, s.project, opts)
+	s.printReport(opts.report)
+	return err
+}
+
+func runSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	flags := addSyncFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s sync [flags] SYNC_CONFIG\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	syncFile := os.Getenv("CIRCLECI_SYNC")
+	if fs.NArg() == 1 {
+		syncFile = fs.Arg(0)
+	} else if fs.NArg() > 1 || syncFile == "" {
+		fs.Usage()
+		return fmt.Errorf("sync takes exactly one sync config")
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	syncConfig, err := readSyncConfig(syncFile)
+	if err != nil {
+		return fmt.Errorf("could not read sync config %s: %v", syncFile, err)
+	}
+	err = flags.sync(s, syncConfig)
+	if err != nil {
+		return err
+	}
+	logInfof("Organisation has been successfully synced using %s", syncFile)
+	return nil
+}
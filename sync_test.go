@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSelectProfile(t *testing.T) {
+	profiles := []SyncProfile{
+		{Name: "payments", Topics: []string{"team-payments"}},
+		{Name: "web", Topics: []string{"frontend", "web"}},
+		{Name: "default"},
+	}
+
+	type test struct {
+		topics   []string
+		expected string
+	}
+
+	testCases := []test{
+		{[]string{"team-payments"}, "payments"},
+		{[]string{"go", "Web"}, "web"},
+		{[]string{"web", "team-payments"}, "payments"},
+		{nil, "default"},
+	}
+
+	for _, tc := range testCases {
+		actual, ok := selectProfile(profiles, GitHubRepo{Name: "repo", Topics: tc.topics})
+		if !ok || actual.Name != tc.expected {
+			t.Errorf("Expected profile %s for topics %v, found %s", tc.expected, tc.topics, actual.Name)
+		}
+	}
+
+	_, ok := selectProfile(profiles[:2], GitHubRepo{Name: "repo", Topics: []string{"unrelated"}})
+	if ok {
+		t.Errorf("Expected no profile to match")
+	}
+}
+
+func TestOrgReposPaginates(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/orgs/test/repos" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		switch r.URL.Query().Get("page") {
+		case "1":
+			io.WriteString(w, `[{"name": "a", "topics": ["team-payments"]}, {"name": "b", "archived": true}]`)
+		case "2":
+			io.WriteString(w, `[{"name": "c"}]`)
+		default:
+			io.WriteString(w, `[]`)
+		}
+	})
+	svr := httptest.NewServer(handler)
+	defer svr.Close()
+
+	github := &GitHubClient{svr.URL, "token", svr.Client()}
+	repos, err := github.OrgRepos(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if len(repos) != 3 {
+		t.Fatalf("Expected 3 repos, found %d", len(repos))
+	}
+	if repos[0].Topics[0] != "team-payments" || !repos[1].Archived || repos[2].Name != "c" {
+		t.Errorf("Unexpected repos %+v", repos)
+	}
+}
+
+func TestSelectProfileByName(t *testing.T) {
+	profiles := []SyncProfile{
+		{Name: "services", Names: []string{"svc-*"}, Topics: []string{"service"}},
+		{Name: "sites", Names: []string{"*-site", "www"}},
+	}
+	for name, expected := range map[string]string{"svc-payments": "services", "docs-site": "sites", "www": "sites"} {
+		actual, ok := selectProfile(profiles, GitHubRepo{Name: name})
+		if !ok || actual.Name != expected {
+			t.Errorf("Expected profile %s for %s, found %s", expected, name, actual.Name)
+		}
+	}
+	if _, ok := selectProfile(profiles, GitHubRepo{Name: "tools"}); ok {
+		t.Errorf("Expected no profile to match")
+	}
+}
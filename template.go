@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+const alphaNum = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// configOptions controls how config files are read.
+type configOptions struct {
+	noTemplate bool                   // Use config files verbatim, without rendering templates
+	format     string                 // Format of config files (yaml, json or toml), detected from their extension if empty
+	rand       *lockedRand            // Source for randomAlphaNum, shared across the run
+	secrets    map[string]SecretStore // Stores env var values can refer to, keyed by scheme
+	values     map[string]interface{} // Values templates are rendered against, from -values
+	strict     bool                   // Fail on placeholders left once rendered, rather than warn
+}
+
+// readValues reads a values file for config templates, in any of the config
+// formats.
+func readValues(file string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	format, err := configFormat(file, "")
+	if err == nil {
+		data, err = convertToYAML(data, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{})
+	err = yaml.Unmarshal(data, &values)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// isEmpty reports whether a template value is unset or the zero value of its
+// type.
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case int:
+		return v == 0
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[interface{}]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// lockedRand is a math/rand source safe for concurrent use.
+type lockedRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (r *lockedRand) alphaNum(n int) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphaNum[r.rng.Intn(len(alphaNum))]
+	}
+	return string(b)
+}
+
+// templateFuncs returns the functions available to config templates. Paths
+// given to fileContents are relative to dir.
+func templateFuncs(dir string, rng *lockedRand) template.FuncMap {
+	return template.FuncMap{
+		"b64enc": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"sha256": func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		},
+		"trimSpace": strings.TrimSpace,
+		"envOrDefault": func(name, def string) string {
+			if value, ok := os.LookupEnv(name); ok {
+				return value
+			}
+			return def
+		},
+		"env": os.Getenv,
+		"default": func(def, value interface{}) interface{} {
+			if isEmpty(value) {
+				return def
+			}
+			return value
+		},
+		"required": func(message string, value interface{}) (interface{}, error) {
+			if isEmpty(value) {
+				return nil, fmt.Errorf("%s", message)
+			}
+			return value, nil
+		},
+		"fileContents": func(path string) (string, error) {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			data, err := ioutil.ReadFile(path)
+			return string(data), err
+		},
+		"randomAlphaNum": rng.alphaNum,
+	}
+}
+
+// renderTemplate renders the config file's contents as a Go template, with
+// the values as its data.
+func renderTemplate(configFile string, data []byte, opts configOptions) ([]byte, error) {
+	rng := opts.rand
+	if rng == nil {
+		rng = newLockedRand(0)
+	}
+	tmpl, err := template.New(filepath.Base(configFile)).
+		Option("missingkey=error").
+		Funcs(templateFuncs(filepath.Dir(configFile), rng)).
+		Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, opts.values)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "cert.pem"), []byte("  cert\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("TEMPLATE_TEST_SET", "set")
+	defer os.Unsetenv("TEMPLATE_TEST_SET")
+
+	type test struct {
+		template string
+		expected string
+	}
+
+	testCases := []test{
+		{`{{ b64enc "hello" }}`, "aGVsbG8="},
+		{`{{ sha256 "hello" }}`, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{`{{ trimSpace "  x  " }}`, "x"},
+		{`{{ envOrDefault "TEMPLATE_TEST_SET" "default" }}`, "set"},
+		{`{{ envOrDefault "TEMPLATE_TEST_UNSET" "default" }}`, "default"},
+		{`{{ fileContents "cert.pem" | trimSpace }}`, "cert"},
+		{`{{ randomAlphaNum 0 }}`, ""},
+	}
+
+	configFile := filepath.Join(dir, "config.yml")
+	for _, tc := range testCases {
+		actual, err := renderTemplate(configFile, []byte(tc.template), configOptions{})
+		if err != nil {
+			t.Errorf("Expected no error rendering %s, found: %v", tc.template, err)
+		}
+		if string(actual) != tc.expected {
+			t.Errorf("Expected %s to render %q, found %q", tc.template, tc.expected, actual)
+		}
+	}
+}
+
+func TestRandomAlphaNumSeed(t *testing.T) {
+	render := func(seed int64) string {
+		out, err := renderTemplate("config.yml", []byte(`{{ randomAlphaNum 16 }}`),
+			configOptions{rand: newLockedRand(seed)})
+		if err != nil {
+			t.Fatalf("Expected no error, found: %v", err)
+		}
+		return string(out)
+	}
+
+	first, second := render(42), render(42)
+	if len(first) != 16 {
+		t.Errorf("Expected 16 characters, found %q", first)
+	}
+	if first != second {
+		t.Errorf("Expected the same seed to give the same value, found %q and %q", first, second)
+	}
+	if render(43) == first {
+		t.Errorf("Expected different seeds to give different values")
+	}
+}
+
+func TestReadConfigNoTemplate(t *testing.T) {
+	fh, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fh.Name())
+	fh.WriteString("envVars:\n  EV: '{{ b64enc \"x\" }}'\n")
+	fh.Close()
+
+	config, err := readConfig(fh.Name(), configOptions{noTemplate: true})
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if config.EnvVars["EV"] != `{{ b64enc "x" }}` {
+		t.Errorf("Expected template to be left verbatim, found %q", config.EnvVars["EV"])
+	}
+
+	config, err = readConfig(fh.Name(), configOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if config.EnvVars["EV"] != "eA==" {
+		t.Errorf("Expected template to be rendered, found %q", config.EnvVars["EV"])
+	}
+}
+
+func TestRenderTemplateValues(t *testing.T) {
+	fh, err := ioutil.TempFile("", "values*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fh.Name())
+	fh.WriteString("owner: acme\nenv:\n  name: staging\n  replicas: 0\n")
+	fh.Close()
+	values, err := readValues(fh.Name())
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	os.Setenv("TEMPLATE_TEST_SET", "set")
+	defer os.Unsetenv("TEMPLATE_TEST_SET")
+
+	type test struct {
+		template string
+		expected string
+	}
+
+	testCases := []test{
+		{`{{ .owner }}`, "acme"},
+		{`{{ .env.name }}`, "staging"},
+		{`{{ env "TEMPLATE_TEST_SET" }}{{ env "TEMPLATE_TEST_UNSET" }}`, "set"},
+		{`{{ .env.replicas | default 2 }}`, "2"},
+		{`{{ index . "region" | default "eu-west-1" }}`, "eu-west-1"},
+		{`{{ required "owner is required" .owner }}`, "acme"},
+	}
+
+	opts := configOptions{values: values}
+	for _, tc := range testCases {
+		actual, err := renderTemplate("config.yml", []byte(tc.template), opts)
+		if err != nil {
+			t.Errorf("Expected no error rendering %s, found: %v", tc.template, err)
+		}
+		if string(actual) != tc.expected {
+			t.Errorf("Expected %s to render %q, found %q", tc.template, tc.expected, actual)
+		}
+	}
+
+	for _, tmpl := range []string{`{{ .region }}`, `{{ required "region is required" (index . "region") }}`} {
+		if _, err := renderTemplate("config.yml", []byte(tmpl), opts); err == nil {
+			t.Errorf("Expected an error rendering %s", tmpl)
+		}
+	}
+}
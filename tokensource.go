@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// resolveToken returns the API token read from file or printed by command,
+// if either is given, or token otherwise. Unlike flags and env vars, neither
+// leaves the token in shell history or process listings.
+func resolveToken(token, file, command string) (string, error) {
+	switch {
+	case file != "" && command != "":
+		return "", fmt.Errorf("only one of -token-file and -token-command can be given")
+	case file != "":
+		return readTokenFile(file)
+	case command != "":
+		return runTokenCommand(command)
+	}
+	return token, nil
+}
+
+// readTokenFile reads the token from file, warning if other users can read
+// it.
+func readTokenFile(file string) (string, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", fmt.Errorf("could not read -token-file: %v", err)
+	}
+	warnReadableByOthers(file, info)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("could not read -token-file: %v", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("-token-file %s is empty", file)
+	}
+	return token, nil
+}
+
+// runTokenCommand runs command with the shell and returns what it prints.
+// The command can prompt, e.g. to unlock a password manager, as it shares
+// the terminal.
+func runTokenCommand(command string) (string, error) {
+	shell := []string{"sh", "-c"}
+	if runtime.GOOS == "windows" {
+		shell = []string{"cmd", "/C"}
+	}
+	var stdout bytes.Buffer
+	cmd := exec.Command(shell[0], shell[1], command)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, &stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("-token-command failed: %v", err)
+	}
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("-token-command printed no token")
+	}
+	return token, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestResolveToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(file, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := ioutil.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, file, command, expected string
+		wantErr                       bool
+	}{
+		{name: "flag", expected: "flag-token"},
+		{name: "file", file: file, expected: "file-token"},
+		{name: "empty file", file: empty, wantErr: true},
+		{name: "missing file", file: filepath.Join(dir, "missing"), wantErr: true},
+		{name: "both", file: file, command: "echo token", wantErr: true},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests, []struct {
+			name, file, command, expected string
+			wantErr                       bool
+		}{
+			{name: "command", command: "printf 'command-token\\n'", expected: "command-token"},
+			{name: "failing command", command: "exit 1", wantErr: true},
+			{name: "silent command", command: "true", wantErr: true},
+		}...)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := resolveToken("flag-token", tt.file, tt.command)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, found token %q", token)
+				}
+				return
+			}
+			if err != nil || token != tt.expected {
+				t.Errorf("Expected token %q and no error, found %q and %v", tt.expected, token, err)
+			}
+		})
+	}
+}
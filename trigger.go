@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// paramsFlag collects repeated -param name=value flags into pipeline
+// parameters, inferring boolean and integer values.
+type paramsFlag map[string]interface{}
+
+func (p paramsFlag) String() string {
+	return fmt.Sprint(map[string]interface{}(p))
+}
+
+func (p paramsFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expected name=value, found %q", value)
+	}
+	if b, err := strconv.ParseBool(parts[1]); err == nil {
+		p[parts[0]] = b
+	} else if i, err := strconv.Atoi(parts[1]); err == nil {
+		p[parts[0]] = i
+	} else {
+		p[parts[0]] = parts[1]
+	}
+	return nil
+}
+
+// waitOptions control waiting for a triggered build.
+type waitOptions struct {
+	interval time.Duration // Wait between checks of the build's status
+	timeout  time.Duration // Give up after this long, no limit if 0
+}
+
+// waitFlags are the flags setting waitOptions.
+type waitFlags struct {
+	interval *time.Duration
+	timeout  *time.Duration
+}
+
+func addWaitFlags(fs *flag.FlagSet) waitFlags {
+	return waitFlags{
+		interval: fs.Duration("wait-interval", 10*time.Second, "Wait between checks of the build's status with -wait"),
+		timeout:  fs.Duration("wait-timeout", 30*time.Minute, "Give up waiting for the build after this long (no limit if 0)"),
+	}
+}
+
+func (f waitFlags) options() waitOptions {
+	return waitOptions{interval: *f.interval, timeout: *f.timeout}
+}
+
+// waitForBuild waits for the build to finish, failing unless it succeeds.
+func waitForBuild(ctx context.Context, project circleci.Project, build circleci.Build, opts waitOptions) error {
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	logInfof("Waiting for build %d of %s to finish", build.Number, project.FullName())
+	status, err := circleci.WaitForBuild(ctx, project, build, opts.interval)
+	if err != nil {
+		return err
+	}
+	if !status.Success {
+		return fmt.Errorf("build %d of %s finished with status %s: %s", build.Number, project.FullName(), status.Status, build.URL)
+	}
+	logInfof("Build %d of %s succeeded", build.Number, project.FullName())
+	return nil
+}
+
+// triggerResult is the outcome of triggering one project.
+type triggerResult struct {
+	project string
+	url     string
+	err     error
+}
+
+// pipelineTrigger is a project whose pipelines can be triggered.
+type pipelineTrigger interface {
+	FullName() string
+	TriggerPipeline(ctx context.Context, opts circleci.TriggerOptions) (circleci.Pipeline, error)
+	PipelineURL(pipeline circleci.Pipeline) string
+}
+
+// triggerAll triggers a pipeline of each project in turn, waiting interval
+// between them so a large org is not rate limited.
+func triggerAll(ctx context.Context, projects []pipelineTrigger, opts circleci.TriggerOptions, interval time.Duration) []triggerResult {
+	results := make([]triggerResult, 0, len(projects))
+	for i, project := range projects {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		logInfof("Triggering pipeline of %s", project.FullName())
+		pipeline, err := project.TriggerPipeline(ctx, opts)
+		result := triggerResult{project: project.FullName(), err: err}
+		if err == nil {
+			result.url = project.PipelineURL(pipeline)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// printTriggerReport writes a table of the trigger results to w and returns
+// the number of failures.
+func printTriggerReport(w io.Writer, results []triggerResult) int {
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tRESULT")
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\t%s\n", result.project, paint(w, colorRed, fmt.Sprintf("failed: %v", result.err)))
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\n", result.project, paint(w, colorGreen, result.url))
+		}
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d triggered, %d failed\n", len(results)-failed, failed)
+	return failed
+}
+
+func runTrigger(args []string) error {
+	fs := flag.NewFlagSet("trigger", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	branch := fs.String("branch", "", "Branch to build (default branch if empty)")
+	tag := fs.String("tag", "", "Tag to build instead of a branch")
+	params := paramsFlag{}
+	fs.Var(params, "param", "Pipeline parameter as name=value (repeatable)")
+	wait := fs.Bool("wait", false, "Wait for the build and fail unless it succeeds")
+	waitOpts := addWaitFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	vcsType, owner, projectName, err := s.projectSlug()
+	if err != nil {
+		return err
+	}
+
+	project := s.project(vcsType, owner, projectName)
+	// Builds triggered through API v1.1 cannot take parameters.
+	if len(params) > 0 {
+		project = instrumentedProject{s.v2Project(vcsType, owner, projectName), s.metrics}
+	}
+	logInfof("Triggering build of %s", project.FullName())
+	build, err := project.Trigger(s.ctx, circleci.TriggerOptions{Branch: *branch, Tag: *tag, Parameters: params})
+	if err != nil {
+		return fmt.Errorf("could not trigger build for project %s: %v", project.FullName(), err)
+	}
+	logInfof("Triggered build %d of %s: %s", build.Number, project.FullName(), build.URL)
+	if *wait {
+		return waitForBuild(s.ctx, project, build, waitOpts.options())
+	}
+	return nil
+}
+
+func runTriggerAll(args []string) error {
+	fs := flag.NewFlagSet("trigger-all", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	branch := fs.String("branch", "", "Branch to build (default branch if empty)")
+	tag := fs.String("tag", "", "Tag to build instead of a branch")
+	params := paramsFlag{}
+	fs.Var(params, "param", "Pipeline parameter as name=value (repeatable)")
+	interval := fs.Duration("interval", time.Second, "Time to wait between triggering projects")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s trigger-all [flags] CONFIG...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("trigger-all needs at least one config")
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	var projects []pipelineTrigger
+	for _, configFile := range fs.Args() {
+		config, err := s.readConfig(configFile)
+		if err != nil {
+			return err
+		}
+		scoped, err := s.forConfig(config)
+		if err != nil {
+			return err
+		}
+		projects = append(projects, scoped.v2Project(config.VcsType, config.Owner, config.ProjectName))
+	}
+
+	results := triggerAll(s.ctx, projects, circleci.TriggerOptions{Branch: *branch, Tag: *tag, Parameters: params}, *interval)
+	failed := printTriggerReport(s.stdout, results)
+	if failed > 0 {
+		return fmt.Errorf("could not trigger %d of %d projects", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+)
+
+// VaultClient is a SecretStore reading from HashiCorp Vault's KV secrets
+// engine. Both KV v1 and v2 (secret/data/...) paths are supported.
+type VaultClient struct {
+	addr      string
+	token     string
+	namespace string
+	client    *http.Client
+
+	mu    sync.Mutex
+	cache map[string]map[string]interface{} // Secret data keyed by path
+}
+
+// NewVaultClient creates a client for the Vault at addr authenticating with
+// token. namespace may be empty.
+func NewVaultClient(addr, token, namespace string) *VaultClient {
+	return &VaultClient{
+		addr:      addr,
+		token:     token,
+		namespace: namespace,
+		client:    &http.Client{},
+		cache:     make(map[string]map[string]interface{}),
+	}
+}
+
+// Secret gets the key of the secret at path.
+func (v *VaultClient) Secret(secretPath, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("secrets in Vault must name a key (path#key)")
+	}
+	data, err := v.read(secretPath)
+	if err != nil {
+		return "", err
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", secretErrorf(secretMissing, "secret %s has no key %s", secretPath, key)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("key %s of secret %s is not a string", key, secretPath)
+	}
+	return s, nil
+}
+
+// read gets the data of the secret at path, reading each path only once.
+func (v *VaultClient) read(secretPath string) (map[string]interface{}, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if data, ok := v.cache[secretPath]; ok {
+		return data, nil
+	}
+	if v.addr == "" {
+		return nil, secretErrorf(secretUnreachable, "VAULT_ADDR should be set to read secrets from Vault")
+	}
+
+	u, err := url.Parse(v.addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Vault address %s: %v", v.addr, err)
+	}
+	u.Path = path.Join(u.Path, "v1", secretPath)
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, secretErrorf(secretUnreachable, "could not read secret %s: %v", secretPath, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response body: %v", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, secretErrorf(secretDenied, "could not read secret %s: status %d", secretPath, resp.StatusCode)
+	case http.StatusNotFound:
+		return nil, secretErrorf(secretMissing, "could not read secret %s: status %d", secretPath, resp.StatusCode)
+	default:
+		return nil, fmt.Errorf("could not read secret %s: status %d", secretPath, resp.StatusCode)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	err = json.Unmarshal(body, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal secret %s: %v", secretPath, err)
+	}
+	data := secret.Data
+	// KV v2 nests the secret under data alongside its metadata.
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	v.cache[secretPath] = data
+	return data, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nick96/circleci-provision/pkg/circleci"
+)
+
+// bitbucketSlug matches the slugs Bitbucket identifies workspaces and
+// repositories by in URLs.
+var bitbucketSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// standaloneID matches the UUIDs standalone organisations and projects are
+// identified by.
+var standaloneID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// checkVCS checks that the project's VCS type is one CircleCI supports, that
+// on Bitbucket its owner and name are the slugs CircleCI expects, and that
+// standalone projects are given by the IDs of their organisation and project.
+func checkVCS(vcsType, owner, projectName string) error {
+	if vcsType == "" {
+		return nil
+	}
+	vcs, err := circleci.ParseVCSType(vcsType)
+	if err != nil {
+		return err
+	}
+	if vcs == circleci.VCSStandalone {
+		for _, id := range []string{owner, projectName} {
+			if id != "" && !standaloneID.MatchString(id) {
+				return fmt.Errorf("standalone projects are given by the IDs of their organisation and project, found %q", id)
+			}
+		}
+		return nil
+	}
+	if vcs != circleci.VCSBitbucket {
+		return nil
+	}
+	for _, name := range []string{owner, projectName} {
+		if name != "" && !bitbucketSlug.MatchString(name) {
+			slug := strings.ToLower(strings.Join(strings.Fields(name), "-"))
+			return fmt.Errorf("workspaces and repositories on Bitbucket are given by their slug, e.g. %q rather than %q", slug, name)
+		}
+	}
+	return nil
+}
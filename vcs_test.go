@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestCheckVCS(t *testing.T) {
+	type test struct {
+		vcsType, owner, projectName string
+		valid                       bool
+	}
+
+	testCases := []test{
+		{"", "Anything", "Goes", true},
+		{"gh", "Nick96", "My_Repo", true},
+		{"bitbucket", "team", "my-repo.v2", true},
+		{"bb", "team", "My Repo", false},
+		{"bitbucket", "Team", "repo", false},
+		{"gitlab", "team", "repo", false},
+		{"circleci", "8a9c4b2e-1f3d-4e5a-9b6c-7d8e9f0a1b2c", "0F1E2D3C-4B5A-4968-8776-655443322110", true},
+		{"svn", "team", "repo", false},
+	}
+
+	for _, tc := range testCases {
+		err := checkVCS(tc.vcsType, tc.owner, tc.projectName)
+		if (err == nil) != tc.valid {
+			t.Errorf("Expected %s/%s/%s valid to be %v, found: %v", tc.vcsType, tc.owner, tc.projectName, tc.valid, err)
+		}
+	}
+	if _, _, _, err := parseProjectSlug("svn/team/repo"); err == nil {
+		t.Error("Expected an unknown VCS in a project slug to be rejected")
+	}
+}
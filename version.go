@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// configVersion is the version of the config schema this build reads.
+// Configs of older versions are upgraded in memory when read.
+const configVersion = 1
+
+// configUpgrades upgrade a config from the version at their index to the
+// next one. A breaking change to the schema bumps configVersion and adds the
+// upgrade from the previous version here.
+var configUpgrades = []func(raw map[interface{}]interface{}) error{
+	// Configs written before versioning have no version and are read as
+	// version 1.
+	func(raw map[interface{}]interface{}) error { return nil },
+}
+
+// upgradeConfig returns the config data of file upgraded to configVersion.
+// Configs without a version are read as the oldest version, with a warning.
+func upgradeConfig(file string, data []byte) ([]byte, error) {
+	var raw map[interface{}]interface{}
+	err := yaml.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		raw = make(map[interface{}]interface{})
+	}
+
+	version := 0
+	if value, ok := raw["version"]; !ok {
+		logWarnf("%s has no version, reading it as version 1: add 'version: %d' to it", file, configVersion)
+	} else if version, ok = value.(int); !ok || version < 1 {
+		return nil, fmt.Errorf("invalid version %v, expected a number from 1", value)
+	}
+	if version > configVersion {
+		return nil, fmt.Errorf("unknown version %d, this build reads versions up to %d: upgrade circleci-provision", version, configVersion)
+	}
+	if version == configVersion {
+		return data, nil
+	}
+
+	for from := version; from < configVersion; from++ {
+		if from > 0 {
+			logDebugf("Upgrading %s from version %d to %d", file, from, from+1)
+		}
+		err = configUpgrades[from](raw)
+		if err != nil {
+			return nil, fmt.Errorf("could not upgrade from version %d to %d: %v", from, from+1, err)
+		}
+	}
+	raw["version"] = configVersion
+	return yaml.Marshal(raw)
+}
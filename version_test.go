@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestUpgradeConfig(t *testing.T) {
+	type test struct {
+		data  string
+		fails bool
+	}
+
+	testCases := []test{
+		{"version: 1\nvcsType: gh\nowner: test\nprojectName: test\n", false},
+		{"vcsType: gh\nowner: test\nprojectName: test\n", false},
+		{"", false},
+		{"version: 99\nvcsType: gh\n", true},
+		{"version: 0\nvcsType: gh\n", true},
+		{"version: one\nvcsType: gh\n", true},
+	}
+
+	for _, tc := range testCases {
+		data, err := upgradeConfig("project.yml", []byte(tc.data))
+		if tc.fails {
+			if err == nil {
+				t.Errorf("Expected an error for %q", tc.data)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected no error for %q, found: %v", tc.data, err)
+			continue
+		}
+		var config Config
+		err = yaml.Unmarshal(data, &config)
+		if err != nil {
+			t.Errorf("Expected a valid config for %q, found: %v", tc.data, err)
+		} else if config.Version != configVersion {
+			t.Errorf("Expected version %d for %q, found %d", configVersion, tc.data, config.Version)
+		}
+	}
+}
+
+func TestUpgradeConfigRunsUpgrades(t *testing.T) {
+	upgrades := configUpgrades
+	defer func() { configUpgrades = upgrades }()
+	configUpgrades = []func(map[interface{}]interface{}) error{
+		func(raw map[interface{}]interface{}) error {
+			raw["projectName"] = "upgraded"
+			return nil
+		},
+	}
+
+	data, err := upgradeConfig("project.yml", []byte("vcsType: gh\nprojectName: test\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	var config Config
+	err = yaml.Unmarshal(data, &config)
+	if err != nil || config.ProjectName != "upgraded" {
+		t.Errorf("Expected the upgrade to be applied, found %+v (%v)", config, err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// circleCIHookHost is the host CircleCI's GitHub webhook delivers to.
+const circleCIHookHost = "circleci.com"
+
+// hookLister lists the webhooks of a repository.
+type hookLister interface {
+	RepoHooks(ctx context.Context, owner, repo string) ([]GitHubHook, error)
+}
+
+// isGitHub reports whether vcsType names GitHub.
+func isGitHub(vcsType string) bool {
+	return vcsType == "gh" || vcsType == "github"
+}
+
+// verifyWebhook checks that the repository has an active webhook delivering
+// to CircleCI. Following a project adds one, but it can fail to be created or
+// later be disabled, in which case pushes silently stop triggering builds.
+func verifyWebhook(ctx context.Context, hooks hookLister, owner, repo string) error {
+	repoHooks, err := hooks.RepoHooks(ctx, owner, repo)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, hook := range repoHooks {
+		u, err := url.Parse(hook.Config.URL)
+		if err != nil {
+			continue
+		}
+		if u.Hostname() != circleCIHookHost && !strings.HasSuffix(u.Hostname(), "."+circleCIHookHost) {
+			continue
+		}
+		if hook.Active {
+			return nil
+		}
+		found = true
+	}
+	if found {
+		return fmt.Errorf("the CircleCI webhook on %s/%s is inactive, pushes will not trigger builds", owner, repo)
+	}
+	return fmt.Errorf("%s/%s has no CircleCI webhook, pushes will not trigger builds", owner, repo)
+}
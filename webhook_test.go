@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	testCases := []struct {
+		hooks string
+		err   string
+	}{
+		{`[{"active": true, "config": {"url": "https://circleci.com/hooks/github"}}]`, ""},
+		{`[{"active": true, "config": {"url": "https://example.com/hook"}},
+			{"active": true, "config": {"url": "https://circleci.com/hooks/github"}}]`, ""},
+		{`[{"active": false, "config": {"url": "https://circleci.com/hooks/github"}}]`, "is inactive"},
+		{`[{"active": true, "config": {"url": "https://notcircleci.com/hooks/github"}}]`, "has no CircleCI webhook"},
+		{`[]`, "has no CircleCI webhook"},
+	}
+
+	for _, tc := range testCases {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/repos/owner/repo/hooks" {
+				t.Errorf("Unexpected path %s", r.URL.Path)
+			}
+			io.WriteString(w, tc.hooks)
+		})
+		svr := httptest.NewServer(handler)
+
+		github := &GitHubClient{svr.URL, "token", svr.Client()}
+		err := verifyWebhook(context.Background(), github, "owner", "repo")
+		if tc.err == "" && err != nil {
+			t.Errorf("Expected no error for hooks %s, found: %v", tc.hooks, err)
+		} else if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("Expected error containing %q for hooks %s, found: %v", tc.err, tc.hooks, err)
+		}
+		svr.Close()
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WorkspaceFile describes named groups of configs applied together by apply.
+type WorkspaceFile struct {
+	Credentials map[string]CredentialProfile `yaml:"credentials"` // Credential profiles, keyed by name
+	Defaults    map[string]string            `yaml:"defaults"`    // Flags for every workspace, keyed by flag name
+	Workspaces  map[string]Workspace         `yaml:"workspaces"`  // Workspaces, keyed by name
+}
+
+// CredentialProfile names the env vars holding the tokens a workspace uses,
+// so that tokens stay out of the workspace file.
+type CredentialProfile struct {
+	TokenEnv     string `yaml:"tokenEnv"`     // Env var holding the CircleCI token
+	TokenCommand string `yaml:"tokenCommand"` // Shell command printing the CircleCI token, instead of tokenEnv
+	OrgTokenEnv  string `yaml:"orgTokenEnv"`  // Env var holding the organisation token, if any
+}
+
+// Workspace is a group of configs applied in order.
+type Workspace struct {
+	Credentials string            `yaml:"credentials"` // Credential profile to use, the -token flag if empty
+	Defaults    map[string]string `yaml:"defaults"`    // Flags overriding the file's defaults, keyed by flag name
+	Configs     []string          `yaml:"configs"`     // Configs in the order they are applied, relative to the workspace file
+}
+
+// readWorkspaceFile reads the workspace file, resolving config paths
+// relative to it.
+func readWorkspaceFile(file string) (WorkspaceFile, error) {
+	var workspaces WorkspaceFile
+	err := readYAML(file, &workspaces)
+	if err != nil {
+		return workspaces, err
+	}
+	for name, workspace := range workspaces.Workspaces {
+		if len(workspace.Configs) == 0 {
+			return workspaces, fmt.Errorf("workspace %s has no configs", name)
+		}
+		if workspace.Credentials != "" {
+			profile, ok := workspaces.Credentials[workspace.Credentials]
+			if !ok {
+				return workspaces, fmt.Errorf("workspace %s uses unknown credentials %s", name, workspace.Credentials)
+			}
+			if profile.TokenEnv != "" && profile.TokenCommand != "" {
+				return workspaces, fmt.Errorf("credentials %s set both tokenEnv and tokenCommand", workspace.Credentials)
+			}
+		}
+		for i, config := range workspace.Configs {
+			if !filepath.IsAbs(config) {
+				workspace.Configs[i] = filepath.Join(filepath.Dir(file), config)
+			}
+		}
+	}
+	return workspaces, nil
+}
+
+// workspace returns the named workspace.
+func (w WorkspaceFile) workspace(name string) (Workspace, error) {
+	workspace, ok := w.Workspaces[name]
+	if !ok {
+		names := make([]string, 0, len(w.Workspaces))
+		for name := range w.Workspaces {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return workspace, fmt.Errorf("unknown workspace %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return workspace, nil
+}
+
+// applyWorkspaceFlags sets the flags the workspace gives defaults or
+// credentials for. Flags given on the command line are left alone.
+func applyWorkspaceFlags(fs *flag.FlagSet, file WorkspaceFile, workspace Workspace, getenv func(string) string) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	defaults := make(map[string]string)
+	for name, value := range file.Defaults {
+		defaults[name] = value
+	}
+	for name, value := range workspace.Defaults {
+		defaults[name] = value
+	}
+	if workspace.Credentials != "" {
+		profile := file.Credentials[workspace.Credentials]
+		for flagName, env := range map[string]string{"token": profile.TokenEnv, "org-token": profile.OrgTokenEnv} {
+			if env == "" {
+				continue
+			}
+			if getenv(env) == "" {
+				return fmt.Errorf("credentials %s need %s to be set", workspace.Credentials, env)
+			}
+			defaults[flagName] = getenv(env)
+		}
+		if profile.TokenCommand != "" && !given["token"] && !given["token-file"] {
+			defaults["token-command"] = profile.TokenCommand
+		}
+	}
+
+	for _, name := range sortedKeys(defaults) {
+		if given[name] {
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %s in workspace defaults", name)
+		}
+		err := fs.Set(name, defaults[name])
+		if err != nil {
+			return fmt.Errorf("invalid default for flag %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func runApply(args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	flags := addProvisionFlags(fs)
+	workspaceFile := fs.String("file", "workspace.yaml", "Workspace file")
+	name := fs.String("workspace", os.Getenv("CIRCLECI_WORKSPACE"), "Workspace to apply")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *name == "" {
+		fs.Usage()
+		return fmt.Errorf("-workspace is required")
+	}
+
+	file, err := readWorkspaceFile(*workspaceFile)
+	if err != nil {
+		return fmt.Errorf("could not read workspace file %s: %v", *workspaceFile, err)
+	}
+	workspace, err := file.workspace(*name)
+	if err != nil {
+		return err
+	}
+	err = applyWorkspaceFlags(fs, file, workspace, os.Getenv)
+	if err != nil {
+		return fmt.Errorf("invalid workspace %s: %v", *name, err)
+	}
+
+	s, err := common.session()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	opts, err := flags.options(s.events)
+	if err != nil {
+		return err
+	}
+	defer s.printReport(opts.report)
+	s.cancelOnInterrupt(opts.report)
+	// Configs are applied in order and the first failure stops the run, as
+	// later configs may rely on what earlier ones set up.
+	for i, configFile := range workspace.Configs {
+		if opts.report.Cancelled() {
+			return fmt.Errorf("workspace %s was cancelled after %d of %d configs", *name, i, len(workspace.Configs))
+		}
+		logInfof("Applying %s (%d of %d) of workspace %s", configFile, i+1, len(workspace.Configs), *name)
+		configs, err := s.readConfigs(configFile)
+		if err == nil {
+			err = flags.provisionConfigs(s, configs, configFile, opts)
+		}
+		if err != nil {
+			return fmt.Errorf("workspace %s stopped at %s: %v", *name, configFile, err)
+		}
+	}
+	logInfof("Workspace %s has been successfully applied", *name)
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadWorkspaceFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "workspace.yaml")
+	err = ioutil.WriteFile(file, []byte(`credentials:
+  ops:
+    tokenEnv: OPS_TOKEN
+defaults:
+  canonical: true
+  platform: server-3
+workspaces:
+  platform:
+    credentials: ops
+    defaults:
+      platform: cloud
+    configs: [api.yml, /abs/web.yml]
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workspaces, err := readWorkspaceFile(file)
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	workspace, err := workspaces.workspace("platform")
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	expected := []string{filepath.Join(dir, "api.yml"), "/abs/web.yml"}
+	if !reflect.DeepEqual(workspace.Configs, expected) {
+		t.Errorf("Expected configs %v, found %v", expected, workspace.Configs)
+	}
+	if workspaces.Defaults["canonical"] != "true" {
+		t.Errorf("Expected canonical to default to true, found %q", workspaces.Defaults["canonical"])
+	}
+	_, err = workspaces.workspace("unknown")
+	if err == nil {
+		t.Error("Expected an error for an unknown workspace")
+	}
+
+	err = ioutil.WriteFile(file, []byte("workspaces:\n  platform:\n    credentials: missing\n    configs: [api.yml]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = readWorkspaceFile(file)
+	if err == nil {
+		t.Error("Expected an error for unknown credentials")
+	}
+}
+
+func TestApplyWorkspaceFlags(t *testing.T) {
+	file := WorkspaceFile{
+		Credentials: map[string]CredentialProfile{"ops": {TokenEnv: "OPS_TOKEN"}},
+		Defaults:    map[string]string{"canonical": "true", "platform": "server-3", "max-retries": "2"},
+	}
+	workspace := Workspace{Credentials: "ops", Defaults: map[string]string{"platform": "cloud"}}
+	env := map[string]string{"OPS_TOKEN": "ops-token"}
+
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	flags := addProvisionFlags(fs)
+	err := fs.Parse([]string{"-max-retries", "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = applyWorkspaceFlags(fs, file, workspace, func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatalf("Expected no error, found: %v", err)
+	}
+	if !*flags.canonical || *common.platform != "cloud" || *common.token != "ops-token" || *common.maxRetries != 5 {
+		t.Errorf("Unexpected flags canonical=%v platform=%s token=%s max-retries=%d",
+			*flags.canonical, *common.platform, *common.token, *common.maxRetries)
+	}
+
+	err = applyWorkspaceFlags(fs, file, workspace, func(string) string { return "" })
+	if err == nil {
+		t.Error("Expected an error when the credentials' token is not set")
+	}
+
+	file.Credentials["op"] = CredentialProfile{TokenCommand: "op read op://ci/circleci/token"}
+	fs = flag.NewFlagSet("apply", flag.ContinueOnError)
+	common = addCommonFlags(fs)
+	addProvisionFlags(fs)
+	err = applyWorkspaceFlags(fs, file, Workspace{Credentials: "op"}, os.Getenv)
+	if err != nil || *common.tokenCommand != "op read op://ci/circleci/token" {
+		t.Errorf("Expected -token-command to be set from the credentials, found %q and %v", *common.tokenCommand, err)
+	}
+	err = applyWorkspaceFlags(fs, WorkspaceFile{Defaults: map[string]string{"colour": "true"}}, Workspace{}, os.Getenv)
+	if err == nil {
+		t.Error("Expected an error for an unknown flag")
+	}
+}